@@ -0,0 +1,80 @@
+// handlers/converter_format_handler.go
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Ndeta100/orbit2x/internal/dataconv"
+	"github.com/Ndeta100/orbit2x/views/converter"
+	"github.com/Ndeta100/orbit2x/views/formatter"
+)
+
+// HandleFormatConvert converts a document between any two supported formats
+// by way of a common tree
+func HandleFormatConvert(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return converter.Results(converter.ConversionResult{
+			Error: "Failed to parse form data",
+		}).Render(r.Context(), w)
+	}
+
+	from, ok := dataconv.Lookup(r.FormValue("from"))
+	if !ok {
+		from = dataconv.JSON
+	}
+	to, ok := dataconv.Lookup(r.FormValue("to"))
+	if !ok {
+		to = dataconv.YAML
+	}
+	text := r.FormValue("text")
+	if text == "" {
+		return converter.Results(converter.ConversionResult{
+			Error:        from + " text is required",
+			SourceFormat: from,
+			TargetFormat: to,
+		}).Render(r.Context(), w)
+	}
+
+	opts := dataconv.Options{
+		Indent:     "  ",
+		InferTypes: r.FormValue("infer_types") != "",
+		AttrPrefix: r.FormValue("attr_prefix"),
+		TextKey:    r.FormValue("text_key"),
+		Root:       r.FormValue("root"),
+		Headerless: r.FormValue("headerless") != "",
+	}
+	switch indent := r.FormValue("indent"); indent {
+	case "tab":
+		opts.Indent = "\t"
+	case "4":
+		opts.Indent = "    "
+	}
+	switch delimiter := r.FormValue("delimiter"); delimiter {
+	case ";", "|":
+		opts.Delimiter = rune(delimiter[0])
+	case "\\t", "tab":
+		opts.Delimiter = '\t'
+	}
+
+	tree, warnings, err := dataconv.Parse(from, text, opts)
+	if err != nil {
+		return formatter.Results(parseErrorResult(from, text, err)).Render(r.Context(), w)
+	}
+	out, more, err := dataconv.Write(to, tree, opts)
+	if err != nil {
+		return converter.Results(converter.ConversionResult{
+			Error:        "Cannot write " + to + ": " + err.Error(),
+			OriginalText: text,
+			SourceFormat: from,
+			TargetFormat: to,
+		}).Render(r.Context(), w)
+	}
+
+	return converter.Results(converter.ConversionResult{
+		OriginalText:  text,
+		ConvertedText: out,
+		SourceFormat:  from,
+		TargetFormat:  to,
+		Warnings:      append(warnings, more...),
+	}).Render(r.Context(), w)
+}
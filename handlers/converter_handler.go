@@ -4,13 +4,15 @@ package handlers
 import (
 	"bytes"
 	"encoding/csv"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 
+	"github.com/Ndeta100/orbit2x/internal/csvjson"
+	"github.com/Ndeta100/orbit2x/internal/jsonfmt"
 	"github.com/Ndeta100/orbit2x/views/converter" // Adjust to your actual path
+	"github.com/Ndeta100/orbit2x/views/formatter"
 )
 
 // HandleConverterIndex renders the CSV & JSON converter page
@@ -49,106 +51,109 @@ func HandleCSVToJSON(w http.ResponseWriter, r *http.Request) error {
 		}
 	}
 
-	hasHeaders := r.FormValue("_headers") != ""
+	// Anything left on auto is sniffed, around whatever was chosen
+	opts := csvjson.Options{InferTypes: r.FormValue("infer_types") != ""}
+	dialect := csvjson.Sniff(text)
+	chosen := false
+	switch delimiter := r.FormValue("delimiter"); delimiter {
+	case ",", ";", "|":
+		dialect.Delimiter, chosen = rune(delimiter[0]), true
+	case "\\t", "tab":
+		dialect.Delimiter, chosen = '\t', true
+	}
+	switch quote := r.FormValue("quote"); quote {
+	case `"`, "'":
+		dialect.Quote, chosen = rune(quote[0]), true
+	}
+	switch r.FormValue("header") {
+	case "yes":
+		dialect.Header = true
+	case "no":
+		dialect.Header = false
+	default:
+		if chosen {
+			dialect.Header = csvjson.HasHeader(text, dialect)
+		}
+	}
+	opts.Dialect = &dialect
 
-	// Parse CSV
-	reader := csv.NewReader(strings.NewReader(text))
-	records, err := reader.ReadAll()
+	overrides, err := csvjson.ParseOverrides(r.FormValue("types"))
 	if err != nil {
 		return converter.Results(converter.ConversionResult{
-			Error:        "Invalid CSV format: " + err.Error(),
+			Error:        "Invalid column types: " + err.Error(),
 			OriginalText: text,
 			SourceFormat: "CSV",
 			TargetFormat: "JSON",
 		}).Render(r.Context(), w)
 	}
+	opts.Overrides = overrides
 
-	if len(records) == 0 {
+	table, warnings, err := csvjson.NewTable(text, opts)
+	if _, ok := jsonfmt.AsParseError(err); ok {
+		return formatter.Results(parseErrorResult("CSV", text, err)).Render(r.Context(), w)
+	}
+	if err != nil {
 		return converter.Results(converter.ConversionResult{
-			Error:        "CSV has no data",
+			Error:        "Invalid CSV: " + err.Error(),
 			OriginalText: text,
 			SourceFormat: "CSV",
 			TargetFormat: "JSON",
 		}).Render(r.Context(), w)
 	}
 
-	// Convert to JSON
-	var result []interface{}
-
-	if hasHeaders && len(records) > 1 {
-		// First row contains _headers
-		headers := records[0]
-
-		// Process data rows
-		for i := 1; i < len(records); i++ {
-			row := records[i]
-			item := make(map[string]interface{})
-
-			for j := 0; j < len(headers) && j < len(row); j++ {
-				// Try to convert to number or boolean if possible
-				value := row[j]
-
-				// Check if it's a number
-				if numVal, err := strconv.ParseFloat(value, 64); err == nil {
-					item[headers[j]] = numVal
-				} else if value == "true" || value == "false" {
-					// Check if it's a boolean
-					item[headers[j]] = value == "true"
-				} else {
-					// It's a string
-					item[headers[j]] = value
-				}
-			}
-
-			result = append(result, item)
-		}
-	} else {
-		// No _headers, convert each row to an array
-		for _, row := range records {
-			rowData := make([]interface{}, len(row))
-
-			for i, value := range row {
-				// Try to convert to number or boolean if possible
-				if numVal, err := strconv.ParseFloat(value, 64); err == nil {
-					rowData[i] = numVal
-				} else if value == "true" || value == "false" {
-					rowData[i] = value == "true"
-				} else {
-					rowData[i] = value
-				}
-			}
-
-			result = append(result, rowData)
-		}
-	}
-
-	// Format JSON with proper indentation
-	var buf bytes.Buffer
-	encoder := json.NewEncoder(&buf)
-	encoder.SetIndent("", generateIndent(indent))
-	encoder.SetEscapeHTML(false)
-	if err := encoder.Encode(result); err != nil {
+	tree, err := table.JSON(r.FormValue("shape"))
+	if err != nil {
 		return converter.Results(converter.ConversionResult{
-			Error:        "Failed to format JSON: " + err.Error(),
+			Error:        "Cannot convert " + err.Error(),
 			OriginalText: text,
 			SourceFormat: "CSV",
 			TargetFormat: "JSON",
 		}).Render(r.Context(), w)
 	}
+	if notation := r.FormValue("unflatten"); notation == csvjson.Dot || notation == csvjson.Bracket {
+		if tree, err = csvjson.Unflatten(tree, notation); err != nil {
+			return converter.Results(converter.ConversionResult{
+				Error:        "Cannot unflatten columns: " + err.Error(),
+				OriginalText: text,
+				SourceFormat: "CSV",
+				TargetFormat: "JSON",
+			}).Render(r.Context(), w)
+		}
+	}
 
-	// Create result
-	convResult := converter.ConversionResult{
+	return converter.Results(converter.ConversionResult{
 		OriginalText:  text,
-		ConvertedText: buf.String(),
+		ConvertedText: jsonfmt.Format(tree, jsonfmt.Options{Indent: generateIndent(indent)}),
 		SourceFormat:  "CSV",
 		TargetFormat:  "JSON",
-	}
+		Summary:       tableSummary(table),
+		Warnings:      warnings,
+	}).Render(r.Context(), w)
+}
 
-	// Render the result
-	return converter.Results(convResult).Render(r.Context(), w)
+// tableSummary describes the dialect and column types a table was read with
+func tableSummary(t *csvjson.Table) string {
+	delimiter := strconv.QuoteRune(t.Dialect.Delimiter)
+	if t.Dialect.Delimiter == '\t' {
+		delimiter = "tab"
+	}
+	header := "no header row"
+	if t.Dialect.Header {
+		header = "header row"
+	}
+	columns := make([]string, len(t.Header))
+	for c, name := range t.Header {
+		columns[c] = name + ": " + t.Types[c].String()
+	}
+	rows := "rows"
+	if len(t.Rows) == 1 {
+		rows = "row"
+	}
+	return fmt.Sprintf("Delimiter %s, quote %s, %s, %d %s. Columns: %s", delimiter, strconv.QuoteRune(t.Dialect.Quote), header, len(t.Rows), rows, strings.Join(columns, ", "))
 }
 
-// HandleJSONToCSV converts JSON data to CSV
+// HandleJSONToCSV converts JSON data to CSV, flattening nested values into
+// columns named by their path
 func HandleJSONToCSV(w http.ResponseWriter, r *http.Request) error {
 	// Parse form data
 	if err := r.ParseForm(); err != nil {
@@ -172,35 +177,30 @@ func HandleJSONToCSV(w http.ResponseWriter, r *http.Request) error {
 	delimiter := r.FormValue("delimiter")
 	if delimiter == "" {
 		delimiter = "," // Default delimiter
-	} else if delimiter == "\\t" {
+	} else if delimiter == "\\t" || delimiter == "tab" {
 		delimiter = "\t" // Handle tab character
 	}
 
 	includeHeaders := r.FormValue("includeHeaders") != ""
-
-	// Parse JSON
-	var jsonData []interface{}
-	err := json.Unmarshal([]byte(text), &jsonData)
-
-	if err != nil {
-		// Try parsing as a single object
-		var jsonObj map[string]interface{}
-		err = json.Unmarshal([]byte(text), &jsonObj)
-
-		if err != nil {
-			return converter.Results(converter.ConversionResult{
-				Error:        "Invalid JSON format: " + err.Error(),
-				OriginalText: text,
-				SourceFormat: "JSON",
-				TargetFormat: "CSV",
-			}).Render(r.Context(), w)
+	opts := csvjson.FlattenOptions{
+		Notation: r.FormValue("notation"),
+		Arrays:   r.FormValue("arrays"),
+	}
+	var picked []string
+	for _, name := range strings.FieldsFunc(r.FormValue("columns"), func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r'
+	}) {
+		if name = strings.TrimSpace(name); name != "" {
+			picked = append(picked, name)
 		}
-
-		// Convert single object to array
-		jsonData = []interface{}{jsonObj}
 	}
 
-	if len(jsonData) == 0 {
+	// Parse JSON, keeping key order for the columns
+	tree, _, err := jsonfmt.Parse([]byte(text))
+	if err != nil {
+		return formatter.Results(parseErrorResult("JSON", text, err)).Render(r.Context(), w)
+	}
+	if tree.Kind == jsonfmt.Array && len(tree.Items) == 0 {
 		return converter.Results(converter.ConversionResult{
 			Error:        "JSON has no data",
 			OriginalText: text,
@@ -209,37 +209,34 @@ func HandleJSONToCSV(w http.ResponseWriter, r *http.Request) error {
 		}).Render(r.Context(), w)
 	}
 
-	// Convert to CSV
 	var buf bytes.Buffer
 	writer := csv.NewWriter(&buf)
 	writer.Comma = []rune(delimiter)[0]
+	var summary string
 
-	// For array of objects, extract _headers
-	//if firstItem, ok := jsonData[0].(map[string]interface{}); ok {
-	if _, ok := jsonData[0].(map[string]interface{}); ok {
-		// Array of objects
-
-		// Get all possible _headers
-		headers := make(map[string]bool)
-		for _, item := range jsonData {
-			if obj, ok := item.(map[string]interface{}); ok {
-				for key := range obj {
-					headers[key] = true
-				}
-			}
+	if table, ok := arrayRows(tree); ok {
+		// An array of arrays is already a table
+		for _, row := range table {
+			writer.Write(row)
 		}
-
-		// Convert _headers map to slice
-		headerSlice := make([]string, 0, len(headers))
-		for key := range headers {
-			headerSlice = append(headerSlice, key)
+		summary = fmt.Sprintf("%d rows written as they are", len(table))
+	} else {
+		rows, err := csvjson.Flatten(tree, opts)
+		if err != nil {
+			return converter.Results(converter.ConversionResult{
+				Error:        "Cannot flatten JSON: " + err.Error(),
+				OriginalText: text,
+				SourceFormat: "JSON",
+				TargetFormat: "CSV",
+			}).Render(r.Context(), w)
 		}
 
-		// Write _headers
-		if includeHeaders {
-			if err := writer.Write(headerSlice); err != nil {
+		header := csvjson.Header(rows, r.FormValue("order") == "sorted")
+		if len(picked) > 0 {
+			header, err = csvjson.Select(header, picked, opts.Notation)
+			if err != nil {
 				return converter.Results(converter.ConversionResult{
-					Error:        "Failed to write CSV _headers: " + err.Error(),
+					Error:        "Invalid columns: " + err.Error(),
 					OriginalText: text,
 					SourceFormat: "JSON",
 					TargetFormat: "CSV",
@@ -247,57 +244,19 @@ func HandleJSONToCSV(w http.ResponseWriter, r *http.Request) error {
 			}
 		}
 
-		// Write data rows
-		for _, item := range jsonData {
-			if obj, ok := item.(map[string]interface{}); ok {
-				row := make([]string, len(headerSlice))
-
-				for i, key := range headerSlice {
-					if val, exists := obj[key]; exists {
-						row[i] = formatJSONValue(val)
-					}
-				}
-
-				if err := writer.Write(row); err != nil {
-					return converter.Results(converter.ConversionResult{
-						Error:        "Failed to write CSV row: " + err.Error(),
-						OriginalText: text,
-						SourceFormat: "JSON",
-						TargetFormat: "CSV",
-					}).Render(r.Context(), w)
-				}
-			}
+		if includeHeaders {
+			writer.Write(header)
 		}
-	} else {
-		// Array of arrays
-		for _, item := range jsonData {
-			if arr, ok := item.([]interface{}); ok {
-				row := make([]string, len(arr))
-
-				for i, val := range arr {
-					row[i] = formatJSONValue(val)
-				}
-
-				if err := writer.Write(row); err != nil {
-					return converter.Results(converter.ConversionResult{
-						Error:        "Failed to write CSV row: " + err.Error(),
-						OriginalText: text,
-						SourceFormat: "JSON",
-						TargetFormat: "CSV",
-					}).Render(r.Context(), w)
-				}
-			} else {
-				// Handle simple values
-				if err := writer.Write([]string{formatJSONValue(item)}); err != nil {
-					return converter.Results(converter.ConversionResult{
-						Error:        "Failed to write CSV row: " + err.Error(),
-						OriginalText: text,
-						SourceFormat: "JSON",
-						TargetFormat: "CSV",
-					}).Render(r.Context(), w)
+		for _, row := range rows {
+			record := make([]string, len(header))
+			for i, v := range csvjson.Cells(row, header) {
+				if v != nil {
+					record[i] = csvjson.Cell(v)
 				}
 			}
+			writer.Write(record)
 		}
+		summary = fmt.Sprintf("%d rows, %d columns: %s", len(rows), len(header), strings.Join(header, ", "))
 	}
 
 	writer.Flush()
@@ -317,33 +276,26 @@ func HandleJSONToCSV(w http.ResponseWriter, r *http.Request) error {
 		ConvertedText: buf.String(),
 		SourceFormat:  "JSON",
 		TargetFormat:  "CSV",
+		Summary:       summary,
 	}
 
 	// Render the result
 	return converter.Results(convResult).Render(r.Context(), w)
 }
 
-// formatJSONValue converts a JSON value to a string
-func formatJSONValue(val interface{}) string {
-	switch v := val.(type) {
-	case nil:
-		return ""
-	case bool:
-		return strconv.FormatBool(v)
-	case float64:
-		return strconv.FormatFloat(v, 'f', -1, 64)
-	case string:
-		return v
-	case []interface{}:
-		// Convert array to JSON string
-		bytes, _ := json.Marshal(v)
-		return string(bytes)
-	case map[string]interface{}:
-		// Convert object to JSON string
-		bytes, _ := json.Marshal(v)
-		return string(bytes)
-	default:
-		// Convert any other type to string
-		return fmt.Sprintf("%v", v)
+// arrayRows gives the cells of an array whose items are all arrays
+func arrayRows(n *jsonfmt.Node) ([][]string, bool) {
+	if n.Kind != jsonfmt.Array {
+		return nil, false
+	}
+	rows := make([][]string, len(n.Items))
+	for i, item := range n.Items {
+		if item.Kind != jsonfmt.Array {
+			return nil, false
+		}
+		for _, v := range item.Items {
+			rows[i] = append(rows[i], csvjson.Cell(v))
+		}
 	}
+	return rows, true
 }
@@ -0,0 +1,172 @@
+// handlers/converter_stream_handler.go
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/Ndeta100/orbit2x/internal/csvjson"
+)
+
+// maxStreamUpload caps streamed uploads. They are converted as they
+// arrive, so the cap is on time spent rather than memory
+const maxStreamUpload = 1 << 30
+
+// maxStreamSpool caps the flattened rows JSON to CSV holds on disk while
+// it collects the header. Flattening arrays multiplies rows, so this is a
+// multiple of the upload cap rather than the cap itself
+const maxStreamSpool = 4 * maxStreamUpload
+
+// maxStreamField caps the option fields sent along with a streamed file
+const maxStreamField = 64 << 10
+
+// HandleStreamCSVToJSON converts an uploaded CSV file of any size to a JSON
+// or NDJSON download, one row at a time
+func HandleStreamCSVToJSON(w http.ResponseWriter, r *http.Request) error {
+	return streamUpload(w, r, func(fields url.Values, file io.Reader, name string, out io.Writer) (string, error) {
+		opts := csvjson.StreamOptions{
+			InferTypes: fields.Get("infer_types") != "",
+			Unflatten:  fields.Get("unflatten"),
+			NDJSON:     fields.Get("output") == "ndjson",
+		}
+		switch delimiter := fields.Get("delimiter"); delimiter {
+		case ",", ";", "|":
+			opts.Delimiter = rune(delimiter[0])
+		case "\\t", "tab":
+			opts.Delimiter = '\t'
+		}
+		if header := fields.Get("header"); header == "yes" || header == "no" {
+			hasHeader := header == "yes"
+			opts.Header = &hasHeader
+		}
+
+		ext, contentType := ".json", "application/json"
+		if opts.NDJSON {
+			ext, contentType = ".ndjson", "application/x-ndjson"
+		}
+		startDownload(w, contentType, name, ext)
+		_, err := csvjson.CSVToJSON(file, out, opts)
+		return "Invalid CSV: ", err
+	})
+}
+
+// HandleStreamJSONToCSV converts an uploaded JSON array or NDJSON file of any
+// size to a CSV download, flattening each record as it is read
+func HandleStreamJSONToCSV(w http.ResponseWriter, r *http.Request) error {
+	return streamUpload(w, r, func(fields url.Values, file io.Reader, name string, out io.Writer) (string, error) {
+		opts := csvjson.StreamOptions{
+			Flatten: csvjson.FlattenOptions{
+				Notation: fields.Get("notation"),
+				Arrays:   fields.Get("arrays"),
+			},
+			Sorted:   fields.Get("order") == "sorted",
+			NoHeader: fields.Get("includeHeaders") == "",
+			MaxSpool: maxStreamSpool,
+		}
+		switch delimiter := fields.Get("delimiter"); delimiter {
+		case ";", "|":
+			opts.Comma = rune(delimiter[0])
+		case "\\t", "tab":
+			opts.Comma = '\t'
+		}
+		for _, column := range strings.FieldsFunc(fields.Get("columns"), func(r rune) bool {
+			return r == ',' || r == '\n' || r == '\r'
+		}) {
+			if column = strings.TrimSpace(column); column != "" {
+				opts.Columns = append(opts.Columns, column)
+			}
+		}
+
+		startDownload(w, "text/csv; charset=utf-8", name, ".csv")
+		_, err := csvjson.JSONToCSV(file, out, opts)
+		return "Invalid JSON: ", err
+	})
+}
+
+// streamUpload reads a multipart body part by part, collecting the option
+// fields and handing the file to convert without buffering it. Fields after
+// the file are never seen, so forms put the file input last. convert
+// returns a prefix for its error message
+func streamUpload(w http.ResponseWriter, r *http.Request, convert func(fields url.Values, file io.Reader, name string, out io.Writer) (string, error)) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxStreamUpload)
+	// HTTP/1 servers otherwise stop reading the body once the download starts
+	http.NewResponseController(w).EnableFullDuplex()
+	mr, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "Expected a file upload", http.StatusBadRequest)
+		return nil
+	}
+
+	fields := url.Values{}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			http.Error(w, "Failed to read the upload: "+err.Error(), http.StatusBadRequest)
+			return nil
+		}
+		if part.FormName() != "file" || part.FileName() == "" {
+			value, err := io.ReadAll(io.LimitReader(part, maxStreamField))
+			if err != nil {
+				http.Error(w, "Failed to read the upload: "+err.Error(), http.StatusBadRequest)
+				return nil
+			}
+			fields.Add(part.FormName(), string(value))
+			continue
+		}
+
+		out := &countingWriter{w: w}
+		prefix, err := convert(fields, part, part.FileName(), out)
+		if err == nil {
+			return nil
+		}
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			prefix = "The file is larger than " + formatFileSize(maxStreamUpload) + ": "
+		}
+		if out.n > 0 {
+			// The download has begun and its status is sent, so all that is
+			// left is to cut it short, which the browser reports as failed
+			slog.Error("streamed conversion failed", "err", err, "path", r.URL.Path)
+			panic(http.ErrAbortHandler)
+		}
+		w.Header().Del("Content-Disposition")
+		http.Error(w, prefix+err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	http.Error(w, "Choose a file to convert", http.StatusBadRequest)
+	return nil
+}
+
+// startDownload sets the headers of a download named after the upload
+func startDownload(w http.ResponseWriter, contentType, upload, ext string) {
+	base := path.Base(strings.ReplaceAll(upload, `\`, "/"))
+	name := strings.TrimSuffix(base, path.Ext(base))
+	if name == "" || name == "." || name == "/" {
+		name = "converted"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name + ext}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+}
+
+// countingWriter notes whether anything has been written, after which an
+// error can no longer be sent as a response of its own
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,312 @@
+// handlers/converter_xlsx_handler.go
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Ndeta100/orbit2x/internal/csvjson"
+	"github.com/Ndeta100/orbit2x/internal/jsonfmt"
+	"github.com/Ndeta100/orbit2x/internal/xlsx"
+	"github.com/Ndeta100/orbit2x/views/converter"
+)
+
+// maxXLSXUpload caps workbooks uploaded for conversion
+const maxXLSXUpload = 20 << 20
+
+// maxListedWarnings bounds the export warnings spelled out one by one
+const maxListedWarnings = 20
+
+// HandleXLSXImport converts a sheet of an uploaded workbook, or every sheet,
+// to JSON or CSV
+func HandleXLSXImport(w http.ResponseWriter, r *http.Request) error {
+	to := "JSON"
+	fail := func(msg string) error {
+		return converter.Results(converter.ConversionResult{
+			Error:        msg,
+			SourceFormat: "XLSX",
+			TargetFormat: to,
+		}).Render(r.Context(), w)
+	}
+
+	if err := r.ParseMultipartForm(maxXLSXUpload); err != nil {
+		return fail("Expected a workbook upload: " + err.Error())
+	}
+	if strings.EqualFold(r.FormValue("to"), "csv") {
+		to = "CSV"
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		return fail("Choose an .xlsx file to convert")
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxXLSXUpload+1))
+	if err != nil {
+		return fail("Failed to read the upload: " + err.Error())
+	}
+	if len(data) > maxXLSXUpload {
+		return fail(fmt.Sprintf("The workbook is larger than %s", formatFileSize(maxXLSXUpload)))
+	}
+
+	sheets, err := xlsx.Read(data)
+	if err != nil {
+		return fail("Cannot read " + header.Filename + ": " + err.Error())
+	}
+	if len(sheets) == 0 {
+		return fail("The workbook has no sheets")
+	}
+	chosen, err := chooseSheets(sheets, strings.TrimSpace(r.FormValue("sheet")))
+	if err != nil {
+		return fail("Invalid sheet: " + err.Error())
+	}
+	hasHeader := r.FormValue("header") != ""
+
+	listed := make([]string, len(sheets))
+	for i, s := range sheets {
+		listed[i] = fmt.Sprintf("%s (%d rows)", s.Name, len(s.Rows))
+	}
+	summary := "Sheets: " + strings.Join(listed, ", ")
+
+	var out string
+	var warnings []string
+	if to == "CSV" {
+		if len(chosen) > 1 {
+			return fail("CSV holds one sheet; choose a sheet, or convert the whole workbook to JSON")
+		}
+		var buf bytes.Buffer
+		writer := csv.NewWriter(&buf)
+		for _, row := range padRows(chosen[0].Rows) {
+			record := make([]string, len(row))
+			for i, v := range row {
+				if v != nil {
+					record[i] = csvjson.Cell(v)
+				}
+			}
+			writer.Write(record)
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return fail("Failed to write CSV: " + err.Error())
+		}
+		out = buf.String()
+		summary += ". Converted " + chosen[0].Name
+	} else {
+		tree := sheetJSON(chosen[0], hasHeader, &warnings)
+		if len(chosen) > 1 {
+			tree = &jsonfmt.Node{Kind: jsonfmt.Object}
+			for _, s := range chosen {
+				tree.Members = append(tree.Members, jsonfmt.Member{Key: s.Name, Value: sheetJSON(s, hasHeader, &warnings)})
+			}
+		} else {
+			summary += ". Converted " + chosen[0].Name
+		}
+		out = jsonfmt.Format(tree, jsonfmt.Options{Indent: "  "})
+	}
+
+	return converter.Results(converter.ConversionResult{
+		ConvertedText: out,
+		SourceFormat:  "XLSX",
+		TargetFormat:  to,
+		Summary:       summary,
+		Warnings:      warnings,
+	}).Render(r.Context(), w)
+}
+
+// chooseSheets picks sheets by name or 1-based number, the first when
+// nothing is chosen and all of them for "*"
+func chooseSheets(sheets []xlsx.Sheet, choice string) ([]xlsx.Sheet, error) {
+	switch choice {
+	case "":
+		return sheets[:1], nil
+	case "*", "all":
+		return sheets, nil
+	}
+	for _, s := range sheets {
+		if strings.EqualFold(s.Name, choice) {
+			return []xlsx.Sheet{s}, nil
+		}
+	}
+	if n, err := strconv.Atoi(choice); err == nil && n >= 1 && n <= len(sheets) {
+		return sheets[n-1 : n], nil
+	}
+	names := make([]string, len(sheets))
+	for i, s := range sheets {
+		names[i] = s.Name
+	}
+	return nil, fmt.Errorf("there is no sheet %q; the sheets are %s", choice, strings.Join(names, ", "))
+}
+
+// padRows makes every row as wide as the widest
+func padRows(rows [][]*jsonfmt.Node) [][]*jsonfmt.Node {
+	width := 0
+	for _, row := range rows {
+		width = max(width, len(row))
+	}
+	padded := make([][]*jsonfmt.Node, len(rows))
+	for i, row := range rows {
+		padded[i] = append(row[:len(row):len(row)], make([]*jsonfmt.Node, width-len(row))...)
+	}
+	return padded
+}
+
+// sheetJSON gives a sheet as an array of objects keyed by its header row,
+// or as an array of arrays. Empty cells are null
+func sheetJSON(s xlsx.Sheet, hasHeader bool, warnings *[]string) *jsonfmt.Node {
+	rows := padRows(s.Rows)
+	for _, row := range rows {
+		for i, v := range row {
+			if v == nil {
+				row[i] = &jsonfmt.Node{Kind: jsonfmt.Null}
+			}
+		}
+	}
+
+	out := &jsonfmt.Node{Kind: jsonfmt.Array, Items: []*jsonfmt.Node{}}
+	if !hasHeader || len(rows) == 0 {
+		for _, row := range rows {
+			out.Items = append(out.Items, &jsonfmt.Node{Kind: jsonfmt.Array, Items: row})
+		}
+		return out
+	}
+
+	cells := make([]string, len(rows[0]))
+	for i, v := range rows[0] {
+		cells[i] = csvjson.Cell(v)
+	}
+	names, more := csvjson.Names(cells)
+	for _, warning := range more {
+		*warnings = append(*warnings, s.Name+": "+warning)
+	}
+	for _, row := range rows[1:] {
+		obj := &jsonfmt.Node{Kind: jsonfmt.Object}
+		for i, name := range names {
+			obj.Members = append(obj.Members, jsonfmt.Member{Key: name, Value: row[i]})
+		}
+		out.Items = append(out.Items, obj)
+	}
+	return out
+}
+
+// HandleXLSXExport writes posted JSON or CSV as a workbook download, with
+// typed cells and a bold header row
+func HandleXLSXExport(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Failed to parse form data", http.StatusBadRequest)
+		return nil
+	}
+	text := r.FormValue("text")
+	if strings.TrimSpace(text) == "" {
+		http.Error(w, "Text to convert is required", http.StatusBadRequest)
+		return nil
+	}
+	name := strings.TrimSpace(r.FormValue("sheet"))
+	if name == "" {
+		name = "Sheet1"
+	}
+
+	var sheets []xlsx.Sheet
+	if strings.EqualFold(r.FormValue("from"), "csv") {
+		table, _, err := csvjson.NewTable(text, csvjson.Options{InferTypes: true})
+		if err != nil {
+			http.Error(w, "Invalid CSV: "+err.Error(), http.StatusBadRequest)
+			return nil
+		}
+		sheet := xlsx.Sheet{Name: name, Header: table.Dialect.Header}
+		if table.Dialect.Header {
+			sheet.Rows = append(sheet.Rows, stringCells(table.Header))
+		}
+		for _, rec := range table.Rows {
+			row := make([]*jsonfmt.Node, len(rec.Fields))
+			for c, field := range rec.Fields {
+				row[c], _ = table.Types[c].Value(field)
+			}
+			sheet.Rows = append(sheet.Rows, row)
+		}
+		sheets = append(sheets, sheet)
+	} else {
+		tree, _, err := jsonfmt.Parse([]byte(text))
+		if err != nil {
+			http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return nil
+		}
+		if r.FormValue("sheets") != "" && tree.Kind == jsonfmt.Object {
+			for _, m := range tree.Members {
+				sheet, err := jsonSheet(m.Key, m.Value)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return nil
+				}
+				sheets = append(sheets, sheet)
+			}
+		} else {
+			sheet, err := jsonSheet(name, tree)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return nil
+			}
+			sheets = append(sheets, sheet)
+		}
+	}
+
+	data, warnings, err := xlsx.Write(sheets)
+	if err != nil {
+		http.Error(w, "Cannot write the workbook: "+err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	// The warnings are about data the workbook does not hold as given, so
+	// nothing is downloaded until the user has seen and accepted them
+	if len(warnings) > 0 {
+		listed := warnings[:min(len(warnings), maxListedWarnings)]
+		if len(warnings) > len(listed) {
+			listed = append(listed, fmt.Sprintf("and %d more", len(warnings)-len(listed)))
+		}
+		if r.FormValue("accept_changes") == "" {
+			http.Error(w, "The workbook would change some of the data:\n- "+strings.Join(listed, "\n- ")+
+				"\n\nGo back and tick \"Download anyway\" to accept these changes.", http.StatusBadRequest)
+			return nil
+		}
+		w.Header().Set("X-Conversion-Warnings", strings.Join(listed, "; "))
+	}
+	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	w.Header().Set("Content-Disposition", `attachment; filename="converted.xlsx"`)
+	_, err = w.Write(data)
+	return err
+}
+
+// jsonSheet lays JSON out as a sheet: an array of arrays row by row, and
+// anything else flattened into columns under a header row
+func jsonSheet(name string, n *jsonfmt.Node) (xlsx.Sheet, error) {
+	if n.Kind == jsonfmt.Array && len(n.Items) > 0 {
+		if _, ok := arrayRows(n); ok {
+			sheet := xlsx.Sheet{Name: name}
+			for _, item := range n.Items {
+				sheet.Rows = append(sheet.Rows, item.Items)
+			}
+			return sheet, nil
+		}
+	}
+
+	rows, err := csvjson.Flatten(n, csvjson.FlattenOptions{})
+	if err != nil {
+		return xlsx.Sheet{}, fmt.Errorf("sheet %s: %v", name, err)
+	}
+	header := csvjson.Header(rows, false)
+	sheet := xlsx.Sheet{Name: name, Header: true, Rows: [][]*jsonfmt.Node{stringCells(header)}}
+	for _, row := range rows {
+		sheet.Rows = append(sheet.Rows, csvjson.Cells(row, header))
+	}
+	return sheet, nil
+}
+
+func stringCells(values []string) []*jsonfmt.Node {
+	cells := make([]*jsonfmt.Node, len(values))
+	for i, v := range values {
+		cells[i] = jsonfmt.NewString(v)
+	}
+	return cells
+}
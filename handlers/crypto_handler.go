@@ -0,0 +1,223 @@
+// handlers/crypto_handler.go
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Ndeta100/orbit2x/internal/codec"
+	"github.com/Ndeta100/orbit2x/internal/passhash"
+	"github.com/Ndeta100/orbit2x/internal/symcrypt"
+	"github.com/Ndeta100/orbit2x/views/cryptotool"
+)
+
+// HandleCryptoIndex renders the encryption tool page
+func HandleCryptoIndex(w http.ResponseWriter, r *http.Request) error {
+	var options []cryptotool.CipherOption
+	for _, c := range symcrypt.Ciphers() {
+		options = append(options, cryptotool.CipherOption{ID: c.ID, Name: c.Name})
+	}
+	return cryptotool.Index(options).Render(r.Context(), w)
+}
+
+// HandleCryptoEncrypt encrypts text or an uploaded file into a Base64
+// envelope
+func HandleCryptoEncrypt(w http.ResponseWriter, r *http.Request) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxEncodeUpload+1<<20)
+	if err := parseEncoderForm(r); err != nil {
+		return cryptotool.Results(cryptotool.CryptoResult{
+			Error: uploadErrorMessage(err),
+			Mode:  "encrypt",
+		}).Render(r.Context(), w)
+	}
+
+	c, ok := symcrypt.Lookup(r.FormValue("cipher"))
+	if !ok {
+		return cryptotool.Results(cryptotool.CryptoResult{
+			Error: "Unsupported cipher",
+			Mode:  "encrypt",
+		}).Render(r.Context(), w)
+	}
+
+	plaintext, fileName, err := encoderUpload(r)
+	if err != nil {
+		return cryptotool.Results(cryptotool.CryptoResult{
+			Error: uploadErrorMessage(err),
+			Mode:  "encrypt",
+		}).Render(r.Context(), w)
+	}
+	if fileName == "" {
+		plaintext = []byte(r.FormValue("text"))
+	}
+	if len(plaintext) == 0 {
+		return cryptotool.Results(cryptotool.CryptoResult{
+			Error: "Text or a file is required",
+			Mode:  "encrypt",
+		}).Render(r.Context(), w)
+	}
+
+	key, err := cryptoKey(r, true)
+	if err != nil {
+		return cryptotool.Results(cryptotool.CryptoResult{
+			Error: err.Error(),
+			Mode:  "encrypt",
+		}).Render(r.Context(), w)
+	}
+
+	envelope, header, err := symcrypt.Encrypt(c, key, plaintext)
+	if err != nil {
+		return cryptotool.Results(cryptotool.CryptoResult{
+			Error: "Encryption failed: " + err.Error(),
+			Mode:  "encrypt",
+		}).Render(r.Context(), w)
+	}
+
+	b64, _ := codec.Lookup("base64")
+	encoded, err := b64.Encode(envelope)
+	if err != nil {
+		return err
+	}
+
+	result := cryptoHeader(header)
+	result.Mode = "encrypt"
+	result.Envelope = encoded
+	result.Size = formatFileSize(int64(len(plaintext)))
+	if fileName != "" {
+		result.FileName = fileName
+		result.DownloadURL = "data:application/octet-stream;base64," + encoded
+		result.DownloadName = fileName + ".enc"
+	}
+	return cryptotool.Results(result).Render(r.Context(), w)
+}
+
+// HandleCryptoDecrypt opens a Base64 envelope pasted as text or uploaded as
+// a file, either raw or Base64 encoded
+func HandleCryptoDecrypt(w http.ResponseWriter, r *http.Request) error {
+	r.Body = http.MaxBytesReader(w, r.Body, 2*maxEncodeUpload)
+	if err := parseEncoderForm(r); err != nil {
+		return cryptotool.Results(cryptotool.CryptoResult{
+			Error: uploadErrorMessage(err),
+			Mode:  "decrypt",
+		}).Render(r.Context(), w)
+	}
+
+	envelope, fileName, err := encoderUpload(r)
+	if err != nil {
+		return cryptotool.Results(cryptotool.CryptoResult{
+			Error: uploadErrorMessage(err),
+			Mode:  "decrypt",
+		}).Render(r.Context(), w)
+	}
+	if fileName == "" || !strings.HasPrefix(string(envelope), "O2XE") {
+		text := r.FormValue("text")
+		if fileName != "" {
+			text = string(envelope)
+		}
+		b64, _ := codec.Lookup("base64")
+		envelope, err = b64.Decode(strings.Join(strings.Fields(text), ""))
+		if err != nil {
+			return cryptotool.Results(cryptotool.CryptoResult{
+				Error: "Invalid Base64 envelope: " + err.Error(),
+				Mode:  "decrypt",
+			}).Render(r.Context(), w)
+		}
+	}
+	if len(envelope) == 0 {
+		return cryptotool.Results(cryptotool.CryptoResult{
+			Error: "An encrypted envelope is required",
+			Mode:  "decrypt",
+		}).Render(r.Context(), w)
+	}
+
+	key, err := cryptoKey(r, false)
+	if err != nil {
+		return cryptotool.Results(cryptotool.CryptoResult{
+			Error: err.Error(),
+			Mode:  "decrypt",
+		}).Render(r.Context(), w)
+	}
+
+	plaintext, header, err := symcrypt.Decrypt(envelope, key)
+	if err != nil {
+		return cryptotool.Results(cryptotool.CryptoResult{
+			Error: err.Error(),
+			Mode:  "decrypt",
+		}).Render(r.Context(), w)
+	}
+
+	result := cryptoHeader(header)
+	result.Mode = "decrypt"
+	result.Size = formatFileSize(int64(len(plaintext)))
+	result.ContentType = http.DetectContentType(plaintext)
+	if utf8.Valid(plaintext) {
+		result.Plaintext = string(plaintext)
+	} else {
+		result.IsBinary = true
+		result.HexDump, result.Truncated = hexDump(plaintext, maxHexDump)
+	}
+	// The download is built in the page so the key never has to be sent again
+	result.DownloadURL = "data:application/octet-stream;base64," + base64.StdEncoding.EncodeToString(plaintext)
+	result.DownloadName = strings.TrimSuffix(fileName, ".enc")
+	if result.DownloadName == "" {
+		result.DownloadName = "decrypted" + fileExtension(result.ContentType)
+	}
+	return cryptotool.Results(result).Render(r.Context(), w)
+}
+
+// cryptoKey reads a passphrase or a raw hex key. Argon2id parameters only
+// matter when encrypting; decryption takes them from the envelope
+func cryptoKey(r *http.Request, encrypt bool) (symcrypt.Key, error) {
+	secret := r.FormValue("key")
+	if secret == "" {
+		return symcrypt.Key{}, errors.New("A passphrase or key is required")
+	}
+
+	if r.FormValue("key_type") == "hex" {
+		raw, err := hex.DecodeString(strings.TrimSpace(secret))
+		if err != nil {
+			return symcrypt.Key{}, fmt.Errorf("Invalid hex key: %v", err)
+		}
+		return symcrypt.Key{Raw: raw}, nil
+	}
+
+	key := symcrypt.Key{Passphrase: []byte(secret)}
+	if encrypt {
+		var errs []error
+		field := func(name string, def int) int {
+			v, err := formInt(r, name, def)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			return v
+		}
+		key.Argon2 = passhash.Argon2Params{
+			Memory:      field("memory", passhash.DefaultArgon2.Memory),
+			Iterations:  field("iterations", passhash.DefaultArgon2.Iterations),
+			Parallelism: field("parallelism", passhash.DefaultArgon2.Parallelism),
+			SaltLength:  passhash.DefaultArgon2.SaltLength,
+		}
+		if len(errs) > 0 {
+			return key, errs[0]
+		}
+	}
+	return key, nil
+}
+
+// cryptoHeader describes the envelope parameters for display
+func cryptoHeader(h symcrypt.Header) cryptotool.CryptoResult {
+	result := cryptotool.CryptoResult{
+		Cipher: h.Cipher.Name,
+		KDF:    h.KDF,
+		Nonce:  hex.EncodeToString(h.Nonce),
+	}
+	if h.KDF == "Argon2id" {
+		result.Salt = hex.EncodeToString(h.Salt)
+		result.KDFParams = fmt.Sprintf("m=%d KiB, t=%d, p=%d", h.Argon2.Memory, h.Argon2.Iterations, h.Argon2.Parallelism)
+	}
+	return result
+}
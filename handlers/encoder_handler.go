@@ -2,51 +2,96 @@
 package handlers
 
 import (
-	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"strings"
+	"unicode/utf8"
 
+	"github.com/Ndeta100/orbit2x/internal/codec"
 	"github.com/Ndeta100/orbit2x/views/encoder" // Adjust to your actual path
 )
 
 // HandleEncoderIndex renders the text encoder/decoder page
 func HandleEncoderIndex(w http.ResponseWriter, r *http.Request) error {
-	return encoder.Index().Render(r.Context(), w)
+	return encoder.Index(codecOptions(), transformOptions()).Render(r.Context(), w)
 }
 
-// HandleEncoderEncode encodes text to Base64
+// maxEncodeUpload caps files uploaded for encoding
+const maxEncodeUpload = 10 << 20
+
+// maxHexDump caps how many bytes of binary output are shown as a hex dump
+const maxHexDump = 4 << 10
+
+// HandleEncoderEncode encodes text, or an uploaded file, with the selected
+// codec
 func HandleEncoderEncode(w http.ResponseWriter, r *http.Request) error {
-	// Parse form data
-	if err := r.ParseForm(); err != nil {
+	// Parse form data, which is multipart when a file is attached
+	r.Body = http.MaxBytesReader(w, r.Body, maxEncodeUpload+1<<20)
+	if err := parseEncoderForm(r); err != nil {
 		return encoder.Results(encoder.EncodingResult{
-			Error: "Failed to parse form data",
+			Error: uploadErrorMessage(err),
 			Mode:  "encode",
 		}).Render(r.Context(), w)
 	}
 
-	// Get text from form
+	c, ok := selectedCodec(r)
+	if !ok {
+		return encoder.Results(encoder.EncodingResult{
+			Error: "Unsupported encoding",
+			Mode:  "encode",
+		}).Render(r.Context(), w)
+	}
+
+	// An uploaded file takes precedence over the text field
+	data, fileName, err := encoderUpload(r)
+	if err != nil {
+		return encoder.Results(encoder.EncodingResult{
+			Error: uploadErrorMessage(err),
+			Mode:  "encode",
+		}).Render(r.Context(), w)
+	}
 	text := r.FormValue("text")
-	if text == "" {
+	if fileName == "" {
+		data = []byte(text)
+	} else {
+		text = fmt.Sprintf("%s (%s)", fileName, formatFileSize(int64(len(data))))
+	}
+	if len(data) == 0 {
 		return encoder.Results(encoder.EncodingResult{
-			Error: "Text is required",
+			Error: "Text or a file is required",
 			Mode:  "encode",
 		}).Render(r.Context(), w)
 	}
 
-	// Encode to Base64
-	encoded := base64.StdEncoding.EncodeToString([]byte(text))
+	encoded, err := c.Encode(data)
+	if err != nil {
+		return encoder.Results(encoder.EncodingResult{
+			Error:        "Cannot encode as " + c.Name + ": " + err.Error(),
+			OriginalText: text,
+			Mode:         "encode",
+			Codec:        c.Name,
+		}).Render(r.Context(), w)
+	}
 
 	// Create result
 	result := encoder.EncodingResult{
 		OriginalText: text,
 		EncodedText:  encoded,
 		Mode:         "encode",
+		Codec:        c.Name,
+		FileName:     fileName,
 	}
 
 	// Render the result
 	return encoder.Results(result).Render(r.Context(), w)
 }
 
-// HandleEncoderDecode decodes Base64 to text
+// HandleEncoderDecode decodes text with the selected codec. Output that is
+// not valid UTF-8 is shown as a hex dump with its sniffed content type
 func HandleEncoderDecode(w http.ResponseWriter, r *http.Request) error {
 	// Parse form data
 	if err := r.ParseForm(); err != nil {
@@ -56,32 +101,167 @@ func HandleEncoderDecode(w http.ResponseWriter, r *http.Request) error {
 		}).Render(r.Context(), w)
 	}
 
+	c, ok := selectedCodec(r)
+	if !ok {
+		return encoder.Results(encoder.EncodingResult{
+			Error: "Unsupported encoding",
+			Mode:  "decode",
+		}).Render(r.Context(), w)
+	}
+
 	// Get text from form
 	text := r.FormValue("text")
 	if text == "" {
 		return encoder.Results(encoder.EncodingResult{
-			Error: "Base64 text is required",
+			Error: c.Name + " text is required",
 			Mode:  "decode",
 		}).Render(r.Context(), w)
 	}
 
-	// Decode from Base64
-	decoded, err := base64.StdEncoding.DecodeString(text)
+	decoded, err := c.Decode(text)
 	if err != nil {
 		return encoder.Results(encoder.EncodingResult{
-			Error:        "Invalid Base64 format: " + err.Error(),
+			Error:        "Invalid " + c.Name + " input: " + err.Error(),
 			OriginalText: text,
 			Mode:         "decode",
+			Codec:        c.Name,
 		}).Render(r.Context(), w)
 	}
 
 	// Create result
 	result := encoder.EncodingResult{
 		OriginalText: text,
-		DecodedText:  string(decoded),
 		Mode:         "decode",
+		Codec:        c.Name,
+		CodecID:      c.ID,
+		ByteCount:    formatFileSize(int64(len(decoded))),
+		ContentType:  http.DetectContentType(decoded),
+	}
+	if utf8.Valid(decoded) {
+		result.DecodedText = string(decoded)
+	} else {
+		result.IsBinary = true
+		result.HexDump, result.Truncated = hexDump(decoded, maxHexDump)
 	}
 
 	// Render the result
 	return encoder.Results(result).Render(r.Context(), w)
 }
+
+// HandleEncoderDownload decodes the posted text again and sends the raw
+// bytes as a file, so binary output survives intact
+func HandleEncoderDownload(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Failed to parse form data", http.StatusBadRequest)
+		return nil
+	}
+
+	c, ok := selectedCodec(r)
+	if !ok {
+		http.Error(w, "Unsupported encoding", http.StatusBadRequest)
+		return nil
+	}
+	decoded, err := c.Decode(r.FormValue("text"))
+	if err != nil {
+		http.Error(w, "Invalid "+c.Name+" input: "+err.Error(), http.StatusBadRequest)
+		return nil
+	}
+
+	contentType := http.DetectContentType(decoded)
+	name := "decoded" + fileExtension(contentType)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	_, err = w.Write(decoded)
+	return err
+}
+
+// parseEncoderForm parses urlencoded and multipart bodies alike
+func parseEncoderForm(r *http.Request) error {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		return r.ParseMultipartForm(maxEncodeUpload)
+	}
+	return r.ParseForm()
+}
+
+// encoderUpload reads the optional "file" field. A missing or unnamed file
+// yields an empty name
+func encoderUpload(r *http.Request) ([]byte, string, error) {
+	file, header, err := r.FormFile("file")
+	if errors.Is(err, http.ErrMissingFile) || errors.Is(err, http.ErrNotMultipart) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxEncodeUpload+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > maxEncodeUpload {
+		return nil, "", &http.MaxBytesError{Limit: maxEncodeUpload}
+	}
+	return data, header.Filename, nil
+}
+
+// hexDump formats up to limit bytes in the offset / hex / ASCII layout of
+// hexdump -C, reporting whether the data was cut short
+func hexDump(data []byte, limit int) (string, bool) {
+	if len(data) > limit {
+		return hex.Dump(data[:limit]), true
+	}
+	return hex.Dump(data), false
+}
+
+// sniffedExtensions gives the usual extension for the types that
+// http.DetectContentType reports, where mime has several to choose from
+var sniffedExtensions = map[string]string{
+	"text/plain":               ".txt",
+	"text/html":                ".html",
+	"text/xml":                 ".xml",
+	"image/jpeg":               ".jpg",
+	"image/png":                ".png",
+	"image/gif":                ".gif",
+	"image/webp":               ".webp",
+	"application/pdf":          ".pdf",
+	"application/zip":          ".zip",
+	"application/x-gzip":       ".gz",
+	"application/wasm":         ".wasm",
+	"application/octet-stream": ".bin",
+}
+
+// fileExtension picks a file extension for a sniffed content type
+func fileExtension(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if ext, ok := sniffedExtensions[mediaType]; ok {
+		return ext
+	}
+	if exts, err := mime.ExtensionsByType(mediaType); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ".bin"
+}
+
+// selectedCodec looks up the codec chosen in the form, defaulting to Base64
+func selectedCodec(r *http.Request) (codec.Codec, bool) {
+	id := r.FormValue("codec")
+	if id == "" {
+		id = "base64"
+	}
+	return codec.Lookup(id)
+}
+
+// codecOptions lists the codecs for the scheme pickers
+func codecOptions() []encoder.CodecOption {
+	var options []encoder.CodecOption
+	for _, c := range codec.All() {
+		options = append(options, encoder.CodecOption{
+			ID:          c.ID,
+			Name:        c.Name,
+			Description: c.Description,
+		})
+	}
+	return options
+}
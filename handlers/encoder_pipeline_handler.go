@@ -0,0 +1,139 @@
+// handlers/encoder_pipeline_handler.go
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/Ndeta100/orbit2x/internal/codec"
+	"github.com/Ndeta100/orbit2x/views/encoder"
+)
+
+// maxPipelineSteps bounds the length of an explicit pipeline
+const maxPipelineSteps = 10
+
+// maxStepPreview caps how many bytes of a binary step are shown as hex
+const maxStepPreview = 512
+
+// HandleEncoderSmartDecode guesses the encoding of the input and peels off
+// one layer at a time, showing every intermediate result
+func HandleEncoderSmartDecode(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return encoder.PipelineResults(encoder.PipelineResult{
+			Error: "Failed to parse form data",
+			Mode:  "smart",
+		}).Render(r.Context(), w)
+	}
+
+	text := r.FormValue("text")
+	if text == "" {
+		return encoder.PipelineResults(encoder.PipelineResult{
+			Error: "Text is required",
+			Mode:  "smart",
+		}).Render(r.Context(), w)
+	}
+
+	steps := codec.SmartDecode([]byte(text))
+	result := encoder.PipelineResult{
+		Input: text,
+		Steps: pipelineSteps(steps),
+		Mode:  "smart",
+	}
+	if len(steps) == 0 {
+		result.Error = "No encoding was recognised; the input already looks like plain text"
+	}
+	return encoder.PipelineResults(result).Render(r.Context(), w)
+}
+
+// HandleEncoderPipeline runs the input through an explicit chain of
+// transforms, or undoes the chain when direction is "decode"
+func HandleEncoderPipeline(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return encoder.PipelineResults(encoder.PipelineResult{
+			Error: "Failed to parse form data",
+			Mode:  "pipeline",
+		}).Render(r.Context(), w)
+	}
+
+	var transforms []codec.Transform
+	for _, id := range r.Form["steps"] {
+		if id == "" {
+			continue
+		}
+		t, ok := codec.LookupTransform(id)
+		if !ok {
+			return encoder.PipelineResults(encoder.PipelineResult{
+				Error: fmt.Sprintf("Unsupported pipeline step %q", id),
+				Mode:  "pipeline",
+			}).Render(r.Context(), w)
+		}
+		transforms = append(transforms, t)
+	}
+	if len(transforms) > maxPipelineSteps {
+		return encoder.PipelineResults(encoder.PipelineResult{
+			Error: fmt.Sprintf("A pipeline can have at most %d steps", maxPipelineSteps),
+			Mode:  "pipeline",
+		}).Render(r.Context(), w)
+	}
+	if len(transforms) == 0 {
+		return encoder.PipelineResults(encoder.PipelineResult{
+			Error: "Choose at least one pipeline step",
+			Mode:  "pipeline",
+		}).Render(r.Context(), w)
+	}
+
+	text := r.FormValue("text")
+	if text == "" {
+		return encoder.PipelineResults(encoder.PipelineResult{
+			Error: "Text is required",
+			Mode:  "pipeline",
+		}).Render(r.Context(), w)
+	}
+
+	reverse := r.FormValue("direction") == "decode"
+	steps, err := codec.RunPipeline([]byte(text), transforms, reverse)
+	result := encoder.PipelineResult{
+		Input: text,
+		Steps: pipelineSteps(steps),
+		Mode:  "pipeline",
+	}
+	if err != nil {
+		result.Error = "Pipeline failed at " + err.Error()
+	}
+	return encoder.PipelineResults(result).Render(r.Context(), w)
+}
+
+// pipelineSteps prepares steps for display. Output that is not text is
+// shown as a hex dump of at most maxStepPreview bytes
+func pipelineSteps(steps []codec.Step) []encoder.PipelineStep {
+	var out []encoder.PipelineStep
+	for _, s := range steps {
+		step := encoder.PipelineStep{
+			Name: s.Name,
+			Size: formatFileSize(int64(len(s.Output))),
+		}
+		if utf8.Valid(s.Output) {
+			step.Output = string(s.Output)
+		} else {
+			step.Binary = true
+			step.ContentType = http.DetectContentType(s.Output)
+			step.Output, step.Truncated = hexDump(s.Output, maxStepPreview)
+		}
+		out = append(out, step)
+	}
+	return out
+}
+
+// transformOptions lists the pipeline steps for the step pickers
+func transformOptions() []encoder.CodecOption {
+	var options []encoder.CodecOption
+	for _, t := range codec.Transforms() {
+		options = append(options, encoder.CodecOption{
+			ID:          t.ID,
+			Name:        t.Name,
+			Description: t.Description,
+		})
+	}
+	return options
+}
@@ -0,0 +1,141 @@
+// handlers/formatter_diff_handler.go
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/Ndeta100/orbit2x/internal/jsondiff"
+	"github.com/Ndeta100/orbit2x/internal/jsonfmt"
+	"github.com/Ndeta100/orbit2x/views/formatter"
+)
+
+// maxDiffValue caps how much of an old or new value the change list shows
+const maxDiffValue = 300
+
+// HandleJSONDiff compares two JSON or YAML documents structurally and
+// returns the changed paths as a list, a JSON Patch and a merge patch
+func HandleJSONDiff(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return formatter.DiffResults(formatter.DiffResult{
+			Error: "Failed to parse form data",
+		}).Render(r.Context(), w)
+	}
+
+	left, right := r.FormValue("left"), r.FormValue("right")
+	if left == "" || right == "" {
+		return formatter.DiffResults(formatter.DiffResult{
+			Error: "Both documents are required",
+		}).Render(r.Context(), w)
+	}
+	a, leftFormat, _, err := jsonfmt.ParseDocument(left)
+	if err != nil {
+		return formatter.Results(parseErrorResult(leftFormat+" (left)", left, err)).Render(r.Context(), w)
+	}
+	b, rightFormat, _, err := jsonfmt.ParseDocument(right)
+	if err != nil {
+		return formatter.Results(parseErrorResult(rightFormat+" (right)", right, err)).Render(r.Context(), w)
+	}
+
+	opts := jsondiff.Options{
+		IgnoreOrder: r.FormValue("ignore_order") != "",
+		IgnoreKeys: strings.FieldsFunc(r.FormValue("ignore_keys"), func(r rune) bool {
+			return r == ',' || r == '\n' || r == '\r'
+		}),
+	}
+	for i, key := range opts.IgnoreKeys {
+		opts.IgnoreKeys[i] = strings.TrimSpace(key)
+	}
+	changes := jsondiff.Diff(a, b, opts)
+	merge, warnings := jsondiff.MergePatch(a, b, opts)
+
+	result := formatter.DiffResult{
+		LeftFormat:  leftFormat,
+		RightFormat: rightFormat,
+		Identical:   len(changes) == 0,
+		Patch:       jsonfmt.Format(jsondiff.JSONPatch(changes), jsonfmt.Options{Indent: "  "}),
+		MergePatch:  jsonfmt.Format(merge, jsonfmt.Options{Indent: "  "}),
+		Warnings:    warnings,
+	}
+	if opts.IgnoreOrder {
+		result.Warnings = append(result.Warnings, "Array order was ignored, so applying the JSON Patch yields the right-hand elements but not necessarily in their order")
+	}
+	for _, c := range changes {
+		change := formatter.DiffChange{Path: pointerOrRoot(c.Path)}
+		switch c.Op {
+		case jsondiff.Add:
+			change.Kind = "added"
+			result.Added++
+		case jsondiff.Remove:
+			change.Kind = "removed"
+			result.Removed++
+		default:
+			change.Kind = "changed"
+			result.Changed++
+		}
+		if c.Old != nil {
+			change.Old = diffValue(c.Old)
+		}
+		if c.New != nil {
+			change.New = diffValue(c.New)
+		}
+		result.Changes = append(result.Changes, change)
+	}
+	return formatter.DiffResults(result).Render(r.Context(), w)
+}
+
+// HandleJSONPatch applies a JSON Patch (an array of operations) or a merge
+// patch (an object) to a JSON or YAML document
+func HandleJSONPatch(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return formatter.PatchResults(formatter.PatchResult{
+			Error: "Failed to parse form data",
+		}).Render(r.Context(), w)
+	}
+
+	text, patchText := r.FormValue("document"), r.FormValue("patch")
+	if text == "" || patchText == "" {
+		return formatter.PatchResults(formatter.PatchResult{
+			Error: "Both a document and a patch are required",
+		}).Render(r.Context(), w)
+	}
+	doc, format, _, err := jsonfmt.ParseDocument(text)
+	if err != nil {
+		return formatter.Results(parseErrorResult(format+" document", text, err)).Render(r.Context(), w)
+	}
+	patch, patchFormat, _, err := jsonfmt.ParseDocument(patchText)
+	if err != nil {
+		return formatter.Results(parseErrorResult(patchFormat+" patch", patchText, err)).Render(r.Context(), w)
+	}
+
+	kind := r.FormValue("patch_type")
+	if kind != "json-patch" && kind != "merge-patch" {
+		kind = "merge-patch"
+		if patch.Kind == jsonfmt.Array {
+			kind = "json-patch"
+		}
+	}
+	result := formatter.PatchResult{Format: format}
+	if kind == "json-patch" {
+		result.Kind = "JSON Patch"
+		result.Operations = len(patch.Items)
+		if doc, err = jsondiff.ApplyPatch(doc, patch); err != nil {
+			result.Error = err.Error()
+			return formatter.PatchResults(result).Render(r.Context(), w)
+		}
+	} else {
+		result.Kind = "merge patch"
+		result.Operations = len(patch.Members)
+		doc = jsondiff.ApplyMergePatch(doc, patch)
+	}
+	result.Output = jsonfmt.Format(doc, jsonfmt.Options{Indent: "  "})
+	return formatter.PatchResults(result).Render(r.Context(), w)
+}
+
+func diffValue(n *jsonfmt.Node) string {
+	s := strings.TrimSuffix(jsonfmt.Format(n, jsonfmt.Options{Minify: true}), "\n")
+	if runes := []rune(s); len(runes) > maxDiffValue {
+		s = string(runes[:maxDiffValue]) + "…"
+	}
+	return s
+}
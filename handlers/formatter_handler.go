@@ -3,10 +3,14 @@ package handlers
 
 import (
 	"bytes"
-	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"github.com/Ndeta100/orbit2x/internal/jsonfmt"
+	"github.com/Ndeta100/orbit2x/internal/jsonquery"
+	"github.com/Ndeta100/orbit2x/internal/jsonschema"
 	"github.com/Ndeta100/orbit2x/views/formatter" // Adjust to your actual path
 	"gopkg.in/yaml.v3"                            // You'll need to add this to your dependencies
 )
@@ -35,51 +39,45 @@ func HandleJSONFormat(w http.ResponseWriter, r *http.Request) error {
 		}).Render(r.Context(), w)
 	}
 
-	indentStr := r.FormValue("indent")
-	indent := 4 // Default indentation
-	if indentStr != "" {
-		var err error
-		indent, err = strconv.Atoi(indentStr)
-		if err != nil {
-			indent = 4 // Default to 4 if there's an error
-		}
+	opts := jsonfmt.Options{
+		Indent:   jsonIndent(r.FormValue("indent")),
+		Minify:   r.FormValue("minify") != "",
+		SortKeys: r.FormValue("sort_keys") != "",
 	}
 
-	// Parse JSON to validate it
-	var data interface{}
-	err := json.Unmarshal([]byte(text), &data)
+	// Walk the token stream so key order, number literals and duplicate
+	// keys survive
+	tree, warnings, err := jsonfmt.Parse([]byte(text))
 	if err != nil {
-		return formatter.Results(formatter.FormatterResult{
-			Error:        "Invalid JSON: " + err.Error(),
-			OriginalText: text,
-			Format:       "JSON",
-		}).Render(r.Context(), w)
-	}
-
-	// Format JSON with proper indentation
-	var buf bytes.Buffer
-	encoder := json.NewEncoder(&buf)
-	encoder.SetIndent("", generateIndent(indent))
-	encoder.SetEscapeHTML(false) // Avoid escaping HTML characters
-	if err := encoder.Encode(data); err != nil {
-		return formatter.Results(formatter.FormatterResult{
-			Error:        "Failed to format JSON: " + err.Error(),
-			OriginalText: text,
-			Format:       "JSON",
-		}).Render(r.Context(), w)
+		return formatter.Results(parseErrorResult("JSON", text, err)).Render(r.Context(), w)
 	}
 
 	// Create result
 	result := formatter.FormatterResult{
 		OriginalText:  text,
-		FormattedText: buf.String(),
+		FormattedText: jsonfmt.Format(tree, opts),
 		Format:        "JSON",
 	}
+	for _, warning := range warnings {
+		result.Warnings = append(result.Warnings, warning.String())
+	}
 
 	// Render the result
 	return formatter.Results(result).Render(r.Context(), w)
 }
 
+// jsonIndent maps the indent option to the string repeated per level
+func jsonIndent(value string) string {
+	if value == "tab" {
+		return "\t"
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 || n > 8 {
+		n = 4
+	}
+	return generateIndent(n)
+}
+
 // HandleYAMLFormat formats and validates YAML
 func HandleYAMLFormat(w http.ResponseWriter, r *http.Request) error {
 	// Parse form data
@@ -113,11 +111,7 @@ func HandleYAMLFormat(w http.ResponseWriter, r *http.Request) error {
 	var data interface{}
 	err := yaml.Unmarshal([]byte(text), &data)
 	if err != nil {
-		return formatter.Results(formatter.FormatterResult{
-			Error:        "Invalid YAML: " + err.Error(),
-			OriginalText: text,
-			Format:       "YAML",
-		}).Render(r.Context(), w)
+		return formatter.Results(parseErrorResult("YAML", text, jsonfmt.YAMLError(text, err))).Render(r.Context(), w)
 	}
 
 	// Format YAML with proper indentation
@@ -145,6 +139,232 @@ func HandleYAMLFormat(w http.ResponseWriter, r *http.Request) error {
 	return formatter.Results(result).Render(r.Context(), w)
 }
 
+// HandleSchemaValidate validates a JSON or YAML document against a JSON
+// Schema and lists every violation
+func HandleSchemaValidate(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return formatter.ValidationResults(formatter.ValidationResult{
+			Error: "Failed to parse form data",
+		}).Render(r.Context(), w)
+	}
+
+	text, schemaText := r.FormValue("document"), r.FormValue("schema")
+	if text == "" || schemaText == "" {
+		return formatter.ValidationResults(formatter.ValidationResult{
+			Error: "Both a document and a schema are required",
+		}).Render(r.Context(), w)
+	}
+
+	doc, format, warnings, err := jsonfmt.ParseDocument(text)
+	if err != nil {
+		return formatter.Results(parseErrorResult(format+" document", text, err)).Render(r.Context(), w)
+	}
+	schemaTree, schemaFormat, _, err := jsonfmt.ParseDocument(schemaText)
+	if err != nil {
+		return formatter.Results(parseErrorResult(schemaFormat+" schema", schemaText, err)).Render(r.Context(), w)
+	}
+	schema, err := jsonschema.Compile(schemaTree)
+	if err != nil {
+		return formatter.ValidationResults(formatter.ValidationResult{
+			Error: "Invalid schema: " + err.Error(),
+		}).Render(r.Context(), w)
+	}
+
+	result := formatter.ValidationResult{
+		Mode:    "validate",
+		Format:  format,
+		Draft:   schema.Draft.String(),
+		Ignored: schema.Ignored,
+	}
+	// A YAML stream, such as a set of Kubernetes manifests, is checked
+	// document by document
+	docs := []*jsonfmt.Node{doc}
+	if format == "YAML" {
+		if docs, warnings, err = jsonfmt.ParseYAMLDocuments(text); err != nil {
+			return formatter.Results(parseErrorResult("YAML document", text, err)).Render(r.Context(), w)
+		}
+		switch {
+		case len(docs) == 0:
+			docs = []*jsonfmt.Node{doc} // nothing but comments, which is null
+		case len(docs) > 1:
+			result.Documents = len(docs)
+		}
+	}
+	for _, warning := range warnings {
+		result.Warnings = append(result.Warnings, warning.String())
+	}
+	for i, d := range docs {
+		for _, v := range schema.Validate(d) {
+			path := pointerOrRoot(v.InstancePath)
+			if len(docs) > 1 {
+				path = "document " + strconv.Itoa(i+1) + ": " + path
+			}
+			result.Violations = append(result.Violations, formatter.Violation{
+				Path:       path,
+				SchemaPath: "#" + v.SchemaPath,
+				Message:    v.Message,
+			})
+		}
+	}
+	result.Valid = len(result.Violations) == 0
+	return formatter.ValidationResults(result).Render(r.Context(), w)
+}
+
+// HandleSchemaInfer infers a JSON Schema from a sample document
+func HandleSchemaInfer(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return formatter.ValidationResults(formatter.ValidationResult{
+			Error: "Failed to parse form data",
+		}).Render(r.Context(), w)
+	}
+
+	text := r.FormValue("document")
+	if text == "" {
+		return formatter.ValidationResults(formatter.ValidationResult{
+			Error: "A sample document is required",
+		}).Render(r.Context(), w)
+	}
+	doc, format, _, err := jsonfmt.ParseDocument(text)
+	if err != nil {
+		return formatter.Results(parseErrorResult(format+" document", text, err)).Render(r.Context(), w)
+	}
+
+	draft := jsonschema.Draft2020
+	if r.FormValue("draft") == "draft-07" {
+		draft = jsonschema.Draft7
+	}
+	schema := jsonschema.Infer(doc, draft)
+	return formatter.ValidationResults(formatter.ValidationResult{
+		Mode:   "infer",
+		Format: format,
+		Draft:  draft.String(),
+		Schema: jsonfmt.Format(schema, jsonfmt.Options{Indent: "  "}),
+	}).Render(r.Context(), w)
+}
+
+// maxQueryMatches caps how many query results are rendered
+const maxQueryMatches = 500
+
+// HandleJSONQuery runs a JSONPath or jq expression against a JSON or YAML
+// document and lists what it selects
+func HandleJSONQuery(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return formatter.QueryResults(formatter.QueryResult{
+			Error: "Failed to parse form data",
+		}).Render(r.Context(), w)
+	}
+
+	text, expr := r.FormValue("document"), strings.TrimSpace(r.FormValue("expression"))
+	if text == "" || expr == "" {
+		return formatter.QueryResults(formatter.QueryResult{
+			Error: "Both a document and an expression are required",
+		}).Render(r.Context(), w)
+	}
+	doc, format, warnings, err := jsonfmt.ParseDocument(text)
+	if err != nil {
+		return formatter.Results(parseErrorResult(format+" document", text, err)).Render(r.Context(), w)
+	}
+
+	language := jsonquery.Detect(expr)
+	switch r.FormValue("language") {
+	case "jsonpath":
+		language = jsonquery.JSONPath
+	case "jq":
+		language = jsonquery.JQ
+	}
+	result := formatter.QueryResult{
+		Language:   language,
+		Format:     format,
+		Expression: expr,
+	}
+	for _, warning := range warnings {
+		result.Warnings = append(result.Warnings, warning.String())
+	}
+
+	// A jq runtime error still leaves the values produced before it
+	matches, err := jsonquery.Run(expr, language, doc)
+	if err != nil {
+		result.Error = err.Error()
+		var syntaxErr *jsonquery.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			result.Error = "Invalid " + language + " at " + err.Error()
+			if !strings.Contains(expr, "\n") {
+				result.Caret = caretLine(expr, syntaxErr.Column(expr))
+			}
+		}
+	}
+	result.Count = len(matches)
+	var output strings.Builder
+	for i, m := range matches {
+		if i == maxQueryMatches {
+			break
+		}
+		value := strings.TrimSuffix(jsonfmt.Format(m.Value, jsonfmt.Options{Indent: "  "}), "\n")
+		match := formatter.QueryMatch{Value: value}
+		if m.HasPath {
+			match.Path = pointerOrRoot(m.Path)
+		}
+		result.Matches = append(result.Matches, match)
+		output.WriteString(value + "\n")
+	}
+	result.Output = output.String()
+	return formatter.QueryResults(result).Render(r.Context(), w)
+}
+
+// pointerOrRoot labels the empty JSON Pointer, which means the whole document
+func pointerOrRoot(pointer string) string {
+	if pointer == "" {
+		return "(root)"
+	}
+	return pointer
+}
+
+// parseErrorResult reports a syntax error with its location, the
+// surrounding lines and a suggested fix
+func parseErrorResult(format, text string, err error) formatter.FormatterResult {
+	result := formatter.FormatterResult{
+		Error:        "Invalid " + format + ": " + err.Error(),
+		OriginalText: text,
+		Format:       format,
+	}
+	pe, ok := jsonfmt.AsParseError(err)
+	if !ok {
+		return result
+	}
+
+	result.ErrorLine = pe.Line
+	result.ErrorColumn = pe.Column
+	result.ErrorHint = pe.Hint
+	for _, line := range jsonfmt.Snippet(text, pe.Line, 2) {
+		result.Snippet = append(result.Snippet, formatter.SnippetLine{
+			Number:  line.Number,
+			Text:    line.Text,
+			Failing: line.Number == pe.Line,
+		})
+		if line.Number == pe.Line && pe.Column > 0 {
+			result.Caret = caretLine(line.Text, pe.Column)
+		}
+	}
+	return result
+}
+
+// caretLine puts a caret under the given 1-based column, copying tabs so
+// it lines up however the browser renders them
+func caretLine(text string, column int) string {
+	var b strings.Builder
+	for i, r := range []rune(text) {
+		if i >= column-1 {
+			break
+		}
+		if r == '\t' {
+			b.WriteRune('\t')
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+	return b.String() + "^"
+}
+
 // generateIndent creates a string with the specified number of spaces
 func generateIndent(count int) string {
 	indent := ""
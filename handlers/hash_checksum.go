@@ -0,0 +1,174 @@
+// handlers/hash_checksum.go
+package handlers
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Ndeta100/orbit2x/internal/hashes"
+	"github.com/Ndeta100/orbit2x/views/hashgen"
+)
+
+var (
+	// bsdChecksumLine matches the tagged format written by `shasum --tag`
+	// and BSD tools, e.g. "SHA256 (file.iso) = 3a98..."
+	bsdChecksumLine = regexp.MustCompile(`^([A-Za-z0-9/-]+) \((.*)\) = ([0-9A-Fa-f]+)$`)
+	// gnuChecksumLine matches coreutils output ("3a98...  file.iso"), where
+	// a '*' before the name marks binary mode and a leading '\' marks an
+	// escaped file name
+	gnuChecksumLine = regexp.MustCompile(`^\\?([0-9A-Fa-f]+)(?: [ *](.*))?$`)
+)
+
+// checksumTags maps algorithm names used in checksum files and prefixes to
+// registry IDs
+var checksumTags = map[string]string{
+	"md4":         "md4",
+	"md5":         "md5",
+	"sha1":        "sha1",
+	"sha-1":       "sha1",
+	"sha224":      "sha224",
+	"sha-224":     "sha224",
+	"sha256":      "sha256",
+	"sha-256":     "sha256",
+	"sha384":      "sha384",
+	"sha-384":     "sha384",
+	"sha512":      "sha512",
+	"sha-512":     "sha512",
+	"sha512/256":  "sha512-256",
+	"sha512-256":  "sha512-256",
+	"sha3-224":    "sha3-224",
+	"sha3-256":    "sha3-256",
+	"sha3-384":    "sha3-384",
+	"sha3-512":    "sha3-512",
+	"blake2b":     "blake2b",
+	"blake2b-512": "blake2b",
+	"blake2s":     "blake2s",
+	"blake2s-256": "blake2s",
+	"blake3":      "blake3",
+	"rmd160":      "ripemd160",
+	"ripemd160":   "ripemd160",
+	"ripemd-160":  "ripemd160",
+	"crc32":       "crc32",
+	"crc32c":      "crc32c",
+	"crc64":       "crc64",
+	"xxh64":       "xxhash64",
+	"xxhash64":    "xxhash64",
+}
+
+// checksumEntry is one expected digest, optionally tied to a file name and
+// an algorithm
+type checksumEntry struct {
+	Name      string
+	Digest    string
+	Algorithm string
+}
+
+// parseChecksumList reads a SHA256SUMS style listing in GNU or BSD format.
+// Blank lines and '#' comments are skipped
+func parseChecksumList(text string) ([]checksumEntry, error) {
+	var entries []checksumEntry
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for line := 1; scanner.Scan(); line++ {
+		s := strings.TrimSpace(scanner.Text())
+		if s == "" || strings.HasPrefix(s, "#") {
+			continue
+		}
+
+		if m := bsdChecksumLine.FindStringSubmatch(s); m != nil {
+			entries = append(entries, checksumEntry{
+				Name:      m[2],
+				Digest:    m[3],
+				Algorithm: checksumTags[strings.ToLower(m[1])],
+			})
+			continue
+		}
+		if m := gnuChecksumLine.FindStringSubmatch(s); m != nil {
+			name := m[2]
+			if strings.HasPrefix(s, `\`) {
+				name = strings.NewReplacer(`\\`, `\`, `\n`, "\n").Replace(name)
+			}
+			entries = append(entries, checksumEntry{Name: name, Digest: m[1]})
+			continue
+		}
+		return nil, fmt.Errorf("Checksum list line %d is not in a recognised format: %q", line, s)
+	}
+	return entries, scanner.Err()
+}
+
+// parseExpectedChecksum reads a single expected digest, which may carry an
+// algorithm prefix such as "sha256:3a98..."
+func parseExpectedChecksum(s string) checksumEntry {
+	s = strings.TrimSpace(s)
+	if tag, digest, ok := strings.Cut(s, ":"); ok {
+		if algo, known := checksumTags[strings.ToLower(tag)]; known {
+			return checksumEntry{Digest: strings.TrimSpace(digest), Algorithm: algo}
+		}
+	}
+	return checksumEntry{Digest: s}
+}
+
+// candidateAlgorithms lists the algorithms that could have produced an
+// entry's digest, going by its tag or else its length
+func candidateAlgorithms(entry checksumEntry) []hashes.Algorithm {
+	if algo, ok := hashes.Lookup(entry.Algorithm); ok {
+		return []hashes.Algorithm{algo}
+	}
+
+	return hashes.Filter(func(algo hashes.Algorithm) bool {
+		return algo.FileSafe && algo.Size*2 == len(entry.Digest)
+	})
+}
+
+// matchChecksum compares an expected digest with the digests of the
+// uploaded file in constant time
+func matchChecksum(entry checksumEntry, fileName string, digests map[string][]byte) hashgen.ChecksumMatch {
+	match := hashgen.ChecksumMatch{
+		Name:     entry.Name,
+		Expected: entry.Digest,
+	}
+
+	expected, err := hex.DecodeString(strings.ToLower(entry.Digest))
+	candidates := candidateAlgorithms(entry)
+	if err != nil || len(candidates) == 0 {
+		match.Status = "Not a recognised hex digest"
+		return match
+	}
+
+	for _, algo := range candidates {
+		sum, ok := digests[algo.ID]
+		if ok && subtle.ConstantTimeCompare(sum, expected) == 1 {
+			match.Algorithm = algo.Name
+			match.Matches = true
+			match.Status = "Match"
+			return match
+		}
+	}
+
+	names := make([]string, len(candidates))
+	for i, algo := range candidates {
+		names[i] = algo.Name
+	}
+	match.Algorithm = strings.Join(names, " / ")
+
+	// Entries for other files in a listing are expected not to match
+	if entry.Name != "" && !sameFileName(entry.Name, fileName) {
+		match.Status = "Different file"
+	} else {
+		match.Status = "Mismatch"
+	}
+	return match
+}
+
+// sameFileName compares a listed path with an uploaded file name, ignoring
+// any directories in the listing
+func sameFileName(listed, uploaded string) bool {
+	listed = strings.TrimPrefix(listed, "./")
+	if i := strings.LastIndexAny(listed, `/\`); i >= 0 {
+		listed = listed[i+1:]
+	}
+	return listed == uploaded
+}
@@ -2,28 +2,25 @@
 package handlers
 
 import (
-	"crypto/md5"
-	"crypto/sha1"
-	"crypto/sha256"
-	"crypto/sha512"
+	"crypto/subtle"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"hash"
-	"hash/crc32"
 	"io"
+	"log"
 	"net/http"
 	"strings"
 
+	"github.com/Ndeta100/orbit2x/internal/hashes"
+	"github.com/Ndeta100/orbit2x/internal/passhash"
 	"github.com/Ndeta100/orbit2x/views/hashgen"
-	"golang.org/x/crypto/bcrypt"
-	"golang.org/x/crypto/blake2b"
-	"golang.org/x/crypto/md4"
-	"golang.org/x/crypto/ripemd160"
-	"golang.org/x/crypto/sha3"
 )
 
 // HandleHashIndex renders the Hash Generator page
 func HandleHashIndex(w http.ResponseWriter, r *http.Request) error {
-	return hashgen.Index().Render(r.Context(), w)
+	return hashgen.Index(hashAlgorithmLists()).Render(r.Context(), w)
 }
 
 // HandleGenerateHash generates various hash values for the provided input
@@ -43,148 +40,198 @@ func HandleGenerateHash(w http.ResponseWriter, r *http.Request) error {
 		}).Render(r.Context(), w)
 	}
 
-	// Selected hash algorithms
-	selectedAlgorithms := r.Form["algorithms"]
-	if len(selectedAlgorithms) == 0 {
-		// If no algorithm is selected, use all
-		selectedAlgorithms = []string{"md5", "sha1", "sha256", "sha512", "sha3-256", "sha3-512", "blake2b", "ripemd160", "crc32", "bcrypt"}
-	}
-
 	// Prepare result
 	result := hashgen.HashResult{
-		InputText:  text,
-		HashValues: make(map[string]string),
+		InputText: text,
 	}
 
-	// Generate hashes for selected algorithms
+	// Generate hashes for the selected algorithms, or the defaults if none are selected
 	data := []byte(text)
-	for _, algo := range selectedAlgorithms {
-		switch algo {
-		case "md4":
-			result.HashValues["MD4"] = generateHash(md4.New(), data)
-		case "md5":
-			result.HashValues["MD5"] = generateHash(md5.New(), data)
-		case "sha1":
-			result.HashValues["SHA-1"] = generateHash(sha1.New(), data)
-		case "sha224":
-			result.HashValues["SHA-224"] = generateHash(sha256.New224(), data)
-		case "sha256":
-			result.HashValues["SHA-256"] = generateHash(sha256.New(), data)
-		case "sha384":
-			result.HashValues["SHA-384"] = generateHash(sha512.New384(), data)
-		case "sha512":
-			result.HashValues["SHA-512"] = generateHash(sha512.New(), data)
-		case "sha3-224":
-			result.HashValues["SHA3-224"] = generateHash(sha3.New224(), data)
-		case "sha3-256":
-			result.HashValues["SHA3-256"] = generateHash(sha3.New256(), data)
-		case "sha3-384":
-			result.HashValues["SHA3-384"] = generateHash(sha3.New384(), data)
-		case "sha3-512":
-			result.HashValues["SHA3-512"] = generateHash(sha3.New512(), data)
-		case "blake2b":
-			blake2bHash, _ := blake2b.New512(nil)
-			result.HashValues["BLAKE2b-512"] = generateHash(blake2bHash, data)
-		case "ripemd160":
-			result.HashValues["RIPEMD-160"] = generateHash(ripemd160.New(), data)
-		case "crc32":
-			crc32Hash := crc32.NewIEEE()
-			crc32Hash.Write(data)
-			result.HashValues["CRC32"] = hex.EncodeToString(crc32Hash.Sum(nil))
-		case "bcrypt":
-			bcryptHash, err := bcrypt.GenerateFromPassword(data, bcrypt.DefaultCost)
-			if err != nil {
-				result.HashValues["bcrypt"] = "Error generating bcrypt hash: " + err.Error()
-			} else {
-				result.HashValues["bcrypt"] = string(bcryptHash)
-			}
+	for _, algo := range hashes.Select(r.Form["algorithms"], hashes.Any) {
+		value, err := algo.Sum(data)
+		if err != nil {
+			value = "Error generating " + algo.Name + " hash: " + err.Error()
 		}
+		result.Hashes = append(result.Hashes, hashgen.HashValue{Algorithm: algo.Name, Value: value})
 	}
 
 	// Render the result
 	return hashgen.Results(result).Render(r.Context(), w)
 }
 
-// HandleFileHash generates hash values for a file
+// maxHashFileSize caps file uploads. The body is streamed through the
+// hashes, so the limit only bounds how long a single request can run
+const maxHashFileSize = 4 << 30
+
+// maxHashFieldSize caps the text fields sent alongside the file, which
+// includes a pasted SHA256SUMS listing
+const maxHashFieldSize = 1 << 20
+
+// HandleFileHash streams an uploaded file through every selected hash in a
+// single pass and optionally checks it against expected checksums
 func HandleFileHash(w http.ResponseWriter, r *http.Request) error {
-	// Set max upload size - 10MB
-	r.Body = http.MaxBytesReader(w, r.Body, 10*1024*1024)
-	if err := r.ParseMultipartForm(10 * 1024 * 1024); err != nil {
+	r.Body = http.MaxBytesReader(w, r.Body, maxHashFileSize)
+	reader, err := r.MultipartReader()
+	if err != nil {
 		return hashgen.Results(hashgen.HashResult{
-			Error: "The uploaded file is too large. Maximum size is 10MB.",
+			Error: "Expected a multipart file upload: " + err.Error(),
 		}).Render(r.Context(), w)
 	}
 
-	// Get file from form
-	file, header, err := r.FormFile("file")
-	if err != nil {
-		return hashgen.Results(hashgen.HashResult{
-			Error: "Failed to get file from form: " + err.Error(),
-		}).Render(r.Context(), w)
+	var (
+		selectedAlgorithms []string
+		expected           string
+		checksums          string
+		fileName           string
+		fileSize           int64
+		digests            map[string][]byte
+	)
+
+	// Parts are handled in the order the browser sends them. The file is
+	// hashed as it arrives, so it is never held in memory or on disk
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return hashgen.Results(hashgen.HashResult{
+				Error: uploadErrorMessage(err),
+			}).Render(r.Context(), w)
+		}
+
+		switch part.FormName() {
+		case "algorithms", "expected", "checksums":
+			value, err := io.ReadAll(io.LimitReader(part, maxHashFieldSize))
+			if err != nil {
+				return hashgen.Results(hashgen.HashResult{
+					Error: uploadErrorMessage(err),
+				}).Render(r.Context(), w)
+			}
+			switch part.FormName() {
+			case "algorithms":
+				selectedAlgorithms = append(selectedAlgorithms, string(value))
+			case "expected":
+				expected = string(value)
+			case "checksums":
+				checksums = string(value)
+			}
+		case "file":
+			if digests != nil {
+				continue
+			}
+			fileName = part.FileName()
+
+			// Options normally precede the file. If they do not, hash with
+			// every algorithm and narrow the results down afterwards
+			algorithms := fileHashAlgorithms(selectedAlgorithms, expected, checksums)
+			if len(selectedAlgorithms) == 0 && expected == "" && checksums == "" {
+				algorithms = hashes.Filter(hashes.IsFileSafe)
+			}
+
+			digests, fileSize, err = hashStream(part, algorithms)
+			if err != nil {
+				return hashgen.Results(hashgen.HashResult{
+					Error: uploadErrorMessage(err),
+				}).Render(r.Context(), w)
+			}
+		}
+		part.Close()
 	}
-	defer file.Close()
 
-	// Selected hash algorithms
-	selectedAlgorithms := r.Form["algorithms"]
-	if len(selectedAlgorithms) == 0 {
-		// If no algorithm is selected, use common ones for files
-		selectedAlgorithms = []string{"md5", "sha1", "sha256", "sha512"}
+	if digests == nil {
+		return hashgen.Results(hashgen.HashResult{
+			Error: "No file was uploaded",
+		}).Render(r.Context(), w)
 	}
 
 	// Prepare result
 	result := hashgen.HashResult{
-		InputText:  "File: " + header.Filename,
-		HashValues: make(map[string]string),
-		IsFile:     true,
-		FileName:   header.Filename,
-		FileSize:   formatFileSize(header.Size),
+		InputText: "File: " + fileName,
+		IsFile:    true,
+		FileName:  fileName,
+		FileSize:  formatFileSize(fileSize),
 	}
-
-	// Generate file hashes for selected algorithms
-	for _, algo := range selectedAlgorithms {
-		// Seek back to start of file for each hash calculation
-		file.Seek(0, io.SeekStart)
-
-		switch algo {
-		case "md4":
-			result.HashValues["MD4"] = generateFileHash(md4.New(), file)
-		case "md5":
-			result.HashValues["MD5"] = generateFileHash(md5.New(), file)
-		case "sha1":
-			result.HashValues["SHA-1"] = generateFileHash(sha1.New(), file)
-		case "sha224":
-			result.HashValues["SHA-224"] = generateFileHash(sha256.New224(), file)
-		case "sha256":
-			result.HashValues["SHA-256"] = generateFileHash(sha256.New(), file)
-		case "sha384":
-			result.HashValues["SHA-384"] = generateFileHash(sha512.New384(), file)
-		case "sha512":
-			result.HashValues["SHA-512"] = generateFileHash(sha512.New(), file)
-		case "sha3-224":
-			result.HashValues["SHA3-224"] = generateFileHash(sha3.New224(), file)
-		case "sha3-256":
-			result.HashValues["SHA3-256"] = generateFileHash(sha3.New256(), file)
-		case "sha3-384":
-			result.HashValues["SHA3-384"] = generateFileHash(sha3.New384(), file)
-		case "sha3-512":
-			result.HashValues["SHA3-512"] = generateFileHash(sha3.New512(), file)
-		case "blake2b":
-			blake2bHash, _ := blake2b.New512(nil)
-			result.HashValues["BLAKE2b-512"] = generateFileHash(blake2bHash, file)
-		case "ripemd160":
-			result.HashValues["RIPEMD-160"] = generateFileHash(ripemd160.New(), file)
-		case "crc32":
-			crc32Hash := crc32.NewIEEE()
-			io.Copy(crc32Hash, file)
-			result.HashValues["CRC32"] = hex.EncodeToString(crc32Hash.Sum(nil))
+	for _, algo := range hashes.Select(selectedAlgorithms, hashes.IsFileSafe) {
+		if sum, ok := digests[algo.ID]; ok {
+			result.Hashes = append(result.Hashes, hashgen.HashValue{Algorithm: algo.Name, Value: hex.EncodeToString(sum)})
 		}
-		// Skip bcrypt for files as it's designed for passwords and has input size limitations
+	}
+
+	if expected = strings.TrimSpace(expected); expected != "" {
+		result.Checksums = append(result.Checksums, matchChecksum(parseExpectedChecksum(expected), fileName, digests))
+	}
+	entries, err := parseChecksumList(checksums)
+	if err != nil {
+		return hashgen.Results(hashgen.HashResult{
+			Error: err.Error(),
+		}).Render(r.Context(), w)
+	}
+	for _, entry := range entries {
+		result.Checksums = append(result.Checksums, matchChecksum(entry, fileName, digests))
 	}
 
 	// Render the result
 	return hashgen.Results(result).Render(r.Context(), w)
 }
 
+// fileHashAlgorithms returns the algorithms to run over a file: the selected
+// ones (or the defaults) plus any needed to check the expected checksums
+func fileHashAlgorithms(selected []string, expected, checksums string) []hashes.Algorithm {
+	algorithms := hashes.Select(selected, hashes.IsFileSafe)
+	seen := make(map[string]bool)
+	for _, algo := range algorithms {
+		seen[algo.ID] = true
+	}
+
+	// Add every algorithm whose digest length fits an expected checksum
+	entries, _ := parseChecksumList(checksums)
+	if expected = strings.TrimSpace(expected); expected != "" {
+		entries = append(entries, parseExpectedChecksum(expected))
+	}
+	for _, entry := range entries {
+		for _, algo := range candidateAlgorithms(entry) {
+			if !seen[algo.ID] {
+				seen[algo.ID] = true
+				algorithms = append(algorithms, algo)
+			}
+		}
+	}
+	return algorithms
+}
+
+// hashStream copies r once into every requested hash and returns the digests
+// along with the number of bytes read
+func hashStream(r io.Reader, algorithms []hashes.Algorithm) (map[string][]byte, int64, error) {
+	running := make(map[string]hash.Hash, len(algorithms))
+	writers := make([]io.Writer, 0, len(algorithms))
+	for _, algo := range algorithms {
+		h := algo.New()
+		running[algo.ID] = h
+		writers = append(writers, h)
+	}
+
+	n, err := io.Copy(io.MultiWriter(writers...), r)
+	if err != nil {
+		return nil, n, err
+	}
+
+	digests := make(map[string][]byte, len(running))
+	for id, h := range running {
+		digests[id] = h.Sum(nil)
+	}
+	return digests, n, nil
+}
+
+// uploadErrorMessage explains a failure while reading a streamed upload
+func uploadErrorMessage(err error) string {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return fmt.Sprintf("The uploaded file is too large. Maximum size is %s.", formatFileSize(maxBytesErr.Limit))
+	}
+	return "Failed to read the uploaded file: " + err.Error()
+}
+
 // HandleVerifyHash verifies if a hash matches the input
 func HandleVerifyHash(w http.ResponseWriter, r *http.Request) error {
 	// Parse form data
@@ -205,44 +252,42 @@ func HandleVerifyHash(w http.ResponseWriter, r *http.Request) error {
 		}).Render(r.Context(), w)
 	}
 
-	// Normalize hash input (remove spaces, make lowercase)
-	hashForm = strings.ToLower(strings.ReplaceAll(hashForm, " ", ""))
-
-	// Calculate the hash of the input
-	var calculatedHash string
+	algo, ok := hashes.Lookup(strings.ToLower(strings.TrimSpace(algorithm)))
+	if !ok {
+		return hashgen.VerifyResult(hashgen.HashVerifyResult{
+			Error: "Unsupported algorithm for verification",
+		}).Render(r.Context(), w)
+	}
 	data := []byte(input)
 
-	switch algorithm {
-	case "md5":
-		calculatedHash = generateHash(md5.New(), data)
-	case "sha1":
-		calculatedHash = generateHash(sha1.New(), data)
-	case "sha256":
-		calculatedHash = generateHash(sha256.New(), data)
-	case "sha512":
-		calculatedHash = generateHash(sha512.New(), data)
-	case "bcrypt":
-		// Special case for bcrypt as it uses a different verification method
-		err := bcrypt.CompareHashAndPassword([]byte(hashForm), data)
+	// Password hashes carry their own salt and parameters, and are case-sensitive
+	if algo.Password {
+		hashForm = strings.TrimSpace(hashForm)
+		matches, _, err := passhash.Verify(data, hashForm)
+		if err != nil {
+			return hashgen.VerifyResult(hashgen.HashVerifyResult{
+				Error: fmt.Sprintf("Invalid %s hash: %v", algo.Name, err),
+			}).Render(r.Context(), w)
+		}
 		result := hashgen.HashVerifyResult{
 			Input:     input,
 			Hash:      hashForm,
-			Algorithm: algorithm,
-			Matches:   err == nil,
+			Algorithm: algo.Name,
+			Matches:   matches,
 		}
 		return hashgen.VerifyResult(result).Render(r.Context(), w)
-	default:
-		return hashgen.VerifyResult(hashgen.HashVerifyResult{
-			Error: "Unsupported algorithm for verification",
-		}).Render(r.Context(), w)
 	}
 
-	// For all other algorithms, compare the calculated hash with the provided hash
+	// Normalize hash input (remove whitespace, make lowercase)
+	hashForm = strings.ToLower(strings.Join(strings.Fields(hashForm), ""))
+
+	// Calculate the hash of the input and compare in constant time
+	calculatedHash := generateHash(algo.New(), data)
 	result := hashgen.HashVerifyResult{
 		Input:          input,
 		Hash:           hashForm,
-		Algorithm:      algorithm,
-		Matches:        strings.EqualFold(calculatedHash, hashForm),
+		Algorithm:      algo.Name,
+		Matches:        subtle.ConstantTimeCompare([]byte(calculatedHash), []byte(hashForm)) == 1,
 		CalculatedHash: calculatedHash,
 	}
 
@@ -250,14 +295,49 @@ func HandleVerifyHash(w http.ResponseWriter, r *http.Request) error {
 	return hashgen.VerifyResult(result).Render(r.Context(), w)
 }
 
+// HandleHashAlgorithms lists the registered hash algorithms as JSON
+func HandleHashAlgorithms(w http.ResponseWriter, r *http.Request) error {
+	setCORSHeaders(w)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(hashes.All()); err != nil {
+		log.Printf("Error encoding hash algorithms JSON: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+	return nil
+}
+
+// hashAlgorithmLists builds the algorithm choices for each form on the page
+func hashAlgorithmLists() hashgen.AlgorithmLists {
+	options := func(keep func(hashes.Algorithm) bool, selected string) []hashgen.AlgorithmOption {
+		var opts []hashgen.AlgorithmOption
+		for _, algo := range hashes.Filter(keep) {
+			opts = append(opts, hashgen.AlgorithmOption{
+				ID:       algo.ID,
+				Name:     algo.Name,
+				Selected: algo.Default || algo.ID == selected,
+			})
+		}
+		return opts
+	}
+	single := func(keep func(hashes.Algorithm) bool) []hashgen.AlgorithmOption {
+		opts := options(keep, "")
+		for i := range opts {
+			opts[i].Selected = opts[i].ID == "sha256"
+		}
+		return opts
+	}
+
+	return hashgen.AlgorithmLists{
+		Text:   options(hashes.Any, ""),
+		File:   options(hashes.IsFileSafe, ""),
+		Verify: single(hashes.Any),
+		HMAC:   single(hashes.IsKeyed),
+	}
+}
+
 // generateHash generates a hash for text input
 func generateHash(h hash.Hash, data []byte) string {
 	h.Write(data)
 	return hex.EncodeToString(h.Sum(nil))
 }
-
-// generateFileHash generates a hash for a file
-func generateFileHash(h hash.Hash, file io.Reader) string {
-	io.Copy(h, file)
-	return hex.EncodeToString(h.Sum(nil))
-}
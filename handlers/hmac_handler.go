@@ -0,0 +1,195 @@
+// handlers/hmac_handler.go
+package handlers
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Ndeta100/orbit2x/internal/hashes"
+	"github.com/Ndeta100/orbit2x/views/hashgen"
+)
+
+// HandleGenerateHMAC computes HMAC values for the input with a user-supplied key
+func HandleGenerateHMAC(w http.ResponseWriter, r *http.Request) error {
+	// Parse form data
+	if err := r.ParseForm(); err != nil {
+		return hashgen.Results(hashgen.HashResult{
+			Error: "Failed to parse form data",
+		}).Render(r.Context(), w)
+	}
+
+	text := r.FormValue("text")
+	if text == "" {
+		return hashgen.Results(hashgen.HashResult{
+			Error: "Input text is required",
+		}).Render(r.Context(), w)
+	}
+
+	key, err := decodeHMACKey(r.FormValue("key"), r.FormValue("key_format"))
+	if err != nil {
+		return hashgen.Results(hashgen.HashResult{
+			Error: err.Error(),
+		}).Render(r.Context(), w)
+	}
+
+	encoding := r.FormValue("encoding")
+	if _, err := encodeDigest(nil, encoding); err != nil {
+		return hashgen.Results(hashgen.HashResult{
+			Error: err.Error(),
+		}).Render(r.Context(), w)
+	}
+
+	selectedAlgorithms := r.Form["algorithms"]
+	if len(selectedAlgorithms) == 0 {
+		selectedAlgorithms = []string{"sha256"}
+	}
+
+	result := hashgen.HashResult{
+		InputText: text,
+	}
+	for _, algo := range hashes.Select(selectedAlgorithms, hashes.IsKeyed) {
+		value, _ := encodeDigest(computeHMAC(algo, key, []byte(text)), encoding)
+		result.Hashes = append(result.Hashes, hashgen.HashValue{Algorithm: "HMAC-" + algo.Name, Value: value})
+	}
+
+	return hashgen.Results(result).Render(r.Context(), w)
+}
+
+// HandleVerifyHMAC checks an HMAC value against the input and key in constant time
+func HandleVerifyHMAC(w http.ResponseWriter, r *http.Request) error {
+	// Parse form data
+	if err := r.ParseForm(); err != nil {
+		return hashgen.VerifyResult(hashgen.HashVerifyResult{
+			Error: "Failed to parse form data",
+		}).Render(r.Context(), w)
+	}
+
+	input := r.FormValue("input")
+	macForm := strings.TrimSpace(r.FormValue("mac"))
+	algorithm := strings.ToLower(r.FormValue("algorithm"))
+	encoding := r.FormValue("encoding")
+
+	if input == "" || macForm == "" || algorithm == "" {
+		return hashgen.VerifyResult(hashgen.HashVerifyResult{
+			Error: "Input, HMAC, and algorithm are all required",
+		}).Render(r.Context(), w)
+	}
+
+	algo, ok := hashes.Lookup(algorithm)
+	if !ok || !algo.Keyed {
+		return hashgen.VerifyResult(hashgen.HashVerifyResult{
+			Error: "Unsupported algorithm for HMAC",
+		}).Render(r.Context(), w)
+	}
+
+	key, err := decodeHMACKey(r.FormValue("key"), r.FormValue("key_format"))
+	if err != nil {
+		return hashgen.VerifyResult(hashgen.HashVerifyResult{
+			Error: err.Error(),
+		}).Render(r.Context(), w)
+	}
+
+	expected, err := decodeDigest(macForm, encoding)
+	if err != nil {
+		return hashgen.VerifyResult(hashgen.HashVerifyResult{
+			Error: err.Error(),
+		}).Render(r.Context(), w)
+	}
+
+	mac := computeHMAC(algo, key, []byte(input))
+	calculated, _ := encodeDigest(mac, encoding)
+
+	result := hashgen.HashVerifyResult{
+		Input:          input,
+		Hash:           macForm,
+		Algorithm:      "HMAC-" + algo.Name,
+		Matches:        hmac.Equal(mac, expected),
+		CalculatedHash: calculated,
+	}
+
+	return hashgen.VerifyResult(result).Render(r.Context(), w)
+}
+
+// computeHMAC returns the HMAC of data using a keyed registry algorithm
+func computeHMAC(algo hashes.Algorithm, key, data []byte) []byte {
+	mac := hmac.New(algo.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// decodeHMACKey reads the key as UTF-8 text, hex or base64
+func decodeHMACKey(key, format string) ([]byte, error) {
+	if key == "" {
+		return nil, fmt.Errorf("HMAC key is required")
+	}
+
+	switch format {
+	case "", "text":
+		return []byte(key), nil
+	case "hex":
+		decoded, err := hex.DecodeString(strings.TrimSpace(key))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid hex key: %v", err)
+		}
+		return decoded, nil
+	case "base64":
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(key))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid base64 key: %v", err)
+		}
+		return decoded, nil
+	case "base64url":
+		decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(strings.TrimSpace(key), "="))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid base64url key: %v", err)
+		}
+		return decoded, nil
+	default:
+		return nil, fmt.Errorf("Unsupported key format %q", format)
+	}
+}
+
+// encodeDigest formats a digest as hex, base64 or unpadded base64url
+func encodeDigest(sum []byte, encoding string) (string, error) {
+	switch encoding {
+	case "", "hex":
+		return hex.EncodeToString(sum), nil
+	case "base64":
+		return base64.StdEncoding.EncodeToString(sum), nil
+	case "base64url":
+		return base64.RawURLEncoding.EncodeToString(sum), nil
+	default:
+		return "", fmt.Errorf("Unsupported output encoding %q", encoding)
+	}
+}
+
+// decodeDigest parses a digest written in the given encoding. Padding is
+// optional for the base64 variants
+func decodeDigest(value, encoding string) ([]byte, error) {
+	value = strings.Join(strings.Fields(value), "")
+	if encoding == "" {
+		encoding = "hex"
+	}
+
+	var decoded []byte
+	var err error
+	switch encoding {
+	case "hex":
+		decoded, err = hex.DecodeString(strings.ToLower(value))
+	case "base64":
+		decoded, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(value, "="))
+	case "base64url":
+		decoded, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+	default:
+		return nil, fmt.Errorf("Unsupported encoding %q", encoding)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("HMAC is not valid %s: %v", encoding, err)
+	}
+	return decoded, nil
+}
@@ -0,0 +1,216 @@
+// handlers/jwt_handler.go
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Ndeta100/orbit2x/internal/jwt"
+	"github.com/Ndeta100/orbit2x/views/jwttool"
+)
+
+// HandleJWTIndex renders the JWT decoder page
+func HandleJWTIndex(w http.ResponseWriter, r *http.Request) error {
+	var options []jwttool.AlgorithmOption
+	for _, alg := range jwt.Algorithms() {
+		options = append(options, jwttool.AlgorithmOption{Name: alg.Name, Family: string(alg.Family)})
+	}
+	return jwttool.Index(options).Render(r.Context(), w)
+}
+
+// HandleJWTDecode decodes a token and, when a key is supplied, verifies its
+// signature
+func HandleJWTDecode(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return jwttool.Results(jwttool.JWTResult{
+			Error: "Failed to parse form data",
+			Mode:  "decode",
+		}).Render(r.Context(), w)
+	}
+
+	raw := strings.TrimSpace(r.FormValue("token"))
+	if raw == "" {
+		return jwttool.Results(jwttool.JWTResult{
+			Error: "Token is required",
+			Mode:  "decode",
+		}).Render(r.Context(), w)
+	}
+
+	tok, err := jwt.Parse(raw)
+	if err != nil {
+		return jwttool.Results(jwttool.JWTResult{
+			Token: raw,
+			Error: "Invalid JWT: " + err.Error(),
+			Mode:  "decode",
+		}).Render(r.Context(), w)
+	}
+
+	result := decodedToken(tok)
+	result.Mode = "decode"
+
+	if strings.TrimSpace(r.FormValue("key")) != "" {
+		result.Checked = true
+		keys, err := jwtKeys(r)
+		if err != nil {
+			result.VerifyMessage = err.Error()
+			return jwttool.Results(result).Render(r.Context(), w)
+		}
+		if alg, ok := jwt.LookupAlgorithm(tok.Alg); ok {
+			result.Findings = append(result.Findings, findingRows(jwt.KeyFindings(alg, keys))...)
+		}
+
+		key, err := jwt.Verify(tok, keys)
+		if err != nil {
+			result.VerifyMessage = "Signature invalid: " + err.Error()
+		} else {
+			result.Verified = true
+			result.VerifyMessage = "Signature verified with " + key.Describe()
+		}
+	}
+
+	return jwttool.Results(result).Render(r.Context(), w)
+}
+
+// HandleJWTSign signs a JSON payload, optionally stamping iat and exp
+func HandleJWTSign(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return jwttool.Results(jwttool.JWTResult{
+			Error: "Failed to parse form data",
+			Mode:  "sign",
+		}).Render(r.Context(), w)
+	}
+
+	alg, ok := jwt.LookupAlgorithm(r.FormValue("alg"))
+	if !ok {
+		return jwttool.Results(jwttool.JWTResult{
+			Error: "Unsupported algorithm",
+			Mode:  "sign",
+		}).Render(r.Context(), w)
+	}
+
+	payload := []byte(strings.TrimSpace(r.FormValue("payload")))
+	if len(payload) == 0 {
+		return jwttool.Results(jwttool.JWTResult{
+			Error: "Payload is required",
+			Mode:  "sign",
+		}).Render(r.Context(), w)
+	}
+
+	expiresIn, err := formInt(r, "expires_in", 0)
+	if err == nil && expiresIn < 0 {
+		err = fmt.Errorf("expires in must not be negative")
+	}
+	if err != nil {
+		return jwttool.Results(jwttool.JWTResult{
+			Error: "Invalid options: " + err.Error(),
+			Mode:  "sign",
+		}).Render(r.Context(), w)
+	}
+
+	now := time.Now()
+	if r.FormValue("set_iat") != "" {
+		payload, err = jwt.SetClaim(payload, "iat", now.Unix())
+	}
+	if err == nil && expiresIn > 0 {
+		payload, err = jwt.SetClaim(payload, "exp", now.Add(time.Duration(expiresIn)*time.Minute).Unix())
+	}
+	if err != nil {
+		return jwttool.Results(jwttool.JWTResult{
+			Error: "Invalid payload: " + err.Error(),
+			Mode:  "sign",
+		}).Render(r.Context(), w)
+	}
+
+	if strings.TrimSpace(r.FormValue("key")) == "" {
+		return jwttool.Results(jwttool.JWTResult{
+			Error: "A secret or private key is required",
+			Mode:  "sign",
+		}).Render(r.Context(), w)
+	}
+	keys, err := jwtKeys(r)
+	if err != nil {
+		return jwttool.Results(jwttool.JWTResult{
+			Error: err.Error(),
+			Mode:  "sign",
+		}).Render(r.Context(), w)
+	}
+	var signer jwt.Key
+	for _, k := range keys {
+		if alg.CanSign(k) {
+			signer = k
+			break
+		}
+	}
+
+	kid := strings.TrimSpace(r.FormValue("kid"))
+	if kid == "" {
+		kid = signer.ID
+	}
+	signed, err := jwt.Sign(alg, signer, kid, payload)
+	if err != nil {
+		return jwttool.Results(jwttool.JWTResult{
+			Error: "Cannot sign: " + err.Error(),
+			Mode:  "sign",
+		}).Render(r.Context(), w)
+	}
+
+	tok, err := jwt.Parse(signed)
+	if err != nil {
+		return err
+	}
+	result := decodedToken(tok)
+	result.Mode = "sign"
+	result.Findings = append(result.Findings, findingRows(jwt.KeyFindings(alg, []jwt.Key{signer}))...)
+	return jwttool.Results(result).Render(r.Context(), w)
+}
+
+// jwtKeys reads the key field: PEM and JWK/JWKS documents are parsed as
+// keys, anything else is a shared secret in the chosen encoding
+func jwtKeys(r *http.Request) ([]jwt.Key, error) {
+	text := r.FormValue("key")
+	if jwt.IsKeyMaterial(text) {
+		keys, err := jwt.ParseKeys(text)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid key: %v", err)
+		}
+		return keys, nil
+	}
+
+	secret, err := decodeHMACKey(text, r.FormValue("key_encoding"))
+	if err != nil {
+		return nil, err
+	}
+	return []jwt.Key{{Secret: secret, Source: "secret"}}, nil
+}
+
+// decodedToken fills in the parts of a result shown for every token
+func decodedToken(tok *jwt.Token) jwttool.JWTResult {
+	result := jwttool.JWTResult{
+		Token:     tok.Raw,
+		Algorithm: tok.Alg,
+		Header:    jwt.Indent(tok.Header),
+		Payload:   jwt.Indent(tok.Payload),
+		Findings:  findingRows(tok.Findings()),
+	}
+	for _, c := range tok.ExplainClaims(time.Now()) {
+		result.Claims = append(result.Claims, jwttool.ClaimRow{
+			Name:        c.Name,
+			Value:       c.Value,
+			Description: c.Description,
+			Time:        c.Time,
+			Status:      c.Status,
+			Severity:    c.Severity,
+		})
+	}
+	return result
+}
+
+func findingRows(findings []jwt.Finding) []jwttool.Finding {
+	var rows []jwttool.Finding
+	for _, f := range findings {
+		rows = append(rows, jwttool.Finding{Severity: f.Severity, Message: f.Message})
+	}
+	return rows
+}
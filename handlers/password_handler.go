@@ -0,0 +1,164 @@
+// handlers/password_handler.go
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Ndeta100/orbit2x/internal/passgen"
+	"github.com/Ndeta100/orbit2x/views/passwordgen"
+)
+
+// HandlePasswordIndex renders the password generator page
+func HandlePasswordIndex(w http.ResponseWriter, r *http.Request) error {
+	var classes []passwordgen.ClassOption
+	for _, c := range passgen.Classes() {
+		classes = append(classes, passwordgen.ClassOption{ID: c.ID, Name: c.Name})
+	}
+	return passwordgen.Index(classes, passgen.WordlistSize()).Render(r.Context(), w)
+}
+
+// HandlePasswordGenerate generates one or more random passwords
+func HandlePasswordGenerate(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return passwordgen.Generated(passwordgen.GeneratedResult{
+			Error: "Failed to parse form data",
+			Mode:  "password",
+		}).Render(r.Context(), w)
+	}
+
+	length, err := formInt(r, "length", 20)
+	count := 1
+	if err == nil {
+		count, err = generateCount(r)
+	}
+	if err != nil {
+		return passwordgen.Generated(passwordgen.GeneratedResult{
+			Error: err.Error(),
+			Mode:  "password",
+		}).Render(r.Context(), w)
+	}
+
+	opts := passgen.Options{
+		Length:           length,
+		Classes:          r.Form["classes"],
+		Custom:           r.FormValue("custom"),
+		ExcludeAmbiguous: r.FormValue("exclude_ambiguous") != "",
+		Exclude:          r.FormValue("exclude"),
+		RequireEach:      r.FormValue("require_each") != "",
+	}
+	result := passwordgen.GeneratedResult{Mode: "password"}
+	var pool int
+	for i := 0; i < count; i++ {
+		p, err := passgen.Generate(opts)
+		if err != nil {
+			return passwordgen.Generated(passwordgen.GeneratedResult{
+				Error: err.Error(),
+				Mode:  "password",
+			}).Render(r.Context(), w)
+		}
+		result.Values = append(result.Values, passwordgen.GeneratedValue{Value: p.Value})
+		result.Entropy, pool = p.Entropy, p.PoolSize
+	}
+	result.Detail = fmt.Sprintf("%d characters from a pool of %d", length, pool)
+	result.Score, result.Label = passgen.Rate(result.Entropy)
+	return passwordgen.Generated(result).Render(r.Context(), w)
+}
+
+// HandlePassphraseGenerate generates diceware passphrases
+func HandlePassphraseGenerate(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return passwordgen.Generated(passwordgen.GeneratedResult{
+			Error: "Failed to parse form data",
+			Mode:  "passphrase",
+		}).Render(r.Context(), w)
+	}
+
+	words, err := formInt(r, "words", 6)
+	count := 1
+	if err == nil {
+		count, err = generateCount(r)
+	}
+	if err != nil {
+		return passwordgen.Generated(passwordgen.GeneratedResult{
+			Error: err.Error(),
+			Mode:  "passphrase",
+		}).Render(r.Context(), w)
+	}
+
+	separator := r.FormValue("separator")
+	if separator == "space" {
+		separator = " "
+	}
+	opts := passgen.PassphraseOptions{
+		Words:      words,
+		Separator:  separator,
+		Capitalize: r.FormValue("capitalize") != "",
+		AddNumber:  r.FormValue("add_number") != "",
+	}
+	result := passwordgen.GeneratedResult{Mode: "passphrase"}
+	for i := 0; i < count; i++ {
+		p, err := passgen.GeneratePassphrase(opts)
+		if err != nil {
+			return passwordgen.Generated(passwordgen.GeneratedResult{
+				Error: err.Error(),
+				Mode:  "passphrase",
+			}).Render(r.Context(), w)
+		}
+		result.Values = append(result.Values, passwordgen.GeneratedValue{Value: p.Value, Rolls: p.Rolls})
+		result.Entropy = p.Entropy
+	}
+	result.Detail = fmt.Sprintf("%d words from a list of %d", words, passgen.WordlistSize())
+	result.Score, result.Label = passgen.Rate(result.Entropy)
+	return passwordgen.Generated(result).Render(r.Context(), w)
+}
+
+// HandlePasswordStrength estimates the strength of a typed password. Nothing
+// is stored or logged
+func HandlePasswordStrength(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return passwordgen.Strength(passwordgen.StrengthResult{
+			Error: "Failed to parse form data",
+		}).Render(r.Context(), w)
+	}
+
+	password := r.FormValue("password")
+	if password == "" {
+		return passwordgen.Strength(passwordgen.StrengthResult{}).Render(r.Context(), w)
+	}
+
+	report, err := passgen.Analyze(password)
+	if err != nil {
+		return passwordgen.Strength(passwordgen.StrengthResult{
+			Error: err.Error(),
+		}).Render(r.Context(), w)
+	}
+
+	result := passwordgen.StrengthResult{
+		Length:       report.Length,
+		PoolSize:     report.PoolSize,
+		NaiveEntropy: report.NaiveEntropy,
+		Entropy:      report.Entropy,
+		Score:        report.Score,
+		Label:        report.Label,
+		Suggestions:  report.Suggestions,
+	}
+	for _, m := range report.Matches {
+		result.Patterns = append(result.Patterns, passwordgen.Pattern{Kind: m.Kind, Message: m.Message})
+	}
+	for _, c := range report.CrackTimes {
+		result.CrackTimes = append(result.CrackTimes, passwordgen.CrackTime{Scenario: c.Scenario, Time: c.Time})
+	}
+	return passwordgen.Strength(result).Render(r.Context(), w)
+}
+
+func generateCount(r *http.Request) (int, error) {
+	count, err := formInt(r, "count", 1)
+	if err != nil {
+		return 0, err
+	}
+	if count < 1 || count > passgen.MaxCount {
+		return 0, fmt.Errorf("count must be between 1 and %d", passgen.MaxCount)
+	}
+	return count, nil
+}
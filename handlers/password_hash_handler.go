@@ -0,0 +1,179 @@
+// handlers/password_hash_handler.go
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ndeta100/orbit2x/internal/passhash"
+	"github.com/Ndeta100/orbit2x/views/hashgen"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// HandlePasswordHash hashes a password with Argon2id, scrypt, PBKDF2 or
+// bcrypt using the parameters from the form
+func HandlePasswordHash(w http.ResponseWriter, r *http.Request) error {
+	// Parse form data
+	if err := r.ParseForm(); err != nil {
+		return hashgen.PasswordResults(hashgen.PasswordHashResult{
+			Error: "Failed to parse form data",
+		}).Render(r.Context(), w)
+	}
+
+	password := r.FormValue("password")
+	if password == "" {
+		return hashgen.PasswordResults(hashgen.PasswordHashResult{
+			Error: "Password is required",
+		}).Render(r.Context(), w)
+	}
+
+	start := time.Now()
+	encoded, err := hashPassword([]byte(password), r)
+	if err != nil {
+		return hashgen.PasswordResults(hashgen.PasswordHashResult{
+			Error: err.Error(),
+		}).Render(r.Context(), w)
+	}
+	elapsed := time.Since(start)
+
+	parsed, err := passhash.Parse(encoded)
+	if err != nil {
+		return hashgen.PasswordResults(hashgen.PasswordHashResult{
+			Error: err.Error(),
+		}).Render(r.Context(), w)
+	}
+
+	result := hashgen.PasswordHashResult{
+		Algorithm: parsed.Algorithm,
+		Encoded:   encoded,
+		Params:    passwordParams(parsed),
+		Duration:  elapsed.Round(time.Millisecond).String(),
+	}
+
+	return hashgen.PasswordResults(result).Render(r.Context(), w)
+}
+
+// HandlePasswordVerify checks a password against a stored hash, reading the
+// algorithm and parameters from the hash itself
+func HandlePasswordVerify(w http.ResponseWriter, r *http.Request) error {
+	// Parse form data
+	if err := r.ParseForm(); err != nil {
+		return hashgen.PasswordResults(hashgen.PasswordHashResult{
+			Error: "Failed to parse form data",
+		}).Render(r.Context(), w)
+	}
+
+	password := r.FormValue("password")
+	encoded := strings.TrimSpace(r.FormValue("hash"))
+	if password == "" || encoded == "" {
+		return hashgen.PasswordResults(hashgen.PasswordHashResult{
+			Error: "Password and hash are both required",
+		}).Render(r.Context(), w)
+	}
+
+	start := time.Now()
+	matches, parsed, err := passhash.Verify([]byte(password), encoded)
+	if err != nil {
+		return hashgen.PasswordResults(hashgen.PasswordHashResult{
+			Error: fmt.Sprintf("Could not read hash: %v", err),
+		}).Render(r.Context(), w)
+	}
+
+	result := hashgen.PasswordHashResult{
+		Algorithm: parsed.Algorithm,
+		Encoded:   encoded,
+		Params:    passwordParams(parsed),
+		Duration:  time.Since(start).Round(time.Millisecond).String(),
+		IsVerify:  true,
+		Matches:   matches,
+	}
+
+	return hashgen.PasswordResults(result).Render(r.Context(), w)
+}
+
+// hashPassword dispatches on the selected algorithm, falling back to the
+// recommended defaults for any parameter left blank
+func hashPassword(password []byte, r *http.Request) (string, error) {
+	var errs []error
+	field := func(name string, def int) int {
+		v, err := formInt(r, name, def)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		return v
+	}
+
+	switch r.FormValue("algorithm") {
+	case "", "argon2id":
+		p := passhash.Argon2Params{
+			Memory:      field("memory", passhash.DefaultArgon2.Memory),
+			Iterations:  field("iterations", passhash.DefaultArgon2.Iterations),
+			Parallelism: field("parallelism", passhash.DefaultArgon2.Parallelism),
+			SaltLength:  field("salt_length", passhash.DefaultArgon2.SaltLength),
+			KeyLength:   field("key_length", passhash.DefaultArgon2.KeyLength),
+		}
+		if len(errs) > 0 {
+			return "", errs[0]
+		}
+		return passhash.HashArgon2id(password, p)
+	case "scrypt":
+		p := passhash.ScryptParams{
+			LogN:       field("scrypt_ln", passhash.DefaultScrypt.LogN),
+			R:          field("scrypt_r", passhash.DefaultScrypt.R),
+			P:          field("scrypt_p", passhash.DefaultScrypt.P),
+			SaltLength: field("salt_length", passhash.DefaultScrypt.SaltLength),
+			KeyLength:  field("key_length", passhash.DefaultScrypt.KeyLength),
+		}
+		if len(errs) > 0 {
+			return "", errs[0]
+		}
+		return passhash.HashScrypt(password, p)
+	case "pbkdf2":
+		p := passhash.PBKDF2Params{
+			Digest:     r.FormValue("pbkdf2_digest"),
+			Iterations: field("pbkdf2_iterations", passhash.DefaultPBKDF2.Iterations),
+			SaltLength: field("salt_length", passhash.DefaultPBKDF2.SaltLength),
+			KeyLength:  field("key_length", passhash.DefaultPBKDF2.KeyLength),
+		}
+		if p.Digest == "" {
+			p.Digest = passhash.DefaultPBKDF2.Digest
+		}
+		if len(errs) > 0 {
+			return "", errs[0]
+		}
+		return passhash.HashPBKDF2(password, p)
+	case "bcrypt":
+		cost := field("bcrypt_cost", bcrypt.DefaultCost)
+		if len(errs) > 0 {
+			return "", errs[0]
+		}
+		return passhash.HashBcrypt(password, cost)
+	default:
+		return "", fmt.Errorf("Unsupported password hashing algorithm")
+	}
+}
+
+// passwordParams converts parsed hash parameters for the results view
+func passwordParams(h *passhash.Hash) []hashgen.PasswordParam {
+	params := make([]hashgen.PasswordParam, len(h.Params))
+	for i, p := range h.Params {
+		params[i] = hashgen.PasswordParam{Name: p.Name, Value: p.Value}
+	}
+	return params
+}
+
+// formInt reads an integer form field, returning def when it is blank
+func formInt(r *http.Request, name string, def int) (int, error) {
+	value := strings.TrimSpace(r.FormValue(name))
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a whole number", strings.ReplaceAll(name, "_", " "))
+	}
+	return n, nil
+}
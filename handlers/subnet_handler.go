@@ -3,10 +3,12 @@ package handlers
 
 import (
 	"fmt"
-	"math"
 	"net"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"github.com/Ndeta100/orbit2x/internal/ipcalc"
 	"github.com/Ndeta100/orbit2x/views/subnet" // Adjust to your actual path
 )
 
@@ -79,19 +81,36 @@ func HandleSubnetCalculateMask(w http.ResponseWriter, r *http.Request) error {
 		}).Render(r.Context(), w)
 	}
 
-	// Parse subnet mask
-	mask := net.ParseIP(subnetMask)
-	if mask == nil {
+	// IPv6 masks are given as a prefix length or in address form
+	if ip.To4() == nil {
+		ipv6Mask, err := parseIPv6Mask(subnetMask)
+		if err != nil {
+			return subnet.SubnetResults(subnet.SubnetResult{
+				Error: err.Error(),
+			}).Render(r.Context(), w)
+		}
+
+		result, err := calculateSubnet(ip, &net.IPNet{IP: ip.Mask(ipv6Mask), Mask: ipv6Mask})
+		if err != nil {
+			return subnet.SubnetResults(subnet.SubnetResult{
+				Error: fmt.Sprintf("Calculation error: %v", err),
+			}).Render(r.Context(), w)
+		}
+		return subnet.SubnetResults(result).Render(r.Context(), w)
+	}
+
+	// Parse subnet mask, accepting netmasks, wildcard masks and prefix lengths
+	prefix, _, err := ipcalc.ParseIPv4Mask(subnetMask)
+	if err != nil {
 		return subnet.SubnetResults(subnet.SubnetResult{
-			Error: "Invalid subnet mask format",
+			Error: fmt.Sprintf("Invalid subnet mask: %v", err),
 		}).Render(r.Context(), w)
 	}
 
 	// Convert mask to IPNet
-	ipv4Mask := net.IPv4Mask(mask[12], mask[13], mask[14], mask[15])
-	//cidr, _ := net.IPv4Mask(mask[12], mask[13], mask[14], mask[15]).Size()
+	ipv4Mask := net.CIDRMask(prefix, 32)
 	ipNet := &net.IPNet{
-		IP:   ip.Mask(ipv4Mask),
+		IP:   ip.To4().Mask(ipv4Mask),
 		Mask: ipv4Mask,
 	}
 
@@ -109,71 +128,180 @@ func HandleSubnetCalculateMask(w http.ResponseWriter, r *http.Request) error {
 
 // calculateSubnet performs the subnet calculations
 func calculateSubnet(ip net.IP, ipNet *net.IPNet) (subnet.SubnetResult, error) {
-	// Ensure we're working with IPv4
-	ip = ip.To4()
-	if ip == nil {
-		return subnet.SubnetResult{}, fmt.Errorf("only IPv4 addresses are supported")
+	// IPv6 networks have no broadcast address and need big integer host counts
+	if ip.To4() == nil {
+		return calculateSubnetIPv6(ip, ipNet)
 	}
 
-	// Get network and broadcast addresses
-	networkIP := ipNet.IP.To4()
+	maskSize, bits := ipNet.Mask.Size()
+	if bits == 0 {
+		return subnet.SubnetResult{}, fmt.Errorf("subnet mask must be contiguous")
+	}
+	// IPv4-mapped addresses carry a 128-bit mask (e.g. ::ffff:10.0.0.0/104)
+	if bits == 128 {
+		maskSize -= 96
+	}
 
-	// Calculate broadcast address (network address OR NOT subnet mask)
-	mask := ipNet.Mask
-	broadcastIP := make(net.IP, len(networkIP))
-	for i := 0; i < len(networkIP); i++ {
-		broadcastIP[i] = networkIP[i] | ^mask[i]
+	details, err := ipcalc.CalculateIPv4(ip, maskSize)
+	if err != nil {
+		return subnet.SubnetResult{}, err
+	}
+
+	// Create result
+	result := subnet.SubnetResult{
+		NetworkAddress:   details.Network.Dotted,
+		BroadcastAddress: details.Broadcast.Dotted,
+		FirstUsableIP:    details.FirstUsable.Dotted,
+		LastUsableIP:     details.LastUsable.Dotted,
+		TotalHosts:       details.TotalHosts,
+		UsableHosts:      details.UsableHosts,
+		SubnetMaskDec:    details.Mask.Dotted,
+		SubnetMaskBin:    details.Mask.Binary,
+		CIDR:             details.PrefixLength,
+		IPVersion:        4,
+		IPAddress:        details.Address.Dotted,
+		WildcardMask:     details.Wildcard.Dotted,
+		IPClass:          details.Class,
+		IsPrivate:        details.Private,
+		IsPublic:         details.Public,
+		AddressScope:     details.Scope,
+		AddressForms: []subnet.AddressForm{
+			addressForm("IP Address", details.Address),
+			addressForm("Network", details.Network),
+			addressForm("Broadcast", details.Broadcast),
+			addressForm("First Usable", details.FirstUsable),
+			addressForm("Last Usable", details.LastUsable),
+			addressForm("Subnet Mask", details.Mask),
+			addressForm("Wildcard Mask", details.Wildcard),
+		},
 	}
 
-	// Calculate first and last usable IP addresses
-	firstUsableIP := make(net.IP, len(networkIP))
-	lastUsableIP := make(net.IP, len(broadcastIP))
+	return result, nil
+}
 
-	copy(firstUsableIP, networkIP)
-	copy(lastUsableIP, broadcastIP)
+// addressForm converts the forms of an address for the results view
+func addressForm(label string, forms ipcalc.AddressForms) subnet.AddressForm {
+	return subnet.AddressForm{
+		Label:   label,
+		Dotted:  forms.Dotted,
+		Hex:     forms.Hex,
+		Binary:  forms.Binary,
+		Integer: strconv.FormatUint(uint64(forms.Integer), 10),
+	}
+}
 
-	// For most subnets, first usable IP is network address + 1,
-	// except for /31 and /32 subnets which have special rules
-	maskSize, _ := mask.Size()
-	if maskSize < 31 {
-		firstUsableIP[3]++
-		lastUsableIP[3]--
+// calculateSubnetIPv6 performs the subnet calculations for IPv6 networks
+func calculateSubnetIPv6(ip net.IP, ipNet *net.IPNet) (subnet.SubnetResult, error) {
+	details, err := ipcalc.CalculateIPv6(ip, ipNet)
+	if err != nil {
+		return subnet.SubnetResult{}, err
 	}
 
-	// Calculate total hosts and usable hosts
-	totalHosts := math.Pow(2, float64(32-maskSize))
-	usableHosts := totalHosts - 2
+	return subnet.SubnetResult{
+		IPVersion:       6,
+		IPAddress:       details.Address,
+		IPExpanded:      details.AddressExpanded,
+		NetworkAddress:  details.Network,
+		NetworkExpanded: details.NetworkExpanded,
+		FirstUsableIP:   details.Network,
+		LastUsableIP:    details.LastAddress,
+		LastAddress:     details.LastAddress,
+		LastExpanded:    details.LastAddressExpanded,
+		TotalAddresses:  details.TotalAddresses.String(),
+		SubnetMaskDec:   net.IP(ipNet.Mask).String(),
+		CIDR:            details.PrefixLength,
+		ReverseZone:     details.ReverseZone,
+		PTRName:         details.PTRName,
+	}, nil
+}
 
-	// Special cases for /31 (point-to-point) and /32 (single host) networks
-	if maskSize == 31 {
-		usableHosts = 2 // RFC 3021 - No network or broadcast addresses in /31
-	} else if maskSize == 32 {
-		usableHosts = 1 // Single host
+// parseIPv6Mask accepts a prefix length ("64" or "/64") or a mask written
+// as an IPv6 address ("ffff:ffff:ffff:ffff::")
+func parseIPv6Mask(s string) (net.IPMask, error) {
+	s = strings.TrimSpace(s)
+	if prefix, err := strconv.Atoi(strings.TrimPrefix(s, "/")); err == nil {
+		if prefix < 0 || prefix > 128 {
+			return nil, fmt.Errorf("IPv6 prefix length must be between 0 and 128")
+		}
+		return net.CIDRMask(prefix, 128), nil
 	}
 
-	if usableHosts < 0 {
-		usableHosts = 0
+	maskIP := net.ParseIP(s)
+	if maskIP == nil || maskIP.To4() != nil {
+		return nil, fmt.Errorf("Invalid IPv6 subnet mask format")
 	}
 
-	// Convert subnet mask to binary
-	maskBinary := ""
-	for i := 0; i < len(mask); i++ {
-		maskBinary += fmt.Sprintf("%08b.", mask[i])
+	mask := net.IPMask(maskIP.To16())
+	if _, bits := mask.Size(); bits == 0 {
+		return nil, fmt.Errorf("IPv6 subnet mask must be contiguous")
 	}
-	maskBinary = maskBinary[:len(maskBinary)-1] // Remove trailing dot
+	return mask, nil
+}
 
-	// Create result
-	result := subnet.SubnetResult{
-		NetworkAddress:   networkIP.String(),
-		BroadcastAddress: broadcastIP.String(),
-		FirstUsableIP:    firstUsableIP.String(),
-		LastUsableIP:     lastUsableIP.String(),
-		TotalHosts:       int(totalHosts),
-		UsableHosts:      int(usableHosts),
-		SubnetMaskDec:    net.IP(mask).String(),
-		SubnetMaskBin:    maskBinary,
-		CIDR:             maskSize,
+// HandleSubnetEUI64 derives an IPv6 address from a MAC address using modified EUI-64
+func HandleSubnetEUI64(w http.ResponseWriter, r *http.Request) error {
+	// Parse form data
+	if err := r.ParseForm(); err != nil {
+		return subnet.EUI64Results(subnet.EUI64Result{
+			Error: "Failed to parse form data",
+		}).Render(r.Context(), w)
 	}
 
-	return result, nil
+	macAddress := strings.TrimSpace(r.FormValue("mac"))
+	if macAddress == "" {
+		return subnet.EUI64Results(subnet.EUI64Result{
+			Error: "MAC address is required",
+		}).Render(r.Context(), w)
+	}
+
+	// Default to the link-local prefix when none is given
+	prefix := strings.TrimSpace(r.FormValue("prefix"))
+	if prefix == "" {
+		prefix = "fe80::/64"
+	}
+
+	mac, err := net.ParseMAC(macAddress)
+	if err != nil {
+		return subnet.EUI64Results(subnet.EUI64Result{
+			Error: fmt.Sprintf("Invalid MAC address: %v", err),
+		}).Render(r.Context(), w)
+	}
+
+	_, prefixNet, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return subnet.EUI64Results(subnet.EUI64Result{
+			Error: fmt.Sprintf("Invalid IPv6 prefix: %v", err),
+		}).Render(r.Context(), w)
+	}
+
+	interfaceID, err := ipcalc.EUI64InterfaceID(mac)
+	if err != nil {
+		return subnet.EUI64Results(subnet.EUI64Result{
+			Error: err.Error(),
+		}).Render(r.Context(), w)
+	}
+
+	address, err := ipcalc.EUI64Address(prefixNet, mac)
+	if err != nil {
+		return subnet.EUI64Results(subnet.EUI64Result{
+			Error: err.Error(),
+		}).Render(r.Context(), w)
+	}
+
+	// Format the interface identifier as four hex groups
+	groups := make([]string, 4)
+	for i := range groups {
+		groups[i] = fmt.Sprintf("%02x%02x", interfaceID[i*2], interfaceID[i*2+1])
+	}
+
+	result := subnet.EUI64Result{
+		MAC:             mac.String(),
+		Prefix:          prefixNet.String(),
+		InterfaceID:     strings.Join(groups, ":"),
+		Address:         address.String(),
+		AddressExpanded: ipcalc.ExpandIPv6(address),
+		PTRName:         ipcalc.PTRNameIPv6(address),
+	}
+
+	return subnet.EUI64Results(result).Render(r.Context(), w)
 }
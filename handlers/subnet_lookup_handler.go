@@ -0,0 +1,245 @@
+// handlers/subnet_lookup_handler.go
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Ndeta100/orbit2x/internal/ipcalc"
+	"github.com/Ndeta100/orbit2x/views/subnet"
+)
+
+const (
+	defaultHostPageSize = 256
+	maxHostPageSize     = 1024
+	// maxExportHosts caps CSV exports so an IPv6 /64 cannot stream forever
+	maxExportHosts = 1 << 24
+)
+
+// HandleSubnetContains checks which entries of a CIDR list contain an IP address
+func HandleSubnetContains(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return subnet.SetResults(subnet.SetResult{
+			Error: "Failed to parse form data",
+		}).Render(r.Context(), w)
+	}
+
+	ip := net.ParseIP(strings.TrimSpace(r.FormValue("ip")))
+	if ip == nil {
+		return subnet.SetResults(subnet.SetResult{
+			Error: "A valid IP address is required",
+		}).Render(r.Context(), w)
+	}
+
+	nets, err := parseCIDRField(r.FormValue("cidrs"), "CIDR list")
+	if err != nil {
+		return subnet.SetResults(subnet.SetResult{
+			Error: err.Error(),
+		}).Render(r.Context(), w)
+	}
+
+	matches := ipcalc.Matching(nets, ip)
+	summary := fmt.Sprintf("%s is not in any of the %d entries", ip, len(nets))
+	if len(matches) > 0 {
+		summary = fmt.Sprintf("%s matches %d of %d entries", ip, len(matches), len(nets))
+	}
+
+	result := subnet.SetResult{
+		Title:   "Membership Check",
+		Summary: summary,
+		CIDRs:   cidrStrings(matches),
+	}
+
+	return subnet.SetResults(result).Render(r.Context(), w)
+}
+
+// HandleSubnetHosts lists the usable addresses of a subnet one page at a time
+func HandleSubnetHosts(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return subnet.HostList(subnet.HostListResult{
+			Error: "Failed to parse form data",
+		}).Render(r.Context(), w)
+	}
+
+	_, ipNet, err := net.ParseCIDR(strings.TrimSpace(r.FormValue("cidr")))
+	if err != nil {
+		return subnet.HostList(subnet.HostListResult{
+			Error: fmt.Sprintf("Invalid CIDR notation: %v", err),
+		}).Render(r.Context(), w)
+	}
+
+	pageSize := defaultHostPageSize
+	if v, err := strconv.Atoi(r.FormValue("page_size")); err == nil && v > 0 {
+		pageSize = min(v, maxHostPageSize)
+	}
+
+	// Pages are big integers because an IPv6 subnet can have 2^64 pages
+	page, ok := new(big.Int).SetString(strings.TrimSpace(r.FormValue("page")), 10)
+	if !ok || page.Sign() <= 0 {
+		page = big.NewInt(1)
+	}
+
+	total := ipcalc.UsableCount(ipNet)
+	size := big.NewInt(int64(pageSize))
+	totalPages := new(big.Int).Add(total, big.NewInt(int64(pageSize-1)))
+	totalPages.Div(totalPages, size)
+	if page.Cmp(totalPages) > 0 {
+		page.Set(totalPages)
+	}
+
+	offset := new(big.Int).Sub(page, big.NewInt(1))
+	offset.Mul(offset, size)
+
+	result := subnet.HostListResult{
+		CIDR:       ipNet.String(),
+		Total:      total.String(),
+		Page:       page.String(),
+		TotalPages: totalPages.String(),
+		PageSize:   pageSize,
+		StartIndex: offset.String(),
+		HasPrev:    page.Cmp(big.NewInt(1)) > 0,
+		HasNext:    page.Cmp(totalPages) < 0,
+		PrevPage:   new(big.Int).Sub(page, big.NewInt(1)).String(),
+		NextPage:   new(big.Int).Add(page, big.NewInt(1)).String(),
+	}
+	for _, host := range ipcalc.Hosts(ipNet, offset, pageSize) {
+		result.Addresses = append(result.Addresses, host.String())
+	}
+
+	return subnet.HostList(result).Render(r.Context(), w)
+}
+
+// HandleSubnetHostsExport streams the usable addresses of a subnet as CSV
+func HandleSubnetHostsExport(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Failed to parse form data", http.StatusBadRequest)
+		return nil
+	}
+
+	_, ipNet, err := net.ParseCIDR(strings.TrimSpace(r.FormValue("cidr")))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid CIDR notation: %v", err), http.StatusBadRequest)
+		return nil
+	}
+
+	filename := strings.NewReplacer("/", "_", ":", "-").Replace(ipNet.String())
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="hosts-%s.csv"`, filename))
+
+	flusher, _ := w.(http.Flusher)
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"index", "address"})
+
+	ipcalc.EachHost(ipNet, maxExportHosts, func(index uint64, ip net.IP) bool {
+		writer.Write([]string{strconv.FormatUint(index, 10), ip.String()})
+
+		// Flush periodically so large ranges stream instead of buffering
+		if index%4096 == 4095 {
+			writer.Flush()
+			if flusher != nil {
+				flusher.Flush()
+			}
+			return r.Context().Err() == nil && writer.Error() == nil
+		}
+		return true
+	})
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// HandleSubnetAddressAt looks up the usable address at an index inside a subnet
+func HandleSubnetAddressAt(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return subnet.LookupResults(subnet.LookupResult{
+			Error: "Failed to parse form data",
+		}).Render(r.Context(), w)
+	}
+
+	_, ipNet, err := net.ParseCIDR(strings.TrimSpace(r.FormValue("cidr")))
+	if err != nil {
+		return subnet.LookupResults(subnet.LookupResult{
+			Error: fmt.Sprintf("Invalid CIDR notation: %v", err),
+		}).Render(r.Context(), w)
+	}
+
+	index, ok := new(big.Int).SetString(strings.TrimSpace(r.FormValue("index")), 10)
+	if !ok {
+		return subnet.LookupResults(subnet.LookupResult{
+			Error: "Index must be a whole number (negative values count from the end)",
+		}).Render(r.Context(), w)
+	}
+
+	ip, err := ipcalc.AddressAt(ipNet, index)
+	if err != nil {
+		return subnet.LookupResults(subnet.LookupResult{
+			Error: fmt.Sprintf("%v (%s has %s usable addresses)", err, ipNet, ipcalc.UsableCount(ipNet)),
+		}).Render(r.Context(), w)
+	}
+
+	result := subnet.LookupResult{
+		Title: fmt.Sprintf("Address %s of %s", index, ipNet),
+		Rows: []subnet.LookupRow{
+			{Label: "Address", Value: ip.String()},
+			{Label: "Usable Addresses", Value: ipcalc.UsableCount(ipNet).String()},
+		},
+	}
+
+	return subnet.LookupResults(result).Render(r.Context(), w)
+}
+
+// HandleSubnetNextIP returns the addresses before and after an IP address
+func HandleSubnetNextIP(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return subnet.LookupResults(subnet.LookupResult{
+			Error: "Failed to parse form data",
+		}).Render(r.Context(), w)
+	}
+
+	ip := net.ParseIP(strings.TrimSpace(r.FormValue("ip")))
+	if ip == nil {
+		return subnet.LookupResults(subnet.LookupResult{
+			Error: "A valid IP address is required",
+		}).Render(r.Context(), w)
+	}
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+
+	result := subnet.LookupResult{
+		Title: fmt.Sprintf("Neighbours of %s", ip),
+	}
+	if next, err := ipcalc.NextIP(ip); err == nil {
+		result.Rows = append(result.Rows, subnet.LookupRow{Label: "Next Address", Value: next.String()})
+	} else {
+		result.Rows = append(result.Rows, subnet.LookupRow{Label: "Next Address", Value: err.Error()})
+	}
+	if prev, err := ipcalc.PrevIP(ip); err == nil {
+		result.Rows = append(result.Rows, subnet.LookupRow{Label: "Previous Address", Value: prev.String()})
+	} else {
+		result.Rows = append(result.Rows, subnet.LookupRow{Label: "Previous Address", Value: err.Error()})
+	}
+
+	// Optionally report the position inside a subnet
+	if cidr := strings.TrimSpace(r.FormValue("cidr")); cidr != "" {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return subnet.LookupResults(subnet.LookupResult{
+				Error: fmt.Sprintf("Invalid CIDR notation: %v", err),
+			}).Render(r.Context(), w)
+		}
+
+		if index, err := ipcalc.IndexOf(ipNet, ip); err == nil {
+			result.Rows = append(result.Rows, subnet.LookupRow{Label: "Index in " + ipNet.String(), Value: index.String()})
+		} else {
+			result.Rows = append(result.Rows, subnet.LookupRow{Label: "Index in " + ipNet.String(), Value: err.Error()})
+		}
+	}
+
+	return subnet.LookupResults(result).Render(r.Context(), w)
+}
@@ -0,0 +1,249 @@
+// handlers/subnet_plan_handler.go
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Ndeta100/orbit2x/internal/ipcalc"
+	"github.com/Ndeta100/orbit2x/views/subnet"
+)
+
+// planExportRow is a single allocated subnet in the JSON export
+type planExportRow struct {
+	Name        string `json:"name"`
+	Requested   string `json:"requested"`
+	CIDR        string `json:"cidr"`
+	Network     string `json:"network"`
+	FirstUsable string `json:"first_usable"`
+	LastUsable  string `json:"last_usable"`
+	Broadcast   string `json:"broadcast,omitempty"`
+	UsableHosts string `json:"usable_hosts"`
+}
+
+// planExport is the JSON document produced by the plan export
+type planExport struct {
+	Parent  string          `json:"parent"`
+	Subnets []planExportRow `json:"subnets"`
+	Free    []string        `json:"free"`
+}
+
+// HandleSubnetPlan allocates subnets for a list of requirements inside a parent block
+func HandleSubnetPlan(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return subnet.PlanResults(subnet.PlanResult{
+			Error: "Failed to parse form data",
+		}).Render(r.Context(), w)
+	}
+
+	result, err := buildSubnetPlan(r.FormValue("parent"), r.FormValue("requirements"))
+	if err != nil {
+		return subnet.PlanResults(subnet.PlanResult{
+			Error: err.Error(),
+		}).Render(r.Context(), w)
+	}
+
+	return subnet.PlanResults(result).Render(r.Context(), w)
+}
+
+// HandleSubnetPlanExport returns the subnet plan as a CSV or JSON download
+func HandleSubnetPlanExport(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Failed to parse form data", http.StatusBadRequest)
+		return nil
+	}
+
+	result, err := buildSubnetPlan(r.FormValue("parent"), r.FormValue("requirements"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+
+	switch r.FormValue("format") {
+	case "json":
+		export := planExport{Parent: result.Parent, Free: result.Free}
+		for _, row := range result.Rows {
+			export.Subnets = append(export.Subnets, planExportRow(row))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="subnet-plan.json"`)
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(export)
+	case "csv", "":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="subnet-plan.csv"`)
+
+		writer := csv.NewWriter(w)
+		writer.Write([]string{"name", "requested", "cidr", "network", "first_usable", "last_usable", "broadcast", "usable_hosts"})
+		for _, row := range result.Rows {
+			writer.Write([]string{row.Name, row.Requested, row.CIDR, row.Network, row.FirstUsable, row.LastUsable, row.Broadcast, row.UsableHosts})
+		}
+		for _, free := range result.Free {
+			writer.Write([]string{"(free)", "", free, "", "", "", "", ""})
+		}
+		writer.Flush()
+		return writer.Error()
+	default:
+		http.Error(w, "Unsupported export format", http.StatusBadRequest)
+		return nil
+	}
+}
+
+// HandleSubnetSplit divides a network into equal subnets of a longer prefix
+func HandleSubnetSplit(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return subnet.PlanResults(subnet.PlanResult{
+			Error: "Failed to parse form data",
+		}).Render(r.Context(), w)
+	}
+
+	_, parent, err := net.ParseCIDR(strings.TrimSpace(r.FormValue("cidr")))
+	if err != nil {
+		return subnet.PlanResults(subnet.PlanResult{
+			Error: fmt.Sprintf("Invalid CIDR notation: %v", err),
+		}).Render(r.Context(), w)
+	}
+
+	newPrefix, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(r.FormValue("prefix")), "/"))
+	if err != nil {
+		return subnet.PlanResults(subnet.PlanResult{
+			Error: "New prefix length must be a number",
+		}).Render(r.Context(), w)
+	}
+
+	subnets, err := ipcalc.Split(parent, newPrefix)
+	if err != nil {
+		return subnet.PlanResults(subnet.PlanResult{
+			Error: err.Error(),
+		}).Render(r.Context(), w)
+	}
+
+	result := subnet.PlanResult{Parent: parent.String()}
+	for i, ipNet := range subnets {
+		row, err := planRow(fmt.Sprintf("Subnet %d", i+1), fmt.Sprintf("/%d", newPrefix), ipNet)
+		if err != nil {
+			return subnet.PlanResults(subnet.PlanResult{
+				Error: fmt.Sprintf("Calculation error: %v", err),
+			}).Render(r.Context(), w)
+		}
+		result.Rows = append(result.Rows, row)
+	}
+
+	return subnet.PlanResults(result).Render(r.Context(), w)
+}
+
+// buildSubnetPlan parses the planner form values and runs the allocation
+func buildSubnetPlan(parentCIDR, requirementsText string) (subnet.PlanResult, error) {
+	parentCIDR = strings.TrimSpace(parentCIDR)
+	if parentCIDR == "" {
+		return subnet.PlanResult{}, fmt.Errorf("Parent block is required")
+	}
+
+	_, parent, err := net.ParseCIDR(parentCIDR)
+	if err != nil {
+		return subnet.PlanResult{}, fmt.Errorf("Invalid parent CIDR notation: %v", err)
+	}
+
+	reqs, err := parseRequirements(requirementsText)
+	if err != nil {
+		return subnet.PlanResult{}, err
+	}
+
+	plan, err := ipcalc.PlanVLSM(parent, reqs)
+	if err != nil {
+		return subnet.PlanResult{}, err
+	}
+
+	result := subnet.PlanResult{
+		Parent:       plan.Parent,
+		Requirements: requirementsText,
+		Free:         plan.Free,
+	}
+	for _, alloc := range plan.Allocations {
+		requested := fmt.Sprintf("/%d", alloc.Requirement.Prefix)
+		if alloc.Requirement.Hosts > 0 {
+			requested = fmt.Sprintf("%d hosts", alloc.Requirement.Hosts)
+		}
+
+		row, err := planRow(alloc.Requirement.Name, requested, alloc.Network)
+		if err != nil {
+			return subnet.PlanResult{}, fmt.Errorf("Calculation error: %v", err)
+		}
+		result.Rows = append(result.Rows, row)
+	}
+
+	return result, nil
+}
+
+// planRow describes an allocated subnet using calculateSubnet
+func planRow(name, requested string, ipNet *net.IPNet) (subnet.PlanRow, error) {
+	details, err := calculateSubnet(ipNet.IP, ipNet)
+	if err != nil {
+		return subnet.PlanRow{}, err
+	}
+
+	usable := strconv.FormatUint(details.UsableHosts, 10)
+	if details.IPVersion == 6 {
+		usable = details.TotalAddresses
+	}
+
+	return subnet.PlanRow{
+		Name:        name,
+		Requested:   requested,
+		CIDR:        ipNet.String(),
+		Network:     details.NetworkAddress,
+		FirstUsable: details.FirstUsableIP,
+		LastUsable:  details.LastUsableIP,
+		Broadcast:   details.BroadcastAddress,
+		UsableHosts: usable,
+	}, nil
+}
+
+// parseRequirements reads one requirement per line in the form
+// "[name] <hosts>" or "[name] /<prefix>"
+func parseRequirements(text string) ([]ipcalc.Requirement, error) {
+	var reqs []ipcalc.Requirement
+
+	for i, line := range strings.Split(text, "\n") {
+		fields := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t' || r == '\r'
+		})
+		if len(fields) == 0 {
+			continue
+		}
+
+		req := ipcalc.Requirement{Name: fmt.Sprintf("Subnet %d", len(reqs)+1)}
+		if len(fields) > 1 {
+			req.Name = strings.Join(fields[:len(fields)-1], " ")
+		}
+
+		size := fields[len(fields)-1]
+		if strings.HasPrefix(size, "/") {
+			prefix, err := strconv.Atoi(size[1:])
+			if err != nil || prefix <= 0 {
+				return nil, fmt.Errorf("Line %d: invalid prefix length %q", i+1, size)
+			}
+			req.Prefix = prefix
+		} else {
+			hosts, err := strconv.Atoi(size)
+			if err != nil || hosts <= 0 {
+				return nil, fmt.Errorf("Line %d: expected a host count or /prefix, got %q", i+1, size)
+			}
+			req.Hosts = hosts
+		}
+
+		reqs = append(reqs, req)
+	}
+
+	if len(reqs) == 0 {
+		return nil, fmt.Errorf("At least one subnet requirement is needed")
+	}
+	return reqs, nil
+}
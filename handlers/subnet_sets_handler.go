@@ -0,0 +1,177 @@
+// handlers/subnet_sets_handler.go
+package handlers
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/Ndeta100/orbit2x/internal/ipcalc"
+	"github.com/Ndeta100/orbit2x/views/subnet"
+)
+
+// HandleSubnetAggregate summarises a list of CIDRs into the minimal covering set
+func HandleSubnetAggregate(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return subnet.SetResults(subnet.SetResult{
+			Error: "Failed to parse form data",
+		}).Render(r.Context(), w)
+	}
+
+	nets, err := parseCIDRField(r.FormValue("cidrs"), "CIDR list")
+	if err != nil {
+		return subnet.SetResults(subnet.SetResult{
+			Error: err.Error(),
+		}).Render(r.Context(), w)
+	}
+
+	aggregated := ipcalc.Aggregate(nets)
+	result := subnet.SetResult{
+		Title:    "Aggregated CIDRs",
+		Summary:  fmt.Sprintf("%d entries summarised into %d blocks", len(nets), len(aggregated)),
+		CIDRs:    cidrStrings(aggregated),
+		Overlaps: overlapRows(ipcalc.Duplicates(nets)),
+	}
+
+	return subnet.SetResults(result).Render(r.Context(), w)
+}
+
+// HandleSubnetCompare finds overlapping and duplicate entries between two CIDR lists
+func HandleSubnetCompare(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return subnet.SetResults(subnet.SetResult{
+			Error: "Failed to parse form data",
+		}).Render(r.Context(), w)
+	}
+
+	listA, listB, err := parseCIDRLists(r)
+	if err != nil {
+		return subnet.SetResults(subnet.SetResult{
+			Error: err.Error(),
+		}).Render(r.Context(), w)
+	}
+
+	overlaps := ipcalc.Overlaps(listA, listB)
+	result := subnet.SetResult{
+		Title:    "Overlap Report",
+		Summary:  fmt.Sprintf("%d overlapping pairs between list A (%d entries) and list B (%d entries)", len(overlaps), len(listA), len(listB)),
+		Overlaps: overlapRows(overlaps),
+	}
+
+	return subnet.SetResults(result).Render(r.Context(), w)
+}
+
+// HandleSubnetSubtract removes the addresses of one CIDR list from another
+func HandleSubnetSubtract(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return subnet.SetResults(subnet.SetResult{
+			Error: "Failed to parse form data",
+		}).Render(r.Context(), w)
+	}
+
+	listA, listB, err := parseCIDRLists(r)
+	if err != nil {
+		return subnet.SetResults(subnet.SetResult{
+			Error: err.Error(),
+		}).Render(r.Context(), w)
+	}
+
+	remaining := ipcalc.Subtract(listA, listB)
+	result := subnet.SetResult{
+		Title:   "List A minus List B",
+		Summary: fmt.Sprintf("%d blocks remain", len(remaining)),
+		CIDRs:   cidrStrings(remaining),
+	}
+
+	return subnet.SetResults(result).Render(r.Context(), w)
+}
+
+// HandleSubnetRangeToCIDR converts start-end address ranges into CIDR blocks
+func HandleSubnetRangeToCIDR(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return subnet.SetResults(subnet.SetResult{
+			Error: "Failed to parse form data",
+		}).Render(r.Context(), w)
+	}
+
+	text := strings.TrimSpace(r.FormValue("ranges"))
+	if text == "" {
+		return subnet.SetResults(subnet.SetResult{
+			Error: "At least one address range is required",
+		}).Render(r.Context(), w)
+	}
+
+	var blocks []*net.IPNet
+	ranges := 0
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		start, end, err := ipcalc.ParseRange(line)
+		if err != nil {
+			return subnet.SetResults(subnet.SetResult{
+				Error: fmt.Sprintf("Line %d: %v", i+1, err),
+			}).Render(r.Context(), w)
+		}
+		blocks = append(blocks, ipcalc.RangeToCIDRs(start, end)...)
+		ranges++
+	}
+
+	result := subnet.SetResult{
+		Title:   "CIDR Blocks",
+		Summary: fmt.Sprintf("%d ranges converted into %d blocks", ranges, len(blocks)),
+		CIDRs:   cidrStrings(blocks),
+	}
+
+	return subnet.SetResults(result).Render(r.Context(), w)
+}
+
+// parseCIDRLists reads the list_a and list_b form fields
+func parseCIDRLists(r *http.Request) ([]*net.IPNet, []*net.IPNet, error) {
+	listA, err := parseCIDRField(r.FormValue("list_a"), "List A")
+	if err != nil {
+		return nil, nil, err
+	}
+	listB, err := parseCIDRField(r.FormValue("list_b"), "List B")
+	if err != nil {
+		return nil, nil, err
+	}
+	return listA, listB, nil
+}
+
+// parseCIDRField parses a required CIDR list form value
+func parseCIDRField(text, label string) ([]*net.IPNet, error) {
+	nets, err := ipcalc.ParseCIDRList(text)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", label, err)
+	}
+	if len(nets) == 0 {
+		return nil, fmt.Errorf("%s is required", label)
+	}
+	return nets, nil
+}
+
+// cidrStrings formats networks in CIDR notation
+func cidrStrings(nets []*net.IPNet) []string {
+	out := make([]string, len(nets))
+	for i, n := range nets {
+		out[i] = n.String()
+	}
+	return out
+}
+
+// overlapRows converts overlaps for the results view
+func overlapRows(overlaps []ipcalc.Overlap) []subnet.OverlapRow {
+	rows := make([]subnet.OverlapRow, len(overlaps))
+	for i, o := range overlaps {
+		rows[i] = subnet.OverlapRow{
+			A:        o.A.String(),
+			B:        o.B.String(),
+			Relation: o.Relation,
+		}
+	}
+	return rows
+}
@@ -0,0 +1,96 @@
+package codec
+
+import "fmt"
+
+// maxBase58Size bounds the bytes Base58 encodes, and maxBase58Text the
+// text it decodes, which is what those bytes encode to. The whole input is
+// one number, so the work grows with the square of its length
+const (
+	maxBase58Size = 4 << 10
+	maxBase58Text = maxBase58Size*138/100 + 1
+)
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// base58Index maps alphabet characters back to their values, -1 if absent
+var base58Index = func() [256]int8 {
+	var idx [256]int8
+	for i := range idx {
+		idx[i] = -1
+	}
+	for i := 0; i < len(base58Alphabet); i++ {
+		idx[base58Alphabet[i]] = int8(i)
+	}
+	return idx
+}()
+
+// base58Encode treats data as a big-endian number and writes it in base 58.
+// Each leading zero byte becomes a leading '1'
+func base58Encode(data []byte) (string, error) {
+	if len(data) > maxBase58Size {
+		return "", fmt.Errorf("Base58 input is limited to %d KB", maxBase58Size>>10)
+	}
+	zeros := 0
+	for zeros < len(data) && data[zeros] == 0 {
+		zeros++
+	}
+
+	// log(256)/log(58) ≈ 1.37, so this is always large enough
+	digits := make([]byte, 0, len(data)*138/100+1)
+	for _, b := range data[zeros:] {
+		carry := int(b)
+		for i := range digits {
+			carry += int(digits[i]) << 8
+			digits[i] = byte(carry % 58)
+			carry /= 58
+		}
+		for carry > 0 {
+			digits = append(digits, byte(carry%58))
+			carry /= 58
+		}
+	}
+
+	out := make([]byte, zeros+len(digits))
+	for i := 0; i < zeros; i++ {
+		out[i] = '1'
+	}
+	for i, d := range digits {
+		out[len(out)-1-i] = base58Alphabet[d]
+	}
+	return string(out), nil
+}
+
+// base58Decode reverses base58Encode
+func base58Decode(text string) ([]byte, error) {
+	if len(text) > maxBase58Text {
+		return nil, fmt.Errorf("Base58 text is limited to %d characters", maxBase58Text)
+	}
+	zeros := 0
+	for zeros < len(text) && text[zeros] == '1' {
+		zeros++
+	}
+
+	bytes := make([]byte, 0, len(text))
+	for i := zeros; i < len(text); i++ {
+		v := base58Index[text[i]]
+		if v < 0 {
+			return nil, errorAt(text, i, "illegal Base58 character")
+		}
+		carry := int(v)
+		for j := range bytes {
+			carry += int(bytes[j]) * 58
+			bytes[j] = byte(carry)
+			carry >>= 8
+		}
+		for carry > 0 {
+			bytes = append(bytes, byte(carry))
+			carry >>= 8
+		}
+	}
+
+	out := make([]byte, zeros+len(bytes))
+	for i, b := range bytes {
+		out[len(out)-1-i] = b
+	}
+	return out, nil
+}
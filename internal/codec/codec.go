@@ -0,0 +1,209 @@
+// Package codec implements the reversible text encodings offered by the
+// encoder tool. Every codec runs in both directions and reports decode
+// failures with the byte offset of the offending input
+package codec
+
+import (
+	"encoding/ascii85"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Codec is one encoding scheme
+type Codec struct {
+	ID          string
+	Name        string
+	Description string
+	Encode      func(data []byte) (string, error)
+	Decode      func(text string) ([]byte, error)
+}
+
+// OffsetError reports invalid input at a byte offset
+type OffsetError struct {
+	Offset int
+	Reason string
+}
+
+func (e *OffsetError) Error() string {
+	return fmt.Sprintf("%s at byte offset %d", e.Reason, e.Offset)
+}
+
+// errorAt builds an OffsetError describing the byte at offset
+func errorAt(text string, offset int, reason string) error {
+	if offset < len(text) {
+		return &OffsetError{Offset: offset, Reason: fmt.Sprintf("%s %q", reason, text[offset])}
+	}
+	return &OffsetError{Offset: offset, Reason: reason}
+}
+
+// registry lists the codecs in display order
+var registry = []Codec{
+	{ID: "base64", Name: "Base64", Description: "RFC 4648 standard alphabet with padding",
+		Encode: stdEncode(base64.StdEncoding), Decode: base64Decode(base64.StdEncoding)},
+	{ID: "base64url", Name: "Base64 URL", Description: "URL and filename safe alphabet with padding",
+		Encode: stdEncode(base64.URLEncoding), Decode: base64Decode(base64.URLEncoding)},
+	{ID: "base64raw", Name: "Base64 (no padding)", Description: "Standard alphabet without '=' padding",
+		Encode: stdEncode(base64.RawStdEncoding), Decode: base64Decode(base64.RawStdEncoding)},
+	{ID: "base64rawurl", Name: "Base64 URL (no padding)", Description: "URL safe alphabet without padding, as used by JWTs",
+		Encode: stdEncode(base64.RawURLEncoding), Decode: base64Decode(base64.RawURLEncoding)},
+	{ID: "base32", Name: "Base32", Description: "RFC 4648 Base32 with padding",
+		Encode: stdEncode(base32.StdEncoding), Decode: base32Decode(base32.StdEncoding)},
+	{ID: "base32hex", Name: "Base32 Hex", Description: "RFC 4648 extended hex alphabet",
+		Encode: stdEncode(base32.HexEncoding), Decode: base32Decode(base32.HexEncoding)},
+	{ID: "base58", Name: "Base58", Description: "Bitcoin alphabet, no ambiguous characters",
+		Encode: base58Encode, Decode: base58Decode},
+	{ID: "base85", Name: "Base85 (Ascii85)", Description: "Adobe Ascii85; <~ ~> delimiters are optional when decoding",
+		Encode: ascii85Encode, Decode: ascii85Decode},
+	{ID: "hex", Name: "Hex", Description: "Two lowercase hex digits per byte",
+		Encode: hexEncode, Decode: hexDecode},
+	{ID: "url", Name: "URL (query)", Description: "Percent-encoding for query strings, space as '+'",
+		Encode: queryEncode, Decode: queryDecode},
+	{ID: "urlpath", Name: "URL (path)", Description: "Percent-encoding for path segments, space as %20",
+		Encode: pathEncode, Decode: pathDecode},
+	{ID: "html", Name: "HTML Entities", Description: "Escapes markup characters and non-ASCII as entities",
+		Encode: htmlEncode, Decode: htmlDecode},
+	{ID: "unicode", Name: "Unicode Escapes", Description: "\\uXXXX escapes for non-ASCII, as in JSON and JavaScript",
+		Encode: unicodeEncode, Decode: unicodeDecode},
+	{ID: "quoted-printable", Name: "Quoted-Printable", Description: "RFC 2045 MIME encoding",
+		Encode: qpEncode, Decode: qpDecode},
+	{ID: "punycode", Name: "Punycode (IDNA)", Description: "Internationalised domain names to xn-- labels",
+		Encode: punycodeEncode, Decode: punycodeDecode},
+}
+
+// All returns every codec in display order
+func All() []Codec {
+	return append([]Codec(nil), registry...)
+}
+
+// Lookup finds a codec by ID
+func Lookup(id string) (Codec, bool) {
+	for _, c := range registry {
+		if c.ID == id {
+			return c, true
+		}
+	}
+	return Codec{}, false
+}
+
+// stdEncode adapts the encoding/base64 and encoding/base32 encoders
+func stdEncode(enc interface{ EncodeToString([]byte) string }) func([]byte) (string, error) {
+	return func(data []byte) (string, error) {
+		return enc.EncodeToString(data), nil
+	}
+}
+
+// base64Decode wraps a base64 decoder, turning its corrupt input errors into
+// OffsetErrors
+func base64Decode(enc *base64.Encoding) func(string) ([]byte, error) {
+	return func(text string) ([]byte, error) {
+		decoded, err := enc.DecodeString(text)
+		var corrupt base64.CorruptInputError
+		if errors.As(err, &corrupt) {
+			return nil, corruptAt(text, int(corrupt))
+		}
+		return decoded, err
+	}
+}
+
+// base32Decode wraps a padded base32 decoder like base64Decode. The
+// standard decoder reports offset 0 for truncated input, so length is
+// checked first
+func base32Decode(enc *base32.Encoding) func(string) ([]byte, error) {
+	return func(text string) ([]byte, error) {
+		if len(text)%8 != 0 {
+			return nil, corruptAt(text, len(text))
+		}
+		decoded, err := enc.DecodeString(text)
+		var corrupt base32.CorruptInputError
+		if errors.As(err, &corrupt) {
+			return nil, corruptAt(text, int(corrupt))
+		}
+		return decoded, err
+	}
+}
+
+// corruptAt explains a corrupt input error from the standard decoders,
+// which point past the end when the input is truncated
+func corruptAt(text string, offset int) error {
+	if offset >= len(text) {
+		return &OffsetError{Offset: offset, Reason: "input is truncated or missing padding"}
+	}
+	if text[offset] == '=' {
+		return &OffsetError{Offset: offset, Reason: "unexpected padding"}
+	}
+	return errorAt(text, offset, "illegal character")
+}
+
+func ascii85Encode(data []byte) (string, error) {
+	buf := make([]byte, ascii85.MaxEncodedLen(len(data)))
+	return string(buf[:ascii85.Encode(buf, data)]), nil
+}
+
+func ascii85Decode(text string) ([]byte, error) {
+	// Strip the Adobe delimiters, keeping offsets relative to the input
+	start := 0
+	body := text
+	if strings.HasPrefix(body, "<~") {
+		start = 2
+		body = body[2:]
+	}
+	body = strings.TrimSuffix(body, "~>")
+
+	buf := make([]byte, 4*len(body))
+	n, _, err := ascii85.Decode(buf, []byte(body), true)
+	var corrupt ascii85.CorruptInputError
+	if errors.As(err, &corrupt) {
+		return nil, errorAt(text, start+int(corrupt), "illegal character")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+const hexDigits = "0123456789abcdef"
+
+func hexEncode(data []byte) (string, error) {
+	var b strings.Builder
+	b.Grow(2 * len(data))
+	for _, c := range data {
+		b.WriteByte(hexDigits[c>>4])
+		b.WriteByte(hexDigits[c&0x0f])
+	}
+	return b.String(), nil
+}
+
+func hexDecode(text string) ([]byte, error) {
+	decoded := make([]byte, 0, len(text)/2)
+	for i := 0; i < len(text); i += 2 {
+		hi, ok := unhex(text[i])
+		if !ok {
+			return nil, errorAt(text, i, "invalid hex digit")
+		}
+		if i+1 == len(text) {
+			return nil, &OffsetError{Offset: i, Reason: "odd number of hex digits"}
+		}
+		lo, ok := unhex(text[i+1])
+		if !ok {
+			return nil, errorAt(text, i+1, "invalid hex digit")
+		}
+		decoded = append(decoded, hi<<4|lo)
+	}
+	return decoded, nil
+}
+
+// unhex converts one hex digit of either case
+func unhex(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
@@ -0,0 +1,219 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxLayers bounds how deep SmartDecode will dig
+const maxLayers = 10
+
+// detector recognises one layer of encoding and peels it off. final marks a
+// layer whose output should not be decoded any further
+type detector struct {
+	name  string
+	peel  func(data []byte) ([]byte, bool)
+	final bool
+}
+
+// detectors are tried in order; the first that accepts the input wins.
+// Binary containers come first, then the stricter text alphabets, so hex is
+// preferred over base64 when both would parse
+var detectors = []detector{
+	{name: "Gzip", peel: peelCompressed(gzipMagic, gzipDecompress)},
+	{name: "Zlib", peel: peelCompressed(zlibMagic, zlibDecompress)},
+	{name: "JWT", peel: peelJWT, final: true},
+	{name: "Hex", peel: peelHex},
+	{name: "Base64", peel: peelBase64},
+	{name: "URL (percent-encoding)", peel: peelPercent},
+	{name: "HTML Entities", peel: peelEscapes("&", htmlDecode)},
+	{name: "Unicode Escapes", peel: peelEscapes(`\u`, unicodeDecode)},
+}
+
+// SmartDecode guesses the outermost encoding of input, removes it and
+// repeats until nothing more is recognised. Each peeled layer is returned as
+// a step, so an empty result means the input already looks like plain text
+func SmartDecode(input []byte) []Step {
+	var steps []Step
+	data := bytes.TrimSpace(input)
+	for len(steps) < maxLayers {
+		d, out, ok := detect(data)
+		if !ok {
+			break
+		}
+		steps = append(steps, Step{Name: d.name, Output: out})
+		if d.final {
+			break
+		}
+		data = out
+		if utf8.Valid(data) {
+			data = bytes.TrimSpace(data)
+		}
+	}
+	return steps
+}
+
+func detect(data []byte) (detector, []byte, bool) {
+	for _, d := range detectors {
+		if out, ok := d.peel(data); ok {
+			return d, out, true
+		}
+	}
+	return detector{}, nil, false
+}
+
+func gzipMagic(data []byte) bool {
+	return len(data) > 2 && data[0] == 0x1f && data[1] == 0x8b
+}
+
+// zlibMagic checks the CMF/FLG header: deflate method and a valid check sum
+func zlibMagic(data []byte) bool {
+	return len(data) > 2 && data[0]&0x0f == 8 && data[0]>>4 <= 7 &&
+		(uint16(data[0])<<8|uint16(data[1]))%31 == 0
+}
+
+func peelCompressed(magic func([]byte) bool, decompress func([]byte) ([]byte, error)) func([]byte) ([]byte, bool) {
+	return func(data []byte) ([]byte, bool) {
+		if !magic(data) {
+			return nil, false
+		}
+		out, err := decompress(data)
+		return out, err == nil
+	}
+}
+
+// peelJWT splits a compact JWS and shows its header and payload. The
+// signature cannot be decoded into anything readable, so it is left out
+func peelJWT(data []byte) ([]byte, bool) {
+	parts := strings.Split(string(data), ".")
+	if len(parts) != 3 {
+		return nil, false
+	}
+
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || !json.Valid(header) {
+		return nil, false
+	}
+	var fields struct {
+		Alg *string `json:"alg"`
+	}
+	if json.Unmarshal(header, &fields) != nil || fields.Alg == nil {
+		return nil, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, false
+	}
+
+	var b bytes.Buffer
+	b.WriteString("Header:\n")
+	writeIndentedJSON(&b, header)
+	b.WriteString("\n\nPayload:\n")
+	writeIndentedJSON(&b, payload)
+	return b.Bytes(), true
+}
+
+func writeIndentedJSON(b *bytes.Buffer, data []byte) {
+	if json.Indent(b, data, "", "  ") != nil {
+		b.Write(data)
+	}
+}
+
+// peelHex accepts an even number of hex digits, at least two bytes long,
+// whose decoded form is itself plausible
+func peelHex(data []byte) ([]byte, bool) {
+	if len(data) < 4 || len(data)%2 != 0 {
+		return nil, false
+	}
+	out, err := hexDecode(string(data))
+	if err != nil || !plausible(out) {
+		return nil, false
+	}
+	return out, true
+}
+
+// peelBase64 tries the standard and URL-safe alphabets, with and without
+// padding. Short inputs and ordinary words happen to be valid base64 too,
+// so the decoded bytes must look meaningful before the layer is accepted
+func peelBase64(data []byte) ([]byte, bool) {
+	text := strings.Join(strings.Fields(string(data)), "")
+	if len(text) < 4 {
+		return nil, false
+	}
+
+	encodings := []*base64.Encoding{base64.StdEncoding, base64.URLEncoding}
+	if !strings.HasSuffix(text, "=") {
+		encodings = append(encodings, base64.RawStdEncoding, base64.RawURLEncoding)
+	}
+	for _, enc := range encodings {
+		out, err := enc.DecodeString(text)
+		if err == nil && plausible(out) {
+			return out, true
+		}
+	}
+	return nil, false
+}
+
+// peelPercent decodes text containing at least one valid %XX escape
+func peelPercent(data []byte) ([]byte, bool) {
+	text := string(data)
+	i := strings.IndexByte(text, '%')
+	if i < 0 || i+2 >= len(text) {
+		return nil, false
+	}
+	if _, ok := unhex(text[i+1]); !ok {
+		return nil, false
+	}
+	if _, ok := unhex(text[i+2]); !ok {
+		return nil, false
+	}
+
+	// '+' only means space in query strings, which never contain raw spaces
+	out, err := percentDecode(text, !strings.Contains(text, " "))
+	return out, err == nil
+}
+
+// peelEscapes decodes text containing marker when decoding changes it
+func peelEscapes(marker string, decode func(string) ([]byte, error)) func([]byte) ([]byte, bool) {
+	return func(data []byte) ([]byte, bool) {
+		if !bytes.Contains(data, []byte(marker)) {
+			return nil, false
+		}
+		out, err := decode(string(data))
+		if err != nil || bytes.Equal(out, data) {
+			return nil, false
+		}
+		return out, true
+	}
+}
+
+// plausible reports whether decoded bytes are worth keeping: a compressed
+// stream, a file with a recognised signature, or UTF-8 text that is almost
+// entirely printable
+func plausible(data []byte) bool {
+	if gzipMagic(data) || zlibMagic(data) {
+		return true
+	}
+	if len(data) == 0 {
+		return false
+	}
+	if !utf8.Valid(data) {
+		sniffed := http.DetectContentType(data)
+		return sniffed != "application/octet-stream" && !strings.HasPrefix(sniffed, "text/")
+	}
+
+	printable := 0
+	total := 0
+	for _, r := range string(data) {
+		total++
+		if unicode.IsPrint(r) || unicode.IsSpace(r) {
+			printable++
+		}
+	}
+	return printable*100 >= total*95
+}
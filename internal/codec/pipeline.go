@@ -0,0 +1,161 @@
+package codec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+)
+
+// maxInflatedSize stops a small compressed payload from expanding without
+// bound while being decoded
+const maxInflatedSize = 16 << 20
+
+// maxPipelineOutput bounds the output of all pipeline steps together,
+// since encodings such as hex and HTML entities grow the data every time
+// they are applied and every step's output is kept for display
+const maxPipelineOutput = 32 << 20
+
+// Transform is a reversible step in a pipeline. Forward encodes or
+// compresses; Reverse undoes it
+type Transform struct {
+	ID          string
+	Name        string
+	Description string
+	Forward     func([]byte) ([]byte, error)
+	Reverse     func([]byte) ([]byte, error)
+}
+
+// Step records the output of one pipeline or smart decode stage
+type Step struct {
+	Name   string
+	Output []byte
+}
+
+// compressors are the transforms that are not text codecs
+var compressors = []Transform{
+	{ID: "gzip", Name: "Gzip", Description: "RFC 1952 gzip compression",
+		Forward: gzipCompress, Reverse: gzipDecompress},
+	{ID: "zlib", Name: "Zlib", Description: "RFC 1950 zlib (deflate) compression",
+		Forward: zlibCompress, Reverse: zlibDecompress},
+}
+
+// Transforms returns the compressors followed by every codec
+func Transforms() []Transform {
+	transforms := append([]Transform(nil), compressors...)
+	for _, c := range registry {
+		transforms = append(transforms, codecTransform(c))
+	}
+	return transforms
+}
+
+// LookupTransform finds a transform by ID
+func LookupTransform(id string) (Transform, bool) {
+	for _, t := range Transforms() {
+		if t.ID == id {
+			return t, true
+		}
+	}
+	return Transform{}, false
+}
+
+// codecTransform adapts a text codec to the byte-oriented Transform
+func codecTransform(c Codec) Transform {
+	return Transform{
+		ID:          c.ID,
+		Name:        c.Name,
+		Description: c.Description,
+		Forward: func(data []byte) ([]byte, error) {
+			encoded, err := c.Encode(data)
+			return []byte(encoded), err
+		},
+		Reverse: func(data []byte) ([]byte, error) {
+			return c.Decode(string(data))
+		},
+	}
+}
+
+// RunPipeline applies the transforms in order. When reverse is set it
+// undoes them instead, starting from the last, so the same pipeline both
+// builds and unpicks a value
+func RunPipeline(input []byte, transforms []Transform, reverse bool) ([]Step, error) {
+	var steps []Step
+	data := input
+	total := 0
+	for i := range transforms {
+		t := transforms[i]
+		name := t.Name + " encode"
+		apply := t.Forward
+		if reverse {
+			t = transforms[len(transforms)-1-i]
+			name = t.Name + " decode"
+			apply = t.Reverse
+		}
+
+		out, err := apply(data)
+		if total += len(out); err == nil && total > maxPipelineOutput {
+			err = fmt.Errorf("the steps so far output more than %d MB", maxPipelineOutput>>20)
+		}
+		if err != nil {
+			return steps, fmt.Errorf("step %d (%s): %w", i+1, name, err)
+		}
+		steps = append(steps, Step{Name: name, Output: out})
+		data = out
+	}
+	return steps, nil
+}
+
+func gzipCompress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func gzipDecompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return readLimited(r)
+}
+
+func zlibCompress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func zlibDecompress(data []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return readLimited(r)
+}
+
+// readLimited reads a decompressor, failing once maxInflatedSize is passed
+func readLimited(r io.Reader) ([]byte, error) {
+	out, err := io.ReadAll(io.LimitReader(r, maxInflatedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > maxInflatedSize {
+		return nil, fmt.Errorf("decompressed data is larger than %d MB", maxInflatedSize>>20)
+	}
+	return out, nil
+}
@@ -0,0 +1,363 @@
+package codec
+
+import (
+	"fmt"
+	"html"
+	"mime/quotedprintable"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+func queryEncode(data []byte) (string, error) {
+	return url.QueryEscape(string(data)), nil
+}
+
+func queryDecode(text string) ([]byte, error) {
+	return percentDecode(text, true)
+}
+
+func pathEncode(data []byte) (string, error) {
+	return url.PathEscape(string(data)), nil
+}
+
+func pathDecode(text string) ([]byte, error) {
+	return percentDecode(text, false)
+}
+
+// percentDecode undoes %XX escapes, and '+' as space when plusAsSpace is
+// set. Unlike url.QueryUnescape it reports where a bad escape starts
+func percentDecode(text string, plusAsSpace bool) ([]byte, error) {
+	decoded := make([]byte, 0, len(text))
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case c == '%':
+			if i+2 >= len(text) {
+				return nil, &OffsetError{Offset: i, Reason: "incomplete percent escape"}
+			}
+			hi, ok1 := unhex(text[i+1])
+			lo, ok2 := unhex(text[i+2])
+			if !ok1 || !ok2 {
+				return nil, &OffsetError{Offset: i, Reason: fmt.Sprintf("invalid percent escape %q", text[i:i+3])}
+			}
+			decoded = append(decoded, hi<<4|lo)
+			i += 2
+		case c == '+' && plusAsSpace:
+			decoded = append(decoded, ' ')
+		default:
+			decoded = append(decoded, c)
+		}
+	}
+	return decoded, nil
+}
+
+// htmlEncode escapes the markup characters and writes every non-ASCII
+// character as a numeric entity, so the output is plain ASCII
+func htmlEncode(data []byte) (string, error) {
+	if !utf8.Valid(data) {
+		return "", fmt.Errorf("input is not valid UTF-8")
+	}
+
+	var b strings.Builder
+	for _, r := range string(data) {
+		switch {
+		case r == '&':
+			b.WriteString("&amp;")
+		case r == '<':
+			b.WriteString("&lt;")
+		case r == '>':
+			b.WriteString("&gt;")
+		case r == '"':
+			b.WriteString("&quot;")
+		case r == '\'':
+			b.WriteString("&#39;")
+		case r >= utf8.RuneSelf:
+			fmt.Fprintf(&b, "&#%d;", r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String(), nil
+}
+
+// htmlDecode replaces named and numeric entities. A bare '&' followed by a
+// space is kept, but anything that starts like an entity must be valid
+func htmlDecode(text string) ([]byte, error) {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] != '&' || i+1 == len(text) || !isEntityStart(text[i+1]) {
+			b.WriteByte(text[i])
+			continue
+		}
+
+		end := strings.IndexByte(text[i:min(len(text), i+40)], ';')
+		if end < 0 {
+			return nil, &OffsetError{Offset: i, Reason: "entity is missing its closing ';'"}
+		}
+		entity := text[i : i+end+1]
+
+		if text[i+1] == '#' {
+			r, err := numericEntity(entity)
+			if err != nil {
+				return nil, &OffsetError{Offset: i, Reason: err.Error()}
+			}
+			b.WriteRune(r)
+		} else {
+			unescaped := html.UnescapeString(entity)
+			if unescaped == entity {
+				return nil, &OffsetError{Offset: i, Reason: fmt.Sprintf("unknown entity %s", entity)}
+			}
+			b.WriteString(unescaped)
+		}
+		i += end
+	}
+	return []byte(b.String()), nil
+}
+
+func isEntityStart(c byte) bool {
+	return c == '#' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// numericEntity parses "&#123;" or "&#x7b;"
+func numericEntity(entity string) (rune, error) {
+	digits := entity[2 : len(entity)-1]
+	base := 10
+	if strings.HasPrefix(digits, "x") || strings.HasPrefix(digits, "X") {
+		digits, base = digits[1:], 16
+	}
+
+	n, err := strconv.ParseUint(digits, base, 32)
+	if err != nil || n == 0 || n > utf8.MaxRune || (n >= 0xD800 && n <= 0xDFFF) {
+		return 0, fmt.Errorf("invalid numeric entity %s", entity)
+	}
+	return rune(n), nil
+}
+
+// unicodeEncode writes printable ASCII as is and everything else as JSON
+// style escapes. Characters outside the BMP become surrogate pairs, and
+// bytes that are not valid UTF-8 become \xHH
+func unicodeEncode(data []byte) (string, error) {
+	var b strings.Builder
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		switch {
+		case r == utf8.RuneError && size == 1:
+			fmt.Fprintf(&b, `\x%02x`, data[0])
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r > 0xFFFF:
+			r1, r2 := utf16.EncodeRune(r)
+			fmt.Fprintf(&b, `\u%04x\u%04x`, r1, r2)
+		default:
+			fmt.Fprintf(&b, `\u%04x`, r)
+		}
+		data = data[size:]
+	}
+	return b.String(), nil
+}
+
+// unicodeDecode understands \uXXXX (with surrogate pairs), \u{X...},
+// \UXXXXXXXX, \xHH and the common single character escapes
+func unicodeDecode(text string) ([]byte, error) {
+	var out []byte
+	for i := 0; i < len(text); i++ {
+		if text[i] != '\\' {
+			out = append(out, text[i])
+			continue
+		}
+		if i+1 == len(text) {
+			return nil, &OffsetError{Offset: i, Reason: "trailing backslash"}
+		}
+
+		start := i
+		switch c := text[i+1]; c {
+		case 'n':
+			out = append(out, '\n')
+		case 'r':
+			out = append(out, '\r')
+		case 't':
+			out = append(out, '\t')
+		case 'b':
+			out = append(out, '\b')
+		case 'f':
+			out = append(out, '\f')
+		case '0':
+			out = append(out, 0)
+		case '\\', '"', '\'', '/':
+			out = append(out, c)
+		case 'x':
+			n, err := hexValue(text, i+2, 2)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, byte(n))
+			i += 2
+		case 'U':
+			n, err := hexValue(text, i+2, 8)
+			if err != nil {
+				return nil, err
+			}
+			if n > utf8.MaxRune || (n >= 0xD800 && n <= 0xDFFF) {
+				return nil, &OffsetError{Offset: start, Reason: "escape is not a valid code point"}
+			}
+			out = utf8.AppendRune(out, rune(n))
+			i += 8
+		case 'u':
+			r, width, err := unicodeEscape(text, i)
+			if err != nil {
+				return nil, err
+			}
+			out = utf8.AppendRune(out, r)
+			i += width - 2
+		default:
+			return nil, errorAt(text, i+1, "unknown escape sequence")
+		}
+		i++
+	}
+	return out, nil
+}
+
+// unicodeEscape reads a \u escape starting at i, combining a following low
+// surrogate if the first is a high one. It returns the rune and the number
+// of bytes consumed
+func unicodeEscape(text string, i int) (rune, int, error) {
+	if i+2 < len(text) && text[i+2] == '{' {
+		end := strings.IndexByte(text[i:], '}')
+		if end < 0 {
+			return 0, 0, &OffsetError{Offset: i, Reason: `unterminated \u{...} escape`}
+		}
+		n, err := strconv.ParseUint(text[i+3:i+end], 16, 32)
+		if err != nil || n > utf8.MaxRune || (n >= 0xD800 && n <= 0xDFFF) {
+			return 0, 0, &OffsetError{Offset: i, Reason: `invalid \u{...} escape`}
+		}
+		return rune(n), end + 1, nil
+	}
+
+	n, err := hexValue(text, i+2, 4)
+	if err != nil {
+		return 0, 0, err
+	}
+	r := rune(n)
+	if !utf16.IsSurrogate(r) {
+		return r, 6, nil
+	}
+
+	// A high surrogate must be followed by an escaped low surrogate
+	if r < 0xDC00 && strings.HasPrefix(text[i+6:], `\u`) {
+		low, err := hexValue(text, i+8, 4)
+		if err == nil {
+			if combined := utf16.DecodeRune(r, rune(low)); combined != utf8.RuneError {
+				return combined, 12, nil
+			}
+		}
+	}
+	return 0, 0, &OffsetError{Offset: i, Reason: "unpaired UTF-16 surrogate"}
+}
+
+// hexValue parses n hex digits starting at offset
+func hexValue(text string, offset, n int) (uint64, error) {
+	if offset+n > len(text) {
+		return 0, &OffsetError{Offset: offset - 2, Reason: "escape is truncated"}
+	}
+	var v uint64
+	for i := offset; i < offset+n; i++ {
+		d, ok := unhex(text[i])
+		if !ok {
+			return 0, errorAt(text, i, "invalid hex digit in escape")
+		}
+		v = v<<4 | uint64(d)
+	}
+	return v, nil
+}
+
+func qpEncode(data []byte) (string, error) {
+	var b strings.Builder
+	w := quotedprintable.NewWriter(&b)
+	if _, err := w.Write(data); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// qpDecode undoes quoted-printable, joining soft line breaks ("=" at the end
+// of a line) and rejecting '=' that is not followed by two hex digits
+func qpDecode(text string) ([]byte, error) {
+	out := make([]byte, 0, len(text))
+	for i := 0; i < len(text); i++ {
+		if text[i] != '=' {
+			out = append(out, text[i])
+			continue
+		}
+
+		rest := text[i+1:]
+		switch {
+		case strings.HasPrefix(rest, "\r\n"):
+			i += 2
+		case strings.HasPrefix(rest, "\n"):
+			i++
+		case len(rest) >= 2:
+			hi, ok1 := unhex(rest[0])
+			lo, ok2 := unhex(rest[1])
+			if !ok1 || !ok2 {
+				return nil, &OffsetError{Offset: i, Reason: fmt.Sprintf("invalid escape %q", text[i:i+3])}
+			}
+			out = append(out, hi<<4|lo)
+			i += 2
+		default:
+			return nil, &OffsetError{Offset: i, Reason: "incomplete escape"}
+		}
+	}
+	return out, nil
+}
+
+// punycodeEncode converts each label of a domain name to its xn-- form
+func punycodeEncode(data []byte) (string, error) {
+	if !utf8.Valid(data) {
+		return "", fmt.Errorf("input is not valid UTF-8")
+	}
+	return mapLabels(string(data), idna.Punycode.ToASCII)
+}
+
+// punycodeDecode converts xn-- labels back to Unicode
+func punycodeDecode(text string) ([]byte, error) {
+	decoded, err := mapLabels(text, idna.Punycode.ToUnicode)
+	return []byte(decoded), err
+}
+
+// maxLabelLength is the longest DNS label. Punycode's work grows with the
+// square of a label's length, so longer ones are refused up front
+const maxLabelLength = 63
+
+// mapLabels applies convert to every dot-separated label, reporting the
+// offset of the label that fails
+func mapLabels(name string, convert func(string) (string, error)) (string, error) {
+	labels := strings.Split(name, ".")
+	offset := 0
+	for i, label := range labels {
+		if utf8.RuneCountInString(label) > maxLabelLength {
+			return "", &OffsetError{Offset: offset, Reason: fmt.Sprintf("label is longer than %d characters", maxLabelLength)}
+		}
+		converted, err := convert(label)
+		if err != nil {
+			return "", &OffsetError{Offset: offset, Reason: fmt.Sprintf("invalid label %q (%v)", label, err)}
+		}
+		labels[i] = converted
+		offset += len(label) + 1
+	}
+	return strings.Join(labels, "."), nil
+}
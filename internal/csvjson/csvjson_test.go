@@ -0,0 +1,150 @@
+package csvjson
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Ndeta100/orbit2x/internal/jsonfmt"
+	"github.com/Ndeta100/orbit2x/internal/jsonfmt/jsonfmttest"
+)
+
+func TestInfer(t *testing.T) {
+	tests := []struct {
+		values []string
+		want   Type
+	}{
+		{values: []string{"1", "-20", "", "300"}, want: Integer},
+		{values: []string{"1", "2.50", "-0.5"}, want: Number},
+		{values: []string{"true", "FALSE", ""}, want: Boolean},
+		{values: []string{"", ""}, want: String},
+		{values: []string{"00123", "00456"}, want: String},
+		{values: []string{"NaN", "1"}, want: String},
+		{values: []string{"1e5", "2"}, want: String},
+		{values: []string{"+1", "2"}, want: String},
+		{values: []string{"1", "true"}, want: String},
+	}
+	for _, tt := range tests {
+		if got := Infer(tt.values); got != tt.want {
+			t.Errorf("Infer(%q) = %v, want %v", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestTableJSON(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		overrides string
+		want      string
+	}{
+		{
+			name: "inferred",
+			text: "zip,count,price,ok,x\n00123,7,1.50,true,NaN\n00456,8,2,FALSE,1e5\n",
+			want: `[{"zip":"00123","count":7,"price":1.50,"ok":true,"x":"NaN"},{"zip":"00456","count":8,"price":2,"ok":false,"x":"1e5"}]`,
+		},
+		{
+			name: "empty cells are null",
+			text: "id,n\n1,\n2,5\n",
+			want: `[{"id":1,"n":null},{"id":2,"n":5}]`,
+		},
+		{
+			name:      "overrides are lenient",
+			text:      "zip,count,x\n00123,7,1E5\n",
+			overrides: "zip: integer, 3: number, count: string",
+			want:      `[{"zip":123,"count":"7","x":1E5}]`,
+		},
+		{
+			name: "no header",
+			text: "Ada\tLovelace\t1815\nAlan\tTuring\t1912\n",
+			want: `[{"column1":"Ada","column2":"Lovelace","column3":1815},{"column1":"Alan","column2":"Turing","column3":1912}]`,
+		},
+	}
+	for _, tt := range tests {
+		overrides, err := ParseOverrides(tt.overrides)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		tab, _, err := NewTable(tt.text, Options{InferTypes: true, Overrides: overrides})
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		n, err := tab.JSON(Objects)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := jsonfmttest.Minified(n); got != tt.want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTableErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		overrides string
+		want      string
+	}{
+		{name: "short row", text: "id,name\n1,a\n2\n3,c\n", want: "the header has 2 fields, but row 3 (line 3) has 1"},
+		{name: "long row", text: "id,name\n1,a\n3,c,d\n", want: "row 3 (line 3) has 3"},
+		{name: "line after a multi-line field", text: "a,b\n\"x\ny\",1\n2\n", want: "row 3 (line 4) has 1"},
+		{name: "empty", text: "\n\n", want: "CSV has no data"},
+		{name: "unknown column", text: "a,b\n1,2\n", overrides: "c: string", want: `there is no column "c"`},
+		{name: "override does not fit", text: "a,b\nx,2\n", overrides: "a: integer", want: `row 2 (line 2), column a: "x" is not an integer`},
+	}
+	for _, tt := range tests {
+		overrides, err := ParseOverrides(tt.overrides)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		tab, _, err := NewTable(tt.text, Options{InferTypes: true, Overrides: overrides})
+		if err == nil {
+			_, err = tab.JSON(Objects)
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want one containing %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := []struct {
+		text      string
+		line, col int
+	}{
+		{text: "a,\"bc\n", line: 1, col: 3},
+		{text: "a,b\nc,\"d\"x,e\n", line: 2, col: 6},
+	}
+	for _, tt := range tests {
+		_, err := Read(tt.text, Dialect{Delimiter: ',', Quote: '"'})
+		pe, ok := err.(*jsonfmt.ParseError)
+		if !ok {
+			t.Errorf("%q: got %v, want a ParseError", tt.text, err)
+			continue
+		}
+		if pe.Line != tt.line || pe.Column != tt.col {
+			t.Errorf("%q: error at %d:%d, want %d:%d", tt.text, pe.Line, pe.Column, tt.line, tt.col)
+		}
+	}
+}
+
+func TestSniff(t *testing.T) {
+	tests := []struct {
+		text string
+		want Dialect
+	}{
+		{text: "name,age\nada,36\nalan,41\n", want: Dialect{Delimiter: ',', Quote: '"', Header: true}},
+		{text: "name;price\nfoo;1,5\nbar;2,0\n", want: Dialect{Delimiter: ';', Quote: '"', Header: true}},
+		{text: "Ada\tLovelace\t1815\nAlan\tTuring\t1912\n", want: Dialect{Delimiter: '\t', Quote: '"'}},
+		{text: "'a'|'b'\n'x|y'|'it''s'\n", want: Dialect{Delimiter: '|', Quote: '\'', Header: true}},
+		{text: "\"a, b\",1\n\"c\",\"2\"\n", want: Dialect{Delimiter: ',', Quote: '"'}},
+		{text: "city,country\nParis,France\nRome,Italy\n", want: Dialect{Delimiter: ',', Quote: '"', Header: true}},
+	}
+	for _, tt := range tests {
+		if got := Sniff(tt.text); got != tt.want {
+			t.Errorf("Sniff(%q) = %+v, want %+v", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,360 @@
+package csvjson
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Ndeta100/orbit2x/internal/jsonfmt"
+)
+
+// Notations for the column names of nested values
+const (
+	Dot     = "dot"     // user.address.city, tags.0
+	Bracket = "bracket" // user[address][city], tags[0]
+)
+
+// Ways to flatten arrays
+const (
+	IndexColumns = "columns" // a column per element: tags.0, tags.1
+	ExplodeRows  = "rows"    // a row per element, repeating the other cells
+	JSONText     = "json"    // the array as JSON text in one cell
+)
+
+// maxFlatCells bounds the cells Flatten makes, counting one more per row.
+// Each exploded array multiplies the rows of the others, and every row
+// repeats the record's other cells
+const maxFlatCells = 1 << 22
+
+// FlattenOptions control how records become rows
+type FlattenOptions struct {
+	Notation string // Dot when empty
+	Arrays   string // IndexColumns when empty
+}
+
+// Flatten turns each record of n, or n itself when it is not an array, into
+// objects whose keys are column names and whose values are scalars, or
+// JSON text for what was not flattened. Records that are not objects become
+// a single value column
+func Flatten(n *jsonfmt.Node, opts FlattenOptions) ([]*jsonfmt.Node, error) {
+	records := n.Items
+	if n.Kind != jsonfmt.Array {
+		records = []*jsonfmt.Node{n}
+	}
+	var rows []*jsonfmt.Node
+	total := 0
+	for i, record := range records {
+		prefix := ""
+		if record.Kind != jsonfmt.Object {
+			prefix = "value"
+		}
+		flat, err := opts.flatten(prefix, record)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %v", i+1, err)
+		}
+		if total += cellCount(flat); total > maxFlatCells {
+			return nil, fmt.Errorf("record %d: the records flatten to more than %d cells", i+1, maxFlatCells)
+		}
+		for _, members := range flat {
+			rows = append(rows, &jsonfmt.Node{Kind: jsonfmt.Object, Members: members})
+		}
+	}
+	return rows, nil
+}
+
+// flatten gives the rows that v, found at column prefix, spreads over
+func (o FlattenOptions) flatten(prefix string, v *jsonfmt.Node) ([][]jsonfmt.Member, error) {
+	leaf := [][]jsonfmt.Member{{{Key: prefix, Value: v}}}
+	if prefix == "" {
+		leaf = [][]jsonfmt.Member{nil} // an empty record
+	}
+	switch v.Kind {
+	case jsonfmt.Object:
+		if len(v.Members) == 0 {
+			return leaf, nil
+		}
+		rows := [][]jsonfmt.Member{nil}
+		for _, m := range v.Members {
+			sub, err := o.flatten(o.join(prefix, m.Key), m.Value)
+			if err != nil {
+				return nil, err
+			}
+			if len(sub)*cellCount(rows)+len(rows)*cellCount(sub) > maxFlatCells {
+				return nil, fmt.Errorf("exploding its arrays makes more than %d cells", maxFlatCells)
+			}
+			var product [][]jsonfmt.Member
+			for _, row := range rows {
+				for _, s := range sub {
+					product = append(product, append(row[:len(row):len(row)], s...))
+				}
+			}
+			rows = product
+		}
+		return rows, nil
+	case jsonfmt.Array:
+		if len(v.Items) == 0 {
+			return leaf, nil
+		}
+		switch o.Arrays {
+		case JSONText:
+			return leaf, nil
+		case ExplodeRows:
+			var rows [][]jsonfmt.Member
+			for _, item := range v.Items {
+				sub, err := o.flatten(prefix, item)
+				if err != nil {
+					return nil, err
+				}
+				rows = append(rows, sub...)
+			}
+			return rows, nil
+		}
+		indexed := &jsonfmt.Node{Kind: jsonfmt.Object}
+		for i, item := range v.Items {
+			indexed.Members = append(indexed.Members, jsonfmt.Member{Key: strconv.Itoa(i), Value: item})
+		}
+		return o.flatten(prefix, indexed)
+	}
+	return leaf, nil
+}
+
+// cellCount counts the cells of rows, and one for each row
+func cellCount(rows [][]jsonfmt.Member) int {
+	n := len(rows)
+	for _, row := range rows {
+		n += len(row)
+	}
+	return n
+}
+
+// Cells gives row's values in the order of header, nil where the row has
+// no such column and the last value where it repeats one, as Get does.
+// Rows are indexed once, as looking up every column in turn is quadratic
+// in wide rows
+func Cells(row *jsonfmt.Node, header []string) []*jsonfmt.Node {
+	values := make(map[string]*jsonfmt.Node, len(row.Members))
+	for _, m := range row.Members {
+		values[m.Key] = m.Value
+	}
+	cells := make([]*jsonfmt.Node, len(header))
+	for i, column := range header {
+		cells[i] = values[column]
+	}
+	return cells
+}
+
+func (o FlattenOptions) join(prefix, key string) string {
+	switch {
+	case prefix == "":
+		return key
+	case o.Notation == Bracket:
+		return prefix + "[" + key + "]"
+	}
+	return prefix + "." + key
+}
+
+// Header gives every column of rows, in first seen order or sorted with
+// numbers in names compared by value, so tags.2 comes before tags.10
+func Header(rows []*jsonfmt.Node, sorted bool) []string {
+	var header []string
+	seen := map[string]bool{}
+	for _, row := range rows {
+		for _, m := range row.Members {
+			if !seen[m.Key] {
+				seen[m.Key] = true
+				header = append(header, m.Key)
+			}
+		}
+	}
+	if sorted {
+		sort.SliceStable(header, func(i, j int) bool { return naturalLess(header[i], header[j]) })
+	}
+	return header
+}
+
+// Select picks columns from header in the order asked for. A name also
+// picks the columns nested under it, so user picks user.name and user.email
+func Select(header, picked []string, notation string) ([]string, error) {
+	var out []string
+	taken := map[string]bool{}
+	for _, name := range picked {
+		found := false
+		for _, column := range header {
+			if column == name || strings.HasPrefix(column, nestedPrefix(name, notation)) {
+				found = true
+				if !taken[column] {
+					taken[column] = true
+					out = append(out, column)
+				}
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("there is no column %q; the columns are %s", name, strings.Join(header, ", "))
+		}
+	}
+	return out, nil
+}
+
+func nestedPrefix(name, notation string) string {
+	if notation == Bracket {
+		return name + "["
+	}
+	return name + "."
+}
+
+// Cell gives the CSV text of a flattened value. Null is an empty cell, and
+// anything left nested is written as JSON
+func Cell(v *jsonfmt.Node) string {
+	switch v.Kind {
+	case jsonfmt.Null:
+		return ""
+	case jsonfmt.Object, jsonfmt.Array:
+		return strings.TrimSuffix(jsonfmt.Format(v, jsonfmt.Options{Minify: true}), "\n")
+	}
+	return v.Value
+}
+
+// Unflatten is the inverse of Flatten. It nests the values of an object,
+// or of each object in an array, under the parts of their keys, and turns
+// objects whose keys are all indexes into arrays. Empty nested cells are
+// left out, since a row only fills the columns its record had
+func Unflatten(n *jsonfmt.Node, notation string) (*jsonfmt.Node, error) {
+	if n.Kind == jsonfmt.Object {
+		return unflatten(n, notation)
+	}
+	out := &jsonfmt.Node{Kind: n.Kind, Value: n.Value, Items: make([]*jsonfmt.Node, len(n.Items))}
+	for i, item := range n.Items {
+		out.Items[i] = item
+		if item.Kind == jsonfmt.Object {
+			row, err := unflatten(item, notation)
+			if err != nil {
+				return nil, fmt.Errorf("record %d: %v", i+1, err)
+			}
+			out.Items[i] = row
+		}
+	}
+	return out, nil
+}
+
+func unflatten(row *jsonfmt.Node, notation string) (*jsonfmt.Node, error) {
+	out := &jsonfmt.Node{Kind: jsonfmt.Object}
+	owner := map[*jsonfmt.Node]string{} // which column made an object, for errors
+	for _, m := range row.Members {
+		parts := splitColumn(m.Key, notation)
+		if len(parts) > 1 && (m.Value.Kind == jsonfmt.Null || m.Value.Kind == jsonfmt.String && m.Value.Value == "") {
+			continue
+		}
+		parent := out
+		for i, part := range parts[:len(parts)-1] {
+			child := parent.Get(part)
+			if child == nil {
+				child = &jsonfmt.Node{Kind: jsonfmt.Object}
+				owner[child] = m.Key
+				parent.Members = append(parent.Members, jsonfmt.Member{Key: part, Value: child})
+			} else if child.Kind != jsonfmt.Object {
+				return nil, fmt.Errorf("column %q nests under %q, which another column already sets to a value", m.Key, strings.Join(parts[:i+1], "."))
+			}
+			parent = child
+		}
+		last := parts[len(parts)-1]
+		if existing := parent.Get(last); existing != nil {
+			if existing.Kind == jsonfmt.Object {
+				return nil, fmt.Errorf("column %q sets a value where column %q nests", m.Key, owner[existing])
+			}
+			return nil, fmt.Errorf("column %q repeats another column once nested", m.Key)
+		}
+		parent.Members = append(parent.Members, jsonfmt.Member{Key: last, Value: m.Value})
+	}
+	for i := range out.Members {
+		out.Members[i].Value = indexedArrays(out.Members[i].Value)
+	}
+	return out, nil
+}
+
+// splitColumn cuts a column name into its parts. A name that does not
+// follow the notation is one part
+func splitColumn(name, notation string) []string {
+	if notation != Bracket {
+		return strings.Split(name, ".")
+	}
+	open := strings.IndexByte(name, '[')
+	if open <= 0 || !strings.HasSuffix(name, "]") {
+		return []string{name}
+	}
+	parts := []string{name[:open]}
+	for rest := name[open:]; rest != ""; {
+		end := strings.IndexByte(rest, ']')
+		if rest[0] != '[' || end < 0 {
+			return []string{name}
+		}
+		parts = append(parts, rest[1:end])
+		rest = rest[end+1:]
+	}
+	return parts
+}
+
+// indexedArrays turns objects keyed 0, 1, 2... into arrays, filling gaps
+// with null, unless the gaps would outnumber the values
+func indexedArrays(n *jsonfmt.Node) *jsonfmt.Node {
+	if n.Kind != jsonfmt.Object {
+		return n
+	}
+	for i := range n.Members {
+		n.Members[i].Value = indexedArrays(n.Members[i].Value)
+	}
+	if len(n.Members) == 0 {
+		return n
+	}
+	size := 0
+	for _, m := range n.Members {
+		i, err := strconv.Atoi(m.Key)
+		if err != nil || i < 0 || strconv.Itoa(i) != m.Key {
+			return n
+		}
+		size = max(size, i+1)
+	}
+	if size > 2*len(n.Members) {
+		return n
+	}
+	arr := &jsonfmt.Node{Kind: jsonfmt.Array, Items: make([]*jsonfmt.Node, size)}
+	for i := range arr.Items {
+		arr.Items[i] = &jsonfmt.Node{Kind: jsonfmt.Null}
+	}
+	for _, m := range n.Members {
+		i, _ := strconv.Atoi(m.Key)
+		arr.Items[i] = m.Value
+	}
+	return arr
+}
+
+// naturalLess orders strings with runs of digits compared as numbers
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		da, db := digits(a), digits(b)
+		if da > 0 && db > 0 {
+			na, nb := strings.TrimLeft(a[:da], "0"), strings.TrimLeft(b[:db], "0")
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+			if na != nb {
+				return na < nb
+			}
+			a, b = a[da:], b[db:]
+			continue
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+func digits(s string) int {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return i
+}
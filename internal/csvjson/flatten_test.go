@@ -0,0 +1,80 @@
+package csvjson
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Ndeta100/orbit2x/internal/jsonfmt"
+	"github.com/Ndeta100/orbit2x/internal/jsonfmt/jsonfmttest"
+)
+
+// TestFlattenRoundTrip flattens records and nests the flat rows again: the
+// records must come back as they were
+func TestFlattenRoundTrip(t *testing.T) {
+	tests := []struct {
+		notation string
+		text     string
+		header   string
+	}{
+		{notation: Dot, text: `[{"id":1,"user":{"name":"ada","address":{"city":"London"}},"tags":["a","b"]}]`, header: "id,user.name,user.address.city,tags.0,tags.1"},
+		{notation: Bracket, text: `[{"id":1,"user":{"name":"ada"},"tags":["a",null,"c"]}]`, header: "id,user[name],tags[0],tags[1],tags[2]"},
+		{notation: Dot, text: `[{"a":1,"b":{"c":true}},{"a":2,"d":[{"e":"x"}]}]`, header: "a,b.c,d.0.e"},
+	}
+	for _, tt := range tests {
+		in := jsonfmttest.MustParse(t, tt.text)
+		rows, err := Flatten(in, FlattenOptions{Notation: tt.notation})
+		if err != nil {
+			t.Errorf("%s: %v", tt.text, err)
+			continue
+		}
+		if got := strings.Join(Header(rows, false), ","); got != tt.header {
+			t.Errorf("%s: header %s, want %s", tt.text, got, tt.header)
+		}
+		out, err := Unflatten(&jsonfmt.Node{Kind: jsonfmt.Array, Items: rows}, tt.notation)
+		if err != nil {
+			t.Errorf("%s: %v", tt.text, err)
+			continue
+		}
+		if !jsonfmt.Equal(in, out) {
+			t.Errorf("%s: came back as %s", tt.text, jsonfmttest.Minified(out))
+		}
+	}
+}
+
+func TestFlattenArrays(t *testing.T) {
+	in := jsonfmttest.MustParse(t, `{"id":1,"tags":["a","b"],"pos":[1,2]}`)
+	tests := []struct {
+		arrays string
+		want   string
+	}{
+		{arrays: IndexColumns, want: `[{"id":1,"tags.0":"a","tags.1":"b","pos.0":1,"pos.1":2}]`},
+		{arrays: ExplodeRows, want: `[{"id":1,"tags":"a","pos":1},{"id":1,"tags":"a","pos":2},{"id":1,"tags":"b","pos":1},{"id":1,"tags":"b","pos":2}]`},
+		{arrays: JSONText, want: `[{"id":1,"tags":["a","b"],"pos":[1,2]}]`},
+	}
+	for _, tt := range tests {
+		rows, err := Flatten(in, FlattenOptions{Arrays: tt.arrays})
+		if err != nil {
+			t.Errorf("%s: %v", tt.arrays, err)
+			continue
+		}
+		if got := jsonfmttest.Minified(&jsonfmt.Node{Kind: jsonfmt.Array, Items: rows}); got != tt.want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.arrays, got, tt.want)
+		}
+	}
+}
+
+func TestUnflattenErrors(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: `{"a":1,"a.b":2}`, want: `column "a.b" nests under "a"`},
+		{text: `{"a.b":1,"a":2}`, want: `column "a" sets a value where column "a.b" nests`},
+	}
+	for _, tt := range tests {
+		_, err := Unflatten(jsonfmttest.MustParse(t, tt.text), Dot)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want one containing %q", tt.text, err, tt.want)
+		}
+	}
+}
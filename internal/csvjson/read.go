@@ -0,0 +1,114 @@
+// Package csvjson reads CSV into jsonfmt trees. It sniffs the dialect of
+// the input, infers a type for each column rather than for each cell, and
+// reports rows whose width does not match the header by row and line.
+//
+// Inference is deliberately strict: a value is a number only when it is
+// written the way JSON writes numbers without an exponent, so zip codes
+// such as 00123, "NaN" and "1e5" stay strings unless a column is told
+// otherwise
+package csvjson
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Ndeta100/orbit2x/internal/jsonfmt"
+)
+
+// Dialect describes how a CSV text is written
+type Dialect struct {
+	Delimiter rune
+	Quote     rune
+	Header    bool // the first record names the columns
+}
+
+// Record is one row of fields
+type Record struct {
+	Fields []string
+	Line   int // line the record starts on, since quoted fields may span lines
+}
+
+// Read splits text into records. Fields may be quoted with d.Quote, in
+// which a doubled quote stands for one; a quote inside an unquoted field is
+// kept as it is. Blank lines are skipped
+func Read(text string, d Dialect) ([]Record, error) {
+	text = strings.TrimPrefix(text, "\ufeff")
+	delim, quote := string(d.Delimiter), string(d.Quote)
+	var records []Record
+	line, i := 1, 0
+
+	for i < len(text) {
+		if eol := lineEnd(text, i); eol > 0 {
+			line++
+			i += eol
+			continue
+		}
+
+		rec := Record{Line: line}
+		for {
+			var field strings.Builder
+			if strings.HasPrefix(text[i:], quote) {
+				openLine, openCol := line, column(text, i)
+				i += len(quote)
+				for {
+					j := strings.Index(text[i:], quote)
+					if j < 0 {
+						return nil, &jsonfmt.ParseError{Msg: "quoted field is never closed", Line: openLine, Column: openCol, Hint: "Double a quote inside a quoted field to escape it"}
+					}
+					field.WriteString(text[i : i+j])
+					line += strings.Count(text[i:i+j], "\n")
+					i += j + len(quote)
+					if !strings.HasPrefix(text[i:], quote) {
+						break
+					}
+					field.WriteString(quote)
+					i += len(quote)
+				}
+				if i < len(text) && !strings.HasPrefix(text[i:], delim) && lineEnd(text, i) == 0 {
+					return nil, &jsonfmt.ParseError{Msg: fmt.Sprintf("unexpected %q after a closing quote", firstRune(text[i:])), Line: line, Column: column(text, i), Hint: "Double a quote inside a quoted field to escape it"}
+				}
+			} else {
+				start := i
+				for i < len(text) && !strings.HasPrefix(text[i:], delim) && lineEnd(text, i) == 0 {
+					i++
+				}
+				field.WriteString(text[start:i])
+			}
+			rec.Fields = append(rec.Fields, field.String())
+
+			if strings.HasPrefix(text[i:], delim) {
+				i += len(delim)
+				continue
+			}
+			if eol := lineEnd(text, i); eol > 0 {
+				line++
+				i += eol
+			}
+			break
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// lineEnd gives the length of the line break at i, or 0 if there is none
+func lineEnd(text string, i int) int {
+	switch {
+	case strings.HasPrefix(text[i:], "\r\n"):
+		return 2
+	case i < len(text) && text[i] == '\n':
+		return 1
+	}
+	return 0
+}
+
+// column gives the 1-based rune column of offset i
+func column(text string, i int) int {
+	return utf8.RuneCountInString(text[strings.LastIndexByte(text[:i], '\n')+1:i]) + 1
+}
+
+func firstRune(s string) rune {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r
+}
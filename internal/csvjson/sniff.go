@@ -0,0 +1,162 @@
+package csvjson
+
+import (
+	"strings"
+)
+
+// Delimiters are the separators Sniff tries, in order of preference
+var Delimiters = []rune{',', ';', '\t', '|'}
+
+// sniffLines bounds how much of the input Sniff looks at
+const sniffLines = 50
+
+// Sniff guesses the dialect from the start of text. The quote is whichever
+// of " and ' more often opens and closes fields, and the delimiter is the
+// one that splits the most records into the same number of fields
+func Sniff(text string) Dialect {
+	sample := text
+	if i := nthIndex(text, '\n', sniffLines); i >= 0 {
+		sample = text[:i]
+	}
+
+	// A cut through a quoted field spoils the sample, so read it all then
+	read := func(d Dialect) ([]Record, error) {
+		records, err := Read(sample, d)
+		if err != nil && len(sample) < len(text) {
+			records, err = Read(text, d)
+			records = records[:min(len(records), sniffLines)]
+		}
+		return records, err
+	}
+
+	d := Dialect{Delimiter: ',', Quote: sniffQuote(sample)}
+	bestShare, bestWidth := 0.0, 1
+	for _, delim := range Delimiters {
+		records, err := read(Dialect{Delimiter: delim, Quote: d.Quote})
+		if err != nil || len(records) == 0 {
+			continue
+		}
+		counts := map[int]int{}
+		for _, rec := range records {
+			counts[len(rec.Fields)]++
+		}
+		width, most := 0, 0
+		for w, c := range counts {
+			if c > most || c == most && w > width {
+				width, most = w, c
+			}
+		}
+		share := float64(most) / float64(len(records))
+		if width > 1 && (share > bestShare || share == bestShare && width > bestWidth) {
+			d.Delimiter, bestShare, bestWidth = delim, share, width
+		}
+	}
+
+	if records, err := read(d); err == nil {
+		d.Header = hasHeader(records)
+	}
+	return d
+}
+
+// HasHeader decides whether text in dialect d starts with a header row, for
+// when the delimiter or quote was chosen rather than sniffed
+func HasHeader(text string, d Dialect) bool {
+	if i := nthIndex(text, '\n', sniffLines); i >= 0 {
+		if records, err := Read(text[:i], d); err == nil {
+			return hasHeader(records)
+		}
+	}
+	records, err := Read(text, d)
+	return err == nil && hasHeader(records[:min(len(records), sniffLines)])
+}
+
+// sniffQuote counts quotes that sit at the edges of fields
+func sniffQuote(sample string) rune {
+	edges := func(q byte) int {
+		n := 0
+		for i := 0; i < len(sample); i++ {
+			if sample[i] != q {
+				continue
+			}
+			opens := i == 0 || strings.IndexByte(",;\t|\n", sample[i-1]) >= 0
+			closes := i == len(sample)-1 || strings.IndexByte(",;\t|\r\n", sample[i+1]) >= 0
+			if opens || closes {
+				n++
+			}
+		}
+		return n
+	}
+	if edges('\'') > edges('"') {
+		return '\''
+	}
+	return '"'
+}
+
+// hasHeader decides whether the first record names the columns. A column
+// votes for a header when its first cell does not fit the type of the rest,
+// or, for text columns of one width, is another width, and votes against
+// when the first cell fits a typed column. With no votes either way, a
+// first row of distinct, non-empty, non-numeric cells is taken as a header
+func hasHeader(records []Record) bool {
+	if len(records) == 0 {
+		return false
+	}
+	first := records[0].Fields
+	votes := 0
+	if len(records) > 1 {
+		for c, cell := range first {
+			var rest []string
+			for _, rec := range records[1:] {
+				if c < len(rec.Fields) {
+					rest = append(rest, rec.Fields[c])
+				}
+			}
+			if t := Infer(rest); t != String {
+				if cell != "" && !t.fits(cell) {
+					votes++
+				} else {
+					votes--
+				}
+			} else if width, ok := sameLength(rest); ok && len(cell) != width {
+				votes++
+			}
+		}
+	}
+	if votes != 0 {
+		return votes > 0
+	}
+
+	seen := map[string]bool{}
+	for _, cell := range first {
+		if cell == "" || seen[cell] || Infer([]string{cell}) != String {
+			return false
+		}
+		seen[cell] = true
+	}
+	return true
+}
+
+// sameLength reports the shared length of values, if they all have one
+func sameLength(values []string) (int, bool) {
+	if len(values) < 2 {
+		return 0, false
+	}
+	for _, v := range values[1:] {
+		if len(v) != len(values[0]) {
+			return 0, false
+		}
+	}
+	return len(values[0]), true
+}
+
+// nthIndex gives the index of the nth occurrence of c, or -1
+func nthIndex(s string, c byte, n int) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] == c {
+			if n--; n == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
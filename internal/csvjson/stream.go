@@ -0,0 +1,407 @@
+package csvjson
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/Ndeta100/orbit2x/internal/jsonfmt"
+)
+
+// Streaming converts whole files while holding one record at a time, so
+// memory stays flat however large the input is
+const (
+	sniffBytes = 64 << 10 // looked at to sniff the dialect
+	sampleRows = 1000     // read ahead to infer column types
+	bufferSize = 64 << 10
+)
+
+// StreamOptions control streaming conversion
+type StreamOptions struct {
+	// CSV to JSON
+	Delimiter  rune  // sniffed when zero
+	Header     *bool // sniffed when nil
+	InferTypes bool
+	Unflatten  string // Dot or Bracket nests columns; empty keeps them flat
+	NDJSON     bool   // one object per line rather than one array
+
+	// JSON to CSV
+	Flatten  FlattenOptions
+	Sorted   bool
+	Columns  []string // picked and ordered as in Select; every column when empty
+	NoHeader bool
+	Comma    rune // comma when zero
+	// MaxSpool caps the bytes of flattened rows held in the temporary
+	// file; no limit when zero. Flattening can make rows far larger than
+	// the records they come from
+	MaxSpool int64
+}
+
+// CSVToJSON copies CSV from r to w as JSON objects and reports how many it
+// wrote. Quotes must be double quotes, as encoding/csv reads them. Column
+// types are inferred from the first rows, and a later cell that does not
+// fit its column's type is written as a string. Nothing is written to w
+// when the input fails within the first rows, so the caller can still
+// report the error cleanly
+func CSVToJSON(r io.Reader, w io.Writer, opts StreamOptions) (int, error) {
+	br := bufio.NewReaderSize(r, sniffBytes)
+	peeked, _ := br.Peek(sniffBytes)
+	sample := string(peeked)
+	if len(peeked) == sniffBytes {
+		sample = sample[:max(0, lastLineEnd(sample))]
+	}
+	d := Sniff(sample)
+	if opts.Delimiter != 0 {
+		d.Delimiter = opts.Delimiter
+		d.Header = HasHeader(sample, Dialect{Delimiter: d.Delimiter, Quote: '"'})
+	}
+	if opts.Header != nil {
+		d.Header = *opts.Header
+	}
+
+	cr := csv.NewReader(br)
+	cr.Comma = d.Delimiter
+	read := func() ([]string, error) {
+		record, err := cr.Read()
+		var pe *csv.ParseError
+		if errors.As(err, &pe) {
+			return nil, fmt.Errorf("line %d: %v", pe.Line, pe.Err)
+		}
+		return record, err
+	}
+
+	var head [][]string
+	for len(head) < sampleRows {
+		record, err := read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		head = append(head, record)
+	}
+	if len(head) == 0 {
+		return 0, fmt.Errorf("CSV has no data")
+	}
+
+	var header []string
+	if d.Header {
+		header, _ = Names(head[0])
+		head = head[1:]
+	} else {
+		for i := range head[0] {
+			header = append(header, "column"+strconv.Itoa(i+1))
+		}
+	}
+	types := make([]Type, len(header))
+	if opts.InferTypes {
+		for c := range types {
+			column := make([]string, len(head))
+			for i, record := range head {
+				column[i] = record[c]
+			}
+			types[c] = Infer(column)
+		}
+	}
+
+	bw := bufio.NewWriterSize(w, bufferSize)
+	count := 0
+	write := func(record []string) error {
+		row := &jsonfmt.Node{Kind: jsonfmt.Object, Members: make([]jsonfmt.Member, len(header))}
+		for c, field := range record {
+			v := jsonfmt.NewString(field)
+			if field == "" || types[c].fits(field) {
+				v, _ = types[c].Value(field)
+			}
+			row.Members[c] = jsonfmt.Member{Key: header[c], Value: v}
+		}
+		if opts.Unflatten == Dot || opts.Unflatten == Bracket {
+			var err error
+			if row, err = Unflatten(row, opts.Unflatten); err != nil {
+				return fmt.Errorf("row %d: %v", count+1, err)
+			}
+		}
+		switch {
+		case opts.NDJSON:
+		case count == 0:
+			bw.WriteString("[\n")
+		default:
+			bw.WriteString(",\n")
+		}
+		bw.WriteString(jsonfmt.Format(row, jsonfmt.Options{Minify: true}))
+		if opts.NDJSON {
+			bw.WriteByte('\n')
+		}
+		count++
+		return nil
+	}
+
+	for _, record := range head {
+		if err := write(record); err != nil {
+			return 0, err
+		}
+	}
+	cr.ReuseRecord = true
+	for {
+		record, err := read()
+		if err == io.EOF {
+			break
+		}
+		if err == nil {
+			err = write(record)
+		}
+		if err != nil {
+			return count, err
+		}
+	}
+
+	switch {
+	case opts.NDJSON:
+	case count == 0:
+		bw.WriteString("[]\n")
+	default:
+		bw.WriteString("\n]\n")
+	}
+	return count, bw.Flush()
+}
+
+// lastLineEnd gives the index just past the last newline in s, or -1
+func lastLineEnd(s string) int {
+	for i := len(s) - 1; i >= 0; i-- {
+		if s[i] == '\n' {
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// JSONToCSV copies a JSON array of records, or NDJSON, from r to w as
+// flattened CSV rows and reports how many it wrote. Records that are arrays
+// are rows of cells and have no header. Otherwise the header has to
+// name columns that only later records bring, so rows are spooled to a
+// temporary file while the header is collected and then written from there
+func JSONToCSV(r io.Reader, w io.Writer, opts StreamOptions) (int, error) {
+	spool, err := os.CreateTemp("", "csvjson-*.ndjson")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	sw := bufio.NewWriterSize(spool, bufferSize)
+	var header []string
+	seen := map[string]bool{}
+	records := 0
+	cellRows := false // the records are arrays of cells, written as they are
+	var spooled int64
+	spoolRow := func(row *jsonfmt.Node) error {
+		line := jsonfmt.Format(row, jsonfmt.Options{Minify: true})
+		if spooled += int64(len(line)) + 1; opts.MaxSpool > 0 && spooled > opts.MaxSpool {
+			return fmt.Errorf("record %d: the records flatten to more than %d MB of rows", records, opts.MaxSpool>>20)
+		}
+		sw.WriteString(line)
+		sw.WriteByte('\n')
+		return nil
+	}
+	add := func(v *jsonfmt.Node) error {
+		records++
+		if records == 1 {
+			cellRows = v.Kind == jsonfmt.Array
+		}
+		if cellRows {
+			if v.Kind != jsonfmt.Array {
+				return fmt.Errorf("record %d is not an array like the first", records)
+			}
+			return spoolRow(v)
+		}
+		rows, err := Flatten(v, opts.Flatten)
+		if err != nil {
+			return fmt.Errorf("record %d: %v", records, err)
+		}
+		for _, row := range rows {
+			for _, m := range row.Members {
+				if !seen[m.Key] {
+					seen[m.Key] = true
+					header = append(header, m.Key)
+				}
+			}
+			if err := spoolRow(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	fail := func(err error) (int, error) {
+		if err == io.ErrUnexpectedEOF {
+			err = errors.New("the JSON ends early")
+		}
+		return 0, fmt.Errorf("record %d, byte %d: %v", records+1, dec.InputOffset(), err)
+	}
+
+	tok, err := dec.Token()
+	if err == io.EOF {
+		return 0, fmt.Errorf("JSON has no data")
+	}
+	if err != nil {
+		return fail(err)
+	}
+	if tok == json.Delim('[') {
+		for dec.More() {
+			v, err := nextValue(dec)
+			if err != nil {
+				return fail(err)
+			}
+			if err := add(v); err != nil {
+				return 0, err
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return fail(err)
+		}
+		if _, err := dec.Token(); err != io.EOF {
+			return fail(errors.New("there is more after the top-level array"))
+		}
+	} else {
+		// A stream of values, as in NDJSON
+		for {
+			v, err := readValue(dec, tok)
+			if err != nil {
+				return fail(err)
+			}
+			if err := add(v); err != nil {
+				return 0, err
+			}
+			if tok, err = dec.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				return fail(err)
+			}
+		}
+	}
+	if err := sw.Flush(); err != nil {
+		return 0, err
+	}
+
+	if opts.Sorted {
+		sort.SliceStable(header, func(i, j int) bool { return naturalLess(header[i], header[j]) })
+	}
+	if len(opts.Columns) > 0 && !cellRows {
+		if header, err = Select(header, opts.Columns, opts.Flatten.Notation); err != nil {
+			return 0, err
+		}
+	}
+
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	bw := bufio.NewWriterSize(w, bufferSize)
+	cw := csv.NewWriter(bw)
+	if opts.Comma != 0 {
+		cw.Comma = opts.Comma
+	}
+	if !opts.NoHeader && !cellRows {
+		cw.Write(header)
+	}
+	sr := bufio.NewReaderSize(spool, bufferSize)
+	record := make([]string, len(header))
+	count := 0
+	for {
+		line, err := sr.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return count, err
+		}
+		row, _, err := jsonfmt.Parse(line)
+		if err != nil {
+			return count, err
+		}
+		if cellRows {
+			cells := make([]string, len(row.Items))
+			for i, v := range row.Items {
+				cells[i] = Cell(v)
+			}
+			cw.Write(cells)
+			count++
+			continue
+		}
+		for i, v := range Cells(row, header) {
+			record[i] = ""
+			if v != nil {
+				record[i] = Cell(v)
+			}
+		}
+		cw.Write(record)
+		count++
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return count, err
+	}
+	return count, bw.Flush()
+}
+
+// nextValue reads one whole value from dec's tokens
+func nextValue(dec *json.Decoder) (*jsonfmt.Node, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	return readValue(dec, tok)
+}
+
+// readValue builds the value that starts with tok, keeping key order and
+// number literals, which decoding into Go values would lose
+func readValue(dec *json.Decoder, tok json.Token) (*jsonfmt.Node, error) {
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			n := &jsonfmt.Node{Kind: jsonfmt.Object}
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				v, err := nextValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				n.Members = append(n.Members, jsonfmt.Member{Key: key.(string), Value: v})
+			}
+			_, err := dec.Token()
+			return n, err
+		case '[':
+			n := &jsonfmt.Node{Kind: jsonfmt.Array, Items: []*jsonfmt.Node{}}
+			for dec.More() {
+				v, err := nextValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				n.Items = append(n.Items, v)
+			}
+			_, err := dec.Token()
+			return n, err
+		}
+		return nil, fmt.Errorf("unexpected %v", t)
+	case string:
+		return jsonfmt.NewString(t), nil
+	case json.Number:
+		return jsonfmt.NewNumber(t.String()), nil
+	case bool:
+		return &jsonfmt.Node{Kind: jsonfmt.Bool, Value: strconv.FormatBool(t)}, nil
+	case nil:
+		return &jsonfmt.Node{Kind: jsonfmt.Null}, nil
+	}
+	return nil, fmt.Errorf("unexpected token %v", tok)
+}
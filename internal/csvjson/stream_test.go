@@ -0,0 +1,193 @@
+package csvjson
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCSVToJSON(t *testing.T) {
+	no := false
+	tests := []struct {
+		name  string
+		text  string
+		opts  StreamOptions
+		want  string
+		count int
+	}{
+		{
+			name:  "typed",
+			text:  "zip,n,ok\n00123,7,true\n00456,8.5,false\n",
+			opts:  StreamOptions{InferTypes: true},
+			want:  "[\n{\"zip\":\"00123\",\"n\":7,\"ok\":true},\n{\"zip\":\"00456\",\"n\":8.5,\"ok\":false}\n]\n",
+			count: 2,
+		},
+		{
+			name:  "strings",
+			text:  "a,b\n1,x\n",
+			want:  "[\n{\"a\":\"1\",\"b\":\"x\"}\n]\n",
+			count: 1,
+		},
+		{
+			name:  "ndjson",
+			text:  "a;b\n1;2\n3;4\n",
+			opts:  StreamOptions{InferTypes: true, NDJSON: true},
+			want:  "{\"a\":1,\"b\":2}\n{\"a\":3,\"b\":4}\n",
+			count: 2,
+		},
+		{
+			name:  "unflatten",
+			text:  "id,user.name,tags.0,tags.1\n1,ada,a,b\n",
+			opts:  StreamOptions{InferTypes: true, Unflatten: Dot},
+			want:  "[\n{\"id\":1,\"user\":{\"name\":\"ada\"},\"tags\":[\"a\",\"b\"]}\n]\n",
+			count: 1,
+		},
+		{
+			name:  "no header",
+			text:  "1,2\n3,4\n",
+			opts:  StreamOptions{Delimiter: ',', Header: &no},
+			want:  "[\n{\"column1\":\"1\",\"column2\":\"2\"},\n{\"column1\":\"3\",\"column2\":\"4\"}\n]\n",
+			count: 2,
+		},
+		{
+			name: "header only",
+			text: "a,b\n",
+			want: "[]\n",
+		},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		count, err := CSVToJSON(strings.NewReader(tt.text), &out, tt.opts)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if out.String() != tt.want || count != tt.count {
+			t.Errorf("%s: wrote %d records:\n%s\nwant %d:\n%s", tt.name, count, out.String(), tt.count, tt.want)
+		}
+	}
+}
+
+// TestCSVToJSONLateCell checks that a cell past the sampled rows which does
+// not fit its column's type is written as a string
+func TestCSVToJSONLateCell(t *testing.T) {
+	var in strings.Builder
+	in.WriteString("n\n")
+	for i := range sampleRows {
+		in.WriteString(strings.Repeat("1", i%3+1) + "\n")
+	}
+	in.WriteString("n/a\n")
+	var out bytes.Buffer
+	count, err := CSVToJSON(strings.NewReader(in.String()), &out, StreamOptions{InferTypes: true, NDJSON: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != sampleRows+1 || !strings.HasPrefix(out.String(), "{\"n\":1}\n") || !strings.HasSuffix(out.String(), "{\"n\":\"n/a\"}\n") {
+		t.Errorf("wrote %d records ending %q", count, out.String()[max(0, out.Len()-40):])
+	}
+}
+
+func TestCSVToJSONErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "empty", text: "", want: "CSV has no data"},
+		{name: "ragged", text: "a,b\n1,2\n3\n", want: "line 3: wrong number of fields"},
+		{name: "bad quote", text: "a,b\n1,\"x\"y\n", want: "line 2:"},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		_, err := CSVToJSON(strings.NewReader(tt.text), &out, StreamOptions{})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want one containing %q", tt.name, err, tt.want)
+		}
+		if out.Len() > 0 {
+			t.Errorf("%s: wrote %q before failing", tt.name, out.String())
+		}
+	}
+}
+
+func TestJSONToCSV(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		opts  StreamOptions
+		want  string
+		count int
+	}{
+		{
+			name:  "late column",
+			text:  `[{"id":1,"user":{"name":"ada"}},{"id":2,"tags":["a","b"]}]`,
+			want:  "id,user.name,tags.0,tags.1\n1,ada,,\n2,,a,b\n",
+			count: 2,
+		},
+		{
+			name:  "ndjson",
+			text:  "{\"a\":\"x,y\",\"b\":null}\n{\"a\":\"say \\\"hi\\\"\",\"b\":1.50}\n",
+			want:  "a,b\n\"x,y\",\n\"say \"\"hi\"\"\",1.50\n",
+			count: 2,
+		},
+		{
+			name:  "array rows",
+			text:  `[["a",1,null],["b",{"c":2}]]`,
+			want:  "a,1,\nb,\"{\"\"c\"\":2}\"\n",
+			count: 2,
+		},
+		{
+			name:  "sorted with tab",
+			text:  `[{"tags":["a","b","c","d","e","f","g","h","i","j","k"],"id":1}]`,
+			opts:  StreamOptions{Sorted: true, Comma: '\t', Columns: []string{"id", "tags.10", "tags.2"}},
+			want:  "id\ttags.10\ttags.2\n1\tk\tc\n",
+			count: 1,
+		},
+		{
+			name:  "bracket rows without header",
+			text:  `{"id":1,"pets":[{"n":"rex"},{"n":"tom"}]}`,
+			opts:  StreamOptions{Flatten: FlattenOptions{Notation: Bracket, Arrays: ExplodeRows}, NoHeader: true},
+			want:  "1,rex\n1,tom\n",
+			count: 2,
+		},
+		{
+			name:  "scalars",
+			text:  `[1,"two"]`,
+			want:  "value\n1\ntwo\n",
+			count: 2,
+		},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		count, err := JSONToCSV(strings.NewReader(tt.text), &out, tt.opts)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if out.String() != tt.want || count != tt.count {
+			t.Errorf("%s: wrote %d rows:\n%s\nwant %d:\n%s", tt.name, count, out.String(), tt.count, tt.want)
+		}
+	}
+}
+
+func TestJSONToCSVErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		opts StreamOptions
+		want string
+	}{
+		{name: "empty", text: "  ", want: "JSON has no data"},
+		{name: "truncated", text: `[{"a":1},{"a":`, want: "record 2, byte"},
+		{name: "trailing", text: `[{"a":1}] {}`, want: "there is more after the top-level array"},
+		{name: "mixed rows", text: `[[1],{"a":1}]`, want: "record 2 is not an array like the first"},
+		{name: "unknown column", text: `[{"a":1}]`, opts: StreamOptions{Columns: []string{"b"}}, want: `there is no column "b"`},
+		{name: "spool", text: `[{"a":1},{"a":"` + strings.Repeat("x", 1<<20) + `"}]`, opts: StreamOptions{MaxSpool: 1 << 20}, want: "record 2: the records flatten to more than 1 MB of rows"},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		_, err := JSONToCSV(strings.NewReader(tt.text), &out, tt.opts)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want one containing %q", tt.name, err, tt.want)
+		}
+	}
+}
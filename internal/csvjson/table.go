@@ -0,0 +1,202 @@
+package csvjson
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Ndeta100/orbit2x/internal/jsonfmt"
+)
+
+// Shapes of JSON output
+const (
+	Objects = "objects" // [{"name": "Ada", "age": 36}, ...]
+	Arrays  = "arrays"  // [["name", "age"], ["Ada", 36], ...]
+	Columns = "columns" // {"name": ["Ada", ...], "age": [36, ...]}
+)
+
+// maxRaggedRows bounds how many bad rows one error lists
+const maxRaggedRows = 5
+
+// Table is a CSV text split into named, typed columns
+type Table struct {
+	Dialect Dialect
+	Header  []string // column names, made up as column1, column2... without a header row
+	Types   []Type
+	Rows    []Record
+}
+
+// Options control how a table is read
+type Options struct {
+	Dialect    *Dialect        // sniffed when nil
+	InferTypes bool            // otherwise every column is a string unless overridden
+	Overrides  map[string]Type // by column name or 1-based position
+}
+
+// NewTable reads text into a table. Every row must be as wide as the first,
+// and the error for ragged rows names them
+func NewTable(text string, opts Options) (*Table, []string, error) {
+	d := Sniff(text)
+	if opts.Dialect != nil {
+		d = *opts.Dialect
+	}
+	records, err := Read(text, d)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil, fmt.Errorf("CSV has no data")
+	}
+
+	t := &Table{Dialect: d, Rows: records}
+	width := len(records[0].Fields)
+	var ragged []string
+	for i, rec := range records {
+		if len(rec.Fields) != width {
+			ragged = append(ragged, fmt.Sprintf("row %d (line %d) has %d", i+1, rec.Line, len(rec.Fields)))
+		}
+	}
+	if len(ragged) > 0 {
+		first := "the first row"
+		if d.Header {
+			first = "the header"
+		}
+		msg := fmt.Sprintf("%s has %d fields, but %s", first, width, strings.Join(ragged[:min(len(ragged), maxRaggedRows)], ", "))
+		if len(ragged) > maxRaggedRows {
+			msg += fmt.Sprintf(" and %d more rows differ", len(ragged)-maxRaggedRows)
+		}
+		return nil, nil, fmt.Errorf("%s", msg)
+	}
+
+	var warnings []string
+	if d.Header {
+		t.Header, warnings = Names(records[0].Fields)
+		t.Rows = records[1:]
+	} else {
+		for i := range width {
+			t.Header = append(t.Header, "column"+strconv.Itoa(i+1))
+		}
+	}
+
+	t.Types = make([]Type, width)
+	for c := range t.Types {
+		if opts.InferTypes {
+			t.Types[c] = Infer(t.Column(c))
+		}
+	}
+	for column, typ := range opts.Overrides {
+		c := t.index(column)
+		if c < 0 {
+			return nil, nil, fmt.Errorf("there is no column %q to set the type of", column)
+		}
+		t.Types[c] = typ
+	}
+	return t, warnings, nil
+}
+
+// Names makes header cells usable as keys, naming empty cells by position
+// and numbering repeats
+func Names(header []string) ([]string, []string) {
+	var out, warnings []string
+	seen := map[string]bool{}
+	for i, h := range header {
+		name := h
+		if strings.TrimSpace(name) == "" {
+			name = "column" + strconv.Itoa(i+1)
+			warnings = append(warnings, fmt.Sprintf("column %d has no name in the header, so it is called %s", i+1, name))
+		}
+		if seen[name] {
+			n := 2
+			for seen[name+"_"+strconv.Itoa(n)] {
+				n++
+			}
+			warnings = append(warnings, fmt.Sprintf("column %q appears more than once in the header, so column %d is called %s", name, i+1, name+"_"+strconv.Itoa(n)))
+			name += "_" + strconv.Itoa(n)
+		}
+		seen[name] = true
+		out = append(out, name)
+	}
+	return out, warnings
+}
+
+// Column gives the cells of column c
+func (t *Table) Column(c int) []string {
+	values := make([]string, len(t.Rows))
+	for i, rec := range t.Rows {
+		values[i] = rec.Fields[c]
+	}
+	return values
+}
+
+// index finds a column by name, or else by 1-based position
+func (t *Table) index(column string) int {
+	for c, name := range t.Header {
+		if name == column {
+			return c
+		}
+	}
+	if n, err := strconv.Atoi(column); err == nil && n >= 1 && n <= len(t.Header) {
+		return n - 1
+	}
+	return -1
+}
+
+// JSON converts the table to the given shape. A cell that does not fit a
+// column's forced type is an error naming its row and line
+func (t *Table) JSON(shape string) (*jsonfmt.Node, error) {
+	cells := make([][]*jsonfmt.Node, len(t.Rows))
+	for r, rec := range t.Rows {
+		cells[r] = make([]*jsonfmt.Node, len(t.Header))
+		for c, field := range rec.Fields {
+			v, err := t.Types[c].Value(field)
+			if err != nil {
+				return nil, fmt.Errorf("row %d (line %d), column %s: %v", r+t.offset(), rec.Line, t.Header[c], err)
+			}
+			cells[r][c] = v
+		}
+	}
+
+	switch shape {
+	case Arrays:
+		out := &jsonfmt.Node{Kind: jsonfmt.Array, Items: []*jsonfmt.Node{}}
+		if t.Dialect.Header {
+			header := &jsonfmt.Node{Kind: jsonfmt.Array}
+			for _, name := range t.Header {
+				header.Items = append(header.Items, jsonfmt.NewString(name))
+			}
+			out.Items = append(out.Items, header)
+		}
+		for _, row := range cells {
+			out.Items = append(out.Items, &jsonfmt.Node{Kind: jsonfmt.Array, Items: row})
+		}
+		return out, nil
+	case Columns:
+		out := &jsonfmt.Node{Kind: jsonfmt.Object}
+		for c, name := range t.Header {
+			column := &jsonfmt.Node{Kind: jsonfmt.Array, Items: []*jsonfmt.Node{}}
+			for _, row := range cells {
+				column.Items = append(column.Items, row[c])
+			}
+			out.Members = append(out.Members, jsonfmt.Member{Key: name, Value: column})
+		}
+		return out, nil
+	}
+
+	out := &jsonfmt.Node{Kind: jsonfmt.Array, Items: []*jsonfmt.Node{}}
+	for _, row := range cells {
+		obj := &jsonfmt.Node{Kind: jsonfmt.Object}
+		for c, name := range t.Header {
+			obj.Members = append(obj.Members, jsonfmt.Member{Key: name, Value: row[c]})
+		}
+		out.Items = append(out.Items, obj)
+	}
+	return out, nil
+}
+
+// offset turns an index into Rows into a 1-based row number in the text
+func (t *Table) offset() int {
+	if t.Dialect.Header {
+		return 2
+	}
+	return 1
+}
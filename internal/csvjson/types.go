@@ -0,0 +1,161 @@
+package csvjson
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Ndeta100/orbit2x/internal/jsonfmt"
+)
+
+// Type is what a column's cells become in JSON
+type Type int
+
+const (
+	String Type = iota
+	Integer
+	Number
+	Boolean
+)
+
+var typeNames = []string{"string", "integer", "number", "boolean"}
+
+func (t Type) String() string {
+	return typeNames[t]
+}
+
+var (
+	integerText = regexp.MustCompile(`^-?(0|[1-9][0-9]*)$`)
+	numberText  = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?$`)
+)
+
+// ParseType reads a type name, accepting common aliases
+func ParseType(name string) (Type, bool) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "string", "str", "text":
+		return String, true
+	case "integer", "int":
+		return Integer, true
+	case "number", "float", "decimal", "num":
+		return Number, true
+	case "boolean", "bool":
+		return Boolean, true
+	}
+	return String, false
+}
+
+// Infer gives the narrowest type every non-empty value fits. Only plainly
+// written numbers count, so a leading zero, a plus sign, an exponent or
+// NaN keeps the column a string
+func Infer(values []string) Type {
+	integer, number, boolean, seen := true, true, true, false
+	for _, v := range values {
+		if v == "" {
+			continue
+		}
+		seen = true
+		integer = integer && integerText.MatchString(v)
+		number = number && numberText.MatchString(v)
+		boolean = boolean && isBool(v)
+	}
+	switch {
+	case !seen:
+		return String
+	case integer:
+		return Integer
+	case number:
+		return Number
+	case boolean:
+		return Boolean
+	}
+	return String
+}
+
+func isBool(s string) bool {
+	return strings.EqualFold(s, "true") || strings.EqualFold(s, "false")
+}
+
+// fits reports whether an inferred column of type t would take s
+func (t Type) fits(s string) bool {
+	switch t {
+	case Integer:
+		return integerText.MatchString(s)
+	case Number:
+		return numberText.MatchString(s)
+	case Boolean:
+		return isBool(s)
+	}
+	return true
+}
+
+// Value converts one cell. Empty cells are null in typed columns. A column
+// forced to a type is more lenient than inference, so "00123" is the integer
+// 123 and "yes" is true, but a cell that still does not fit is an error
+func (t Type) Value(s string) (*jsonfmt.Node, error) {
+	if t == String {
+		return jsonfmt.NewString(s), nil
+	}
+	if strings.TrimSpace(s) == "" {
+		return &jsonfmt.Node{Kind: jsonfmt.Null}, nil
+	}
+	if t.fits(s) {
+		if t == Boolean {
+			return &jsonfmt.Node{Kind: jsonfmt.Bool, Value: strings.ToLower(s)}, nil
+		}
+		return jsonfmt.NewNumber(s), nil
+	}
+
+	s = strings.TrimSpace(s)
+	switch t {
+	case Integer:
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return jsonfmt.NewNumber(strconv.FormatInt(i, 10)), nil
+		}
+	case Number:
+		if jsonfmt.IsNumber(s) {
+			return jsonfmt.NewNumber(s), nil
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+			return jsonfmt.NewNumber(strconv.FormatFloat(f, 'g', -1, 64)), nil
+		}
+	case Boolean:
+		switch strings.ToLower(s) {
+		case "true", "yes", "y", "on", "1":
+			return &jsonfmt.Node{Kind: jsonfmt.Bool, Value: "true"}, nil
+		case "false", "no", "n", "off", "0":
+			return &jsonfmt.Node{Kind: jsonfmt.Bool, Value: "false"}, nil
+		}
+	}
+	return nil, fmt.Errorf("%q is not %s", s, article(t))
+}
+
+func article(t Type) string {
+	if t == Integer {
+		return "an integer"
+	}
+	return "a " + t.String()
+}
+
+// ParseOverrides reads a list such as "zip: string, 3: number", naming
+// columns by header or by 1-based position
+func ParseOverrides(spec string) (map[string]Type, error) {
+	overrides := map[string]Type{}
+	for _, part := range strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		i := strings.LastIndexByte(part, ':')
+		if i < 0 {
+			return nil, fmt.Errorf("%q should be column:type", strings.TrimSpace(part))
+		}
+		column, name := strings.TrimSpace(part[:i]), part[i+1:]
+		t, ok := ParseType(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown type %q for column %q; use string, integer, number or boolean", strings.TrimSpace(name), column)
+		}
+		overrides[column] = t
+	}
+	return overrides, nil
+}
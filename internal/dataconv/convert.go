@@ -0,0 +1,231 @@
+// Package dataconv converts between data formats through one tree. Every
+// format is read into a jsonfmt tree and written out of one, so each format
+// needs only a reader and a writer rather than a converter per pair.
+//
+// The tree keeps JSON's types: strings, numbers by literal, booleans, null,
+// arrays and ordered objects, plus jsonfmt.DateTime tags for dates. Formats
+// that cannot hold a type say so in a warning rather than failing, and
+// formats whose text is untyped (XML, CSV, INI and .env) can have numbers
+// and booleans inferred on the way in
+package dataconv
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Ndeta100/orbit2x/internal/jsonfmt"
+)
+
+// Formats, by display name
+const (
+	JSON   = "JSON"
+	YAML   = "YAML"
+	TOML   = "TOML"
+	XML    = "XML"
+	CSV    = "CSV"
+	NDJSON = "NDJSON"
+	INI    = "INI"
+	Env    = ".env"
+	HCL    = "HCL"
+)
+
+// maxDepth bounds nesting in the hand-written parsers, as encoding/json
+// bounds it, so deeply nested input is an error rather than a stack overflow
+const maxDepth = 10000
+
+// Formats lists every supported format in menu order
+var Formats = []string{JSON, YAML, TOML, XML, CSV, NDJSON, INI, Env, HCL}
+
+// Options tune reading and writing. The zero value is usable
+type Options struct {
+	Indent     string // per level for JSON, YAML, TOML and HCL; two spaces when empty
+	InferTypes bool   // read numbers, booleans and null out of untyped text
+
+	// XML conventions: attributes become members named AttrPrefix+name and
+	// text beside attributes or child elements becomes TextKey
+	AttrPrefix string // "@" when empty
+	TextKey    string // "#text" when empty
+	Root       string // element written around a tree without a single top key; "root" when empty
+
+	Delimiter  rune // CSV field separator; comma when zero
+	Headerless bool // CSV has no header row, so rows read as arrays
+}
+
+func (o Options) indent() string {
+	if o.Indent == "" {
+		return "  "
+	}
+	return o.Indent
+}
+
+func (o Options) attrPrefix() string {
+	if o.AttrPrefix == "" {
+		return "@"
+	}
+	return o.AttrPrefix
+}
+
+func (o Options) textKey() string {
+	if o.TextKey == "" {
+		return "#text"
+	}
+	return o.TextKey
+}
+
+// Lookup matches a format name case-insensitively, also accepting common
+// aliases such as yml, jsonl and env
+func Lookup(name string) (string, bool) {
+	switch strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), ".")) {
+	case "json":
+		return JSON, true
+	case "yaml", "yml":
+		return YAML, true
+	case "toml":
+		return TOML, true
+	case "xml":
+		return XML, true
+	case "csv":
+		return CSV, true
+	case "ndjson", "jsonl", "json lines":
+		return NDJSON, true
+	case "ini", "cfg":
+		return INI, true
+	case "env", "dotenv":
+		return Env, true
+	case "hcl", "tf":
+		return HCL, true
+	}
+	return "", false
+}
+
+// Parse reads text in a format. Syntax errors are *jsonfmt.ParseError
+// where the position is known
+func Parse(format, text string, opts Options) (*jsonfmt.Node, []string, error) {
+	switch format {
+	case JSON:
+		n, warnings, err := jsonfmt.Parse([]byte(text))
+		return n, warningText(warnings), err
+	case YAML:
+		n, warnings, err := jsonfmt.ParseYAML(text)
+		return n, warningText(warnings), err
+	case TOML:
+		return parseTOML(text)
+	case XML:
+		return parseXML(text, opts)
+	case CSV:
+		return parseCSV(text, opts)
+	case NDJSON:
+		return parseNDJSON(text)
+	case INI:
+		return parseINI(text, opts)
+	case Env:
+		return parseEnv(text, opts)
+	case HCL:
+		return parseHCL(text)
+	}
+	return nil, nil, fmt.Errorf("unknown format %q", format)
+}
+
+// Write renders a tree in a format. Values the format cannot hold are
+// dropped or stringified with a warning; an error means the tree's shape
+// does not fit at all, such as a list where TOML needs a table
+func Write(format string, n *jsonfmt.Node, opts Options) (string, []string, error) {
+	switch format {
+	case JSON:
+		return jsonfmt.Format(n, jsonfmt.Options{Indent: opts.indent()}), nil, nil
+	case YAML:
+		return writeYAML(n, opts)
+	case TOML:
+		return writeTOML(n, opts)
+	case XML:
+		return writeXML(n, opts)
+	case CSV:
+		return writeCSV(n, opts)
+	case NDJSON:
+		return writeNDJSON(n)
+	case INI:
+		return writeINI(n)
+	case Env:
+		return writeEnv(n)
+	case HCL:
+		return writeHCL(n, opts)
+	}
+	return "", nil, fmt.Errorf("unknown format %q", format)
+}
+
+// Convert reads text in one format and writes it in another
+func Convert(from, to, text string, opts Options) (string, []string, error) {
+	n, warnings, err := Parse(from, text, opts)
+	if err != nil {
+		return "", warnings, err
+	}
+	out, more, err := Write(to, n, opts)
+	return out, append(warnings, more...), err
+}
+
+func warningText(warnings []jsonfmt.Warning) []string {
+	var out []string
+	for _, w := range warnings {
+		out = append(out, w.String())
+	}
+	return out
+}
+
+// infer reads an untyped field as a number, boolean or null when it is
+// exactly one, so "00123" and "1.50 " stay strings
+func infer(s string) *jsonfmt.Node {
+	switch s {
+	case "true", "false":
+		return &jsonfmt.Node{Kind: jsonfmt.Bool, Value: s}
+	case "null":
+		return &jsonfmt.Node{Kind: jsonfmt.Null}
+	}
+	if jsonfmt.IsNumber(s) {
+		return jsonfmt.NewNumber(s)
+	}
+	return jsonfmt.NewString(s)
+}
+
+// text gives a scalar's untyped text, for formats that only hold strings
+func text(n *jsonfmt.Node) string {
+	if n.Kind == jsonfmt.Null {
+		return ""
+	}
+	return n.Value
+}
+
+// distinct lists an object's members once per key, keeping the first
+// position and the last value as most parsers do
+func distinct(n *jsonfmt.Node) []jsonfmt.Member {
+	at := map[string]int{}
+	var out []jsonfmt.Member
+	for _, m := range n.Members {
+		if i, ok := at[m.Key]; ok {
+			out[i].Value = m.Value
+			continue
+		}
+		at[m.Key] = len(out)
+		out = append(out, m)
+	}
+	return out
+}
+
+func isScalar(n *jsonfmt.Node) bool {
+	return n.Kind != jsonfmt.Array && n.Kind != jsonfmt.Object
+}
+
+// position converts a byte offset to a 1-based line and column
+func position(text string, offset int) (int, int) {
+	offset = min(offset, len(text))
+	line := 1 + strings.Count(text[:offset], "\n")
+	col := offset - strings.LastIndexByte(text[:offset], '\n')
+	return line, col
+}
+
+// pathText shows a JSON Pointer for warnings, naming the root explicitly
+func pathText(path string) string {
+	if path == "" {
+		return "the document"
+	}
+	return path
+}
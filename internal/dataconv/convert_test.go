@@ -0,0 +1,296 @@
+package dataconv
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Ndeta100/orbit2x/internal/jsonfmt"
+	"github.com/Ndeta100/orbit2x/internal/jsonfmt/jsonfmttest"
+)
+
+// TestRoundTrip reads a document in each format, writes it back in the same
+// format and reads that again: the trees must match and a second write must
+// reproduce the first
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		format string
+		opts   Options
+		text   string
+	}{
+		{format: JSON, text: `{"name":"orbit","port":8080,"ratio":0.25,"big":12345678901234567890,"on":true,"none":null,"tags":["a","b"],"nested":{"deep":[{"x":1},[]]}}`},
+		{format: YAML, text: "name: orbit\nport: 8080\nsince: 2024-03-01\nquoted: \"123\"\nempty: null\nlist:\n  - 1\n  - two\n  - {x: true}\nmultiline: |\n  line one\n  line two\n"},
+		{format: TOML, text: `title = "TOML \"example\""
+hex = 0xff
+under = 1_000
+float = 6.5e-3
+literal = 'C:\path'
+multi = """
+Roses \
+  are red"""
+dob = 1979-05-27T07:32:00-08:00
+day = 1979-05-27
+at = 07:32:00
+mixed = [1, "two", { three = 3 }]
+"quoted key" = true
+
+[server]
+host = "localhost"
+ports = [8000, 8001]
+
+[server.limits]
+rps = 100
+
+[[products]]
+name = "Hammer"
+
+[[products]]
+name = "Nail"
+tags.color = "grey"
+`},
+		{format: XML, opts: Options{InferTypes: true}, text: `<?xml version="1.0"?>
+<catalog xmlns:x="urn:x">
+  <!-- comment -->
+  <book id="bk101" x:lang="en">
+    <author>Gambardella, Matthew</author>
+    <price>44.95</price>
+    <note/>
+    <blank></blank>
+  </book>
+  <book id="bk102">
+    <author>Ralls &amp; Kim</author>
+    <price currency="USD">5.95</price>
+    <title>Midnight <![CDATA[<Rain>]]></title>
+  </book>
+</catalog>`},
+		{format: CSV, opts: Options{InferTypes: true}, text: "id,name,score,active\n1,\"Smith, J\",9.5,true\n2,\"say \"\"hi\"\"\",,false\n"},
+		{format: CSV, opts: Options{Headerless: true}, text: "a,b\n1,2,3\n"},
+		{format: NDJSON, text: "{\"a\":1}\n\n[1,2]\n\"text\"\nnull\n"},
+		{format: INI, text: "; top comment\nname = orbit\n\n[database]\nhost = db.local\nport: 5432\nquoted = \"  spaced  \"\n\n[empty]\n"},
+		{format: INI, opts: Options{InferTypes: true}, text: "debug = true\n[limits]\nmax = 10\nratio = 0.5\n"},
+		{format: Env, text: "# comment\nexport APP_NAME=orbit\nEMPTY=\nSPACED=\"hello world\" # trailing\nLITERAL='$HOME/x'\nMULTI=\"line1\nline2\"\nESCAPED=\"tab\\there\"\nURL=http://x.test/?a=1#frag\n"},
+		{format: HCL, text: `# Terraform-style
+region = "eu-west-1"
+count  = 3
+enabled = true
+nothing = null
+tags = {
+  Name = "web"
+  "with space" = "yes"
+}
+ids = [1, 2, 3]
+ref = var.instance_type
+
+resource "aws_instance" "web" {
+  ami = "ami-123"
+  /* block comment */
+  ebs { size = 10 }
+  ebs { size = 20 }
+}
+
+resource "aws_instance" "db" {
+  ami = "ami-456"
+}
+
+script = <<-EOT
+    echo hello
+      indented
+    EOT
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			first, _, err := Parse(tt.format, tt.text, tt.opts)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			out, _, err := Write(tt.format, first, tt.opts)
+			if err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			second, _, err := Parse(tt.format, out, tt.opts)
+			if err != nil {
+				t.Fatalf("Parse of written %s: %v\n%s", tt.format, err, out)
+			}
+			if !jsonfmt.Equal(first, second) {
+				t.Fatalf("round trip changed the tree\nbefore: %s\nafter:  %s\nwritten:\n%s", jsonfmttest.Minified(first), jsonfmttest.Minified(second), out)
+			}
+			again, _, err := Write(tt.format, second, tt.opts)
+			if err != nil || again != out {
+				t.Errorf("second write differs (err %v)\nfirst:\n%s\nsecond:\n%s", err, out, again)
+			}
+		})
+	}
+}
+
+// TestViaFormat converts JSON to each format and back, checking what
+// survives: everything in typed formats, and with type inference the
+// values untyped formats can express
+func TestViaFormat(t *testing.T) {
+	doc := `{"name":"orbit","port":8080,"ratio":0.5,"on":false,"server":{"host":"h","tags":["a","b"]}}`
+	tests := []struct {
+		format string
+		opts   Options
+		in     string
+		want   string // empty when the input should come back unchanged
+	}{
+		{format: YAML, in: doc},
+		{format: YAML, in: `{"s":"true","n":"1.5","e":"","null":null,"date":"2024-01-01","big":1e400}`},
+		{format: TOML, in: doc},
+		{format: TOML, in: `{"list":[{"a":1},{"a":2,"b":{"c":[]}}],"empty":{},"gone":null}`, want: `{"list":[{"a":1},{"a":2,"b":{"c":[]}}],"empty":{}}`},
+		{format: HCL, in: doc},
+		{format: HCL, in: `{"a":[{"b":1},{"c":[{"d":null}]}],"e":{"f g":{"h":"${x}"}}}`},
+		{format: NDJSON, in: `[{"a":1},[2],"three",null]`},
+		{format: NDJSON, in: `{"single":true}`, want: `[{"single":true}]`},
+		{format: XML, opts: Options{InferTypes: true}, in: doc, want: `{"root":{"name":"orbit","port":8080,"ratio":0.5,"on":false,"server":{"host":"h","tags":["a","b"]}}}`},
+		{format: XML, opts: Options{InferTypes: true}, in: `{"item":{"@id":7,"#text":"hello","child":null}}`},
+		{format: XML, in: `{"item":{"@id":7,"#text":"hello"}}`, want: `{"item":{"@id":"7","#text":"hello"}}`},
+		{format: XML, opts: Options{InferTypes: true, AttrPrefix: "-", TextKey: "_"}, in: `{"item":{"-id":7,"_":"hello"}}`},
+		{format: CSV, opts: Options{InferTypes: true}, in: `[{"a":1,"b":"x"},{"b":"y","c":true}]`, want: `[{"a":1,"b":"x","c":""},{"a":"","b":"y","c":true}]`},
+		{format: CSV, opts: Options{InferTypes: true, Headerless: true, Delimiter: ';'}, in: `[[1,"a;b"],[2,"c"]]`},
+		{format: INI, opts: Options{InferTypes: true}, in: `{"top":1,"section":{"key":"value","n":2}}`},
+		{format: Env, opts: Options{InferTypes: true}, in: `{"A":"x y","B":2,"db":{"host":"h"}}`, want: `{"A":"x y","B":2,"db_host":"h"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			in := jsonfmttest.MustParse(t, tt.in)
+			out, _, err := Write(tt.format, in, tt.opts)
+			if err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			back, _, err := Parse(tt.format, out, tt.opts)
+			if err != nil {
+				t.Fatalf("Parse: %v\n%s", err, out)
+			}
+			want := in
+			if tt.want != "" {
+				want = jsonfmttest.MustParse(t, tt.want)
+			}
+			if !jsonfmt.Equal(back, want) {
+				t.Errorf("%s via %s came back as %s, want %s\nwritten:\n%s", tt.in, tt.format, jsonfmttest.Minified(back), jsonfmttest.Minified(want), out)
+			}
+		})
+	}
+}
+
+func TestTypesKept(t *testing.T) {
+	// Dates stay dates between YAML and TOML, not quoted strings
+	out, _, err := Convert(YAML, TOML, "when: 2024-03-01\nat: 2024-03-01T10:00:00Z\n", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "when = 2024-03-01\nat = 2024-03-01T10:00:00Z\n"; out != want {
+		t.Errorf("YAML to TOML = %q, want %q", out, want)
+	}
+	out, _, err = Convert(TOML, YAML, "d = 1979-05-27\ns = \"1979-05-27\"\n", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "d: 1979-05-27\ns: \"1979-05-27\"\n"; out != want {
+		t.Errorf("TOML to YAML = %q, want %q", out, want)
+	}
+
+	// Number literals are kept exactly, and TOML's other bases become decimal
+	n, _, err := Parse(TOML, "a = 0x1F\nb = 0o17\nc = 0b101\nd = 1.50\ne = 99999999999999999999.0\n", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := jsonfmttest.Minified(n), `{"a":31,"b":15,"c":5,"d":1.50,"e":99999999999999999999.0}`; got != want {
+		t.Errorf("TOML = %s, want %s", got, want)
+	}
+
+	// Values that would read back as another type are quoted
+	out, _, err = Convert(JSON, YAML, `{"a":"yes","b":"1","c":"null"}`, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "a: \"yes\"\nb: \"1\"\nc: \"null\"\n"; out != want {
+		t.Errorf("JSON to YAML = %q, want %q", out, want)
+	}
+
+	// Untyped formats only guess when asked, and never for padded numbers
+	n, _, err = Parse(CSV, "zip,count\n00123,7\n", Options{InferTypes: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := jsonfmttest.Minified(n), `[{"zip":"00123","count":7}]`; got != want {
+		t.Errorf("CSV = %s, want %s", got, want)
+	}
+}
+
+func TestWriteWarnings(t *testing.T) {
+	tests := []struct {
+		format  string
+		in      string
+		warning string
+	}{
+		{TOML, `{"a":null}`, "/a is null"},
+		{TOML, `{"a":[1,null]}`, "/a/1 is null"},
+		{XML, `{"a":{"b":[]}}`, "empty array"},
+		{XML, `{"a":{"b":[1]}}`, "array of one"},
+		{XML, `{"a":{"1st":1}}`, "not a valid XML name"},
+		{CSV, `[{"a":{"b":1}}]`, "JSON text"},
+		{INI, `{"s":{"deep":{"x":1}}}`, "deeper than an INI section"},
+		{Env, `{"a":{"b":1}}`, "flattened"},
+		{HCL, `{"bad key":{"x":1}}`, "not a valid HCL name"},
+	}
+	for _, tt := range tests {
+		_, warnings, err := Write(tt.format, jsonfmttest.MustParse(t, tt.in), Options{})
+		if err != nil {
+			t.Errorf("Write(%s, %s): %v", tt.format, tt.in, err)
+			continue
+		}
+		if !strings.Contains(strings.Join(warnings, "\n"), tt.warning) {
+			t.Errorf("Write(%s, %s) warnings %q, want one containing %q", tt.format, tt.in, warnings, tt.warning)
+		}
+	}
+
+	for _, format := range []string{TOML, INI, Env, HCL} {
+		if _, _, err := Write(format, jsonfmttest.MustParse(t, `[1]`), Options{}); err == nil {
+			t.Errorf("Write(%s) of an array: want error", format)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		format string
+		text   string
+		line   int
+	}{
+		{TOML, "a = 1\nb = \n", 2},
+		{TOML, "a = 1\na = 2\n", 2},
+		{TOML, "[t]\nx = 1\n[t]\n", 3},
+		{TOML, "s = \"open\n", 1},
+		{TOML, "n = 1__0\n", 1},
+		{TOML, "d = 2024-13-01\n", 1},
+		{XML, "<a>\n<b></a>", 2},
+		{XML, "<a/><b/>", 1},
+		{CSV, "a,b\n1,2\n3\n", 3},
+		{NDJSON, "{}\n{bad}\n", 2},
+		{INI, "[ok]\n[broken\n", 2},
+		{Env, "A=1\nnot a line\n", 2},
+		{Env, "A=\"open\n", 1},
+		{HCL, "a = 1\nb {\n", 2},
+		{HCL, "a = \"x\" b\n", 1},
+		{HCL, "a = 1\na = 2\n", 2},
+		{TOML, "a = " + strings.Repeat("[", 5_000_000), 1},
+		{TOML, "a = " + strings.Repeat("{ b = ", maxDepth+1), 1},
+		{HCL, "a = " + strings.Repeat("[", 5_000_000), 1},
+		{HCL, "a = " + strings.Repeat("{ b = ", maxDepth+1), 1},
+		{HCL, strings.Repeat("b {\n", maxDepth+1), maxDepth + 1},
+	}
+	for _, tt := range tests {
+		_, _, err := Parse(tt.format, tt.text, Options{})
+		var pe *jsonfmt.ParseError
+		if !errors.As(err, &pe) {
+			t.Errorf("Parse(%s, %q) = %v, want a ParseError", tt.format, tt.text, err)
+			continue
+		}
+		if pe.Line != tt.line {
+			t.Errorf("Parse(%s, %q) error on line %d, want %d: %v", tt.format, tt.text, pe.Line, tt.line, err)
+		}
+	}
+}
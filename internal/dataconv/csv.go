@@ -0,0 +1,139 @@
+package dataconv
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Ndeta100/orbit2x/internal/jsonfmt"
+)
+
+// parseCSV reads rows as objects keyed by the header row, or as arrays when
+// the data is headerless
+func parseCSV(text string, opts Options) (*jsonfmt.Node, []string, error) {
+	r := csv.NewReader(strings.NewReader(text))
+	if opts.Delimiter != 0 {
+		r.Comma = opts.Delimiter
+	}
+	if opts.Headerless {
+		r.FieldsPerRecord = -1
+	}
+	records, err := r.ReadAll()
+	if err != nil {
+		var pe *csv.ParseError
+		if errors.As(err, &pe) {
+			return nil, nil, &jsonfmt.ParseError{Msg: pe.Err.Error(), Line: pe.Line, Column: pe.Column}
+		}
+		return nil, nil, err
+	}
+	cell := jsonfmt.NewString
+	if opts.InferTypes {
+		cell = infer
+	}
+
+	out := &jsonfmt.Node{Kind: jsonfmt.Array, Items: []*jsonfmt.Node{}}
+	if opts.Headerless {
+		for _, record := range records {
+			row := &jsonfmt.Node{Kind: jsonfmt.Array, Items: []*jsonfmt.Node{}}
+			for _, field := range record {
+				row.Items = append(row.Items, cell(field))
+			}
+			out.Items = append(out.Items, row)
+		}
+		return out, nil, nil
+	}
+
+	var warnings []string
+	if len(records) > 0 {
+		seen := map[string]bool{}
+		for _, h := range records[0] {
+			if seen[h] {
+				warnings = append(warnings, fmt.Sprintf("column %q appears twice in the header; the last one wins", h))
+			}
+			seen[h] = true
+		}
+	}
+	for _, record := range records[min(1, len(records)):] {
+		row := &jsonfmt.Node{Kind: jsonfmt.Object}
+		for i, field := range record {
+			row.Set(records[0][i], cell(field))
+		}
+		out.Items = append(out.Items, row)
+	}
+	return out, warnings, nil
+}
+
+// writeCSV writes an array of objects under a header of every key in first
+// seen order, or an array of arrays as bare rows. Nested values are written
+// as JSON text
+func writeCSV(n *jsonfmt.Node, opts Options) (string, []string, error) {
+	rows := n.Items
+	if n.Kind != jsonfmt.Array {
+		rows = []*jsonfmt.Node{n}
+	}
+	objects := len(rows) > 0
+	for _, row := range rows {
+		if row.Kind != jsonfmt.Object {
+			objects = false
+		} else if !objects {
+			return "", nil, fmt.Errorf("CSV rows must be all objects or all arrays and values, not a mix")
+		}
+	}
+
+	var buf strings.Builder
+	cw := csv.NewWriter(&buf)
+	if opts.Delimiter != 0 {
+		cw.Comma = opts.Delimiter
+	}
+	var warnings []string
+	nested := false
+	field := func(v *jsonfmt.Node) string {
+		if isScalar(v) {
+			return text(v)
+		}
+		nested = true
+		return strings.TrimSuffix(jsonfmt.Format(v, jsonfmt.Options{Minify: true}), "\n")
+	}
+
+	if objects {
+		var header []string
+		index := map[string]int{}
+		for _, row := range rows {
+			for _, m := range row.Members {
+				if _, ok := index[m.Key]; !ok {
+					index[m.Key] = len(header)
+					header = append(header, m.Key)
+				}
+			}
+		}
+		cw.Write(header)
+		for _, row := range rows {
+			record := make([]string, len(header))
+			for _, m := range distinct(row) {
+				record[index[m.Key]] = field(m.Value)
+			}
+			cw.Write(record)
+		}
+	} else {
+		for _, row := range rows {
+			var record []string
+			if row.Kind == jsonfmt.Array {
+				for _, v := range row.Items {
+					record = append(record, field(v))
+				}
+			} else {
+				record = []string{field(row)}
+			}
+			cw.Write(record)
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return "", nil, err
+	}
+	if nested {
+		warnings = append(warnings, "nested arrays and objects were written as JSON text in their cells")
+	}
+	return buf.String(), warnings, nil
+}
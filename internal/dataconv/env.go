@@ -0,0 +1,202 @@
+package dataconv
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Ndeta100/orbit2x/internal/jsonfmt"
+)
+
+var envKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// parseEnv reads KEY=value lines into a flat object, as dotenv loaders do:
+// export prefixes and # comments are skipped, single quotes are literal and
+// double quotes take escapes and may span lines
+func parseEnv(text string, opts Options) (*jsonfmt.Node, []string, error) {
+	value := jsonfmt.NewString
+	if opts.InferTypes {
+		value = infer
+	}
+	root := &jsonfmt.Node{Kind: jsonfmt.Object}
+	var warnings []string
+	fail := func(at int, msg string) error {
+		line, col := position(text, at)
+		return &jsonfmt.ParseError{Msg: msg, Line: line, Column: col}
+	}
+
+	for pos := 0; pos < len(text); {
+		end := strings.IndexByte(text[pos:], '\n')
+		if end < 0 {
+			end = len(text) - pos
+		}
+		line := strings.TrimSpace(text[pos : pos+end])
+		lineStart := pos + strings.Index(text[pos:pos+end], line)
+		if line == "" || line[0] == '#' {
+			pos += end + 1
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		eq := strings.IndexByte(line, '=')
+		if eq < 0 {
+			return nil, nil, fail(lineStart, "expected KEY=value")
+		}
+		key := strings.TrimSpace(line[:eq])
+		if !envKey.MatchString(key) {
+			return nil, nil, fail(lineStart, fmt.Sprintf("%q is not a valid variable name", key))
+		}
+
+		// Values are read from the raw text since quoted ones may span lines
+		start := lineStart + strings.IndexByte(text[lineStart:], '=') + 1
+		for start < len(text) && (text[start] == ' ' || text[start] == '\t') {
+			start++
+		}
+		var v *jsonfmt.Node
+		var next int
+		switch {
+		case start < len(text) && text[start] == '\'':
+			closing := strings.IndexByte(text[start+1:], '\'')
+			if closing < 0 {
+				return nil, nil, fail(start, "unterminated ' quote")
+			}
+			v = jsonfmt.NewString(text[start+1 : start+1+closing])
+			next = start + closing + 2
+		case start < len(text) && text[start] == '"':
+			var b strings.Builder
+			i := start + 1
+			for ; i < len(text) && text[i] != '"'; i++ {
+				if text[i] == '\\' && i+1 < len(text) {
+					i++
+					switch text[i] {
+					case 'n':
+						b.WriteByte('\n')
+					case 't':
+						b.WriteByte('\t')
+					case 'r':
+						b.WriteByte('\r')
+					default:
+						b.WriteByte(text[i])
+					}
+					continue
+				}
+				b.WriteByte(text[i])
+			}
+			if i == len(text) {
+				return nil, nil, fail(start, `unterminated " quote`)
+			}
+			v = jsonfmt.NewString(b.String())
+			next = i + 1
+		default:
+			next = pos + end
+			raw := text[start:next]
+			if hash := strings.Index(raw, " #"); hash >= 0 {
+				raw = raw[:hash]
+			}
+			v = value(strings.TrimSpace(raw))
+		}
+
+		if root.Get(key) != nil {
+			line, _ := position(text, lineStart)
+			warnings = append(warnings, fmt.Sprintf("line %d: %s is set again; the last value wins", line, key))
+		}
+		root.Set(key, v)
+
+		// Only a comment may follow a quoted value
+		rest := next
+		for rest < len(text) && text[rest] != '\n' {
+			rest++
+		}
+		if trailing := strings.TrimSpace(text[next:rest]); trailing != "" && trailing[0] != '#' {
+			return nil, nil, fail(next, "unexpected text after the closing quote")
+		}
+		pos = rest + 1
+	}
+	return root, warnings, nil
+}
+
+// writeEnv writes one variable per key. Nested objects and arrays are
+// flattened into KEY_CHILD names, since .env is flat, and values are quoted
+// when a loader would otherwise misread them
+func writeEnv(n *jsonfmt.Node) (string, []string, error) {
+	if n.Kind != jsonfmt.Object {
+		return "", nil, fmt.Errorf(".env needs an object of variables at the top level, not %s", n.Kind)
+	}
+	var b strings.Builder
+	var warnings []string
+	flattened := false
+	seen := map[string]bool{}
+
+	var walk func(prefix string, v *jsonfmt.Node, path string)
+	walk = func(prefix string, v *jsonfmt.Node, path string) {
+		switch v.Kind {
+		case jsonfmt.Object:
+			flattened = flattened || prefix != ""
+			for _, m := range distinct(v) {
+				walk(joinEnvKey(prefix, m.Key), m.Value, path+"/"+jsonfmt.EscapePointer(m.Key))
+			}
+			return
+		case jsonfmt.Array:
+			flattened = true
+			for i, item := range v.Items {
+				walk(joinEnvKey(prefix, strconv.Itoa(i)), item, path+"/"+strconv.Itoa(i))
+			}
+			return
+		}
+		key := envName(prefix)
+		if key != prefix {
+			warnings = append(warnings, fmt.Sprintf("%s: %q is not a valid variable name, so it was written as %s", path, prefix, key))
+		}
+		if seen[key] {
+			warnings = append(warnings, fmt.Sprintf("%s: %s is written twice; loaders keep the last one", path, key))
+		}
+		seen[key] = true
+		b.WriteString(key + "=" + envValue(text(v)) + "\n")
+	}
+	walk("", n, "")
+
+	if flattened {
+		warnings = append(warnings, "nested values were flattened into names joined with _, which read back as separate variables")
+	}
+	return b.String(), warnings, nil
+}
+
+func joinEnvKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "_" + key
+}
+
+// envName replaces characters a variable name cannot contain
+func envName(key string) string {
+	var b strings.Builder
+	for i, r := range key {
+		switch {
+		case r == '_' || r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z':
+		case r >= '0' && r <= '9' || r == '.' || r == '-':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+		default:
+			r = '_'
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
+
+func envValue(s string) string {
+	if s == "" || !strings.ContainsAny(s, " \t\n\r#\"'\\$=") {
+		return s
+	}
+	// Single quotes stop loaders expanding $VARIABLES
+	if strings.Contains(s, "$") && !strings.ContainsAny(s, "'\n\r") {
+		return "'" + s + "'"
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return `"` + r.Replace(s) + `"`
+}
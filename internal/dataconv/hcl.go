@@ -0,0 +1,597 @@
+package dataconv
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Ndeta100/orbit2x/internal/jsonfmt"
+)
+
+var hclIdent = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// parseHCL reads the HCL subset used for configuration: attributes,
+// blocks with labels, lists, objects, heredocs and comments. A block nests
+// under its type and then each label, and repeating a block makes an array.
+// Expressions other than literals, such as var.name or function calls, are
+// kept as "${...}" strings the way Terraform's JSON syntax writes them
+func parseHCL(text string) (*jsonfmt.Node, []string, error) {
+	p := &hclParser{text: text, repeated: map[*jsonfmt.Node]bool{}}
+	root := &jsonfmt.Node{Kind: jsonfmt.Object}
+	if err := p.body(root, -1); err != nil {
+		return nil, nil, err
+	}
+	return root, nil, nil
+}
+
+type hclParser struct {
+	text     string
+	pos      int
+	repeated map[*jsonfmt.Node]bool // arrays made from repeated blocks
+	depth    int
+}
+
+func (p *hclParser) errorf(format string, args ...any) error {
+	line, col := position(p.text, p.pos)
+	return &jsonfmt.ParseError{Msg: fmt.Sprintf(format, args...), Line: line, Column: col}
+}
+
+func (p *hclParser) peek() byte {
+	if p.pos < len(p.text) {
+		return p.text[p.pos]
+	}
+	return 0
+}
+
+// skip passes over spaces and comments, and newlines too when asked
+func (p *hclParser) skip(newlines bool) {
+	for p.pos < len(p.text) {
+		switch c := p.text[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\r':
+			p.pos++
+		case c == '\n' && newlines:
+			p.pos++
+		case c == '#' || strings.HasPrefix(p.text[p.pos:], "//"):
+			end := strings.IndexByte(p.text[p.pos:], '\n')
+			if end < 0 {
+				p.pos = len(p.text)
+			} else {
+				p.pos += end
+			}
+		case strings.HasPrefix(p.text[p.pos:], "/*"):
+			end := strings.Index(p.text[p.pos+2:], "*/")
+			if end < 0 {
+				p.pos = len(p.text)
+			} else {
+				p.pos += end + 4
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *hclParser) ident() string {
+	start := p.pos
+	for p.pos < len(p.text) {
+		c := p.text[p.pos]
+		if !(c == '_' || c == '-' && p.pos > start || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' && p.pos > start) {
+			break
+		}
+		p.pos++
+	}
+	return p.text[start:p.pos]
+}
+
+// body reads attributes and blocks up to the brace closing the block that
+// opened at offset open, or to the end of the text when open is -1
+func (p *hclParser) body(n *jsonfmt.Node, open int) error {
+	for {
+		p.skip(true)
+		if p.pos == len(p.text) {
+			if open >= 0 {
+				p.pos = open
+				return p.errorf("the block is never closed with }")
+			}
+			return nil
+		}
+		if p.peek() == '}' && open >= 0 {
+			p.pos++
+			return nil
+		}
+		start := p.pos
+		name := p.ident()
+		if name == "" {
+			return p.errorf("expected an attribute or block name")
+		}
+		p.skip(false)
+
+		if p.peek() == '=' {
+			p.pos++
+			p.skip(false)
+			if n.Get(name) != nil {
+				p.pos = start
+				return p.errorf("attribute %q is set twice", name)
+			}
+			v, err := p.expr()
+			if err != nil {
+				return err
+			}
+			n.Members = append(n.Members, jsonfmt.Member{Key: name, Value: v})
+			p.skip(false)
+			if p.pos < len(p.text) && p.peek() != '\n' && p.peek() != '}' {
+				return p.errorf("expected a new line after the attribute")
+			}
+			continue
+		}
+
+		path := []string{name}
+		for p.peek() != '{' {
+			switch c := p.peek(); {
+			case c == '"':
+				label, err := p.quoted()
+				if err != nil {
+					return err
+				}
+				path = append(path, label)
+			case c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z':
+				path = append(path, p.ident())
+			default:
+				return p.errorf("expected = for an attribute or { for a block")
+			}
+			p.skip(false)
+		}
+		p.pos++ // {
+		if p.depth++; p.depth > maxDepth {
+			return p.errorf("exceeded max depth")
+		}
+		block := &jsonfmt.Node{Kind: jsonfmt.Object}
+		if err := p.body(block, start); err != nil {
+			return err
+		}
+		p.depth--
+		if err := p.place(n, path, block, start); err != nil {
+			return err
+		}
+	}
+}
+
+// place nests a block under its type and labels
+func (p *hclParser) place(n *jsonfmt.Node, path []string, block *jsonfmt.Node, start int) error {
+	for _, key := range path[:len(path)-1] {
+		next := n.Get(key)
+		if next == nil {
+			next = &jsonfmt.Node{Kind: jsonfmt.Object}
+			n.Members = append(n.Members, jsonfmt.Member{Key: key, Value: next})
+		} else if p.repeated[next] {
+			next = next.Items[len(next.Items)-1]
+		} else if next.Kind != jsonfmt.Object {
+			p.pos = start
+			return p.errorf("block %s clashes with attribute %q", strings.Join(path, " "), key)
+		}
+		n = next
+	}
+	last := path[len(path)-1]
+	switch existing := n.Get(last); {
+	case existing == nil:
+		n.Members = append(n.Members, jsonfmt.Member{Key: last, Value: block})
+	case p.repeated[existing]:
+		existing.Items = append(existing.Items, block)
+	case existing.Kind == jsonfmt.Object:
+		list := &jsonfmt.Node{Kind: jsonfmt.Array, Items: []*jsonfmt.Node{existing, block}}
+		p.repeated[list] = true
+		n.Set(last, list)
+	default:
+		p.pos = start
+		return p.errorf("block %s clashes with attribute %q", strings.Join(path, " "), last)
+	}
+	return nil
+}
+
+func (p *hclParser) expr() (*jsonfmt.Node, error) {
+	switch c := p.peek(); {
+	case c == '"':
+		s, err := p.quoted()
+		return jsonfmt.NewString(s), err
+	case strings.HasPrefix(p.text[p.pos:], "<<"):
+		s, err := p.heredoc()
+		return jsonfmt.NewString(s), err
+	case c == '[':
+		return p.list()
+	case c == '{':
+		return p.object()
+	case c == '-' || c >= '0' && c <= '9':
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.text) && strings.IndexByte("0123456789.eE+-", p.text[p.pos]) >= 0 {
+			// A sign only belongs to the number right after an exponent
+			if c := p.text[p.pos]; (c == '+' || c == '-') && p.text[p.pos-1] != 'e' && p.text[p.pos-1] != 'E' {
+				break
+			}
+			p.pos++
+		}
+		literal := p.text[start:p.pos]
+		if !jsonfmt.IsNumber(literal) {
+			p.pos = start
+			return nil, p.errorf("invalid number %q", literal)
+		}
+		return jsonfmt.NewNumber(literal), nil
+	case c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z':
+		start := p.pos
+		switch word := p.ident(); word {
+		case "true", "false":
+			if !p.continuesExpr() {
+				return &jsonfmt.Node{Kind: jsonfmt.Bool, Value: word}, nil
+			}
+		case "null":
+			if !p.continuesExpr() {
+				return &jsonfmt.Node{Kind: jsonfmt.Null}, nil
+			}
+		}
+		p.pos = start
+		return jsonfmt.NewString("${" + p.raw() + "}"), nil
+	}
+	return nil, p.errorf("expected a value")
+}
+
+// continuesExpr reports whether a word is followed by more of an
+// expression, as in true && x
+func (p *hclParser) continuesExpr() bool {
+	rest := strings.TrimLeft(p.text[p.pos:], " \t")
+	return rest != "" && strings.IndexByte("\n\r,]}#/", rest[0]) < 0
+}
+
+// raw captures an expression's source up to the end of the line or the
+// list or object it sits in
+func (p *hclParser) raw() string {
+	start := p.pos
+	depth := 0
+	for p.pos < len(p.text) {
+		c := p.text[p.pos]
+		switch {
+		case c == '"':
+			if _, err := p.quoted(); err != nil {
+				p.pos++
+			}
+			continue
+		case c == '(' || c == '[' || c == '{':
+			depth++
+		case c == ')' || c == ']' || c == '}':
+			if depth == 0 {
+				return strings.TrimSpace(p.text[start:p.pos])
+			}
+			depth--
+		case (c == '\n' || c == ',' || c == '#') && depth == 0:
+			return strings.TrimSpace(p.text[start:p.pos])
+		}
+		p.pos++
+	}
+	return strings.TrimSpace(p.text[start:p.pos])
+}
+
+func (p *hclParser) list() (*jsonfmt.Node, error) {
+	if p.depth++; p.depth > maxDepth {
+		return nil, p.errorf("exceeded max depth")
+	}
+	defer func() { p.depth-- }()
+	n := &jsonfmt.Node{Kind: jsonfmt.Array, Items: []*jsonfmt.Node{}}
+	p.pos++ // [
+	for {
+		p.skip(true)
+		if p.peek() == ']' {
+			p.pos++
+			return n, nil
+		}
+		v, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		n.Items = append(n.Items, v)
+		p.skip(true)
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+			p.pos++
+			return n, nil
+		default:
+			return nil, p.errorf("expected , or ] in the list")
+		}
+	}
+}
+
+func (p *hclParser) object() (*jsonfmt.Node, error) {
+	if p.depth++; p.depth > maxDepth {
+		return nil, p.errorf("exceeded max depth")
+	}
+	defer func() { p.depth-- }()
+	n := &jsonfmt.Node{Kind: jsonfmt.Object}
+	p.pos++ // {
+	for {
+		p.skip(true)
+		if p.peek() == '}' {
+			p.pos++
+			return n, nil
+		}
+		var key string
+		if p.peek() == '"' {
+			k, err := p.quoted()
+			if err != nil {
+				return nil, err
+			}
+			key = k
+		} else if key = p.ident(); key == "" {
+			return nil, p.errorf("expected a key")
+		}
+		p.skip(false)
+		if c := p.peek(); c != '=' && c != ':' {
+			return nil, p.errorf("expected = after the key")
+		}
+		p.pos++
+		p.skip(false)
+		v, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		n.Set(key, v)
+		p.skip(false)
+		if p.peek() == ',' {
+			p.pos++
+		}
+	}
+}
+
+func (p *hclParser) quoted() (string, error) {
+	start := p.pos
+	p.pos++ // "
+	var b strings.Builder
+	for {
+		if p.pos == len(p.text) || p.peek() == '\n' {
+			p.pos = start
+			return "", p.errorf("unterminated string")
+		}
+		c := p.text[p.pos]
+		if c == '"' {
+			p.pos++
+			return b.String(), nil
+		}
+		if c != '\\' {
+			b.WriteByte(c)
+			p.pos++
+			continue
+		}
+		p.pos++
+		switch e := p.peek(); e {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case '"', '\\':
+			b.WriteByte(e)
+		case 'u', 'U':
+			size := 4
+			if e == 'U' {
+				size = 8
+			}
+			if p.pos+1+size > len(p.text) {
+				return "", p.errorf("incomplete \\%c escape", e)
+			}
+			r, err := strconv.ParseUint(p.text[p.pos+1:p.pos+1+size], 16, 32)
+			if err != nil || !utf8.ValidRune(rune(r)) {
+				return "", p.errorf("invalid \\%c escape", e)
+			}
+			b.WriteRune(rune(r))
+			p.pos += size
+		default:
+			return "", p.errorf("invalid escape \\%c", e)
+		}
+		p.pos++
+	}
+}
+
+// heredoc reads <<EOF or, stripping the common indent, <<-EOF. The text
+// keeps its final newline, as HCL does
+func (p *hclParser) heredoc() (string, error) {
+	start := p.pos
+	p.pos += 2
+	strip := p.peek() == '-'
+	if strip {
+		p.pos++
+	}
+	marker := p.ident()
+	if marker == "" {
+		return "", p.errorf("expected a heredoc marker after <<")
+	}
+	p.skip(false)
+	if p.peek() != '\n' {
+		return "", p.errorf("expected a new line after the heredoc marker")
+	}
+	p.pos++
+
+	var lines []string
+	for {
+		if p.pos >= len(p.text) {
+			p.pos = start
+			return "", p.errorf("heredoc %s is never closed", marker)
+		}
+		end := strings.IndexByte(p.text[p.pos:], '\n')
+		if end < 0 {
+			end = len(p.text) - p.pos
+		}
+		line := strings.TrimRight(p.text[p.pos:p.pos+end], "\r")
+		p.pos += end
+		if strings.TrimSpace(line) == marker {
+			break
+		}
+		p.pos++
+		lines = append(lines, line)
+	}
+	if strip {
+		indent := -1
+		for _, line := range lines {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			if n := len(line) - len(strings.TrimLeft(line, " \t")); indent < 0 || n < indent {
+				indent = n
+			}
+		}
+		for i, line := range lines {
+			lines[i] = line[min(max(indent, 0), len(line)):]
+		}
+	}
+	if len(lines) == 0 {
+		return "", nil
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// writeHCL writes objects as blocks and everything else as attributes.
+// Arrays stay attributes, even arrays of objects that came from repeated
+// blocks, so they read back the same, and so do objects with keys that are
+// not identifiers, since only object expressions can quote keys
+func writeHCL(n *jsonfmt.Node, opts Options) (string, []string, error) {
+	if n.Kind != jsonfmt.Object {
+		return "", nil, fmt.Errorf("HCL needs an object of attributes and blocks at the top level, not %s", n.Kind)
+	}
+	w := &hclWriter{indent: opts.indent()}
+	w.body(n, "", 0)
+	return w.b.String(), w.warnings, nil
+}
+
+type hclWriter struct {
+	b        strings.Builder
+	indent   string
+	warnings []string
+}
+
+func (w *hclWriter) body(n *jsonfmt.Node, path string, depth int) {
+	pad := strings.Repeat(w.indent, depth)
+	members := distinct(n)
+	for i, m := range members {
+		child := path + "/" + jsonfmt.EscapePointer(m.Key)
+		name := m.Key
+		if !hclIdent.MatchString(name) {
+			name = hclName(name)
+			w.warnings = append(w.warnings, fmt.Sprintf("%s: %q is not a valid HCL name, so it was written as %s", child, m.Key, name))
+		}
+		if m.Value.Kind != jsonfmt.Object || !identKeys(m.Value) {
+			w.b.WriteString(pad + name + " = " + w.expr(m.Value, depth) + "\n")
+			continue
+		}
+		// Blanks set blocks apart from what is around them
+		if i > 0 {
+			w.b.WriteByte('\n')
+		}
+		w.b.WriteString(pad + name + " {\n")
+		w.body(m.Value, child, depth+1)
+		w.b.WriteString(pad + "}\n")
+		if i+1 < len(members) && (members[i+1].Value.Kind != jsonfmt.Object || !identKeys(members[i+1].Value)) {
+			w.b.WriteByte('\n')
+		}
+	}
+}
+
+func identKeys(n *jsonfmt.Node) bool {
+	for _, m := range n.Members {
+		if !hclIdent.MatchString(m.Key) {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *hclWriter) expr(n *jsonfmt.Node, depth int) string {
+	switch n.Kind {
+	case jsonfmt.Null:
+		return "null"
+	case jsonfmt.Bool, jsonfmt.Number:
+		return n.Value
+	case jsonfmt.String:
+		return hclString(n.Value)
+	}
+
+	pad := strings.Repeat(w.indent, depth)
+	inner := pad + w.indent
+	if n.Kind == jsonfmt.Array {
+		if len(n.Items) == 0 {
+			return "[]"
+		}
+		simple := true
+		parts := make([]string, len(n.Items))
+		for i, item := range n.Items {
+			simple = simple && isScalar(item)
+			parts[i] = w.expr(item, depth+1)
+		}
+		if simple {
+			return "[" + strings.Join(parts, ", ") + "]"
+		}
+		return "[\n" + inner + strings.Join(parts, ",\n"+inner) + ",\n" + pad + "]"
+	}
+	if len(n.Members) == 0 {
+		return "{}"
+	}
+	var b strings.Builder
+	b.WriteString("{\n")
+	for _, m := range distinct(n) {
+		key := m.Key
+		if !hclIdent.MatchString(key) {
+			key = hclString(key)
+		}
+		b.WriteString(inner + key + " = " + w.expr(m.Value, depth+1) + "\n")
+	}
+	b.WriteString(pad + "}")
+	return b.String()
+}
+
+// hclName replaces characters an identifier cannot contain
+func hclName(key string) string {
+	var b strings.Builder
+	for i, r := range key {
+		switch {
+		case r == '_' || r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z':
+		case r >= '0' && r <= '9' || r == '-':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+		default:
+			r = '_'
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
+
+func hclString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&b, `\u%04x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,107 @@
+package dataconv
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Ndeta100/orbit2x/internal/jsonfmt"
+)
+
+// parseINI reads keys before the first [section] into the top level and the
+// rest into an object per section. Values are text, unquoted when wrapped in
+// matching quotes; ; and # start comments on their own line
+func parseINI(text string, opts Options) (*jsonfmt.Node, []string, error) {
+	value := jsonfmt.NewString
+	if opts.InferTypes {
+		value = infer
+	}
+	root := &jsonfmt.Node{Kind: jsonfmt.Object}
+	section := root
+	var warnings []string
+
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == ';' || line[0] == '#' {
+			continue
+		}
+		if line[0] == '[' {
+			end := strings.IndexByte(line, ']')
+			if end < 0 {
+				return nil, nil, &jsonfmt.ParseError{Msg: "section header is missing ]", Line: i + 1, Column: len(line) + 1}
+			}
+			name := strings.TrimSpace(line[1:end])
+			if section = root.Get(name); section == nil || section.Kind != jsonfmt.Object {
+				if section != nil {
+					warnings = append(warnings, fmt.Sprintf("line %d: section [%s] replaces a top-level key of the same name", i+1, name))
+				}
+				section = &jsonfmt.Node{Kind: jsonfmt.Object}
+				root.Set(name, section)
+			}
+			continue
+		}
+		eq := strings.IndexAny(line, "=:")
+		if eq <= 0 {
+			return nil, nil, &jsonfmt.ParseError{Msg: "expected key = value", Line: i + 1, Column: 1}
+		}
+		key := strings.TrimSpace(line[:eq])
+		v := strings.TrimSpace(line[eq+1:])
+		if section.Get(key) != nil {
+			warnings = append(warnings, fmt.Sprintf("line %d: key %q is repeated; the last value wins", i+1, key))
+		}
+		if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+			section.Set(key, jsonfmt.NewString(v[1:len(v)-1]))
+		} else {
+			section.Set(key, value(v))
+		}
+	}
+	return root, warnings, nil
+}
+
+// writeINI writes scalars at the top level first, then each object as a
+// section. INI has one level of nesting, so anything deeper is written as
+// JSON text
+func writeINI(n *jsonfmt.Node) (string, []string, error) {
+	if n.Kind != jsonfmt.Object {
+		return "", nil, fmt.Errorf("INI needs an object of keys and sections at the top level, not %s", n.Kind)
+	}
+	var b strings.Builder
+	var warnings []string
+	pair := func(key string, v *jsonfmt.Node, path string) {
+		if !isScalar(v) {
+			warnings = append(warnings, path+" is nested deeper than an INI section, so it was written as JSON")
+			b.WriteString(key + " = " + strings.TrimSuffix(jsonfmt.Format(v, jsonfmt.Options{Minify: true}), "\n") + "\n")
+			return
+		}
+		s := text(v)
+		if s != strings.TrimSpace(s) || strings.ContainsAny(s, "\n;#") || v.Kind == jsonfmt.String && s != "" && (s[0] == '"' || s[0] == '\'') {
+			s = `"` + s + `"`
+		}
+		if strings.Contains(s, "\n") {
+			warnings = append(warnings, path+" spans several lines, which INI cannot hold")
+			s = strings.ReplaceAll(s, "\n", " ")
+		}
+		b.WriteString(key + " = " + s + "\n")
+	}
+
+	members := distinct(n)
+	for _, m := range members {
+		if m.Value.Kind != jsonfmt.Object {
+			pair(m.Key, m.Value, "/"+jsonfmt.EscapePointer(m.Key))
+		}
+	}
+	// Sections come after the top-level keys, since a key after a header
+	// belongs to that section
+	for _, m := range members {
+		if m.Value.Kind != jsonfmt.Object {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString("[" + m.Key + "]\n")
+		for _, sm := range distinct(m.Value) {
+			pair(sm.Key, sm.Value, "/"+jsonfmt.EscapePointer(m.Key)+"/"+jsonfmt.EscapePointer(sm.Key))
+		}
+	}
+	return b.String(), warnings, nil
+}
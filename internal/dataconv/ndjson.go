@@ -0,0 +1,50 @@
+package dataconv
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Ndeta100/orbit2x/internal/jsonfmt"
+)
+
+// parseNDJSON reads one JSON value per line into an array. Blank lines are
+// skipped, and error positions are moved to the line they came from
+func parseNDJSON(text string) (*jsonfmt.Node, []string, error) {
+	out := &jsonfmt.Node{Kind: jsonfmt.Array}
+	var warnings []string
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		n, more, err := jsonfmt.Parse([]byte(line))
+		if err != nil {
+			var pe *jsonfmt.ParseError
+			if errors.As(err, &pe) {
+				pe.Line = i + 1
+			}
+			return nil, nil, err
+		}
+		for _, w := range more {
+			warnings = append(warnings, fmt.Sprintf("line %d: %s", i+1, w.Message))
+		}
+		out.Items = append(out.Items, n)
+	}
+	return out, warnings, nil
+}
+
+// writeNDJSON writes each element of an array on its own line; any other
+// value becomes a single line
+func writeNDJSON(n *jsonfmt.Node) (string, []string, error) {
+	items := n.Items
+	if n.Kind != jsonfmt.Array {
+		items = []*jsonfmt.Node{n}
+	}
+	var b strings.Builder
+	for _, item := range items {
+		b.WriteString(jsonfmt.Format(item, jsonfmt.Options{Minify: true}))
+		b.WriteByte('\n')
+	}
+	return b.String(), nil, nil
+}
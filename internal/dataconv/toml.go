@@ -0,0 +1,757 @@
+package dataconv
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Ndeta100/orbit2x/internal/jsonfmt"
+)
+
+var (
+	tomlBareKey  = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	tomlDateTime = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})([Tt ]\d{2}:\d{2}:\d{2}(\.\d+)?([Zz]|[+-]\d{2}:\d{2})?)?$|^\d{2}:\d{2}:\d{2}(\.\d+)?$`)
+)
+
+// parseTOML reads a TOML 1.0 document. Integers in hex, octal and binary
+// become decimal, datetimes become tagged strings and inf and nan, which
+// JSON cannot hold, become strings with a warning
+func parseTOML(text string) (*jsonfmt.Node, []string, error) {
+	p := &tomlParser{
+		text:    text,
+		root:    &jsonfmt.Node{Kind: jsonfmt.Object},
+		headers: map[*jsonfmt.Node]bool{},
+		static:  map[*jsonfmt.Node]bool{},
+		arrays:  map[*jsonfmt.Node]bool{},
+	}
+	p.table = p.root
+	if err := p.document(); err != nil {
+		return nil, nil, err
+	}
+	return p.root, p.warnings, nil
+}
+
+type tomlParser struct {
+	text     string
+	pos      int
+	root     *jsonfmt.Node
+	table    *jsonfmt.Node // the table key/value pairs go into
+	warnings []string
+	depth    int
+
+	headers map[*jsonfmt.Node]bool // tables opened by a [header]
+	static  map[*jsonfmt.Node]bool // inline tables and arrays, which are closed
+	arrays  map[*jsonfmt.Node]bool // arrays of tables, which [[headers]] extend
+}
+
+func (p *tomlParser) errorf(format string, args ...any) error {
+	line, col := position(p.text, p.pos)
+	return &jsonfmt.ParseError{Msg: fmt.Sprintf(format, args...), Line: line, Column: col}
+}
+
+func (p *tomlParser) peek() byte {
+	if p.pos < len(p.text) {
+		return p.text[p.pos]
+	}
+	return 0
+}
+
+// space skips spaces and tabs on the current line
+func (p *tomlParser) space() {
+	for p.pos < len(p.text) && (p.text[p.pos] == ' ' || p.text[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// blank skips whitespace, newlines and comments
+func (p *tomlParser) blank() {
+	for {
+		p.space()
+		switch p.peek() {
+		case '#':
+			p.comment()
+		case '\n':
+			p.pos++
+		case '\r':
+			if strings.HasPrefix(p.text[p.pos:], "\r\n") {
+				p.pos += 2
+				continue
+			}
+			return
+		default:
+			return
+		}
+	}
+}
+
+func (p *tomlParser) comment() {
+	if end := strings.IndexByte(p.text[p.pos:], '\n'); end >= 0 {
+		p.pos += end
+	} else {
+		p.pos = len(p.text)
+	}
+}
+
+// endOfLine requires nothing but a comment before the next line
+func (p *tomlParser) endOfLine() error {
+	p.space()
+	if p.peek() == '#' {
+		p.comment()
+	}
+	switch {
+	case p.pos == len(p.text):
+		return nil
+	case p.peek() == '\n':
+		p.pos++
+		return nil
+	case strings.HasPrefix(p.text[p.pos:], "\r\n"):
+		p.pos += 2
+		return nil
+	}
+	return p.errorf("expected the end of the line, found %q", p.peek())
+}
+
+func (p *tomlParser) document() error {
+	for {
+		p.blank()
+		if p.pos == len(p.text) {
+			return nil
+		}
+		var err error
+		if p.peek() == '[' {
+			err = p.header()
+		} else {
+			err = p.keyValue(p.table)
+		}
+		if err == nil {
+			err = p.endOfLine()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// header opens a [table] or appends to an [[array of tables]]
+func (p *tomlParser) header() error {
+	start := p.pos
+	array := strings.HasPrefix(p.text[p.pos:], "[[")
+	if array {
+		p.pos += 2
+	} else {
+		p.pos++
+	}
+	p.space()
+	keys, err := p.key()
+	if err != nil {
+		return err
+	}
+	p.space()
+	closing := "]"
+	if array {
+		closing = "]]"
+	}
+	if !strings.HasPrefix(p.text[p.pos:], closing) {
+		return p.errorf("expected %s to close the table header", closing)
+	}
+	p.pos += len(closing)
+
+	parent := p.root
+	for _, k := range keys[:len(keys)-1] {
+		if parent, err = p.descend(parent, k); err != nil {
+			p.pos = start
+			return err
+		}
+	}
+	last := keys[len(keys)-1]
+	existing := parent.Get(last)
+	name := strings.Join(keys, ".")
+
+	if array {
+		if existing == nil {
+			existing = &jsonfmt.Node{Kind: jsonfmt.Array, Items: []*jsonfmt.Node{}}
+			p.arrays[existing] = true
+			parent.Members = append(parent.Members, jsonfmt.Member{Key: last, Value: existing})
+		} else if !p.arrays[existing] {
+			p.pos = start
+			return p.errorf("[[%s]] extends %s, which is not an array of tables", name, name)
+		}
+		p.table = &jsonfmt.Node{Kind: jsonfmt.Object}
+		existing.Items = append(existing.Items, p.table)
+		return nil
+	}
+
+	switch {
+	case existing == nil:
+		existing = &jsonfmt.Node{Kind: jsonfmt.Object}
+		parent.Members = append(parent.Members, jsonfmt.Member{Key: last, Value: existing})
+	case existing.Kind != jsonfmt.Object || p.static[existing]:
+		p.pos = start
+		return p.errorf("[%s] redefines a key that already has a value", name)
+	case p.headers[existing]:
+		p.pos = start
+		return p.errorf("table [%s] is defined twice", name)
+	}
+	p.headers[existing] = true
+	p.table = existing
+	return nil
+}
+
+// descend steps into a table on the way to a header or dotted key,
+// creating it if needed. An array of tables leads into its last element
+func (p *tomlParser) descend(parent *jsonfmt.Node, key string) (*jsonfmt.Node, error) {
+	next := parent.Get(key)
+	switch {
+	case next == nil:
+		next = &jsonfmt.Node{Kind: jsonfmt.Object}
+		parent.Members = append(parent.Members, jsonfmt.Member{Key: key, Value: next})
+	case p.arrays[next]:
+		next = next.Items[len(next.Items)-1]
+	case next.Kind != jsonfmt.Object || p.static[next]:
+		return nil, p.errorf("key %q already has a value that is not a table", key)
+	}
+	return next, nil
+}
+
+// keyValue reads a key = value pair into table
+func (p *tomlParser) keyValue(table *jsonfmt.Node) error {
+	start := p.pos
+	keys, err := p.key()
+	if err != nil {
+		return err
+	}
+	p.space()
+	if p.peek() != '=' {
+		return p.errorf("expected = after the key")
+	}
+	p.pos++
+	p.space()
+	for _, k := range keys[:len(keys)-1] {
+		if table, err = p.descend(table, k); err != nil {
+			p.pos = start
+			return err
+		}
+	}
+	last := keys[len(keys)-1]
+	if table.Get(last) != nil {
+		p.pos = start
+		return p.errorf("duplicate key %q", strings.Join(keys, "."))
+	}
+	v, err := p.value()
+	if err != nil {
+		return err
+	}
+	table.Members = append(table.Members, jsonfmt.Member{Key: last, Value: v})
+	return nil
+}
+
+// key reads a possibly dotted key
+func (p *tomlParser) key() ([]string, error) {
+	var keys []string
+	for {
+		var k string
+		switch p.peek() {
+		case '"':
+			s, err := p.basicString()
+			if err != nil {
+				return nil, err
+			}
+			k = s
+		case '\'':
+			s, err := p.literalString()
+			if err != nil {
+				return nil, err
+			}
+			k = s
+		default:
+			start := p.pos
+			for p.pos < len(p.text) && isBareKeyByte(p.text[p.pos]) {
+				p.pos++
+			}
+			if p.pos == start {
+				return nil, p.errorf("expected a key")
+			}
+			k = p.text[start:p.pos]
+		}
+		keys = append(keys, k)
+		p.space()
+		if p.peek() != '.' {
+			return keys, nil
+		}
+		p.pos++
+		p.space()
+	}
+}
+
+func isBareKeyByte(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+func (p *tomlParser) value() (*jsonfmt.Node, error) {
+	switch c := p.peek(); {
+	case c == '"':
+		if strings.HasPrefix(p.text[p.pos:], `"""`) {
+			s, err := p.multilineString(`"""`)
+			return jsonfmt.NewString(s), err
+		}
+		s, err := p.basicString()
+		return jsonfmt.NewString(s), err
+	case c == '\'':
+		if strings.HasPrefix(p.text[p.pos:], "'''") {
+			s, err := p.multilineString("'''")
+			return jsonfmt.NewString(s), err
+		}
+		s, err := p.literalString()
+		return jsonfmt.NewString(s), err
+	case c == '[':
+		return p.array()
+	case c == '{':
+		return p.inlineTable()
+	case c == 0 || c == '\n' || c == '\r' || c == '#':
+		return nil, p.errorf("expected a value")
+	}
+	return p.bare()
+}
+
+func (p *tomlParser) array() (*jsonfmt.Node, error) {
+	if p.depth++; p.depth > maxDepth {
+		return nil, p.errorf("exceeded max depth")
+	}
+	defer func() { p.depth-- }()
+	n := &jsonfmt.Node{Kind: jsonfmt.Array, Items: []*jsonfmt.Node{}}
+	p.static[n] = true
+	p.pos++ // [
+	for {
+		p.blank()
+		if p.peek() == ']' {
+			p.pos++
+			return n, nil
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		n.Items = append(n.Items, v)
+		p.blank()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+			p.pos++
+			return n, nil
+		default:
+			return nil, p.errorf("expected , or ] in the array")
+		}
+	}
+}
+
+func (p *tomlParser) inlineTable() (*jsonfmt.Node, error) {
+	if p.depth++; p.depth > maxDepth {
+		return nil, p.errorf("exceeded max depth")
+	}
+	defer func() { p.depth-- }()
+	n := &jsonfmt.Node{Kind: jsonfmt.Object}
+	p.pos++ // {
+	p.space()
+	if p.peek() == '}' {
+		p.pos++
+		p.static[n] = true
+		return n, nil
+	}
+	for {
+		p.space()
+		if err := p.keyValue(n); err != nil {
+			return nil, err
+		}
+		p.space()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			// Mark the whole table closed only once it is complete, so
+			// dotted keys inside it can still build subtables
+			p.close(n)
+			return n, nil
+		default:
+			return nil, p.errorf("expected , or } in the inline table")
+		}
+	}
+}
+
+func (p *tomlParser) close(n *jsonfmt.Node) {
+	p.static[n] = true
+	for _, m := range n.Members {
+		if m.Value.Kind == jsonfmt.Object {
+			p.close(m.Value)
+		}
+	}
+}
+
+func (p *tomlParser) basicString() (string, error) {
+	p.pos++ // "
+	var b strings.Builder
+	for {
+		if p.pos == len(p.text) || p.peek() == '\n' {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.text[p.pos]
+		switch c {
+		case '"':
+			p.pos++
+			return b.String(), nil
+		case '\\':
+			if err := p.escape(&b); err != nil {
+				return "", err
+			}
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+func (p *tomlParser) literalString() (string, error) {
+	p.pos++ // '
+	end := strings.IndexAny(p.text[p.pos:], "'\n")
+	if end < 0 || p.text[p.pos+end] == '\n' {
+		return "", p.errorf("unterminated string")
+	}
+	s := p.text[p.pos : p.pos+end]
+	p.pos += end + 1
+	return s, nil
+}
+
+// multilineString reads a string in triple quotes. A newline right after the
+// opening quotes is dropped, and in basic strings a backslash at the end of
+// a line joins it to the next non-blank text
+func (p *tomlParser) multilineString(quotes string) (string, error) {
+	p.pos += 3
+	if strings.HasPrefix(p.text[p.pos:], "\r\n") {
+		p.pos += 2
+	} else if p.peek() == '\n' {
+		p.pos++
+	}
+	var b strings.Builder
+	for {
+		if p.pos == len(p.text) {
+			return "", p.errorf("unterminated multi-line string")
+		}
+		if strings.HasPrefix(p.text[p.pos:], quotes) {
+			// Up to two quotes may sit right before the closing three
+			run := 3
+			for run < 5 && p.pos+run < len(p.text) && p.text[p.pos+run] == quotes[0] {
+				run++
+			}
+			b.WriteString(p.text[p.pos : p.pos+run-3])
+			p.pos += run
+			return b.String(), nil
+		}
+		c := p.text[p.pos]
+		if c == '\\' && quotes == `"""` {
+			rest := strings.TrimLeft(p.text[p.pos+1:], " \t")
+			if strings.HasPrefix(rest, "\n") || strings.HasPrefix(rest, "\r\n") {
+				p.pos = len(p.text) - len(strings.TrimLeft(rest, " \t\r\n"))
+				continue
+			}
+			if err := p.escape(&b); err != nil {
+				return "", err
+			}
+			continue
+		}
+		b.WriteByte(c)
+		p.pos++
+	}
+}
+
+func (p *tomlParser) escape(b *strings.Builder) error {
+	p.pos++ // backslash
+	c := p.peek()
+	p.pos++
+	switch c {
+	case 'b':
+		b.WriteByte('\b')
+	case 't':
+		b.WriteByte('\t')
+	case 'n':
+		b.WriteByte('\n')
+	case 'f':
+		b.WriteByte('\f')
+	case 'r':
+		b.WriteByte('\r')
+	case '"':
+		b.WriteByte('"')
+	case '\\':
+		b.WriteByte('\\')
+	case 'u', 'U':
+		size := 4
+		if c == 'U' {
+			size = 8
+		}
+		if p.pos+size > len(p.text) {
+			return p.errorf("incomplete \\%c escape", c)
+		}
+		r, err := strconv.ParseUint(p.text[p.pos:p.pos+size], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(r)) {
+			return p.errorf("invalid \\%c escape", c)
+		}
+		b.WriteRune(rune(r))
+		p.pos += size
+	default:
+		p.pos--
+		return p.errorf("invalid escape \\%c", c)
+	}
+	return nil
+}
+
+// bare reads a boolean, number or datetime
+func (p *tomlParser) bare() (*jsonfmt.Node, error) {
+	start := p.pos
+	for p.pos < len(p.text) && isBareValueByte(p.text[p.pos]) {
+		p.pos++
+	}
+	// A date and time may be separated by a space
+	if p.pos-start == 10 && p.pos+1 < len(p.text) && p.text[p.pos] == ' ' && isDigit(p.text[p.pos+1]) {
+		p.pos++
+		for p.pos < len(p.text) && isBareValueByte(p.text[p.pos]) {
+			p.pos++
+		}
+	}
+	token := p.text[start:p.pos]
+
+	switch token {
+	case "true", "false":
+		return &jsonfmt.Node{Kind: jsonfmt.Bool, Value: token}, nil
+	case "inf", "+inf", "-inf", "nan", "+nan", "-nan":
+		p.warnings = append(p.warnings, fmt.Sprintf("line %d: %s has no JSON equivalent and was kept as a string", p.line(start), token))
+		return jsonfmt.NewString(token), nil
+	}
+	if tomlDateTime.MatchString(token) {
+		if m := tomlDateTime.FindStringSubmatch(token); m[1] != "" {
+			if _, err := time.Parse("2006-01-02", m[1]); err != nil {
+				p.pos = start
+				return nil, p.errorf("invalid date %s", m[1])
+			}
+		}
+		return &jsonfmt.Node{Kind: jsonfmt.String, Value: token, Tag: jsonfmt.DateTime}, nil
+	}
+
+	if n, ok := tomlNumber(token); ok {
+		return n, nil
+	}
+	p.pos = start
+	if token == "" {
+		return nil, p.errorf("expected a value")
+	}
+	return nil, p.errorf("invalid value %q; strings need quotes", token)
+}
+
+func (p *tomlParser) line(offset int) int {
+	line, _ := position(p.text, offset)
+	return line
+}
+
+func isBareValueByte(c byte) bool {
+	return isBareKeyByte(c) || c == '+' || c == '.' || c == ':'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// tomlNumber converts a TOML integer or float to a JSON number literal
+func tomlNumber(token string) (*jsonfmt.Node, bool) {
+	// Underscores must sit between digits
+	for i := 0; i < len(token); i++ {
+		if token[i] == '_' && (i == 0 || i+1 == len(token) || !isHexDigit(token[i-1]) || !isHexDigit(token[i+1])) {
+			return nil, false
+		}
+	}
+	plain := strings.ReplaceAll(token, "_", "")
+	if len(plain) > 2 && plain[0] == '0' && strings.IndexByte("xob", plain[1]) >= 0 {
+		base := map[byte]int{'x': 16, 'o': 8, 'b': 2}[plain[1]]
+		if plain[2] == '+' || plain[2] == '-' {
+			return nil, false
+		}
+		v, err := strconv.ParseUint(plain[2:], base, 64)
+		if err != nil || v > 1<<63-1 {
+			return nil, false
+		}
+		return jsonfmt.NewNumber(strconv.FormatUint(v, 10)), true
+	}
+	plain = strings.TrimPrefix(plain, "+")
+	if !jsonfmt.IsNumber(plain) {
+		return nil, false
+	}
+	if !strings.ContainsAny(plain, ".eE") {
+		if _, err := strconv.ParseInt(plain, 10, 64); err != nil {
+			return nil, false
+		}
+	}
+	return jsonfmt.NewNumber(plain), true
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+// writeTOML writes plain keys of each table first, then its subtables as
+// [headers] and arrays of objects as [[headers]], since everything after a
+// header belongs to that table. Null, which TOML lacks, is left out
+func writeTOML(n *jsonfmt.Node, opts Options) (string, []string, error) {
+	if n.Kind != jsonfmt.Object {
+		return "", nil, fmt.Errorf("a TOML document is a table, so the top level must be an object, not %s", n.Kind)
+	}
+	w := &tomlWriter{}
+	w.table(n, nil, "")
+	return strings.TrimLeft(w.b.String(), "\n"), w.warnings, nil
+}
+
+type tomlWriter struct {
+	b        strings.Builder
+	warnings []string
+}
+
+func (w *tomlWriter) table(n *jsonfmt.Node, keys []string, path string) {
+	members := distinct(n)
+	for _, m := range members {
+		child := path + "/" + jsonfmt.EscapePointer(m.Key)
+		if m.Value.Kind == jsonfmt.Null {
+			w.warnings = append(w.warnings, child+" is null, which TOML cannot hold, so it was left out")
+			continue
+		}
+		if m.Value.Kind == jsonfmt.Object || isTableArray(m.Value) {
+			continue
+		}
+		w.b.WriteString(tomlKey(m.Key) + " = " + w.inline(m.Value, child) + "\n")
+	}
+	for _, m := range members {
+		child := path + "/" + jsonfmt.EscapePointer(m.Key)
+		sub := append(append([]string(nil), keys...), m.Key)
+		switch {
+		case m.Value.Kind == jsonfmt.Object:
+			// A table holding only subtables needs no header of its own
+			if hasPlainMembers(m.Value) || len(m.Value.Members) == 0 {
+				w.b.WriteString("\n[" + tomlPath(sub) + "]\n")
+			}
+			w.table(m.Value, sub, child)
+		case isTableArray(m.Value):
+			for i, item := range m.Value.Items {
+				w.b.WriteString("\n[[" + tomlPath(sub) + "]]\n")
+				w.table(item, sub, child+"/"+strconv.Itoa(i))
+			}
+		}
+	}
+}
+
+func (w *tomlWriter) inline(n *jsonfmt.Node, path string) string {
+	switch n.Kind {
+	case jsonfmt.Bool:
+		return n.Value
+	case jsonfmt.Number:
+		if !strings.ContainsAny(n.Value, ".eE") {
+			if _, err := strconv.ParseInt(n.Value, 10, 64); err != nil {
+				w.warnings = append(w.warnings, path+" is too large for a TOML integer, so it was written as a float")
+				return n.Value + ".0"
+			}
+		}
+		return n.Value
+	case jsonfmt.String:
+		if n.Tag == jsonfmt.DateTime && tomlDateTime.MatchString(n.Value) {
+			return n.Value
+		}
+		return tomlString(n.Value)
+	case jsonfmt.Array:
+		var parts []string
+		for i, item := range n.Items {
+			if item.Kind == jsonfmt.Null {
+				w.warnings = append(w.warnings, fmt.Sprintf("%s/%d is null, which TOML cannot hold, so it was left out", path, i))
+				continue
+			}
+			parts = append(parts, w.inline(item, path+"/"+strconv.Itoa(i)))
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	}
+	var parts []string
+	for _, m := range distinct(n) {
+		child := path + "/" + jsonfmt.EscapePointer(m.Key)
+		if m.Value.Kind == jsonfmt.Null {
+			w.warnings = append(w.warnings, child+" is null, which TOML cannot hold, so it was left out")
+			continue
+		}
+		parts = append(parts, tomlKey(m.Key)+" = "+w.inline(m.Value, child))
+	}
+	if len(parts) == 0 {
+		return "{}"
+	}
+	return "{ " + strings.Join(parts, ", ") + " }"
+}
+
+func isTableArray(n *jsonfmt.Node) bool {
+	if n.Kind != jsonfmt.Array || len(n.Items) == 0 {
+		return false
+	}
+	for _, item := range n.Items {
+		if item.Kind != jsonfmt.Object {
+			return false
+		}
+	}
+	return true
+}
+
+func hasPlainMembers(n *jsonfmt.Node) bool {
+	for _, m := range n.Members {
+		if m.Value.Kind != jsonfmt.Object && !isTableArray(m.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+func tomlKey(k string) string {
+	if tomlBareKey.MatchString(k) {
+		return k
+	}
+	return tomlString(k)
+}
+
+func tomlPath(keys []string) string {
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = tomlKey(k)
+	}
+	return strings.Join(parts, ".")
+}
+
+// tomlString writes a basic string. TOML has no \/ or \u escapes for
+// printable text, so only quotes, backslashes and control characters are
+// escaped
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,269 @@
+package dataconv
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/Ndeta100/orbit2x/internal/jsonfmt"
+)
+
+// parseXML maps the document to {root: value}. An element with only text
+// becomes that text, or null when it is self-closing; otherwise it becomes
+// an object of its attributes, prefixed, its child elements, which turn into
+// arrays when repeated, and its text under the text key. Namespace prefixes
+// are kept as part of names, and comments and processing instructions are
+// dropped
+func parseXML(text string, opts Options) (*jsonfmt.Node, []string, error) {
+	dec := xml.NewDecoder(strings.NewReader(text))
+	scalar := jsonfmt.NewString
+	if opts.InferTypes {
+		scalar = infer
+	}
+
+	type frame struct {
+		name     string
+		node     *jsonfmt.Node
+		text     strings.Builder
+		children bool
+		empty    bool            // written as <name/>
+		repeated map[string]bool // child names already collected into arrays
+	}
+	var stack []*frame
+	var root *jsonfmt.Node
+	var warnings []string
+
+	for {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var se *xml.SyntaxError
+			if errors.As(err, &se) {
+				return nil, nil, &jsonfmt.ParseError{Msg: strings.TrimPrefix(se.Msg, "xml: "), Line: se.Line}
+			}
+			return nil, nil, err
+		}
+		line, col := dec.InputPos()
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if len(stack) == 0 && root != nil {
+				return nil, nil, &jsonfmt.ParseError{Msg: "an XML document has one root element, but there is another", Line: line, Column: col}
+			}
+			f := &frame{name: xmlName(t.Name), node: &jsonfmt.Node{Kind: jsonfmt.Object}, repeated: map[string]bool{}}
+			end := int(dec.InputOffset())
+			f.empty = end >= 2 && text[end-2:end] == "/>"
+			for _, a := range t.Attr {
+				f.node.Members = append(f.node.Members, jsonfmt.Member{Key: opts.attrPrefix() + xmlName(a.Name), Value: scalar(a.Value)})
+			}
+			if len(stack) > 0 {
+				stack[len(stack)-1].children = true
+			}
+			stack = append(stack, f)
+		case xml.EndElement:
+			if len(stack) == 0 || stack[len(stack)-1].name != xmlName(t.Name) {
+				return nil, nil, &jsonfmt.ParseError{Msg: fmt.Sprintf("unexpected closing tag </%s>", xmlName(t.Name)), Line: line, Column: col}
+			}
+			f := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			v := f.node
+			content := f.text.String()
+			switch {
+			case len(v.Members) == 0 && !f.children && f.empty:
+				v = &jsonfmt.Node{Kind: jsonfmt.Null}
+			case len(v.Members) == 0 && !f.children:
+				v = scalar(content)
+			case strings.TrimSpace(content) != "":
+				v.Members = append(v.Members, jsonfmt.Member{Key: opts.textKey(), Value: scalar(strings.TrimSpace(content))})
+			}
+
+			if len(stack) == 0 {
+				root = &jsonfmt.Node{Kind: jsonfmt.Object, Members: []jsonfmt.Member{{Key: f.name, Value: v}}}
+				continue
+			}
+			parent := stack[len(stack)-1]
+			switch existing := parent.node.Get(f.name); {
+			case existing == nil:
+				parent.node.Members = append(parent.node.Members, jsonfmt.Member{Key: f.name, Value: v})
+			case parent.repeated[f.name]:
+				existing.Items = append(existing.Items, v)
+			default:
+				if strings.HasPrefix(f.name, opts.attrPrefix()) || f.name == opts.textKey() {
+					warnings = append(warnings, fmt.Sprintf("line %d: element <%s> clashes with the attribute or text naming convention", line, f.name))
+				}
+				parent.repeated[f.name] = true
+				parent.node.Set(f.name, &jsonfmt.Node{Kind: jsonfmt.Array, Items: []*jsonfmt.Node{existing, v}})
+			}
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text.Write(t)
+			} else if strings.TrimSpace(string(t)) != "" {
+				return nil, nil, &jsonfmt.ParseError{Msg: "text outside the root element", Line: line, Column: col}
+			}
+		}
+	}
+
+	if len(stack) > 0 {
+		line, col := position(text, len(text))
+		return nil, nil, &jsonfmt.ParseError{Msg: fmt.Sprintf("<%s> is never closed", stack[len(stack)-1].name), Line: line, Column: col}
+	}
+	if root == nil {
+		return nil, nil, &jsonfmt.ParseError{Msg: "no root element", Line: 1}
+	}
+	return root, warnings, nil
+}
+
+func xmlName(n xml.Name) string {
+	if n.Space != "" {
+		return n.Space + ":" + n.Local
+	}
+	return n.Local
+}
+
+// writeXML is the inverse of parseXML. A tree that is not an object with a
+// single element-like key is wrapped in the root element, and array items
+// repeat their parent's name. Types are lost, since XML text is untyped
+func writeXML(n *jsonfmt.Node, opts Options) (string, []string, error) {
+	w := &xmlWriter{opts: opts, indent: opts.indent()}
+	w.b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+
+	name, v := opts.Root, n
+	if name == "" {
+		name = "root"
+	}
+	if n.Kind == jsonfmt.Object && len(n.Members) == 1 && n.Members[0].Value.Kind != jsonfmt.Array && !w.special(n.Members[0].Key) {
+		name, v = n.Members[0].Key, n.Members[0].Value
+	}
+	if v.Kind == jsonfmt.Array {
+		// Wrap the items so the document keeps a single root
+		v = &jsonfmt.Node{Kind: jsonfmt.Object, Members: []jsonfmt.Member{{Key: "item", Value: v}}}
+	}
+	w.element(name, v, "", 0)
+	return w.b.String(), w.warnings, nil
+}
+
+type xmlWriter struct {
+	b        strings.Builder
+	opts     Options
+	indent   string
+	warnings []string
+}
+
+// special reports whether a key names an attribute or the text
+func (w *xmlWriter) special(key string) bool {
+	return strings.HasPrefix(key, w.opts.attrPrefix()) || key == w.opts.textKey()
+}
+
+func (w *xmlWriter) element(name string, v *jsonfmt.Node, path string, depth int) {
+	tag := w.name(name, path)
+	pad := strings.Repeat(w.indent, depth)
+
+	switch v.Kind {
+	case jsonfmt.Null:
+		w.b.WriteString(pad + "<" + tag + "/>\n")
+		return
+	case jsonfmt.Array:
+		switch len(v.Items) {
+		case 0:
+			w.warnings = append(w.warnings, pathText(path)+" is an empty array, which XML cannot show, so it was left out")
+		case 1:
+			w.warnings = append(w.warnings, pathText(path)+" is an array of one, which reads back from XML as a single value")
+		}
+		for i, item := range v.Items {
+			if item.Kind == jsonfmt.Array {
+				item = &jsonfmt.Node{Kind: jsonfmt.Object, Members: []jsonfmt.Member{{Key: "item", Value: item}}}
+				w.warnings = append(w.warnings, fmt.Sprintf("%s/%d is a nested array, written as <item> elements", path, i))
+			}
+			w.element(name, item, path+"/"+strconv.Itoa(i), depth)
+		}
+		return
+	case jsonfmt.Object:
+	default:
+		w.b.WriteString(pad + "<" + tag + ">" + escapeXML(text(v), false) + "</" + tag + ">\n")
+		return
+	}
+
+	var attrs, body strings.Builder
+	var children []jsonfmt.Member
+	for _, m := range distinct(v) {
+		child := path + "/" + jsonfmt.EscapePointer(m.Key)
+		switch {
+		case m.Key == w.opts.textKey():
+			body.WriteString(escapeXML(w.scalarText(m.Value, child), false))
+		case strings.HasPrefix(m.Key, w.opts.attrPrefix()):
+			attr := w.name(strings.TrimPrefix(m.Key, w.opts.attrPrefix()), child)
+			attrs.WriteString(" " + attr + `="` + escapeXML(w.scalarText(m.Value, child), true) + `"`)
+		default:
+			children = append(children, m)
+		}
+	}
+
+	w.b.WriteString(pad + "<" + tag + attrs.String())
+	switch {
+	case len(children) == 0 && body.Len() == 0:
+		w.b.WriteString("></" + tag + ">\n")
+	case len(children) == 0:
+		w.b.WriteString(">" + body.String() + "</" + tag + ">\n")
+	default:
+		w.b.WriteString(">\n")
+		if body.Len() > 0 {
+			w.b.WriteString(pad + w.indent + body.String() + "\n")
+		}
+		for _, m := range children {
+			w.element(m.Key, m.Value, path+"/"+jsonfmt.EscapePointer(m.Key), depth+1)
+		}
+		w.b.WriteString(pad + "</" + tag + ">\n")
+	}
+}
+
+// scalarText renders an attribute or text value, which must be a scalar
+func (w *xmlWriter) scalarText(n *jsonfmt.Node, path string) string {
+	if isScalar(n) {
+		return text(n)
+	}
+	w.warnings = append(w.warnings, path+" holds "+n.Kind.String()+" where XML needs text, so it was written as JSON")
+	return strings.TrimSuffix(jsonfmt.Format(n, jsonfmt.Options{Minify: true}), "\n")
+}
+
+// name makes a key a valid XML name, replacing what is not allowed
+func (w *xmlWriter) name(key, path string) string {
+	var b strings.Builder
+	for i, r := range key {
+		ok := unicode.IsLetter(r) || r == '_' || r == ':' || i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.')
+		if !ok {
+			if i == 0 && (unicode.IsDigit(r) || r == '-' || r == '.') {
+				b.WriteByte('_')
+				b.WriteRune(r)
+				continue
+			}
+			r = '_'
+		}
+		b.WriteRune(r)
+	}
+	name := b.String()
+	if name == "" {
+		name = "_"
+	}
+	if name != key {
+		w.warnings = append(w.warnings, fmt.Sprintf("%s: %q is not a valid XML name, so it was written as %s", pathText(path), key, name))
+	}
+	return name
+}
+
+// escapeXML escapes text for element content, or for a double-quoted
+// attribute, where quotes and line breaks must be escaped too
+func escapeXML(s string, attr bool) string {
+	if attr {
+		var b strings.Builder
+		xml.EscapeText(&b, []byte(s))
+		return b.String()
+	}
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;").Replace(s)
+}
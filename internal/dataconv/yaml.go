@@ -0,0 +1,65 @@
+package dataconv
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/Ndeta100/orbit2x/internal/jsonfmt"
+	"gopkg.in/yaml.v3"
+)
+
+// yaml11Bools are read as booleans by YAML 1.1 parsers, so strings with
+// these values are quoted even though YAML 1.2 would not need it
+var yaml11Bools = map[string]bool{"y": true, "yes": true, "n": true, "no": true, "on": true, "off": true}
+
+// writeYAML builds a yaml.v3 node tree so mapping order is kept. Tags let
+// the encoder quote strings that would otherwise read back as another type
+func writeYAML(n *jsonfmt.Node, opts Options) (string, []string, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	// YAML indents with spaces only, so a tab indent counts as two
+	enc.SetIndent(max(2, len(strings.ReplaceAll(opts.indent(), "\t", ""))))
+	if err := enc.Encode(yamlNode(n)); err != nil {
+		return "", nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return "", nil, err
+	}
+	return buf.String(), nil, nil
+}
+
+func yamlNode(n *jsonfmt.Node) *yaml.Node {
+	switch n.Kind {
+	case jsonfmt.Null:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+	case jsonfmt.Bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: n.Value}
+	case jsonfmt.Number:
+		tag := "!!float"
+		if !strings.ContainsAny(n.Value, ".eE") {
+			tag = "!!int"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: n.Value}
+	case jsonfmt.String:
+		y := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: n.Value}
+		if n.Tag == jsonfmt.DateTime {
+			y.Tag = "!!timestamp"
+		} else if yaml11Bools[strings.ToLower(n.Value)] {
+			y.Style = yaml.DoubleQuotedStyle
+		} else if strings.Contains(strings.TrimRight(n.Value, "\n"), "\n") {
+			y.Style = yaml.LiteralStyle
+		}
+		return y
+	case jsonfmt.Array:
+		y := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range n.Items {
+			y.Content = append(y.Content, yamlNode(item))
+		}
+		return y
+	}
+	y := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, m := range n.Members {
+		y.Content = append(y.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: m.Key}, yamlNode(m.Value))
+	}
+	return y
+}
@@ -0,0 +1,197 @@
+// Package hashes is the registry of hash algorithms offered by the hash
+// generator. The text, file and verify tools, HMAC and the algorithm API
+// all read from it, so adding an entry here makes it available everywhere
+package hashes
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"hash/crc32"
+	"hash/crc64"
+
+	"github.com/Ndeta100/orbit2x/internal/passhash"
+	"github.com/cespare/xxhash/v2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/blake2s"
+	"golang.org/x/crypto/md4"
+	"golang.org/x/crypto/ripemd160"
+	"golang.org/x/crypto/sha3"
+	"lukechampine.com/blake3"
+)
+
+// Algorithm describes one registered hash algorithm
+type Algorithm struct {
+	// ID is the form value and API identifier, e.g. "sha256"
+	ID string `json:"id"`
+	// Name is the display name, e.g. "SHA-256"
+	Name string `json:"name"`
+	// Family groups related algorithms in listings
+	Family string `json:"family"`
+	// Size is the digest length in bytes, or 0 for password hashes
+	Size int `json:"digest_size"`
+	// Keyed algorithms can be used as a MAC through HMAC
+	Keyed bool `json:"keyed"`
+	// FileSafe algorithms can hash a stream of any length
+	FileSafe bool `json:"file_safe"`
+	// Password algorithms are salted and slow, producing an encoded string
+	// rather than a fixed digest
+	Password bool `json:"password"`
+	// Default marks the algorithms selected when the user picks none
+	Default bool `json:"default"`
+
+	// New returns a fresh digest; nil for password hashes
+	New func() hash.Hash `json:"-"`
+	// encode produces an encoded password hash with default parameters
+	encode func(password []byte) (string, error)
+}
+
+// Sum hashes data, returning a hex digest or an encoded password hash
+func (a Algorithm) Sum(data []byte) (string, error) {
+	if a.encode != nil {
+		return a.encode(data)
+	}
+	h := a.New()
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// registry lists the algorithms in display order
+var registry = []Algorithm{
+	{ID: "md5", Name: "MD5", Family: "MD", New: md5.New, Keyed: true, FileSafe: true, Default: true},
+	{ID: "sha1", Name: "SHA-1", Family: "SHA-1", New: sha1.New, Keyed: true, FileSafe: true, Default: true},
+	{ID: "sha224", Name: "SHA-224", Family: "SHA-2", New: sha256.New224, Keyed: true, FileSafe: true},
+	{ID: "sha256", Name: "SHA-256", Family: "SHA-2", New: sha256.New, Keyed: true, FileSafe: true, Default: true},
+	{ID: "sha384", Name: "SHA-384", Family: "SHA-2", New: sha512.New384, Keyed: true, FileSafe: true},
+	{ID: "sha512", Name: "SHA-512", Family: "SHA-2", New: sha512.New, Keyed: true, FileSafe: true, Default: true},
+	{ID: "sha512-256", Name: "SHA-512/256", Family: "SHA-2", New: sha512.New512_256, Keyed: true, FileSafe: true},
+	{ID: "sha3-224", Name: "SHA3-224", Family: "SHA-3", New: sha3.New224, Keyed: true, FileSafe: true},
+	{ID: "sha3-256", Name: "SHA3-256", Family: "SHA-3", New: sha3.New256, Keyed: true, FileSafe: true},
+	{ID: "sha3-384", Name: "SHA3-384", Family: "SHA-3", New: sha3.New384, Keyed: true, FileSafe: true},
+	{ID: "sha3-512", Name: "SHA3-512", Family: "SHA-3", New: sha3.New512, Keyed: true, FileSafe: true},
+	{ID: "blake2b", Name: "BLAKE2b-512", Family: "BLAKE", New: newBLAKE2b, Keyed: true, FileSafe: true},
+	{ID: "blake2s", Name: "BLAKE2s-256", Family: "BLAKE", New: newBLAKE2s, Keyed: true, FileSafe: true},
+	{ID: "blake3", Name: "BLAKE3", Family: "BLAKE", New: newBLAKE3, Keyed: true, FileSafe: true},
+	{ID: "ripemd160", Name: "RIPEMD-160", Family: "RIPEMD", New: ripemd160.New, Keyed: true, FileSafe: true},
+	{ID: "crc32", Name: "CRC32", Family: "Checksum", New: newCRC32, FileSafe: true},
+	{ID: "crc32c", Name: "CRC32C", Family: "Checksum", New: newCRC32C, FileSafe: true},
+	{ID: "crc64", Name: "CRC64-ECMA", Family: "Checksum", New: newCRC64, FileSafe: true},
+	{ID: "xxhash64", Name: "xxHash64", Family: "Non-cryptographic", New: newXXHash64, FileSafe: true},
+	{ID: "bcrypt", Name: "bcrypt", Family: "Password", Password: true, encode: encodeBcrypt},
+	{ID: "argon2id", Name: "Argon2id", Family: "Password", Password: true, encode: encodeArgon2id},
+	{ID: "scrypt", Name: "scrypt", Family: "Password", Password: true, encode: encodeScrypt},
+	{ID: "pbkdf2", Name: "PBKDF2-SHA256", Family: "Password", Password: true, encode: encodePBKDF2},
+	{ID: "md4", Name: "MD4", Family: "MD", New: md4.New, Keyed: true, FileSafe: true},
+}
+
+// byID indexes the registry
+var byID = make(map[string]Algorithm, len(registry))
+
+func init() {
+	for i, a := range registry {
+		if a.New != nil {
+			registry[i].Size = a.New().Size()
+		}
+		byID[a.ID] = registry[i]
+	}
+}
+
+// All returns every registered algorithm in display order
+func All() []Algorithm {
+	return append([]Algorithm(nil), registry...)
+}
+
+// Lookup finds an algorithm by ID
+func Lookup(id string) (Algorithm, bool) {
+	a, ok := byID[id]
+	return a, ok
+}
+
+// Filter returns the algorithms, in display order, for which keep is true
+func Filter(keep func(Algorithm) bool) []Algorithm {
+	var out []Algorithm
+	for _, a := range registry {
+		if keep(a) {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// Select looks up the requested IDs, dropping unknown ones and any that
+// keep rejects, and returns them in display order. If none remain, the
+// default algorithms that keep accepts are returned
+func Select(ids []string, keep func(Algorithm) bool) []Algorithm {
+	requested := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		requested[id] = true
+	}
+
+	selected := Filter(func(a Algorithm) bool { return requested[a.ID] && keep(a) })
+	if len(selected) == 0 {
+		selected = Filter(func(a Algorithm) bool { return a.Default && keep(a) })
+	}
+	return selected
+}
+
+// IsKeyed reports whether an algorithm can be used with HMAC
+func IsKeyed(a Algorithm) bool { return a.Keyed }
+
+// IsFileSafe reports whether an algorithm can hash streams
+func IsFileSafe(a Algorithm) bool { return a.FileSafe }
+
+// IsDigest reports whether an algorithm produces a fixed-size digest
+func IsDigest(a Algorithm) bool { return a.New != nil }
+
+// Any accepts every algorithm
+func Any(Algorithm) bool { return true }
+
+func newBLAKE2b() hash.Hash {
+	h, _ := blake2b.New512(nil)
+	return h
+}
+
+func newBLAKE2s() hash.Hash {
+	h, _ := blake2s.New256(nil)
+	return h
+}
+
+func newBLAKE3() hash.Hash {
+	return blake3.New(32, nil)
+}
+
+func newCRC32() hash.Hash {
+	return crc32.NewIEEE()
+}
+
+func newCRC32C() hash.Hash {
+	return crc32.New(crc32.MakeTable(crc32.Castagnoli))
+}
+
+func newCRC64() hash.Hash {
+	return crc64.New(crc64.MakeTable(crc64.ECMA))
+}
+
+func newXXHash64() hash.Hash {
+	return xxhash.New()
+}
+
+func encodeBcrypt(password []byte) (string, error) {
+	return passhash.HashBcrypt(password, bcrypt.DefaultCost)
+}
+
+func encodeArgon2id(password []byte) (string, error) {
+	return passhash.HashArgon2id(password, passhash.DefaultArgon2)
+}
+
+func encodeScrypt(password []byte) (string, error) {
+	return passhash.HashScrypt(password, passhash.DefaultScrypt)
+}
+
+func encodePBKDF2(password []byte) (string, error) {
+	return passhash.HashPBKDF2(password, passhash.DefaultPBKDF2)
+}
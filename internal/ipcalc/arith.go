@@ -0,0 +1,77 @@
+package ipcalc
+
+import (
+	"math/big"
+	"net"
+)
+
+// ipBits returns the address length in bits (32 for IPv4, 128 for IPv6)
+func ipBits(ip net.IP) int {
+	if ip.To4() != nil {
+		return 32
+	}
+	return 128
+}
+
+// ipToInt converts an IP address to a big integer
+func ipToInt(ip net.IP) *big.Int {
+	if v4 := ip.To4(); v4 != nil {
+		return new(big.Int).SetBytes(v4)
+	}
+	return new(big.Int).SetBytes(ip.To16())
+}
+
+// intToIP converts a big integer back to an IP address of the given bit length
+func intToIP(n *big.Int, bits int) net.IP {
+	size := bits / 8
+	ip := make(net.IP, size)
+	n.FillBytes(ip)
+	return ip
+}
+
+// networkRange returns the first and last address of a network as integers
+func networkRange(ipNet *net.IPNet) (*big.Int, *big.Int) {
+	ones, bits := ipNet.Mask.Size()
+	first := ipToInt(ipNet.IP.Mask(ipNet.Mask))
+	last := new(big.Int).Add(first, AddressCount(ones, bits))
+	return first, last.Sub(last, big.NewInt(1))
+}
+
+// RangeToCIDRs returns the smallest list of CIDR blocks that exactly covers
+// the inclusive range start-end. Both addresses must be the same family
+func RangeToCIDRs(start, end net.IP) []*net.IPNet {
+	bits := ipBits(start)
+	return rangeToCIDRs(ipToInt(start), ipToInt(end), bits)
+}
+
+func rangeToCIDRs(start, end *big.Int, bits int) []*net.IPNet {
+	var blocks []*net.IPNet
+	cur := new(big.Int).Set(start)
+	one := big.NewInt(1)
+
+	for cur.Cmp(end) <= 0 {
+		// Largest block aligned at cur
+		size := bits
+		if cur.Sign() != 0 {
+			size = int(cur.TrailingZeroBits())
+			if size > bits {
+				size = bits
+			}
+		}
+
+		// Shrink until the block fits inside the remaining range
+		remaining := new(big.Int).Sub(end, cur)
+		remaining.Add(remaining, one)
+		for size > 0 && new(big.Int).Lsh(one, uint(size)).Cmp(remaining) > 0 {
+			size--
+		}
+
+		blocks = append(blocks, &net.IPNet{
+			IP:   intToIP(cur, bits),
+			Mask: net.CIDRMask(bits-size, bits),
+		})
+		cur.Add(cur, new(big.Int).Lsh(one, uint(size)))
+	}
+
+	return blocks
+}
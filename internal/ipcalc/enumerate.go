@@ -0,0 +1,129 @@
+package ipcalc
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+)
+
+var (
+	errIndexOutOfRange = errors.New("index is outside the usable address range")
+	errNoNextAddress   = errors.New("address is the last address of its family")
+	errNoPrevAddress   = errors.New("address is the first address of its family")
+)
+
+// UsableRange returns the first and last usable address of a network as
+// integers. IPv4 networks shorter than /31 exclude the network and
+// broadcast addresses; IPv6 networks have no broadcast address
+func UsableRange(ipNet *net.IPNet) (*big.Int, *big.Int) {
+	first, last := networkRange(ipNet)
+	ones, bits := ipNet.Mask.Size()
+	if bits == 32 && ones < 31 {
+		first.Add(first, big.NewInt(1))
+		last.Sub(last, big.NewInt(1))
+	}
+	return first, last
+}
+
+// UsableCount returns the number of usable addresses in a network
+func UsableCount(ipNet *net.IPNet) *big.Int {
+	first, last := UsableRange(ipNet)
+	count := new(big.Int).Sub(last, first)
+	return count.Add(count, big.NewInt(1))
+}
+
+// AddressAt returns the usable address at the given zero-based index.
+// Negative indexes count back from the last usable address (-1 is the last)
+func AddressAt(ipNet *net.IPNet, index *big.Int) (net.IP, error) {
+	_, bits := ipNet.Mask.Size()
+	first, last := UsableRange(ipNet)
+
+	var n *big.Int
+	if index.Sign() < 0 {
+		n = new(big.Int).Add(last, index)
+		n.Add(n, big.NewInt(1))
+	} else {
+		n = new(big.Int).Add(first, index)
+	}
+
+	if n.Cmp(first) < 0 || n.Cmp(last) > 0 {
+		return nil, errIndexOutOfRange
+	}
+	return intToIP(n, bits), nil
+}
+
+// IndexOf returns the zero-based usable index of ip inside the network
+func IndexOf(ipNet *net.IPNet, ip net.IP) (*big.Int, error) {
+	if !ipNet.Contains(ip) {
+		return nil, fmt.Errorf("%s is not inside %s", ip, ipNet)
+	}
+
+	first, last := UsableRange(ipNet)
+	n := ipToInt(ip)
+	if n.Cmp(first) < 0 || n.Cmp(last) > 0 {
+		return nil, fmt.Errorf("%s is not a usable address of %s", ip, ipNet)
+	}
+	return n.Sub(n, first), nil
+}
+
+// Hosts returns up to limit usable addresses starting at the given offset
+func Hosts(ipNet *net.IPNet, offset *big.Int, limit int) []net.IP {
+	_, bits := ipNet.Mask.Size()
+	first, last := UsableRange(ipNet)
+
+	cur := new(big.Int).Add(first, offset)
+	hosts := make([]net.IP, 0, limit)
+	for i := 0; i < limit && cur.Cmp(last) <= 0; i++ {
+		hosts = append(hosts, intToIP(cur, bits))
+		cur.Add(cur, big.NewInt(1))
+	}
+	return hosts
+}
+
+// EachHost calls fn for every usable address in order, stopping after
+// limit addresses or when fn returns false
+func EachHost(ipNet *net.IPNet, limit uint64, fn func(index uint64, ip net.IP) bool) {
+	_, bits := ipNet.Mask.Size()
+	first, last := UsableRange(ipNet)
+
+	cur := new(big.Int).Set(first)
+	one := big.NewInt(1)
+	for i := uint64(0); i < limit && cur.Cmp(last) <= 0; i++ {
+		if !fn(i, intToIP(cur, bits)) {
+			return
+		}
+		cur.Add(cur, one)
+	}
+}
+
+// NextIP returns the address directly after ip
+func NextIP(ip net.IP) (net.IP, error) {
+	bits := ipBits(ip)
+	n := ipToInt(ip)
+	if n.Cmp(new(big.Int).Sub(AddressCount(0, bits), big.NewInt(1))) == 0 {
+		return nil, errNoNextAddress
+	}
+	return intToIP(n.Add(n, big.NewInt(1)), bits), nil
+}
+
+// PrevIP returns the address directly before ip
+func PrevIP(ip net.IP) (net.IP, error) {
+	bits := ipBits(ip)
+	n := ipToInt(ip)
+	if n.Sign() == 0 {
+		return nil, errNoPrevAddress
+	}
+	return intToIP(n.Sub(n, big.NewInt(1)), bits), nil
+}
+
+// Matching returns the networks from the list that contain ip
+func Matching(nets []*net.IPNet, ip net.IP) []*net.IPNet {
+	var matches []*net.IPNet
+	for _, n := range nets {
+		if n.Contains(ip) {
+			matches = append(matches, n)
+		}
+	}
+	return matches
+}
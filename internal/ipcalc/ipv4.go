@@ -0,0 +1,234 @@
+package ipcalc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+	"net"
+	"strconv"
+	"strings"
+)
+
+var (
+	errNotIPv4          = errors.New("address is not an IPv4 address")
+	errInvalidMask      = errors.New("invalid subnet mask format")
+	errNonContiguous    = errors.New("subnet mask must be contiguous (e.g. 255.255.255.0 or wildcard 0.0.0.255)")
+	errPrefixOutOfRange = errors.New("IPv4 prefix length must be between 0 and 32")
+)
+
+// AddressForms holds the alternative representations of an IPv4 address
+type AddressForms struct {
+	Dotted  string
+	Hex     string
+	Binary  string
+	Integer uint32
+}
+
+// IPv4Details holds the calculated values for an IPv4 network
+type IPv4Details struct {
+	Address      AddressForms
+	Network      AddressForms
+	Broadcast    AddressForms
+	FirstUsable  AddressForms
+	LastUsable   AddressForms
+	Mask         AddressForms
+	Wildcard     AddressForms
+	PrefixLength int
+	TotalHosts   uint64
+	UsableHosts  uint64
+	Class        string
+	Private      bool
+	Public       bool
+	Scope        string
+}
+
+// IPv4ToUint32 converts an IPv4 address to its integer value
+func IPv4ToUint32(ip net.IP) (uint32, error) {
+	v4 := ip.To4()
+	if v4 == nil {
+		return 0, errNotIPv4
+	}
+	return binary.BigEndian.Uint32(v4), nil
+}
+
+// Uint32ToIPv4 converts an integer value to an IPv4 address
+func Uint32ToIPv4(n uint32) net.IP {
+	ip := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ip, n)
+	return ip
+}
+
+// PrefixMask returns the IPv4 netmask for a prefix length as an integer
+func PrefixMask(prefix int) uint32 {
+	if prefix <= 0 {
+		return 0
+	}
+	return ^uint32(0) << (32 - prefix)
+}
+
+// FormatIPv4 returns the dotted, hex, binary and integer forms of an address
+func FormatIPv4(n uint32) AddressForms {
+	octets := make([]string, 4)
+	for i := 0; i < 4; i++ {
+		octets[i] = fmt.Sprintf("%08b", byte(n>>(24-8*i)))
+	}
+
+	return AddressForms{
+		Dotted:  Uint32ToIPv4(n).String(),
+		Hex:     fmt.Sprintf("0x%08X", n),
+		Binary:  strings.Join(octets, "."),
+		Integer: n,
+	}
+}
+
+// ParseIPv4Mask parses a subnet mask given as a prefix length ("24" or
+// "/24"), a dotted netmask (255.255.255.0) or a dotted wildcard mask
+// (0.0.0.255) and returns the prefix length. Non-contiguous masks such as
+// 255.0.255.0 are rejected. The wildcard flag reports how it was read
+func ParseIPv4Mask(s string) (prefix int, wildcard bool, err error) {
+	s = strings.TrimSpace(s)
+	if n, convErr := strconv.Atoi(strings.TrimPrefix(s, "/")); convErr == nil {
+		if n < 0 || n > 32 {
+			return 0, false, errPrefixOutOfRange
+		}
+		return n, false, nil
+	}
+
+	maskIP := net.ParseIP(s)
+	if maskIP == nil || maskIP.To4() == nil {
+		return 0, false, errInvalidMask
+	}
+	m, _ := IPv4ToUint32(maskIP)
+
+	// A netmask is ones followed by zeros, so its inverse is of the form 2^k-1
+	if inv := ^m; inv&(inv+1) == 0 {
+		return 32 - bits.OnesCount32(inv), false, nil
+	}
+
+	// A wildcard mask is zeros followed by ones
+	if m&(m+1) == 0 {
+		return 32 - bits.OnesCount32(m), true, nil
+	}
+
+	return 0, false, errNonContiguous
+}
+
+// CalculateIPv4 computes network, broadcast, usable range and address
+// classification for an IPv4 address with the given prefix length
+func CalculateIPv4(ip net.IP, prefix int) (IPv4Details, error) {
+	addr, err := IPv4ToUint32(ip)
+	if err != nil {
+		return IPv4Details{}, err
+	}
+	if prefix < 0 || prefix > 32 {
+		return IPv4Details{}, errPrefixOutOfRange
+	}
+
+	mask := PrefixMask(prefix)
+	network := addr & mask
+	broadcast := network | ^mask
+	total := uint64(1) << (32 - prefix)
+
+	// /31 (RFC 3021) and /32 networks have no network or broadcast address
+	first, last := network, broadcast
+	usable := total
+	if prefix < 31 {
+		first = network + 1
+		last = broadcast - 1
+		usable = total - 2
+	}
+
+	scope := IPv4Scope(addr)
+	return IPv4Details{
+		Address:      FormatIPv4(addr),
+		Network:      FormatIPv4(network),
+		Broadcast:    FormatIPv4(broadcast),
+		FirstUsable:  FormatIPv4(first),
+		LastUsable:   FormatIPv4(last),
+		Mask:         FormatIPv4(mask),
+		Wildcard:     FormatIPv4(^mask),
+		PrefixLength: prefix,
+		TotalHosts:   total,
+		UsableHosts:  usable,
+		Class:        IPv4Class(addr),
+		Private:      IsPrivateIPv4(addr),
+		Public:       scope == "Public",
+		Scope:        scope,
+	}, nil
+}
+
+// IPv4Class returns the historical classful network class of an address
+func IPv4Class(addr uint32) string {
+	switch first := addr >> 24; {
+	case first < 128:
+		return "A"
+	case first < 192:
+		return "B"
+	case first < 224:
+		return "C"
+	case first < 240:
+		return "D (multicast)"
+	default:
+		return "E (reserved)"
+	}
+}
+
+// ipv4Block is a special-purpose IPv4 range
+type ipv4Block struct {
+	network uint32
+	prefix  int
+	scope   string
+}
+
+// rfc1918Blocks are the private address ranges
+var rfc1918Blocks = []ipv4Block{
+	{0x0A000000, 8, "Private (RFC 1918)"},
+	{0xAC100000, 12, "Private (RFC 1918)"},
+	{0xC0A80000, 16, "Private (RFC 1918)"},
+}
+
+// specialBlocks are other non-public ranges from the IANA special-purpose registry
+var specialBlocks = []ipv4Block{
+	{0x00000000, 8, "This network"},
+	{0x64400000, 10, "Shared address space (CGNAT)"},
+	{0x7F000000, 8, "Loopback"},
+	{0xA9FE0000, 16, "Link-local"},
+	{0xC0000200, 24, "Documentation (TEST-NET-1)"},
+	{0xC6120000, 15, "Benchmarking"},
+	{0xC6336400, 24, "Documentation (TEST-NET-2)"},
+	{0xCB007100, 24, "Documentation (TEST-NET-3)"},
+	{0xE0000000, 4, "Multicast"},
+	{0xFFFFFFFF, 32, "Limited broadcast"},
+	{0xF0000000, 4, "Reserved"},
+}
+
+// contains reports whether the block contains addr
+func (b ipv4Block) contains(addr uint32) bool {
+	return addr&PrefixMask(b.prefix) == b.network
+}
+
+// IsPrivateIPv4 reports whether an address is in an RFC 1918 private range
+func IsPrivateIPv4(addr uint32) bool {
+	for _, b := range rfc1918Blocks {
+		if b.contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// IPv4Scope describes the address range an address belongs to, or "Public"
+func IPv4Scope(addr uint32) string {
+	for _, b := range rfc1918Blocks {
+		if b.contains(addr) {
+			return b.scope
+		}
+	}
+	for _, b := range specialBlocks {
+		if b.contains(addr) {
+			return b.scope
+		}
+	}
+	return "Public"
+}
@@ -0,0 +1,221 @@
+package ipcalc
+
+import (
+	"net"
+	"testing"
+)
+
+func TestParseIPv4Mask(t *testing.T) {
+	tests := []struct {
+		name         string
+		mask         string
+		wantPrefix   int
+		wantWildcard bool
+		wantErr      bool
+	}{
+		{name: "dotted /24", mask: "255.255.255.0", wantPrefix: 24},
+		{name: "dotted /0", mask: "0.0.0.0", wantPrefix: 0},
+		{name: "dotted /32", mask: "255.255.255.255", wantPrefix: 32},
+		{name: "dotted /17", mask: "255.255.128.0", wantPrefix: 17},
+		{name: "wildcard /24", mask: "0.0.0.255", wantPrefix: 24, wantWildcard: true},
+		{name: "wildcard /12", mask: "0.15.255.255", wantPrefix: 12, wantWildcard: true},
+		{name: "prefix with slash", mask: "/20", wantPrefix: 20},
+		{name: "prefix without slash", mask: "8", wantPrefix: 8},
+		{name: "non-contiguous", mask: "255.0.255.0", wantErr: true},
+		{name: "non-contiguous wildcard", mask: "0.255.0.255", wantErr: true},
+		{name: "prefix too long", mask: "/33", wantErr: true},
+		{name: "not an address", mask: "255.255.255", wantErr: true},
+		{name: "ipv6 mask", mask: "ffff:ffff::", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefix, wildcard, err := ParseIPv4Mask(tt.mask)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseIPv4Mask(%q) = /%d, want error", tt.mask, prefix)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseIPv4Mask(%q) unexpected error: %v", tt.mask, err)
+			}
+			if prefix != tt.wantPrefix || wildcard != tt.wantWildcard {
+				t.Errorf("ParseIPv4Mask(%q) = /%d wildcard=%v, want /%d wildcard=%v",
+					tt.mask, prefix, wildcard, tt.wantPrefix, tt.wantWildcard)
+			}
+		})
+	}
+}
+
+func TestCalculateIPv4(t *testing.T) {
+	tests := []struct {
+		name          string
+		ip            string
+		prefix        int
+		wantNetwork   string
+		wantBroadcast string
+		wantFirst     string
+		wantLast      string
+		wantWildcard  string
+		wantTotal     uint64
+		wantUsable    uint64
+	}{
+		{
+			name: "/24", ip: "192.168.1.130", prefix: 24,
+			wantNetwork: "192.168.1.0", wantBroadcast: "192.168.1.255",
+			wantFirst: "192.168.1.1", wantLast: "192.168.1.254",
+			wantWildcard: "0.0.0.255", wantTotal: 256, wantUsable: 254,
+		},
+		{
+			name: "/23 crosses an octet", ip: "10.0.1.7", prefix: 23,
+			wantNetwork: "10.0.0.0", wantBroadcast: "10.0.1.255",
+			wantFirst: "10.0.0.1", wantLast: "10.0.1.254",
+			wantWildcard: "0.0.1.255", wantTotal: 512, wantUsable: 510,
+		},
+		{
+			name: "/30", ip: "172.16.5.6", prefix: 30,
+			wantNetwork: "172.16.5.4", wantBroadcast: "172.16.5.7",
+			wantFirst: "172.16.5.5", wantLast: "172.16.5.6",
+			wantWildcard: "0.0.0.3", wantTotal: 4, wantUsable: 2,
+		},
+		{
+			name: "/31 point-to-point", ip: "10.1.1.1", prefix: 31,
+			wantNetwork: "10.1.1.0", wantBroadcast: "10.1.1.1",
+			wantFirst: "10.1.1.0", wantLast: "10.1.1.1",
+			wantWildcard: "0.0.0.1", wantTotal: 2, wantUsable: 2,
+		},
+		{
+			name: "/32 single host", ip: "8.8.8.8", prefix: 32,
+			wantNetwork: "8.8.8.8", wantBroadcast: "8.8.8.8",
+			wantFirst: "8.8.8.8", wantLast: "8.8.8.8",
+			wantWildcard: "0.0.0.0", wantTotal: 1, wantUsable: 1,
+		},
+		{
+			name: "/1", ip: "200.1.2.3", prefix: 1,
+			wantNetwork: "128.0.0.0", wantBroadcast: "255.255.255.255",
+			wantFirst: "128.0.0.1", wantLast: "255.255.255.254",
+			wantWildcard: "127.255.255.255", wantTotal: 1 << 31, wantUsable: 1<<31 - 2,
+		},
+		{
+			name: "/0 whole address space", ip: "1.2.3.4", prefix: 0,
+			wantNetwork: "0.0.0.0", wantBroadcast: "255.255.255.255",
+			wantFirst: "0.0.0.1", wantLast: "255.255.255.254",
+			wantWildcard: "255.255.255.255", wantTotal: 1 << 32, wantUsable: 1<<32 - 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CalculateIPv4(net.ParseIP(tt.ip), tt.prefix)
+			if err != nil {
+				t.Fatalf("CalculateIPv4(%s/%d) unexpected error: %v", tt.ip, tt.prefix, err)
+			}
+
+			checks := []struct{ field, got, want string }{
+				{"network", got.Network.Dotted, tt.wantNetwork},
+				{"broadcast", got.Broadcast.Dotted, tt.wantBroadcast},
+				{"first usable", got.FirstUsable.Dotted, tt.wantFirst},
+				{"last usable", got.LastUsable.Dotted, tt.wantLast},
+				{"wildcard", got.Wildcard.Dotted, tt.wantWildcard},
+			}
+			for _, c := range checks {
+				if c.got != c.want {
+					t.Errorf("%s = %s, want %s", c.field, c.got, c.want)
+				}
+			}
+			if got.TotalHosts != tt.wantTotal {
+				t.Errorf("total hosts = %d, want %d", got.TotalHosts, tt.wantTotal)
+			}
+			if got.UsableHosts != tt.wantUsable {
+				t.Errorf("usable hosts = %d, want %d", got.UsableHosts, tt.wantUsable)
+			}
+		})
+	}
+}
+
+func TestCalculateIPv4Rejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		ip     string
+		prefix int
+	}{
+		{name: "ipv6 address", ip: "2001:db8::1", prefix: 24},
+		{name: "negative prefix", ip: "10.0.0.1", prefix: -1},
+		{name: "prefix too long", ip: "10.0.0.1", prefix: 33},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := CalculateIPv4(net.ParseIP(tt.ip), tt.prefix); err == nil {
+				t.Errorf("CalculateIPv4(%s/%d) expected an error", tt.ip, tt.prefix)
+			}
+		})
+	}
+}
+
+func TestFormatIPv4(t *testing.T) {
+	tests := []struct {
+		ip         string
+		wantHex    string
+		wantBinary string
+		wantInt    uint32
+	}{
+		{ip: "0.0.0.0", wantHex: "0x00000000", wantBinary: "00000000.00000000.00000000.00000000", wantInt: 0},
+		{ip: "192.168.1.1", wantHex: "0xC0A80101", wantBinary: "11000000.10101000.00000001.00000001", wantInt: 3232235777},
+		{ip: "255.255.255.0", wantHex: "0xFFFFFF00", wantBinary: "11111111.11111111.11111111.00000000", wantInt: 4294967040},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			n, err := IPv4ToUint32(net.ParseIP(tt.ip))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := FormatIPv4(n)
+			if got.Dotted != tt.ip || got.Hex != tt.wantHex || got.Binary != tt.wantBinary || got.Integer != tt.wantInt {
+				t.Errorf("FormatIPv4(%s) = %+v", tt.ip, got)
+			}
+		})
+	}
+}
+
+func TestIPv4Classification(t *testing.T) {
+	tests := []struct {
+		ip          string
+		wantClass   string
+		wantPrivate bool
+		wantPublic  bool
+		wantScope   string
+	}{
+		{ip: "10.20.30.40", wantClass: "A", wantPrivate: true, wantScope: "Private (RFC 1918)"},
+		{ip: "172.31.255.255", wantClass: "B", wantPrivate: true, wantScope: "Private (RFC 1918)"},
+		{ip: "172.32.0.1", wantClass: "B", wantPublic: true, wantScope: "Public"},
+		{ip: "192.168.0.1", wantClass: "C", wantPrivate: true, wantScope: "Private (RFC 1918)"},
+		{ip: "8.8.8.8", wantClass: "A", wantPublic: true, wantScope: "Public"},
+		{ip: "127.0.0.1", wantClass: "A", wantScope: "Loopback"},
+		{ip: "100.64.0.1", wantClass: "A", wantScope: "Shared address space (CGNAT)"},
+		{ip: "169.254.1.1", wantClass: "B", wantScope: "Link-local"},
+		{ip: "224.0.0.251", wantClass: "D (multicast)", wantScope: "Multicast"},
+		{ip: "250.0.0.1", wantClass: "E (reserved)", wantScope: "Reserved"},
+		{ip: "255.255.255.255", wantClass: "E (reserved)", wantScope: "Limited broadcast"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			got, err := CalculateIPv4(net.ParseIP(tt.ip), 32)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Class != tt.wantClass {
+				t.Errorf("class = %q, want %q", got.Class, tt.wantClass)
+			}
+			if got.Private != tt.wantPrivate || got.Public != tt.wantPublic {
+				t.Errorf("private=%v public=%v, want private=%v public=%v", got.Private, got.Public, tt.wantPrivate, tt.wantPublic)
+			}
+			if got.Scope != tt.wantScope {
+				t.Errorf("scope = %q, want %q", got.Scope, tt.wantScope)
+			}
+		})
+	}
+}
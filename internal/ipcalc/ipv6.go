@@ -0,0 +1,160 @@
+package ipcalc
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+)
+
+var (
+	errNotIPv6       = errors.New("address is not an IPv6 address")
+	errInvalidMAC    = errors.New("MAC address must be 48 bits (6 octets)")
+	errPrefixTooLong = errors.New("prefix for EUI-64 must be /64 or shorter")
+)
+
+// IPv6Details holds the calculated values for an IPv6 network
+type IPv6Details struct {
+	Address             string
+	AddressExpanded     string
+	Network             string
+	NetworkExpanded     string
+	LastAddress         string
+	LastAddressExpanded string
+	PrefixLength        int
+	TotalAddresses      *big.Int
+	ReverseZone         string
+	PTRName             string
+}
+
+// CalculateIPv6 computes network, last address, address count and reverse
+// DNS details for an IPv6 address within the given network
+func CalculateIPv6(ip net.IP, ipNet *net.IPNet) (IPv6Details, error) {
+	if ip.To4() != nil || ip.To16() == nil {
+		return IPv6Details{}, errNotIPv6
+	}
+
+	ones, bits := ipNet.Mask.Size()
+	if bits != 128 {
+		return IPv6Details{}, fmt.Errorf("invalid IPv6 prefix mask")
+	}
+
+	network := ip.Mask(ipNet.Mask)
+	last := LastAddress(network, ipNet.Mask)
+
+	return IPv6Details{
+		Address:             ip.String(),
+		AddressExpanded:     ExpandIPv6(ip),
+		Network:             network.String(),
+		NetworkExpanded:     ExpandIPv6(network),
+		LastAddress:         last.String(),
+		LastAddressExpanded: ExpandIPv6(last),
+		PrefixLength:        ones,
+		TotalAddresses:      AddressCount(ones, bits),
+		ReverseZone:         ReverseZoneIPv6(network, ones),
+		PTRName:             PTRNameIPv6(ip),
+	}, nil
+}
+
+// LastAddress returns the highest address in the network (network OR NOT mask)
+func LastAddress(network net.IP, mask net.IPMask) net.IP {
+	if len(mask) == net.IPv4len {
+		network = network.To4()
+	} else {
+		network = network.To16()
+	}
+
+	last := make(net.IP, len(network))
+	for i := range network {
+		last[i] = network[i] | ^mask[i]
+	}
+	return last
+}
+
+// AddressCount returns 2^(bits-ones) as a big integer
+func AddressCount(ones, bits int) *big.Int {
+	return new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+}
+
+// ExpandIPv6 returns the fully expanded form of an IPv6 address, with all
+// eight groups written as four hex digits
+func ExpandIPv6(ip net.IP) string {
+	ip = ip.To16()
+	if ip == nil {
+		return ""
+	}
+
+	groups := make([]string, 8)
+	for i := 0; i < 8; i++ {
+		groups[i] = fmt.Sprintf("%02x%02x", ip[i*2], ip[i*2+1])
+	}
+	return strings.Join(groups, ":")
+}
+
+// nibbles returns the 32 hex nibbles of an IPv6 address, most significant first
+func nibbles(ip net.IP) []string {
+	ip = ip.To16()
+	out := make([]string, 0, 32)
+	for _, b := range ip {
+		out = append(out, fmt.Sprintf("%x", b>>4), fmt.Sprintf("%x", b&0x0f))
+	}
+	return out
+}
+
+// reverseNibbles joins the first n nibbles in reverse order under ip6.arpa
+func reverseNibbles(ip net.IP, n int) string {
+	nib := nibbles(ip)[:n]
+	parts := make([]string, 0, n+1)
+	for i := n - 1; i >= 0; i-- {
+		parts = append(parts, nib[i])
+	}
+	parts = append(parts, "ip6.arpa")
+	return strings.Join(parts, ".")
+}
+
+// ReverseZoneIPv6 returns the ip6.arpa zone for the network. Delegation
+// happens on nibble boundaries, so prefixes that are not a multiple of 4
+// are rounded down to the enclosing zone
+func ReverseZoneIPv6(network net.IP, prefixLen int) string {
+	return reverseNibbles(network, prefixLen/4)
+}
+
+// PTRNameIPv6 returns the full ip6.arpa PTR record name for an address
+func PTRNameIPv6(ip net.IP) string {
+	return reverseNibbles(ip, 32)
+}
+
+// EUI64InterfaceID derives the modified EUI-64 interface identifier from a
+// 48-bit MAC address (RFC 4291 appendix A): FFFE is inserted in the middle
+// and the universal/local bit is inverted
+func EUI64InterfaceID(mac net.HardwareAddr) ([]byte, error) {
+	if len(mac) != 6 {
+		return nil, errInvalidMAC
+	}
+
+	id := []byte{mac[0] ^ 0x02, mac[1], mac[2], 0xff, 0xfe, mac[3], mac[4], mac[5]}
+	return id, nil
+}
+
+// EUI64Address combines a /64 (or shorter) prefix with the interface
+// identifier derived from the MAC address
+func EUI64Address(prefix *net.IPNet, mac net.HardwareAddr) (net.IP, error) {
+	ones, bits := prefix.Mask.Size()
+	if bits != 128 {
+		return nil, errNotIPv6
+	}
+	if ones > 64 {
+		return nil, errPrefixTooLong
+	}
+
+	id, err := EUI64InterfaceID(mac)
+	if err != nil {
+		return nil, err
+	}
+
+	addr := make(net.IP, net.IPv6len)
+	copy(addr, prefix.IP.To16().Mask(prefix.Mask))
+	copy(addr[8:], id)
+	return addr, nil
+}
@@ -0,0 +1,329 @@
+package ipcalc
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"sort"
+	"strings"
+)
+
+// Relation values reported by Overlaps
+const (
+	RelationDuplicate = "duplicate"
+	RelationContains  = "contains"
+	RelationWithin    = "within"
+)
+
+// Overlap describes a pair of networks from two lists that share addresses
+type Overlap struct {
+	A        *net.IPNet
+	B        *net.IPNet
+	Relation string // A is a duplicate of, contains, or is within B
+}
+
+// ipRange is an inclusive range of addresses of a single family
+type ipRange struct {
+	start *big.Int
+	end   *big.Int
+	bits  int
+}
+
+// ParseCIDRList parses CIDR blocks separated by newlines, commas or spaces.
+// Bare addresses are treated as single-host networks (/32 or /128)
+func ParseCIDRList(text string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+
+	for _, entry := range splitList(text) {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address or CIDR %q", entry)
+			}
+			bits := ipBits(ip)
+			nets = append(nets, &net.IPNet{IP: intToIP(ipToInt(ip), bits), Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", entry)
+		}
+		nets = append(nets, ipNet)
+	}
+
+	return nets, nil
+}
+
+// splitList splits on newlines, commas and whitespace and drops comments
+func splitList(text string) []string {
+	var entries []string
+	for _, line := range strings.Split(text, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		entries = append(entries, strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || r == ';' || r == ' ' || r == '\t' || r == '\r'
+		})...)
+	}
+	return entries
+}
+
+// toRange converts a network to its inclusive address range
+func toRange(ipNet *net.IPNet) ipRange {
+	_, bits := ipNet.Mask.Size()
+	start, end := networkRange(ipNet)
+	return ipRange{start: start, end: end, bits: bits}
+}
+
+// mergeRanges sorts ranges and joins the ones that overlap or touch.
+// IPv4 ranges sort before IPv6 ranges
+func mergeRanges(ranges []ipRange) []ipRange {
+	sort.Slice(ranges, func(i, j int) bool {
+		if ranges[i].bits != ranges[j].bits {
+			return ranges[i].bits < ranges[j].bits
+		}
+		return ranges[i].start.Cmp(ranges[j].start) < 0
+	})
+
+	var merged []ipRange
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && merged[n-1].bits == r.bits {
+			last := &merged[n-1]
+			next := new(big.Int).Add(last.end, big.NewInt(1))
+			if r.start.Cmp(next) <= 0 {
+				if r.end.Cmp(last.end) > 0 {
+					last.end = r.end
+				}
+				continue
+			}
+		}
+		merged = append(merged, ipRange{start: r.start, end: r.end, bits: r.bits})
+	}
+	return merged
+}
+
+// rangesToCIDRs converts merged ranges into CIDR blocks
+func rangesToCIDRs(ranges []ipRange) []*net.IPNet {
+	var blocks []*net.IPNet
+	for _, r := range ranges {
+		blocks = append(blocks, rangeToCIDRs(r.start, r.end, r.bits)...)
+	}
+	return blocks
+}
+
+// Aggregate summarises a list of networks into the minimal set of CIDR
+// blocks covering exactly the same addresses
+func Aggregate(nets []*net.IPNet) []*net.IPNet {
+	ranges := make([]ipRange, 0, len(nets))
+	for _, n := range nets {
+		ranges = append(ranges, toRange(n))
+	}
+	return rangesToCIDRs(mergeRanges(ranges))
+}
+
+// Subtract removes every address covered by b from a and returns the
+// remainder as a minimal list of CIDR blocks
+func Subtract(a, b []*net.IPNet) []*net.IPNet {
+	var keep, remove []ipRange
+	for _, n := range a {
+		keep = append(keep, toRange(n))
+	}
+	for _, n := range b {
+		remove = append(remove, toRange(n))
+	}
+	keep = mergeRanges(keep)
+	remove = mergeRanges(remove)
+
+	one := big.NewInt(1)
+	var result []ipRange
+	for _, k := range keep {
+		cur := new(big.Int).Set(k.start)
+		for _, r := range remove {
+			if r.bits != k.bits || r.end.Cmp(cur) < 0 || r.start.Cmp(k.end) > 0 {
+				continue
+			}
+			if r.start.Cmp(cur) > 0 {
+				result = append(result, ipRange{start: cur, end: new(big.Int).Sub(r.start, one), bits: k.bits})
+			}
+			cur = new(big.Int).Add(r.end, one)
+			if cur.Cmp(k.end) > 0 {
+				break
+			}
+		}
+		if cur.Cmp(k.end) <= 0 {
+			result = append(result, ipRange{start: cur, end: k.end, bits: k.bits})
+		}
+	}
+
+	return rangesToCIDRs(result)
+}
+
+// Overlaps returns every pair of networks, one from each list, that share
+// addresses. CIDR blocks are either nested or disjoint, so each pair is
+// either a duplicate or one block contains the other. An entry repeated
+// within its list is paired by its first appearance only, as Duplicates
+// reports the repeat
+func Overlaps(a, b []*net.IPNet) []Overlap {
+	type entry struct {
+		ipNet *net.IPNet
+		r     ipRange
+		fromA bool
+	}
+
+	entries := make([]entry, 0, len(a)+len(b))
+	for _, n := range a {
+		entries = append(entries, entry{ipNet: n, r: toRange(n), fromA: true})
+	}
+	for _, n := range b {
+		entries = append(entries, entry{ipNet: n, r: toRange(n)})
+	}
+
+	// Sort by family, then start ascending and size descending, so every
+	// block comes after the blocks that contain it
+	sort.SliceStable(entries, func(i, j int) bool {
+		ri, rj := entries[i].r, entries[j].r
+		if ri.bits != rj.bits {
+			return ri.bits < rj.bits
+		}
+		if c := ri.start.Cmp(rj.start); c != 0 {
+			return c < 0
+		}
+		return ri.end.Cmp(rj.end) > 0
+	})
+
+	// Identical blocks are now adjacent. Keep the first from each list, so
+	// the stack never holds repeats that every later block would be paired
+	// with again
+	unique := entries[:0]
+	var last ipRange
+	var seenA, seenB bool
+	for i, cur := range entries {
+		if i == 0 || !sameRange(last, cur.r) {
+			last, seenA, seenB = cur.r, false, false
+		}
+		seen := &seenB
+		if cur.fromA {
+			seen = &seenA
+		}
+		if !*seen {
+			*seen = true
+			unique = append(unique, cur)
+		}
+	}
+
+	var overlaps []Overlap
+	var stack []entry
+	for _, cur := range unique {
+		// Drop enclosing blocks that end before this one starts
+		for len(stack) > 0 {
+			top := stack[len(stack)-1]
+			if top.r.bits == cur.r.bits && top.r.end.Cmp(cur.r.start) >= 0 {
+				break
+			}
+			stack = stack[:len(stack)-1]
+		}
+
+		// Everything left on the stack contains the current block
+		for _, outer := range stack {
+			if outer.fromA == cur.fromA {
+				continue
+			}
+
+			relation := RelationContains
+			if sameRange(outer.r, cur.r) {
+				relation = RelationDuplicate
+			}
+
+			if outer.fromA {
+				overlaps = append(overlaps, Overlap{A: outer.ipNet, B: cur.ipNet, Relation: relation})
+			} else {
+				if relation == RelationContains {
+					relation = RelationWithin
+				}
+				overlaps = append(overlaps, Overlap{A: cur.ipNet, B: outer.ipNet, Relation: relation})
+			}
+		}
+
+		stack = append(stack, cur)
+	}
+
+	return overlaps
+}
+
+// Duplicates returns networks that appear more than once in a single list,
+// or are fully covered by another entry of the same list. A repeated entry
+// is reported once against its first appearance, and only that appearance
+// is reported as containing other entries
+func Duplicates(nets []*net.IPNet) []Overlap {
+	type entry struct {
+		ipNet *net.IPNet
+		r     ipRange
+	}
+
+	entries := make([]entry, 0, len(nets))
+	for _, n := range nets {
+		entries = append(entries, entry{ipNet: n, r: toRange(n)})
+	}
+
+	// Sorted as in Overlaps; the stable sort keeps repeats in list order
+	sort.SliceStable(entries, func(i, j int) bool {
+		ri, rj := entries[i].r, entries[j].r
+		if ri.bits != rj.bits {
+			return ri.bits < rj.bits
+		}
+		if c := ri.start.Cmp(rj.start); c != 0 {
+			return c < 0
+		}
+		return ri.end.Cmp(rj.end) > 0
+	})
+
+	var found []Overlap
+	var stack []entry
+	for _, cur := range entries {
+		for len(stack) > 0 {
+			top := stack[len(stack)-1]
+			if top.r.bits == cur.r.bits && top.r.end.Cmp(cur.r.start) >= 0 {
+				break
+			}
+			stack = stack[:len(stack)-1]
+		}
+
+		if n := len(stack); n > 0 && sameRange(stack[n-1].r, cur.r) {
+			found = append(found, Overlap{A: stack[n-1].ipNet, B: cur.ipNet, Relation: RelationDuplicate})
+			continue
+		}
+		for _, outer := range stack {
+			found = append(found, Overlap{A: outer.ipNet, B: cur.ipNet, Relation: RelationContains})
+		}
+		stack = append(stack, cur)
+	}
+	return found
+}
+
+// sameRange reports whether two ranges cover the same addresses
+func sameRange(a, b ipRange) bool {
+	return a.bits == b.bits && a.start.Cmp(b.start) == 0 && a.end.Cmp(b.end) == 0
+}
+
+// ParseRange parses "start-end" into two addresses of the same family
+func ParseRange(text string) (net.IP, net.IP, error) {
+	parts := strings.SplitN(text, "-", 2)
+	if len(parts) != 2 {
+		return nil, nil, fmt.Errorf("range %q must be written as start-end", text)
+	}
+
+	start := net.ParseIP(strings.TrimSpace(parts[0]))
+	end := net.ParseIP(strings.TrimSpace(parts[1]))
+	if start == nil || end == nil {
+		return nil, nil, fmt.Errorf("range %q contains an invalid address", text)
+	}
+	if ipBits(start) != ipBits(end) {
+		return nil, nil, fmt.Errorf("range %q mixes IPv4 and IPv6", text)
+	}
+	if ipToInt(start).Cmp(ipToInt(end)) > 0 {
+		return nil, nil, fmt.Errorf("range %q starts after it ends", text)
+	}
+
+	return start, end, nil
+}
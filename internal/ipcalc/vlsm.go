@@ -0,0 +1,158 @@
+package ipcalc
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"math/bits"
+	"net"
+	"sort"
+)
+
+// MaxSplitSubnets limits how many subnets a single split may return
+const MaxSplitSubnets = 4096
+
+var errEmptyPlan = errors.New("at least one subnet requirement is needed")
+
+// Requirement describes one subnet the planner must allocate. Either Hosts
+// or Prefix is set; Prefix wins when both are present
+type Requirement struct {
+	Name   string `json:"name"`
+	Hosts  int    `json:"hosts,omitempty"`
+	Prefix int    `json:"prefix,omitempty"`
+}
+
+// Allocation is a subnet assigned to a requirement
+type Allocation struct {
+	Requirement Requirement `json:"requirement"`
+	Network     *net.IPNet  `json:"-"`
+	CIDR        string      `json:"cidr"`
+}
+
+// Plan is the result of a VLSM allocation inside a parent block
+type Plan struct {
+	Parent      string       `json:"parent"`
+	Allocations []Allocation `json:"allocations"`
+	Free        []string     `json:"free"`
+}
+
+// PrefixForHosts returns the longest prefix that holds the requested number
+// of usable hosts. IPv4 subnets lose the network and broadcast addresses,
+// except /31 (RFC 3021) and /32
+func PrefixForHosts(hosts, addrBits int) (int, error) {
+	if hosts < 1 {
+		return 0, fmt.Errorf("host count must be at least 1")
+	}
+
+	if addrBits == 128 {
+		return 128 - bits.Len(uint(hosts-1)), nil
+	}
+
+	switch hosts {
+	case 1:
+		return 32, nil
+	case 2:
+		return 31, nil
+	}
+
+	hostBits := bits.Len(uint(hosts + 1))
+	if hostBits > 32 {
+		return 0, fmt.Errorf("%d hosts do not fit in an IPv4 network", hosts)
+	}
+	return 32 - hostBits, nil
+}
+
+// PlanVLSM allocates non-overlapping subnets for the requirements inside the
+// parent block, largest first, and reports the space left over
+func PlanVLSM(parent *net.IPNet, reqs []Requirement) (Plan, error) {
+	if len(reqs) == 0 {
+		return Plan{}, errEmptyPlan
+	}
+
+	parentOnes, addrBits := parent.Mask.Size()
+
+	// Resolve every requirement to a prefix length
+	type sized struct {
+		req    Requirement
+		prefix int
+	}
+	items := make([]sized, 0, len(reqs))
+	for _, req := range reqs {
+		prefix := req.Prefix
+		if prefix == 0 {
+			var err error
+			prefix, err = PrefixForHosts(req.Hosts, addrBits)
+			if err != nil {
+				return Plan{}, fmt.Errorf("%s: %v", req.Name, err)
+			}
+		}
+		if prefix < parentOnes || prefix > addrBits {
+			return Plan{}, fmt.Errorf("%s: /%d does not fit inside %s", req.Name, prefix, parent.String())
+		}
+		items = append(items, sized{req: req, prefix: prefix})
+	}
+
+	// Largest subnets first keeps every allocation aligned without gaps
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].prefix < items[j].prefix
+	})
+
+	cursor, last := networkRange(parent)
+	end := new(big.Int).Add(last, big.NewInt(1))
+
+	plan := Plan{Parent: parent.String()}
+	for _, item := range items {
+		size := AddressCount(item.prefix, addrBits)
+		next := new(big.Int).Add(cursor, size)
+		if next.Cmp(end) > 0 {
+			return Plan{}, fmt.Errorf("%s (/%d) does not fit: %s is exhausted", item.req.Name, item.prefix, parent.String())
+		}
+
+		network := &net.IPNet{
+			IP:   intToIP(cursor, addrBits),
+			Mask: net.CIDRMask(item.prefix, addrBits),
+		}
+		item.req.Prefix = item.prefix
+		plan.Allocations = append(plan.Allocations, Allocation{
+			Requirement: item.req,
+			Network:     network,
+			CIDR:        network.String(),
+		})
+		cursor = next
+	}
+
+	// Everything after the cursor is free
+	if cursor.Cmp(last) <= 0 {
+		for _, block := range rangeToCIDRs(cursor, last, addrBits) {
+			plan.Free = append(plan.Free, block.String())
+		}
+	}
+
+	return plan, nil
+}
+
+// Split divides a network into equal subnets of the new prefix length
+func Split(parent *net.IPNet, newPrefix int) ([]*net.IPNet, error) {
+	ones, addrBits := parent.Mask.Size()
+	if newPrefix < ones || newPrefix > addrBits {
+		return nil, fmt.Errorf("new prefix must be between /%d and /%d", ones, addrBits)
+	}
+	if newPrefix-ones > bits.Len(MaxSplitSubnets)-1 {
+		return nil, fmt.Errorf("splitting %s into /%d would create more than %d subnets", parent.String(), newPrefix, MaxSplitSubnets)
+	}
+
+	count := 1 << (newPrefix - ones)
+	size := AddressCount(newPrefix, addrBits)
+	cursor, _ := networkRange(parent)
+
+	subnets := make([]*net.IPNet, 0, count)
+	for i := 0; i < count; i++ {
+		subnets = append(subnets, &net.IPNet{
+			IP:   intToIP(cursor, addrBits),
+			Mask: net.CIDRMask(newPrefix, addrBits),
+		})
+		cursor = new(big.Int).Add(cursor, size)
+	}
+
+	return subnets, nil
+}
@@ -0,0 +1,345 @@
+// Package jsondiff compares jsonfmt trees structurally and converts the
+// result to an RFC 6902 JSON Patch or an RFC 7386 merge patch, and applies
+// either kind of patch to a document.
+//
+// Numbers compare by value, so 1.0 equals 1, and object member order never
+// matters. Arrays compare in order, aligned on their longest common
+// subsequence so one inserted element is one change, unless the diff is
+// told to ignore array order
+package jsondiff
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Ndeta100/orbit2x/internal/jsonfmt"
+)
+
+// Operations, as named in JSON Patch
+const (
+	Add     = "add"
+	Remove  = "remove"
+	Replace = "replace"
+)
+
+// maxAlignCells bounds the table used to align two arrays; longer arrays
+// are compared position by position
+const maxAlignCells = 1 << 22
+
+// Options control what counts as a difference
+type Options struct {
+	IgnoreOrder bool
+	// IgnoreKeys are member names, skipped at any depth, or JSON Pointers
+	// in which * stands for any one segment, such as /items/*/id
+	IgnoreKeys []string
+}
+
+// Change is one difference. Changes are in JSON Patch order: applying them
+// in sequence turns the left document into the right one, so array indexes
+// account for the changes before them. Old is nil for additions and New
+// for removals
+type Change struct {
+	Op   string
+	Path string
+	Old  *jsonfmt.Node
+	New  *jsonfmt.Node
+}
+
+type differ struct {
+	opts     Options
+	names    map[string]bool
+	patterns [][]string
+	canons   map[canonKey]string
+}
+
+// canonKey names a value and, when pointers pick the ignored keys, the
+// path that decides which of its members count
+type canonKey struct {
+	n    *jsonfmt.Node
+	path string
+}
+
+func newDiffer(opts Options) *differ {
+	d := &differ{opts: opts, names: map[string]bool{}, canons: map[canonKey]string{}}
+	for _, key := range opts.IgnoreKeys {
+		if !strings.HasPrefix(key, "/") {
+			d.names[key] = true
+		} else if tokens, err := jsonfmt.SplitPointer(key); err == nil {
+			d.patterns = append(d.patterns, tokens)
+		}
+	}
+	return d
+}
+
+// Diff lists the changes that turn a into b
+func Diff(a, b *jsonfmt.Node, opts Options) []Change {
+	d := newDiffer(opts)
+	var out []Change
+	d.diff(a, b, "", &out)
+	return out
+}
+
+func (d *differ) diff(a, b *jsonfmt.Node, path string, out *[]Change) {
+	switch {
+	case a.Kind == jsonfmt.Object && b.Kind == jsonfmt.Object:
+		d.diffObjects(a, b, path, out)
+	case a.Kind == jsonfmt.Array && b.Kind == jsonfmt.Array && d.opts.IgnoreOrder:
+		d.diffBags(a, b, path, out)
+	case a.Kind == jsonfmt.Array && b.Kind == jsonfmt.Array:
+		d.diffArrays(a, b, path, out)
+	case !jsonfmt.Equal(a, b):
+		*out = append(*out, Change{Op: Replace, Path: path, Old: a, New: b})
+	}
+}
+
+func (d *differ) diffObjects(a, b *jsonfmt.Node, path string, out *[]Change) {
+	av, bv := members(a), members(b)
+	for _, k := range distinctKeys(a) {
+		child := path + "/" + jsonfmt.EscapePointer(k)
+		if d.ignored(k, child) {
+			continue
+		}
+		if bv[k] == nil {
+			*out = append(*out, Change{Op: Remove, Path: child, Old: av[k]})
+		} else {
+			d.diff(av[k], bv[k], child, out)
+		}
+	}
+	for _, k := range distinctKeys(b) {
+		child := path + "/" + jsonfmt.EscapePointer(k)
+		if av[k] == nil && !d.ignored(k, child) {
+			*out = append(*out, Change{Op: Add, Path: child, New: bv[k]})
+		}
+	}
+}
+
+// diffArrays aligns the arrays on their longest common subsequence. Within
+// each run of differing elements, removed and added elements are paired
+// up and compared in place, and any left over are removed or inserted
+func (d *differ) diffArrays(a, b *jsonfmt.Node, path string, out *[]Change) {
+	ca, cb := d.canonItems(a, path), d.canonItems(b, path)
+	n, m := len(ca), len(cb)
+
+	var steps []byte // '=' keep, '-' remove from a, '+' insert from b
+	if (n+1)*(m+1) > maxAlignCells {
+		for i := 0; i < max(n, m); i++ {
+			switch {
+			case i < n && i < m:
+				steps = append(steps, '-', '+')
+			case i < n:
+				steps = append(steps, '-')
+			default:
+				steps = append(steps, '+')
+			}
+		}
+	} else {
+		// lcs[i][j] is the common subsequence length of a[i:] and b[j:]
+		lcs := make([][]int32, n+1)
+		for i := range lcs {
+			lcs[i] = make([]int32, m+1)
+		}
+		for i := n - 1; i >= 0; i-- {
+			for j := m - 1; j >= 0; j-- {
+				if ca[i] == cb[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < n || j < m {
+			switch {
+			case i < n && j < m && ca[i] == cb[j]:
+				steps = append(steps, '=')
+				i++
+				j++
+			case j == m || i < n && lcs[i+1][j] >= lcs[i][j+1]:
+				steps = append(steps, '-')
+				i++
+			default:
+				steps = append(steps, '+')
+				j++
+			}
+		}
+	}
+
+	// k is the index in the array as patched so far
+	i, j, k := 0, 0, 0
+	for s := 0; s < len(steps); {
+		if steps[s] == '=' {
+			i, j, k, s = i+1, j+1, k+1, s+1
+			continue
+		}
+		var removed, added []int
+		for ; s < len(steps) && steps[s] != '='; s++ {
+			if steps[s] == '-' {
+				removed = append(removed, i)
+				i++
+			} else {
+				added = append(added, j)
+				j++
+			}
+		}
+		paired := min(len(removed), len(added))
+		for p := 0; p < paired; p++ {
+			d.diff(a.Items[removed[p]], b.Items[added[p]], path+"/"+strconv.Itoa(k), out)
+			k++
+		}
+		for _, r := range removed[paired:] {
+			*out = append(*out, Change{Op: Remove, Path: path + "/" + strconv.Itoa(k), Old: a.Items[r]})
+		}
+		for _, ad := range added[paired:] {
+			*out = append(*out, Change{Op: Add, Path: path + "/" + strconv.Itoa(k), New: b.Items[ad]})
+			k++
+		}
+	}
+}
+
+// diffBags compares arrays as multisets. Equal elements cancel out; the
+// rest are paired up in order and compared at their left-hand index, then
+// leftovers are removed from the end backwards or appended
+func (d *differ) diffBags(a, b *jsonfmt.Node, path string, out *[]Change) {
+	ca, cb := d.canonItems(a, path), d.canonItems(b, path)
+	unmatched := map[string][]int{}
+	for i, c := range ca {
+		unmatched[c] = append(unmatched[c], i)
+	}
+	var added []int
+	for j, c := range cb {
+		if free := unmatched[c]; len(free) > 0 {
+			unmatched[c] = free[1:]
+		} else {
+			added = append(added, j)
+		}
+	}
+	var removed []int
+	for _, free := range unmatched {
+		removed = append(removed, free...)
+	}
+	sort.Ints(removed)
+
+	paired := min(len(removed), len(added))
+	for p := 0; p < paired; p++ {
+		d.diff(a.Items[removed[p]], b.Items[added[p]], path+"/"+strconv.Itoa(removed[p]), out)
+	}
+	for p := len(removed) - 1; p >= paired; p-- {
+		*out = append(*out, Change{Op: Remove, Path: path + "/" + strconv.Itoa(removed[p]), Old: a.Items[removed[p]]})
+	}
+	for _, j := range added[paired:] {
+		*out = append(*out, Change{Op: Add, Path: path + "/-", New: b.Items[j]})
+	}
+}
+
+// ignored reports whether an object member is excluded from the diff
+func (d *differ) ignored(key, path string) bool {
+	if d.names[key] {
+		return true
+	}
+	if len(d.patterns) == 0 {
+		return false
+	}
+	tokens, _ := jsonfmt.SplitPointer(path)
+	for _, pattern := range d.patterns {
+		if len(pattern) != len(tokens) {
+			continue
+		}
+		match := true
+		for i := range pattern {
+			if pattern[i] != "*" && pattern[i] != tokens[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *differ) canonItems(n *jsonfmt.Node, path string) []string {
+	out := make([]string, len(n.Items))
+	for i, item := range n.Items {
+		out[i] = d.canon(item, path+"/"+strconv.Itoa(i))
+	}
+	return out
+}
+
+// canon writes a value so that two values are equal under the options
+// exactly when their canonical strings are: keys sorted, ignored keys
+// dropped, numbers in lowest terms and, when ignoring order, array
+// elements sorted. Arrays and objects are written as a digest of that
+// form, remembered per node, so nested arrays are not rewritten in full at
+// every level they are compared on
+func (d *differ) canon(n *jsonfmt.Node, path string) string {
+	switch n.Kind {
+	case jsonfmt.Null:
+		return "null"
+	case jsonfmt.Bool:
+		return n.Value
+	case jsonfmt.Number:
+		if x, ok := jsonfmt.Rat(n); ok {
+			return x.RatString()
+		}
+		return n.Value
+	case jsonfmt.String:
+		return jsonfmt.Quote(n.Value)
+	}
+
+	key := canonKey{n: n}
+	if len(d.patterns) > 0 {
+		key.path = path
+	}
+	if c, ok := d.canons[key]; ok {
+		return c
+	}
+	var form string
+	if n.Kind == jsonfmt.Array {
+		items := d.canonItems(n, path)
+		if d.opts.IgnoreOrder {
+			sort.Strings(items)
+		}
+		form = "[" + strings.Join(items, ",") + "]"
+	} else {
+		var parts []string
+		values := members(n)
+		for _, k := range jsonfmt.SortedKeys(n) {
+			child := path + "/" + jsonfmt.EscapePointer(k)
+			if !d.ignored(k, child) {
+				parts = append(parts, jsonfmt.Quote(k)+":"+d.canon(values[k], child))
+			}
+		}
+		form = "{" + strings.Join(parts, ",") + "}"
+	}
+	sum := sha256.Sum256([]byte(form))
+	c := "#" + hex.EncodeToString(sum[:16])
+	d.canons[key] = c
+	return c
+}
+
+// members maps an object's keys to their values, the last one when a key
+// repeats as with Get, so wide objects are not scanned once per key
+func members(n *jsonfmt.Node) map[string]*jsonfmt.Node {
+	values := make(map[string]*jsonfmt.Node, len(n.Members))
+	for _, m := range n.Members {
+		values[m.Key] = m.Value
+	}
+	return values
+}
+
+// distinctKeys lists an object's keys in document order, once each
+func distinctKeys(n *jsonfmt.Node) []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, m := range n.Members {
+		if !seen[m.Key] {
+			seen[m.Key] = true
+			keys = append(keys, m.Key)
+		}
+	}
+	return keys
+}
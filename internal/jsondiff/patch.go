@@ -0,0 +1,382 @@
+package jsondiff
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Ndeta100/orbit2x/internal/jsonfmt"
+)
+
+// JSONPatch writes changes as an RFC 6902 operation list
+func JSONPatch(changes []Change) *jsonfmt.Node {
+	patch := &jsonfmt.Node{Kind: jsonfmt.Array}
+	for _, c := range changes {
+		op := &jsonfmt.Node{Kind: jsonfmt.Object}
+		op.Set("op", jsonfmt.NewString(c.Op))
+		op.Set("path", jsonfmt.NewString(c.Path))
+		if c.New != nil {
+			op.Set("value", c.New)
+		}
+		patch.Items = append(patch.Items, op)
+	}
+	return patch
+}
+
+// MergePatch builds the RFC 7386 merge patch that turns a into b. Merge
+// patches replace arrays whole and use null to delete, so a null that b
+// sets inside an object cannot be expressed; each one is returned as a
+// warning
+func MergePatch(a, b *jsonfmt.Node, opts Options) (*jsonfmt.Node, []string) {
+	d := newDiffer(opts)
+	var warnings []string
+	patch, changed := d.merge(a, b, "", &warnings)
+	if !changed {
+		return &jsonfmt.Node{Kind: jsonfmt.Object}, warnings
+	}
+	return patch, warnings
+}
+
+func (d *differ) merge(a, b *jsonfmt.Node, path string, warnings *[]string) (*jsonfmt.Node, bool) {
+	if a.Kind != jsonfmt.Object || b.Kind != jsonfmt.Object {
+		if d.canon(a, path) == d.canon(b, path) {
+			return nil, false
+		}
+		if b.Kind == jsonfmt.Object {
+			nullMembers(b, path, warnings)
+		}
+		return b, true
+	}
+
+	// Keys are distinct and a key is only in one loop's output, so members
+	// are appended rather than Set, which would scan the patch each time
+	patch := &jsonfmt.Node{Kind: jsonfmt.Object}
+	am, bm := members(a), members(b)
+	for _, k := range distinctKeys(a) {
+		child := path + "/" + jsonfmt.EscapePointer(k)
+		if bm[k] == nil && !d.ignored(k, child) {
+			patch.Members = append(patch.Members, jsonfmt.Member{Key: k, Value: &jsonfmt.Node{Kind: jsonfmt.Null}})
+		}
+	}
+	for _, k := range distinctKeys(b) {
+		child := path + "/" + jsonfmt.EscapePointer(k)
+		if d.ignored(k, child) {
+			continue
+		}
+		bv := bm[k]
+		if av := am[k]; av != nil {
+			if sub, changed := d.merge(av, bv, child, warnings); changed {
+				if bv.Kind == jsonfmt.Null {
+					*warnings = append(*warnings, child+" is set to null, which a merge patch can only express as removing it")
+				}
+				patch.Members = append(patch.Members, jsonfmt.Member{Key: k, Value: sub})
+			}
+			continue
+		}
+		if bv.Kind == jsonfmt.Null {
+			*warnings = append(*warnings, child+" is set to null, which a merge patch can only express as removing it")
+		} else if bv.Kind == jsonfmt.Object {
+			nullMembers(bv, child, warnings)
+		}
+		patch.Members = append(patch.Members, jsonfmt.Member{Key: k, Value: bv})
+	}
+	return patch, len(patch.Members) > 0
+}
+
+// nullMembers warns about nulls in an object a merge patch would set,
+// since applying it drops them
+func nullMembers(n *jsonfmt.Node, path string, warnings *[]string) {
+	for _, m := range n.Members {
+		child := path + "/" + jsonfmt.EscapePointer(m.Key)
+		switch m.Value.Kind {
+		case jsonfmt.Null:
+			*warnings = append(*warnings, child+" is null, which a merge patch drops when applied")
+		case jsonfmt.Object:
+			nullMembers(m.Value, child, warnings)
+		}
+	}
+}
+
+// ApplyMergePatch applies an RFC 7386 merge patch, returning a new document
+func ApplyMergePatch(doc, patch *jsonfmt.Node) *jsonfmt.Node {
+	if patch.Kind != jsonfmt.Object {
+		return patch.Clone()
+	}
+	out := &jsonfmt.Node{Kind: jsonfmt.Object}
+	if doc != nil && doc.Kind == jsonfmt.Object {
+		out = doc.Clone()
+	}
+	// Work on an index of the members rather than with Get, Set and Delete,
+	// which each scan the whole object
+	values, changes := members(out), members(patch)
+	merged := make(map[string]bool)
+	kept := out.Members[:0]
+	for _, m := range out.Members {
+		v, changed := changes[m.Key]
+		switch {
+		case !changed || v.Kind != jsonfmt.Null && merged[m.Key]:
+			kept = append(kept, m)
+		case v.Kind != jsonfmt.Null:
+			// As Set does, the first member takes the merge of the value Get
+			// finds, which is the last one
+			merged[m.Key] = true
+			kept = append(kept, jsonfmt.Member{Key: m.Key, Value: ApplyMergePatch(values[m.Key], v)})
+		}
+	}
+	out.Members = kept
+	for _, k := range distinctKeys(patch) {
+		if v := changes[k]; v.Kind != jsonfmt.Null && values[k] == nil {
+			out.Members = append(out.Members, jsonfmt.Member{Key: k, Value: ApplyMergePatch(nil, v)})
+		}
+	}
+	return out
+}
+
+// ApplyPatch applies an RFC 6902 JSON Patch, returning a new document. The
+// patch is atomic: on error the document is returned unchanged along with
+// the operation that failed
+func ApplyPatch(doc, patch *jsonfmt.Node) (*jsonfmt.Node, error) {
+	if patch.Kind != jsonfmt.Array {
+		return doc, fmt.Errorf("a JSON Patch is an array of operations, not %s", patch.Kind)
+	}
+	root := doc.Clone()
+	copied := 0
+	for i, op := range patch.Items {
+		var err error
+		if root, err = applyOp(root, op, &copied); err != nil {
+			name, path := op.Get("op"), op.Get("path")
+			if name != nil && path != nil {
+				return doc, fmt.Errorf("operation %d (%s %s): %w", i+1, name.Value, path.Value, err)
+			}
+			return doc, fmt.Errorf("operation %d: %w", i+1, err)
+		}
+	}
+	return root, nil
+}
+
+// maxCopied bounds the values a patch's copy operations duplicate in all,
+// since each copy of the document into itself doubles it
+const maxCopied = 1 << 20
+
+// applyOp applies one operation, adding the values it copies to copied
+func applyOp(root, op *jsonfmt.Node, copied *int) (*jsonfmt.Node, error) {
+	if op.Kind != jsonfmt.Object {
+		return nil, fmt.Errorf("an operation must be an object, not %s", op.Kind)
+	}
+	name, err := stringMember(op, "op")
+	if err != nil {
+		return nil, err
+	}
+	pathText, err := stringMember(op, "path")
+	if err != nil {
+		return nil, err
+	}
+	path, err := jsonfmt.SplitPointer(pathText)
+	if err != nil {
+		return nil, err
+	}
+	value := op.Get("value")
+	if value == nil && (name == "add" || name == "replace" || name == "test") {
+		return nil, fmt.Errorf("%q needs a value", name)
+	}
+
+	switch name {
+	case "add":
+		return add(root, path, value.Clone())
+	case "remove":
+		_, err := remove(root, path)
+		return root, err
+	case "replace":
+		if _, err := get(root, path); err != nil {
+			return nil, err
+		}
+		if len(path) == 0 {
+			return value.Clone(), nil
+		}
+		parent, _ := get(root, path[:len(path)-1])
+		last := path[len(path)-1]
+		if parent.Kind == jsonfmt.Object {
+			parent.Set(last, value.Clone())
+		} else {
+			i, _ := strconv.Atoi(last)
+			parent.Items[i] = value.Clone()
+		}
+		return root, nil
+	case "test":
+		current, err := get(root, path)
+		if err != nil {
+			return nil, err
+		}
+		if !jsonfmt.Equal(current, value) {
+			return nil, fmt.Errorf("test failed: the value is %s", compact(current))
+		}
+		return root, nil
+	case "move", "copy":
+		fromText, err := stringMember(op, "from")
+		if err != nil {
+			return nil, err
+		}
+		from, err := jsonfmt.SplitPointer(fromText)
+		if err != nil {
+			return nil, err
+		}
+		if name == "copy" {
+			v, err := get(root, from)
+			if err != nil {
+				return nil, err
+			}
+			if *copied += countValues(v, maxCopied-*copied+1); *copied > maxCopied {
+				return nil, fmt.Errorf("the patch copies more than %d values in all", maxCopied)
+			}
+			return add(root, path, v.Clone())
+		}
+		if len(from) < len(path) && strings.HasPrefix(pathText, fromText+"/") {
+			return nil, fmt.Errorf("cannot move a value into one of its own children")
+		}
+		if fromText == pathText {
+			if _, err := get(root, from); err != nil {
+				return nil, err
+			}
+			return root, nil
+		}
+		v, err := remove(root, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(root, path, v)
+	}
+	return nil, fmt.Errorf("unknown op %q", name)
+}
+
+// countValues counts n and the values inside it, stopping past limit
+func countValues(n *jsonfmt.Node, limit int) int {
+	count := 1
+	for _, item := range n.Items {
+		if count >= limit {
+			break
+		}
+		count += countValues(item, limit-count)
+	}
+	for _, m := range n.Members {
+		if count >= limit {
+			break
+		}
+		count += countValues(m.Value, limit-count)
+	}
+	return count
+}
+
+func stringMember(op *jsonfmt.Node, key string) (string, error) {
+	v := op.Get(key)
+	if v == nil || v.Kind != jsonfmt.String {
+		return "", fmt.Errorf("the operation needs a string %q", key)
+	}
+	return v.Value, nil
+}
+
+// get follows a parsed pointer
+func get(root *jsonfmt.Node, path []string) (*jsonfmt.Node, error) {
+	n := root
+	for depth, token := range path {
+		switch n.Kind {
+		case jsonfmt.Object:
+			next := n.Get(token)
+			if next == nil {
+				return nil, fmt.Errorf("%s does not exist", pointer(path[:depth+1]))
+			}
+			n = next
+		case jsonfmt.Array:
+			i, err := arrayIndex(token, len(n.Items)-1)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", pointer(path[:depth+1]), err)
+			}
+			n = n.Items[i]
+		default:
+			return nil, fmt.Errorf("%s is %s, which has no children", pointer(path[:depth]), n.Kind)
+		}
+	}
+	return n, nil
+}
+
+func add(root *jsonfmt.Node, path []string, value *jsonfmt.Node) (*jsonfmt.Node, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := get(root, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+	switch parent.Kind {
+	case jsonfmt.Object:
+		parent.Set(last, value)
+	case jsonfmt.Array:
+		i := len(parent.Items)
+		if last != "-" {
+			if i, err = arrayIndex(last, len(parent.Items)); err != nil {
+				return nil, fmt.Errorf("%s: %v", pointer(path), err)
+			}
+		}
+		parent.Items = append(parent.Items, nil)
+		copy(parent.Items[i+1:], parent.Items[i:])
+		parent.Items[i] = value
+	default:
+		return nil, fmt.Errorf("%s is %s, which has no children", pointer(path[:len(path)-1]), parent.Kind)
+	}
+	return root, nil
+}
+
+// remove deletes the value at path and returns it
+func remove(root *jsonfmt.Node, path []string) (*jsonfmt.Node, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("the whole document cannot be removed")
+	}
+	v, err := get(root, path)
+	if err != nil {
+		return nil, err
+	}
+	parent, _ := get(root, path[:len(path)-1])
+	last := path[len(path)-1]
+	if parent.Kind == jsonfmt.Object {
+		parent.Delete(last)
+	} else {
+		i, _ := strconv.Atoi(last)
+		parent.Items = append(parent.Items[:i], parent.Items[i+1:]...)
+	}
+	return v, nil
+}
+
+// arrayIndex parses an array reference token, which must be a plain
+// decimal no larger than limit
+func arrayIndex(token string, limit int) (int, error) {
+	if token == "-" {
+		return 0, fmt.Errorf("- (the end of the array) can only be used to add")
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || token != strconv.Itoa(i) {
+		return 0, fmt.Errorf("%q is not an array index", token)
+	}
+	if i > limit {
+		return 0, fmt.Errorf("index %d is out of range", i)
+	}
+	return i, nil
+}
+
+func pointer(tokens []string) string {
+	if len(tokens) == 0 {
+		return "the document"
+	}
+	var b strings.Builder
+	for _, t := range tokens {
+		b.WriteString("/" + jsonfmt.EscapePointer(t))
+	}
+	return b.String()
+}
+
+func compact(n *jsonfmt.Node) string {
+	s := strings.TrimSuffix(jsonfmt.Format(n, jsonfmt.Options{Minify: true}), "\n")
+	if runes := []rune(s); len(runes) > 80 {
+		s = string(runes[:80]) + "..."
+	}
+	return s
+}
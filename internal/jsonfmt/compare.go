@@ -0,0 +1,175 @@
+package jsonfmt
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxExponent keeps exact arithmetic on literals like 1e999999999 cheap
+const maxExponent = 1000
+
+// Rat reads a number node exactly. Literals with huge exponents are
+// refused rather than expanded
+func Rat(n *Node) (*big.Rat, bool) {
+	if n == nil || n.Kind != Number {
+		return nil, false
+	}
+	if i := strings.IndexAny(n.Value, "eE"); i >= 0 {
+		exp, err := strconv.Atoi(n.Value[i+1:])
+		if err != nil || exp > maxExponent || exp < -maxExponent {
+			return nil, false
+		}
+	}
+	return new(big.Rat).SetString(n.Value)
+}
+
+// NewRat returns a number node for x, exact for integers and as short as
+// float64 allows otherwise
+func NewRat(x *big.Rat) *Node {
+	if x.IsInt() {
+		return NewNumber(x.Num().String())
+	}
+	f, _ := x.Float64()
+	return NewNumber(strconv.FormatFloat(f, 'g', -1, 64))
+}
+
+// Equal compares JSON values: numbers by value and objects regardless of
+// member order
+func Equal(a, b *Node) bool {
+	return Compare(a, b) == 0
+}
+
+// Compare orders values the way jq does: null, false, true, numbers,
+// strings, arrays, then objects. Objects compare by their sorted key sets
+// first and then by the values under those keys
+func Compare(a, b *Node) int {
+	if ra, rb := rank(a), rank(b); ra != rb {
+		return sign(ra - rb)
+	}
+	switch a.Kind {
+	case Number:
+		x, ok1 := Rat(a)
+		y, ok2 := Rat(b)
+		if !ok1 || !ok2 {
+			return strings.Compare(a.Value, b.Value)
+		}
+		return x.Cmp(y)
+	case String:
+		return strings.Compare(a.Value, b.Value)
+	case Array:
+		for i := 0; i < len(a.Items) && i < len(b.Items); i++ {
+			if c := Compare(a.Items[i], b.Items[i]); c != 0 {
+				return c
+			}
+		}
+		return sign(len(a.Items) - len(b.Items))
+	case Object:
+		ka, kb := SortedKeys(a), SortedKeys(b)
+		for i := 0; i < len(ka) && i < len(kb); i++ {
+			if c := strings.Compare(ka[i], kb[i]); c != 0 {
+				return c
+			}
+		}
+		if len(ka) != len(kb) {
+			return sign(len(ka) - len(kb))
+		}
+		for _, k := range ka {
+			if c := Compare(a.Get(k), b.Get(k)); c != 0 {
+				return c
+			}
+		}
+	}
+	return 0
+}
+
+// Key returns a string that two values share exactly when Equal holds for
+// them, so values can be grouped in a map. Arrays and objects are hashed,
+// which keeps keys short however large the values are
+func Key(n *Node) string {
+	switch n.Kind {
+	case Null:
+		return "null"
+	case Bool:
+		return n.Value
+	case Number:
+		if x, ok := Rat(n); ok {
+			return x.RatString()
+		}
+		return n.Value
+	case String:
+		return Quote(n.Value)
+	}
+	var form strings.Builder
+	if n.Kind == Array {
+		form.WriteString("[")
+		for i, item := range n.Items {
+			if i > 0 {
+				form.WriteString(",")
+			}
+			form.WriteString(Key(item))
+		}
+		form.WriteString("]")
+	} else {
+		values := make(map[string]*Node, len(n.Members))
+		for _, m := range n.Members {
+			values[m.Key] = m.Value // the last one, as Get finds
+		}
+		form.WriteString("{")
+		for i, k := range SortedKeys(n) {
+			if i > 0 {
+				form.WriteString(",")
+			}
+			form.WriteString(Quote(k) + ":" + Key(values[k]))
+		}
+		form.WriteString("}")
+	}
+	sum := sha256.Sum256([]byte(form.String()))
+	return "#" + hex.EncodeToString(sum[:16])
+}
+
+// SortedKeys returns an object's distinct keys in sorted order
+func SortedKeys(n *Node) []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, m := range n.Members {
+		if !seen[m.Key] {
+			seen[m.Key] = true
+			keys = append(keys, m.Key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func rank(n *Node) int {
+	switch n.Kind {
+	case Null:
+		return 0
+	case Bool:
+		if n.Value == "true" {
+			return 2
+		}
+		return 1
+	case Number:
+		return 3
+	case String:
+		return 4
+	case Array:
+		return 5
+	}
+	return 6
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
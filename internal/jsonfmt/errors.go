@@ -0,0 +1,223 @@
+package jsonfmt
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ParseError is a syntax error located in the source text. Line and Column
+// are 1-based; Column is 0 when only the line is known
+type ParseError struct {
+	Msg    string
+	Line   int
+	Column int
+	Hint   string // a likely fix, when one can be guessed
+}
+
+func (e *ParseError) Error() string {
+	if e.Column > 0 {
+		return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Msg)
+	}
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// SnippetLine is one numbered line of source around an error
+type SnippetLine struct {
+	Number int
+	Text   string
+}
+
+// Snippet returns the given line with up to context lines either side
+func Snippet(text string, line, context int) []SnippetLine {
+	lines := strings.Split(text, "\n")
+	var out []SnippetLine
+	for n := max(1, line-context); n <= min(len(lines), line+context); n++ {
+		out = append(out, SnippetLine{Number: n, Text: strings.TrimRight(lines[n-1], "\r")})
+	}
+	return out
+}
+
+// jsonError locates err at byte offset in text and guesses a fix. offset
+// is where the decoder stopped, just past the offending byte
+func jsonError(text string, offset int64, msg string) *ParseError {
+	at := min(max(int(offset)-1, 0), len(text))
+	line, col := position(text, at)
+	e := &ParseError{Msg: msg, Line: line, Column: col}
+
+	var c byte
+	if at < len(text) {
+		c = text[at]
+	}
+	next := nextSignificant(text, at+1)
+	prev := prevSignificant(text, at)
+
+	switch {
+	case at >= len(text) || strings.Contains(msg, "unexpected end"):
+		e.Hint = unclosedHint(text)
+	case c == ',' && (next == '}' || next == ']'), (c == '}' || c == ']') && prev == ',':
+		e.Hint = "Remove the trailing comma; JSON does not allow a comma before } or ]"
+	case c == '\'':
+		e.Hint = "Use double quotes; JSON strings and keys cannot be single-quoted"
+	case c == '/' && (next == '/' || next == '*'):
+		e.Hint = "Remove the comment; JSON does not support comments"
+	case isIdentStart(c):
+		word := identAt(text, at)
+		if (prev == '{' || prev == ',') && nextSignificant(text, at+len(word)) == ':' {
+			e.Hint = fmt.Sprintf("Quote the key: %q", word)
+		} else if fix, ok := literalFixes[word]; ok {
+			e.Hint = fmt.Sprintf("Write %s instead of %s", fix, word)
+		} else if strings.Contains(msg, "in literal") {
+			e.Hint = "Check the spelling of true, false or null"
+		} else {
+			e.Hint = fmt.Sprintf("Quote the string: %q", word)
+		}
+	case strings.Contains(msg, "in literal"):
+		e.Hint = "Check the spelling of true, false or null"
+	case strings.Contains(msg, "after object key:value pair"), strings.Contains(msg, "after array element"):
+		e.Hint = "Add a comma between the values"
+	case strings.Contains(msg, "after object key"):
+		e.Hint = "Add a colon between the key and its value"
+	case strings.Contains(msg, "in string"):
+		e.Hint = `Escape control characters in strings, for example \n for a line break`
+	case strings.Contains(msg, "after the top-level value"):
+		e.Hint = "A document holds one value; wrap several values in an array"
+	}
+	return e
+}
+
+// literalFixes maps literals from other languages to JSON
+var literalFixes = map[string]string{
+	"True": "true", "TRUE": "true", "False": "false", "FALSE": "false",
+	"None": "null", "NULL": "null", "Null": "null", "nil": "null", "undefined": "null",
+	"NaN": "null or a string", "Infinity": "null or a string",
+}
+
+// unclosedHint names the brackets still open at the end of the input
+func unclosedHint(text string) string {
+	var stack []byte
+	inString := false
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case inString && c == '\\':
+			i++
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '{' || c == '[':
+			stack = append(stack, c)
+		case (c == '}' || c == ']') && len(stack) > 0:
+			stack = stack[:len(stack)-1]
+		}
+	}
+	if inString {
+		return "Close the string with a double quote"
+	}
+	if len(stack) == 0 {
+		return "The document is empty or incomplete"
+	}
+	var closing []string
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i] == '{' {
+			closing = append(closing, "}")
+		} else {
+			closing = append(closing, "]")
+		}
+	}
+	return "Add the missing " + strings.Join(closing, " ") + " at the end"
+}
+
+var yamlLine = regexp.MustCompile(`line (\d+): (.*)`)
+
+// YAMLError locates a yaml.v3 error. The library reports lines only, so the
+// column is filled in where the message points at something findable
+func YAMLError(text string, err error) *ParseError {
+	m := yamlLine.FindStringSubmatch(err.Error())
+	if m == nil {
+		return &ParseError{Msg: strings.TrimPrefix(err.Error(), "yaml: "), Line: 1}
+	}
+	line, _ := strconv.Atoi(m[1])
+	e := &ParseError{Msg: m[2], Line: line}
+	lines := strings.Split(text, "\n")
+	src := ""
+	if line >= 1 && line <= len(lines) {
+		src = lines[line-1]
+	}
+
+	switch {
+	case strings.Contains(e.Msg, "cannot start any token") && strings.Contains(src, "\t"):
+		e.Column = utf8.RuneCountInString(src[:strings.Index(src, "\t")]) + 1
+		e.Hint = "Indent with spaces; YAML does not allow tabs"
+	case strings.Contains(e.Msg, "mapping values are not allowed"):
+		if first := strings.Index(src, ": "); first >= 0 {
+			if second := strings.Index(src[first+2:], ": "); second >= 0 {
+				e.Column = utf8.RuneCountInString(src[:first+2+second]) + 1
+				e.Hint = "Quote the value; it contains \": \""
+				break
+			}
+		}
+		e.Hint = "Check the indentation; this line is nested under a value that is not a mapping"
+	case strings.Contains(e.Msg, "already defined"):
+		e.Hint = "Remove or rename the duplicate key"
+	case strings.Contains(e.Msg, "did not find expected ',' or ']'"), strings.Contains(e.Msg, "did not find expected ',' or '}'"):
+		e.Hint = "Close the flow collection opened on this line"
+	case strings.Contains(e.Msg, "did not find expected"):
+		e.Hint = "Check the indentation of this block"
+	case strings.Contains(e.Msg, "unexpected end of stream"):
+		e.Hint = "Close the open quote or bracket"
+	}
+	return e
+}
+
+// AsParseError returns err as a *ParseError when it is one
+func AsParseError(err error) (*ParseError, bool) {
+	var pe *ParseError
+	ok := errors.As(err, &pe)
+	return pe, ok
+}
+
+// position converts a byte offset to a 1-based line and rune column
+func position(text string, offset int) (int, int) {
+	before := text[:offset]
+	line := strings.Count(before, "\n") + 1
+	start := strings.LastIndexByte(before, '\n') + 1
+	return line, utf8.RuneCountInString(before[start:]) + 1
+}
+
+func nextSignificant(text string, i int) byte {
+	for ; i < len(text); i++ {
+		if !isSpace(text[i]) {
+			return text[i]
+		}
+	}
+	return 0
+}
+
+func prevSignificant(text string, i int) byte {
+	for i--; i >= 0; i-- {
+		if !isSpace(text[i]) {
+			return text[i]
+		}
+	}
+	return 0
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '$' || unicode.IsLetter(rune(c))
+}
+
+func identAt(text string, i int) string {
+	j := i
+	for j < len(text) && (isIdentStart(text[j]) || text[j] >= '0' && text[j] <= '9' || text[j] == '-') {
+		j++
+	}
+	return text[i:j]
+}
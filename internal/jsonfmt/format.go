@@ -0,0 +1,92 @@
+package jsonfmt
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// Options controls how a tree is written
+type Options struct {
+	Indent   string // per level; ignored when minifying
+	Minify   bool
+	SortKeys bool // stable, so duplicate keys keep their relative order
+}
+
+// Format writes n as JSON. Output ends with a newline unless minified
+func Format(n *Node, opts Options) string {
+	var b strings.Builder
+	write(&b, n, opts, 0)
+	if !opts.Minify {
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func write(b *strings.Builder, n *Node, opts Options, depth int) {
+	newline := func(depth int) {
+		if !opts.Minify {
+			b.WriteByte('\n')
+			b.WriteString(strings.Repeat(opts.Indent, depth))
+		}
+	}
+
+	switch n.Kind {
+	case Null:
+		b.WriteString("null")
+	case Bool, Number:
+		b.WriteString(n.Value)
+	case String:
+		b.WriteString(Quote(n.Value))
+	case Array:
+		if len(n.Items) == 0 {
+			b.WriteString("[]")
+			return
+		}
+		b.WriteByte('[')
+		for i, item := range n.Items {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			newline(depth + 1)
+			write(b, item, opts, depth+1)
+		}
+		newline(depth)
+		b.WriteByte(']')
+	case Object:
+		if len(n.Members) == 0 {
+			b.WriteString("{}")
+			return
+		}
+		members := n.Members
+		if opts.SortKeys {
+			members = append([]Member(nil), members...)
+			sort.SliceStable(members, func(i, j int) bool { return members[i].Key < members[j].Key })
+		}
+		b.WriteByte('{')
+		for i, m := range members {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			newline(depth + 1)
+			b.WriteString(Quote(m.Key))
+			b.WriteByte(':')
+			if !opts.Minify {
+				b.WriteByte(' ')
+			}
+			write(b, m.Value, opts, depth+1)
+		}
+		newline(depth)
+		b.WriteByte('}')
+	}
+}
+
+// Quote encodes s as a JSON string without escaping HTML characters
+func Quote(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
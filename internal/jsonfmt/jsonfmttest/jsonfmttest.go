@@ -0,0 +1,25 @@
+// Package jsonfmttest has helpers for tests that build and compare JSON
+// trees
+package jsonfmttest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Ndeta100/orbit2x/internal/jsonfmt"
+)
+
+// MustParse parses text, failing the test when it is not valid JSON
+func MustParse(t testing.TB, text string) *jsonfmt.Node {
+	t.Helper()
+	n, _, err := jsonfmt.Parse([]byte(text))
+	if err != nil {
+		t.Fatalf("bad test JSON %s: %v", text, err)
+	}
+	return n
+}
+
+// Minified formats n on one line without the trailing newline
+func Minified(n *jsonfmt.Node) string {
+	return strings.TrimSuffix(jsonfmt.Format(n, jsonfmt.Options{Minify: true}), "\n")
+}
@@ -0,0 +1,260 @@
+// Package jsonfmt parses JSON into an ordered tree and writes it back out.
+// Unlike decoding into interface{}, object members keep their document
+// order, numbers keep their exact literal and duplicate keys are reported
+package jsonfmt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Kind is the JSON type of a node
+type Kind int
+
+const (
+	Null Kind = iota
+	Bool
+	Number
+	String
+	Array
+	Object
+)
+
+func (k Kind) String() string {
+	return [...]string{"null", "boolean", "number", "string", "array", "object"}[k]
+}
+
+// Node is a JSON value. Scalars keep their value in Value: the literal for
+// numbers, the decoded text for strings and "true"/"false" for booleans
+type Node struct {
+	Kind    Kind
+	Value   string
+	Items   []*Node
+	Members []Member
+	Tag     string // a finer type a source format gave a string, such as DateTime
+}
+
+// DateTime tags a string that was a date, time or timestamp in YAML or TOML
+const DateTime = "datetime"
+
+// Member is an object entry
+type Member struct {
+	Key   string
+	Value *Node
+}
+
+// Warning flags something legal but suspicious, located by JSON Pointer.
+// A warning about the whole text has no path
+type Warning struct {
+	Path    string
+	Message string
+}
+
+func (w Warning) String() string {
+	if w.Path == "" {
+		return w.Message
+	}
+	return w.Path + ": " + w.Message
+}
+
+// Parse reads exactly one JSON document. Syntax errors are returned as
+// *ParseError
+func Parse(data []byte) (*Node, []Warning, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	p := parser{dec: dec}
+
+	n, err := p.value("")
+	if err != nil {
+		return nil, nil, locate(string(data), err)
+	}
+	end := dec.InputOffset()
+	if _, err := dec.Token(); err != io.EOF {
+		// Point at the first byte of the extra value, even a stray bracket
+		at := int(end)
+		for at < len(data) && isSpace(data[at]) {
+			at++
+		}
+		return nil, nil, jsonError(string(data), int64(at+1), "unexpected data after the top-level value")
+	}
+	return n, p.warnings, nil
+}
+
+// locate turns a decoder error into a *ParseError
+func locate(text string, err error) error {
+	var se *json.SyntaxError
+	switch {
+	case errors.As(err, &se):
+		return jsonError(text, se.Offset, se.Error())
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		// Point just past the last non-blank character
+		return jsonError(text, int64(len(strings.TrimRight(text, " \t\r\n"))+1), "unexpected end of JSON input")
+	}
+	return err
+}
+
+type parser struct {
+	dec      *json.Decoder
+	warnings []Warning
+}
+
+func (p *parser) value(path string) (*Node, error) {
+	tok, err := p.dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		if t == '[' {
+			n := &Node{Kind: Array}
+			for p.dec.More() {
+				item, err := p.value(path + "/" + strconv.Itoa(len(n.Items)))
+				if err != nil {
+					return nil, err
+				}
+				n.Items = append(n.Items, item)
+			}
+			_, err := p.dec.Token() // ']'
+			return n, err
+		}
+
+		n := &Node{Kind: Object}
+		seen := map[string]bool{}
+		for p.dec.More() {
+			tok, err := p.dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := tok.(string)
+			child := path + "/" + EscapePointer(key)
+			if seen[key] {
+				p.warnings = append(p.warnings, Warning{
+					Path:    child,
+					Message: fmt.Sprintf("duplicate key %q; most parsers keep only the last value", key),
+				})
+			}
+			seen[key] = true
+			v, err := p.value(child)
+			if err != nil {
+				return nil, err
+			}
+			n.Members = append(n.Members, Member{Key: key, Value: v})
+		}
+		_, err := p.dec.Token() // '}'
+		return n, err
+	case string:
+		return &Node{Kind: String, Value: t}, nil
+	case json.Number:
+		return &Node{Kind: Number, Value: t.String()}, nil
+	case bool:
+		return &Node{Kind: Bool, Value: strconv.FormatBool(t)}, nil
+	default:
+		return &Node{Kind: Null}, nil
+	}
+}
+
+// Built once: a Replacer sets itself up on first use, which costs more
+// than escaping a short key
+var (
+	pointerEscaper   = strings.NewReplacer("~", "~0", "/", "~1")
+	pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+)
+
+// EscapePointer escapes a key for use as a JSON Pointer segment (RFC 6901)
+func EscapePointer(key string) string {
+	return pointerEscaper.Replace(key)
+}
+
+// SplitPointer parses a JSON Pointer into its unescaped reference tokens.
+// The empty pointer is the whole document and has none
+func SplitPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("JSON Pointer %q must start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		for j := 0; j < len(t); j++ {
+			if t[j] == '~' && (j+1 == len(t) || t[j+1] != '0' && t[j+1] != '1') {
+				return nil, fmt.Errorf("JSON Pointer %q has ~ not followed by 0 or 1", pointer)
+			}
+		}
+		tokens[i] = pointerUnescaper.Replace(t)
+	}
+	return tokens, nil
+}
+
+// Clone copies a tree so it can be changed without touching the original
+func (n *Node) Clone() *Node {
+	c := &Node{Kind: n.Kind, Value: n.Value, Tag: n.Tag}
+	if n.Items != nil {
+		c.Items = make([]*Node, len(n.Items))
+		for i, item := range n.Items {
+			c.Items[i] = item.Clone()
+		}
+	}
+	if n.Members != nil {
+		c.Members = make([]Member, len(n.Members))
+		for i, m := range n.Members {
+			c.Members[i] = Member{Key: m.Key, Value: m.Value.Clone()}
+		}
+	}
+	return c
+}
+
+// Delete removes every member with the key and reports whether there was one
+func (n *Node) Delete(key string) bool {
+	kept := n.Members[:0]
+	for _, m := range n.Members {
+		if m.Key != key {
+			kept = append(kept, m)
+		}
+	}
+	found := len(kept) < len(n.Members)
+	n.Members = kept
+	return found
+}
+
+// Get returns the value of an object member, the last one when a key is
+// repeated, or nil
+func (n *Node) Get(key string) *Node {
+	if n == nil || n.Kind != Object {
+		return nil
+	}
+	var v *Node
+	for _, m := range n.Members {
+		if m.Key == key {
+			v = m.Value
+		}
+	}
+	return v
+}
+
+// Set replaces an object member or appends it
+func (n *Node) Set(key string, v *Node) {
+	for i, m := range n.Members {
+		if m.Key == key {
+			n.Members[i].Value = v
+			return
+		}
+	}
+	n.Members = append(n.Members, Member{Key: key, Value: v})
+}
+
+// NewString returns a string node
+func NewString(s string) *Node {
+	return &Node{Kind: String, Value: s}
+}
+
+// NewNumber returns a number node holding a JSON number literal
+func NewNumber(literal string) *Node {
+	return &Node{Kind: Number, Value: literal}
+}
@@ -0,0 +1,208 @@
+package jsonfmt
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxAliasDepth stops alias chains from expanding without bound
+const maxAliasDepth = 64
+
+// maxAliasValues bounds the values aliases expand to in one document, since
+// every use copies its anchor and nested uses multiply, as in billion laughs
+const maxAliasValues = 1 << 18
+
+// ParseYAML reads the first YAML document into a tree, keeping mapping
+// order, with a warning when later documents are skipped. Syntax errors are
+// returned as *ParseError
+func ParseYAML(text string) (*Node, []Warning, error) {
+	docs, warnings, err := ParseYAMLDocuments(text)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(docs) == 0 {
+		return &Node{Kind: Null}, warnings, nil
+	}
+	if len(docs) > 1 {
+		skipped := "document 2 was"
+		if len(docs) > 2 {
+			skipped = fmt.Sprintf("documents 2 to %d were", len(docs))
+		}
+		warnings = append(warnings, Warning{Message: fmt.Sprintf("the text holds %d YAML documents separated by ---; only the first was read and %s skipped", len(docs), skipped)})
+	}
+	return docs[0], warnings, nil
+}
+
+// ParseYAMLDocuments reads every document of a YAML stream, as split by
+// --- lines
+func ParseYAMLDocuments(text string) ([]*Node, []Warning, error) {
+	dec := yaml.NewDecoder(strings.NewReader(text))
+	c := yamlConverter{}
+	var docs []*Node
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, YAMLError(text, err)
+		}
+		n, err := c.convert(&doc, "", 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		docs = append(docs, n)
+	}
+	return docs, c.warnings, nil
+}
+
+// ParseDocument reads JSON when the text looks like JSON and YAML otherwise,
+// returning which it was
+func ParseDocument(text string) (*Node, string, []Warning, error) {
+	switch nextSignificant(text, 0) {
+	case '{', '[', '"':
+		n, warnings, err := Parse([]byte(text))
+		return n, "JSON", warnings, err
+	}
+	n, warnings, err := ParseYAML(text)
+	return n, "YAML", warnings, err
+}
+
+type yamlConverter struct {
+	warnings []Warning
+	aliased  int // values made by expanding aliases
+}
+
+func (c *yamlConverter) convert(y *yaml.Node, path string, depth int) (*Node, error) {
+	if depth > maxAliasDepth {
+		return nil, &ParseError{Msg: "aliases are nested too deeply", Line: y.Line, Column: y.Column}
+	}
+	if depth > 0 {
+		if c.aliased++; c.aliased > maxAliasValues {
+			return nil, &ParseError{Msg: fmt.Sprintf("aliases expand to more than %d values", maxAliasValues), Line: y.Line, Column: y.Column}
+		}
+	}
+
+	switch y.Kind {
+	case yaml.AliasNode:
+		return c.convert(y.Alias, path, depth+1)
+	case yaml.SequenceNode:
+		n := &Node{Kind: Array}
+		for i, item := range y.Content {
+			v, err := c.convert(item, path+"/"+strconv.Itoa(i), depth)
+			if err != nil {
+				return nil, err
+			}
+			n.Items = append(n.Items, v)
+		}
+		return n, nil
+	case yaml.MappingNode:
+		n := &Node{Kind: Object}
+		seen := map[string]bool{}
+		for i := 0; i+1 < len(y.Content); i += 2 {
+			k := y.Content[i]
+			if k.Kind != yaml.ScalarNode {
+				return nil, &ParseError{Msg: "only scalar mapping keys can be represented in JSON", Line: k.Line, Column: k.Column}
+			}
+			child := path + "/" + EscapePointer(k.Value)
+			if seen[k.Value] {
+				c.warnings = append(c.warnings, Warning{
+					Path:    child,
+					Message: fmt.Sprintf("duplicate key %q on line %d", k.Value, k.Line),
+				})
+			}
+			seen[k.Value] = true
+			v, err := c.convert(y.Content[i+1], child, depth)
+			if err != nil {
+				return nil, err
+			}
+			n.Members = append(n.Members, Member{Key: k.Value, Value: v})
+		}
+		return n, nil
+	case yaml.DocumentNode:
+		if len(y.Content) == 0 {
+			return &Node{Kind: Null}, nil
+		}
+		return c.convert(y.Content[0], path, depth)
+	}
+	return scalar(y), nil
+}
+
+// scalar resolves a YAML scalar by its tag. Integers and floats are
+// normalised to JSON number literals; values JSON cannot hold, such as .inf,
+// become strings
+func scalar(y *yaml.Node) *Node {
+	switch y.ShortTag() {
+	case "!!null":
+		return &Node{Kind: Null}
+	case "!!bool":
+		var b bool
+		if y.Decode(&b) == nil {
+			return &Node{Kind: Bool, Value: strconv.FormatBool(b)}
+		}
+	case "!!int":
+		literal := strings.ReplaceAll(y.Value, "_", "")
+		if IsNumber(literal) {
+			return &Node{Kind: Number, Value: literal}
+		}
+		if i, err := strconv.ParseInt(literal, 0, 64); err == nil {
+			return &Node{Kind: Number, Value: strconv.FormatInt(i, 10)}
+		}
+		if u, err := strconv.ParseUint(literal, 0, 64); err == nil {
+			return &Node{Kind: Number, Value: strconv.FormatUint(u, 10)}
+		}
+	case "!!float":
+		if IsNumber(y.Value) {
+			return &Node{Kind: Number, Value: y.Value}
+		}
+		var f float64
+		if y.Decode(&f) == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+			return &Node{Kind: Number, Value: strconv.FormatFloat(f, 'g', -1, 64)}
+		}
+	case "!!timestamp":
+		return &Node{Kind: String, Value: y.Value, Tag: DateTime}
+	}
+	return &Node{Kind: String, Value: y.Value}
+}
+
+// IsNumber reports whether s is a valid JSON number literal
+func IsNumber(s string) bool {
+	i := 0
+	digits := func() int {
+		start := i
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		return i - start
+	}
+	if i < len(s) && s[i] == '-' {
+		i++
+	}
+	if i < len(s) && s[i] == '0' {
+		i++
+	} else if digits() == 0 {
+		return false
+	}
+	if i < len(s) && s[i] == '.' {
+		i++
+		if digits() == 0 {
+			return false
+		}
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		if digits() == 0 {
+			return false
+		}
+	}
+	return i == len(s)
+}
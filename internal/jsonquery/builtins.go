@@ -0,0 +1,430 @@
+package jsonquery
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Ndeta100/orbit2x/internal/jsonfmt"
+)
+
+type jqBuiltin func(env *jqEnv, in *jsonfmt.Node, args []jqFilter, emit func(*jsonfmt.Node) error) error
+
+// jqBuiltins are keyed by name and arity, as jq itself does
+var jqBuiltins = map[string]jqBuiltin{
+	"empty/0": func(*jqEnv, *jsonfmt.Node, []jqFilter, func(*jsonfmt.Node) error) error { return nil },
+	"not/0":   unary(func(in *jsonfmt.Node) (*jsonfmt.Node, error) { return newBool(!truthy(in)), nil }),
+	"type/0":  unary(func(in *jsonfmt.Node) (*jsonfmt.Node, error) { return jsonfmt.NewString(in.Kind.String()), nil }),
+	"length/0": unary(func(in *jsonfmt.Node) (*jsonfmt.Node, error) {
+		switch in.Kind {
+		case jsonfmt.Null:
+			return newInt(0), nil
+		case jsonfmt.Number:
+			x, ok := jsonfmt.Rat(in)
+			if !ok {
+				return in, nil
+			}
+			return jsonfmt.NewRat(x.Abs(x)), nil
+		}
+		if n, ok := length(in); ok {
+			return newInt(n), nil
+		}
+		return nil, jqErrorf("%s has no length", describe(in))
+	}),
+	"values/0":        ofKind(func(k jsonfmt.Kind) bool { return k != jsonfmt.Null }),
+	"nulls/0":         ofKind(func(k jsonfmt.Kind) bool { return k == jsonfmt.Null }),
+	"booleans/0":      ofKind(func(k jsonfmt.Kind) bool { return k == jsonfmt.Bool }),
+	"numbers/0":       ofKind(func(k jsonfmt.Kind) bool { return k == jsonfmt.Number }),
+	"strings/0":       ofKind(func(k jsonfmt.Kind) bool { return k == jsonfmt.String }),
+	"arrays/0":        ofKind(func(k jsonfmt.Kind) bool { return k == jsonfmt.Array }),
+	"objects/0":       ofKind(func(k jsonfmt.Kind) bool { return k == jsonfmt.Object }),
+	"iterables/0":     ofKind(func(k jsonfmt.Kind) bool { return k == jsonfmt.Array || k == jsonfmt.Object }),
+	"scalars/0":       ofKind(func(k jsonfmt.Kind) bool { return k != jsonfmt.Array && k != jsonfmt.Object }),
+	"keys/0":          keys(true),
+	"keys_unsorted/0": keys(false),
+	"has/1": func(env *jqEnv, in *jsonfmt.Node, args []jqFilter, emit func(*jsonfmt.Node) error) error {
+		return args[0](env, in, func(k *jsonfmt.Node) error {
+			switch {
+			case in.Kind == jsonfmt.Object && k.Kind == jsonfmt.String:
+				return emit(newBool(in.Get(k.Value) != nil))
+			case in.Kind == jsonfmt.Array && k.Kind == jsonfmt.Number:
+				i, ok := toInt(k)
+				return emit(newBool(ok && i >= 0 && i < len(in.Items)))
+			}
+			return jqErrorf("cannot check whether %s has %s", describe(in), describe(k))
+		})
+	},
+	"select/1": func(env *jqEnv, in *jsonfmt.Node, args []jqFilter, emit func(*jsonfmt.Node) error) error {
+		return args[0](env, in, func(c *jsonfmt.Node) error {
+			if truthy(c) {
+				return emit(in)
+			}
+			return nil
+		})
+	},
+	"map/1": func(env *jqEnv, in *jsonfmt.Node, args []jqFilter, emit func(*jsonfmt.Node) error) error {
+		out := &jsonfmt.Node{Kind: jsonfmt.Array}
+		err := iterate(env, in, func(v *jsonfmt.Node) error {
+			return args[0](env, v, func(r *jsonfmt.Node) error {
+				out.Items = append(out.Items, r)
+				return nil
+			})
+		})
+		if err != nil {
+			return err
+		}
+		return emit(out)
+	},
+	"first/0": unary(func(in *jsonfmt.Node) (*jsonfmt.Node, error) { return lookup(in, newInt(0)) }),
+	"last/0":  unary(func(in *jsonfmt.Node) (*jsonfmt.Node, error) { return lookup(in, newInt(-1)) }),
+	"first/1": func(env *jqEnv, in *jsonfmt.Node, args []jqFilter, emit func(*jsonfmt.Node) error) error {
+		var first *jsonfmt.Node
+		err := args[0](env, in, func(v *jsonfmt.Node) error {
+			first = v
+			return errStop
+		})
+		if err != nil && !errors.Is(err, errStop) {
+			return err
+		}
+		if first == nil {
+			return nil
+		}
+		return emit(first)
+	},
+	"last/1": func(env *jqEnv, in *jsonfmt.Node, args []jqFilter, emit func(*jsonfmt.Node) error) error {
+		values, err := collect(env, args[0], in)
+		if err != nil || len(values) == 0 {
+			return err
+		}
+		return emit(values[len(values)-1])
+	},
+	"add/0": func(env *jqEnv, in *jsonfmt.Node, _ []jqFilter, emit func(*jsonfmt.Node) error) error {
+		var values []*jsonfmt.Node
+		err := iterate(env, in, func(v *jsonfmt.Node) error {
+			values = append(values, v)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		sum, err := addAll(values)
+		if err == nil {
+			err = env.grow(sum)
+		}
+		if err != nil {
+			return err
+		}
+		return emit(sum)
+	},
+	"any/0": unary(func(in *jsonfmt.Node) (*jsonfmt.Node, error) { return quantify(in, true) }),
+	"all/0": unary(func(in *jsonfmt.Node) (*jsonfmt.Node, error) { return quantify(in, false) }),
+	"sort/0": unary(func(in *jsonfmt.Node) (*jsonfmt.Node, error) {
+		if in.Kind != jsonfmt.Array {
+			return nil, jqErrorf("%s cannot be sorted, as it is not an array", describe(in))
+		}
+		return &jsonfmt.Node{Kind: jsonfmt.Array, Items: sortNodes(in.Items)}, nil
+	}),
+	"sort_by/1": func(env *jqEnv, in *jsonfmt.Node, args []jqFilter, emit func(*jsonfmt.Node) error) error {
+		groups, err := sortBy(env, in, args[0])
+		if err != nil {
+			return err
+		}
+		out := &jsonfmt.Node{Kind: jsonfmt.Array}
+		for _, g := range groups {
+			out.Items = append(out.Items, g.Items...)
+		}
+		return emit(out)
+	},
+	"group_by/1": func(env *jqEnv, in *jsonfmt.Node, args []jqFilter, emit func(*jsonfmt.Node) error) error {
+		groups, err := sortBy(env, in, args[0])
+		if err != nil {
+			return err
+		}
+		return emit(&jsonfmt.Node{Kind: jsonfmt.Array, Items: groups})
+	},
+	"unique/0": unary(func(in *jsonfmt.Node) (*jsonfmt.Node, error) {
+		if in.Kind != jsonfmt.Array {
+			return nil, jqErrorf("%s cannot be sorted, as it is not an array", describe(in))
+		}
+		out := &jsonfmt.Node{Kind: jsonfmt.Array}
+		for _, item := range sortNodes(in.Items) {
+			if n := len(out.Items); n == 0 || !jsonfmt.Equal(out.Items[n-1], item) {
+				out.Items = append(out.Items, item)
+			}
+		}
+		return out, nil
+	}),
+	"reverse/0": unary(func(in *jsonfmt.Node) (*jsonfmt.Node, error) {
+		switch in.Kind {
+		case jsonfmt.Null:
+			return &jsonfmt.Node{Kind: jsonfmt.Array}, nil
+		case jsonfmt.String:
+			runes := []rune(in.Value)
+			for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+				runes[i], runes[j] = runes[j], runes[i]
+			}
+			return jsonfmt.NewString(string(runes)), nil
+		case jsonfmt.Array:
+			out := &jsonfmt.Node{Kind: jsonfmt.Array}
+			for i := len(in.Items) - 1; i >= 0; i-- {
+				out.Items = append(out.Items, in.Items[i])
+			}
+			return out, nil
+		}
+		return nil, jqErrorf("%s cannot be reversed", describe(in))
+	}),
+	"min/0": unary(func(in *jsonfmt.Node) (*jsonfmt.Node, error) { return extreme(in, -1) }),
+	"max/0": unary(func(in *jsonfmt.Node) (*jsonfmt.Node, error) { return extreme(in, 1) }),
+	"tostring/0": unary(func(in *jsonfmt.Node) (*jsonfmt.Node, error) {
+		if in.Kind == jsonfmt.String {
+			return in, nil
+		}
+		return jsonfmt.NewString(compact(in)), nil
+	}),
+	"tojson/0": unary(func(in *jsonfmt.Node) (*jsonfmt.Node, error) {
+		return jsonfmt.NewString(compact(in)), nil
+	}),
+	"tonumber/0": unary(func(in *jsonfmt.Node) (*jsonfmt.Node, error) {
+		switch {
+		case in.Kind == jsonfmt.Number:
+			return in, nil
+		case in.Kind == jsonfmt.String:
+			s := strings.TrimSpace(in.Value)
+			if numberRe.FindString(s) == s && s != "" {
+				return jsonfmt.NewNumber(s), nil
+			}
+		}
+		return nil, jqErrorf("%s cannot be parsed as a number", describe(in))
+	}),
+	"to_entries/0": unary(func(in *jsonfmt.Node) (*jsonfmt.Node, error) {
+		if in.Kind != jsonfmt.Object {
+			return nil, jqErrorf("%s has no keys", describe(in))
+		}
+		out := &jsonfmt.Node{Kind: jsonfmt.Array}
+		for _, m := range in.Members {
+			entry := &jsonfmt.Node{Kind: jsonfmt.Object}
+			entry.Set("key", jsonfmt.NewString(m.Key))
+			entry.Set("value", m.Value)
+			out.Items = append(out.Items, entry)
+		}
+		return out, nil
+	}),
+	"from_entries/0": unary(fromEntries),
+	"join/1": func(env *jqEnv, in *jsonfmt.Node, args []jqFilter, emit func(*jsonfmt.Node) error) error {
+		return args[0](env, in, func(sep *jsonfmt.Node) error {
+			if in.Kind != jsonfmt.Array || sep.Kind != jsonfmt.String {
+				return jqErrorf("join needs an array and a string separator")
+			}
+			parts := make([]string, len(in.Items))
+			for i, item := range in.Items {
+				switch item.Kind {
+				case jsonfmt.Null:
+				case jsonfmt.String, jsonfmt.Number, jsonfmt.Bool:
+					parts[i] = item.Value
+				default:
+					return jqErrorf("cannot join %s", describe(item))
+				}
+			}
+			return emit(jsonfmt.NewString(strings.Join(parts, sep.Value)))
+		})
+	},
+	"split/1":      stringArg(func(s, arg string) *jsonfmt.Node { return splitString(s, arg) }),
+	"startswith/1": stringArg(func(s, arg string) *jsonfmt.Node { return newBool(strings.HasPrefix(s, arg)) }),
+	"endswith/1":   stringArg(func(s, arg string) *jsonfmt.Node { return newBool(strings.HasSuffix(s, arg)) }),
+	"ltrimstr/1": stringArg(func(s, arg string) *jsonfmt.Node {
+		return jsonfmt.NewString(strings.TrimPrefix(s, arg))
+	}),
+	"rtrimstr/1": stringArg(func(s, arg string) *jsonfmt.Node {
+		return jsonfmt.NewString(strings.TrimSuffix(s, arg))
+	}),
+	"ascii_downcase/0": unary(func(in *jsonfmt.Node) (*jsonfmt.Node, error) { return mapASCII(in, 'A', 'Z', 'a'-'A') }),
+	"ascii_upcase/0":   unary(func(in *jsonfmt.Node) (*jsonfmt.Node, error) { return mapASCII(in, 'a', 'z', 'A'-'a') }),
+	"test/1": func(env *jqEnv, in *jsonfmt.Node, args []jqFilter, emit func(*jsonfmt.Node) error) error {
+		return args[0](env, in, func(pattern *jsonfmt.Node) error {
+			if in.Kind != jsonfmt.String || pattern.Kind != jsonfmt.String {
+				return jqErrorf("test needs a string input and a string pattern")
+			}
+			re, ok := env.regexps[pattern.Value]
+			if !ok {
+				var err error
+				if re, err = regexp.Compile(pattern.Value); err != nil {
+					return jqErrorf("invalid pattern %s: %v", jsonfmt.Quote(pattern.Value), err)
+				}
+				env.regexps[pattern.Value] = re
+			}
+			return emit(newBool(re.MatchString(in.Value)))
+		})
+	},
+}
+
+// unary adapts a function of the input alone
+func unary(f func(in *jsonfmt.Node) (*jsonfmt.Node, error)) jqBuiltin {
+	return func(_ *jqEnv, in *jsonfmt.Node, _ []jqFilter, emit func(*jsonfmt.Node) error) error {
+		out, err := f(in)
+		if err != nil {
+			return err
+		}
+		return emit(out)
+	}
+}
+
+// ofKind passes through inputs of the wanted types, like select(type == ...)
+func ofKind(want func(jsonfmt.Kind) bool) jqBuiltin {
+	return func(_ *jqEnv, in *jsonfmt.Node, _ []jqFilter, emit func(*jsonfmt.Node) error) error {
+		if want(in.Kind) {
+			return emit(in)
+		}
+		return nil
+	}
+}
+
+// sortBy stably sorts an array by the outputs of f and returns runs of
+// items with equal keys
+func sortBy(env *jqEnv, in *jsonfmt.Node, f jqFilter) ([]*jsonfmt.Node, error) {
+	if in.Kind != jsonfmt.Array {
+		return nil, jqErrorf("%s cannot be sorted, as it is not an array", describe(in))
+	}
+	keys := make([]*jsonfmt.Node, len(in.Items))
+	order := make([]int, len(in.Items))
+	for i, item := range in.Items {
+		key, err := collect(env, f, item)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = &jsonfmt.Node{Kind: jsonfmt.Array, Items: key}
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return jsonfmt.Compare(keys[order[a]], keys[order[b]]) < 0 })
+
+	var groups []*jsonfmt.Node
+	for n, i := range order {
+		if n == 0 || !jsonfmt.Equal(keys[order[n-1]], keys[i]) {
+			groups = append(groups, &jsonfmt.Node{Kind: jsonfmt.Array})
+		}
+		g := groups[len(groups)-1]
+		g.Items = append(g.Items, in.Items[i])
+	}
+	return groups, nil
+}
+
+// stringArg adapts a function of a string input and a string argument
+func stringArg(f func(s, arg string) *jsonfmt.Node) jqBuiltin {
+	return func(env *jqEnv, in *jsonfmt.Node, args []jqFilter, emit func(*jsonfmt.Node) error) error {
+		return args[0](env, in, func(arg *jsonfmt.Node) error {
+			if in.Kind != jsonfmt.String || arg.Kind != jsonfmt.String {
+				return jqErrorf("%s and %s are not both strings", describe(in), describe(arg))
+			}
+			return emit(f(in.Value, arg.Value))
+		})
+	}
+}
+
+func keys(sorted bool) jqBuiltin {
+	return unary(func(in *jsonfmt.Node) (*jsonfmt.Node, error) {
+		out := &jsonfmt.Node{Kind: jsonfmt.Array}
+		switch in.Kind {
+		case jsonfmt.Object:
+			var names []string
+			if sorted {
+				names = jsonfmt.SortedKeys(in)
+			} else {
+				seen := map[string]bool{}
+				for _, m := range in.Members {
+					if !seen[m.Key] {
+						seen[m.Key] = true
+						names = append(names, m.Key)
+					}
+				}
+			}
+			for _, k := range names {
+				out.Items = append(out.Items, jsonfmt.NewString(k))
+			}
+		case jsonfmt.Array:
+			for i := range in.Items {
+				out.Items = append(out.Items, newInt(i))
+			}
+		default:
+			return nil, jqErrorf("%s has no keys", describe(in))
+		}
+		return out, nil
+	})
+}
+
+func quantify(in *jsonfmt.Node, any bool) (*jsonfmt.Node, error) {
+	if in.Kind != jsonfmt.Array {
+		return nil, jqErrorf("%s is not an array", describe(in))
+	}
+	for _, item := range in.Items {
+		if truthy(item) == any {
+			return newBool(any), nil
+		}
+	}
+	return newBool(!any), nil
+}
+
+// extreme is min (sign -1) or max (sign 1); the last of equal values wins
+// for max and the first for min, as in jq
+func extreme(in *jsonfmt.Node, sign int) (*jsonfmt.Node, error) {
+	if in.Kind != jsonfmt.Array {
+		return nil, jqErrorf("%s is not an array", describe(in))
+	}
+	if len(in.Items) == 0 {
+		return &jsonfmt.Node{Kind: jsonfmt.Null}, nil
+	}
+	best := in.Items[0]
+	for _, item := range in.Items[1:] {
+		if c := jsonfmt.Compare(item, best) * sign; c > 0 || c == 0 && sign > 0 {
+			best = item
+		}
+	}
+	return best, nil
+}
+
+func fromEntries(in *jsonfmt.Node) (*jsonfmt.Node, error) {
+	if in.Kind != jsonfmt.Array {
+		return nil, jqErrorf("%s is not an array of entries", describe(in))
+	}
+	out := &jsonfmt.Node{Kind: jsonfmt.Object}
+	for _, entry := range in.Items {
+		if entry.Kind != jsonfmt.Object {
+			return nil, jqErrorf("%s is not an entry", describe(entry))
+		}
+		var key, value *jsonfmt.Node
+		for _, name := range []string{"key", "k", "name", "Name", "Key", "K"} {
+			if key = entry.Get(name); key != nil {
+				break
+			}
+		}
+		for _, name := range []string{"value", "v", "Value", "V"} {
+			if value = entry.Get(name); value != nil {
+				break
+			}
+		}
+		if key == nil || key.Kind == jsonfmt.Null || key.Kind == jsonfmt.Array || key.Kind == jsonfmt.Object {
+			return nil, jqErrorf("entry has no usable key")
+		}
+		if value == nil {
+			value = &jsonfmt.Node{Kind: jsonfmt.Null}
+		}
+		out.Set(key.Value, value)
+	}
+	return out, nil
+}
+
+func mapASCII(in *jsonfmt.Node, lo, hi byte, shift int) (*jsonfmt.Node, error) {
+	if in.Kind != jsonfmt.String {
+		return nil, jqErrorf("%s is not a string", describe(in))
+	}
+	b := []byte(in.Value)
+	for i, c := range b {
+		if lo <= c && c <= hi {
+			b[i] = byte(int(c) + shift)
+		}
+	}
+	return jsonfmt.NewString(string(b)), nil
+}
+
+func compact(n *jsonfmt.Node) string {
+	return strings.TrimSuffix(jsonfmt.Format(n, jsonfmt.Options{Minify: true}), "\n")
+}
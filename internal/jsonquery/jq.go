@@ -0,0 +1,1115 @@
+package jsonquery
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Ndeta100/orbit2x/internal/jsonfmt"
+)
+
+// jqFilter runs a filter on one input and passes each output to emit, so
+// generators like .[] and , stream instead of building lists
+type jqFilter func(env *jqEnv, in *jsonfmt.Node, emit func(*jsonfmt.Node) error) error
+
+// jqEnv is the state of one run
+type jqEnv struct {
+	steps   int
+	bytes   int // size of the values the query has built
+	regexps map[string]*regexp.Regexp
+}
+
+// jqError is a runtime error, which ? and try suppress
+type jqError struct{ msg string }
+
+func (e *jqError) Error() string { return e.msg }
+
+func jqErrorf(format string, args ...any) error {
+	return &jqError{fmt.Sprintf(format, args...)}
+}
+
+// errStop ends a generator early, for first(f)
+var errStop = errors.New("stop")
+
+func (env *jqEnv) tick(n int) error {
+	env.steps += n
+	if env.steps > maxResults {
+		return fmt.Errorf("the query visits more than %d values; narrow it down", maxResults)
+	}
+	return nil
+}
+
+// grow charges a value the query built against maxBytes: the bytes of its
+// text, or a pointer for each item and member. Counting values alone lets
+// . + . double a string with every step
+func (env *jqEnv) grow(v *jsonfmt.Node) error {
+	env.bytes += len(v.Value) + 8*len(v.Items) + 8*len(v.Members)
+	if env.bytes > maxBytes {
+		return fmt.Errorf("the query builds more than %d MB of values; narrow it down", maxBytes>>20)
+	}
+	return nil
+}
+
+type jqProgram struct{ filter jqFilter }
+
+func (q jqProgram) run(doc *jsonfmt.Node) ([]*jsonfmt.Node, error) {
+	env := &jqEnv{regexps: map[string]*regexp.Regexp{}}
+	var out []*jsonfmt.Node
+	err := q.filter(env, doc, func(v *jsonfmt.Node) error {
+		out = append(out, v)
+		return env.tick(1)
+	})
+	return out, err
+}
+
+// Lexer
+
+type jqTokenKind int
+
+const (
+	tokEOF     jqTokenKind = iota
+	tokDot                 // .
+	tokRecurse             // ..
+	tokField               // .name
+	tokIdent
+	tokNumber
+	tokString
+	tokPunct
+)
+
+type jqToken struct {
+	kind jqTokenKind
+	text string // the field name, identifier, literal or punctuation
+	pos  int
+}
+
+// jqPunct is longest first so // and == win over / and =
+var jqPunct = []string{"==", "!=", "<=", ">=", "//", "|", ",", "(", ")", "[", "]", "{", "}", ":", ";", "?", "+", "-", "*", "/", "%", "<", ">", "="}
+
+var jqUnsupported = map[string]string{
+	"reduce": "reduce", "foreach": "foreach", "def": "function definitions",
+	"as": "variables", "catch": "try/catch; use try or ? on its own", "label": "label",
+	"import": "modules", "include": "modules",
+}
+
+func isIdentByte(c byte, first bool) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || !first && '0' <= c && c <= '9'
+}
+
+func lexJQ(src string) ([]jqToken, error) {
+	var toks []jqToken
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+			continue
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			continue
+		case c == '$':
+			return nil, &SyntaxError{Offset: i, Msg: "variables are not supported"}
+		case c == '"':
+			if j := strings.Index(src[i:], `\(`); j >= 0 && !strings.Contains(src[i+1:i+j], `"`) {
+				return nil, &SyntaxError{Offset: i + j, Msg: "string interpolation is not supported"}
+			}
+			s, next, err := unquote(src, i)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, jqToken{tokString, s, i})
+			i = next
+			continue
+		case c == '.':
+			switch {
+			case strings.HasPrefix(src[i:], ".."):
+				toks = append(toks, jqToken{tokRecurse, "..", i})
+				i += 2
+			case i+1 < len(src) && isIdentByte(src[i+1], true):
+				j := i + 1
+				for j < len(src) && isIdentByte(src[j], false) {
+					j++
+				}
+				toks = append(toks, jqToken{tokField, src[i+1 : j], i})
+				i = j
+			default:
+				toks = append(toks, jqToken{tokDot, ".", i})
+				i++
+			}
+			continue
+		case '0' <= c && c <= '9':
+			num := numberRe.FindString(src[i:])
+			toks = append(toks, jqToken{tokNumber, num, i})
+			i += len(num)
+			continue
+		case isIdentByte(c, true):
+			j := i
+			for j < len(src) && isIdentByte(src[j], false) {
+				j++
+			}
+			toks = append(toks, jqToken{tokIdent, src[i:j], i})
+			i = j
+			continue
+		}
+		matched := false
+		for _, p := range jqPunct {
+			if strings.HasPrefix(src[i:], p) {
+				if p == "=" {
+					return nil, &SyntaxError{Offset: i, Msg: "assignment is not supported; compare with =="}
+				}
+				toks = append(toks, jqToken{tokPunct, p, i})
+				i += len(p)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, &SyntaxError{Offset: i, Msg: fmt.Sprintf("unexpected %q", src[i:i+1])}
+		}
+	}
+	return append(toks, jqToken{kind: tokEOF, pos: len(src)}), nil
+}
+
+// Parser, loosest binding first: | , // or and comparisons + - * / %
+// then postfix paths
+
+type jqParser struct {
+	toks  []jqToken
+	i     int
+	depth int // filters nested so far, since running them recurses as deep
+}
+
+func compileJQ(src string) (jqProgram, error) {
+	toks, err := lexJQ(src)
+	if err != nil {
+		return jqProgram{}, err
+	}
+	p := &jqParser{toks: toks}
+	f, err := p.pipe(true)
+	if err != nil {
+		return jqProgram{}, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return jqProgram{}, p.errorf(t, "unexpected %s", describeToken(t))
+	}
+	return jqProgram{f}, nil
+}
+
+func describeToken(t jqToken) string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokField:
+		return "." + t.text
+	case tokString:
+		return "string " + jsonfmt.Quote(t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+func (p *jqParser) peek() jqToken { return p.toks[p.i] }
+
+func (p *jqParser) next() jqToken {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *jqParser) is(text string) bool {
+	t := p.peek()
+	return (t.kind == tokPunct || t.kind == tokIdent) && t.text == text
+}
+
+func (p *jqParser) eat(text string) bool {
+	if p.is(text) {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *jqParser) expect(text string) error {
+	if p.eat(text) {
+		return nil
+	}
+	t := p.peek()
+	return p.errorf(t, "expected %s, found %s", text, describeToken(t))
+}
+
+func (p *jqParser) errorf(t jqToken, format string, args ...any) error {
+	return &SyntaxError{Offset: t.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// deeper counts one more level of nesting. Callers defer restore, so a
+// chain such as .a.b.c counts one level per link until its rule returns
+func (p *jqParser) deeper() error {
+	if p.depth++; p.depth > maxDepth {
+		return p.errorf(p.peek(), "the expression nests more than %d levels deep", maxDepth)
+	}
+	return nil
+}
+
+func (p *jqParser) restore(depth int) { p.depth = depth }
+
+// pipe parses a | b; object values may pipe but not use commas
+func (p *jqParser) pipe(commas bool) (jqFilter, error) {
+	defer p.restore(p.depth)
+	if err := p.deeper(); err != nil {
+		return nil, err
+	}
+	var left jqFilter
+	var err error
+	if commas {
+		left, err = p.comma()
+	} else {
+		left, err = p.alternative()
+	}
+	if err != nil || !p.eat("|") {
+		return left, err
+	}
+	right, err := p.pipe(commas)
+	if err != nil {
+		return nil, err
+	}
+	return func(env *jqEnv, in *jsonfmt.Node, emit func(*jsonfmt.Node) error) error {
+		return left(env, in, func(v *jsonfmt.Node) error {
+			return right(env, v, emit)
+		})
+	}, nil
+}
+
+func (p *jqParser) comma() (jqFilter, error) {
+	defer p.restore(p.depth)
+	left, err := p.alternative()
+	if err != nil {
+		return nil, err
+	}
+	for p.eat(",") {
+		if err := p.deeper(); err != nil {
+			return nil, err
+		}
+		right, err := p.alternative()
+		if err != nil {
+			return nil, err
+		}
+		a, b := left, right
+		left = func(env *jqEnv, in *jsonfmt.Node, emit func(*jsonfmt.Node) error) error {
+			if err := a(env, in, emit); err != nil {
+				return err
+			}
+			return b(env, in, emit)
+		}
+	}
+	return left, nil
+}
+
+// alternative is a // b: the truthy outputs of a, or else those of b
+func (p *jqParser) alternative() (jqFilter, error) {
+	defer p.restore(p.depth)
+	if err := p.deeper(); err != nil {
+		return nil, err
+	}
+	left, err := p.or()
+	if err != nil || !p.eat("//") {
+		return left, err
+	}
+	right, err := p.alternative()
+	if err != nil {
+		return nil, err
+	}
+	return func(env *jqEnv, in *jsonfmt.Node, emit func(*jsonfmt.Node) error) error {
+		found := false
+		err := optional(left)(env, in, func(v *jsonfmt.Node) error {
+			if !truthy(v) {
+				return nil
+			}
+			found = true
+			return emit(v)
+		})
+		if err != nil || found {
+			return err
+		}
+		return right(env, in, emit)
+	}, nil
+}
+
+func (p *jqParser) or() (jqFilter, error) {
+	defer p.restore(p.depth)
+	left, err := p.and()
+	for err == nil && p.eat("or") {
+		var right jqFilter
+		if err = p.deeper(); err != nil {
+			break
+		}
+		if right, err = p.and(); err == nil {
+			left = logical(left, right, true)
+		}
+	}
+	return left, err
+}
+
+func (p *jqParser) and() (jqFilter, error) {
+	defer p.restore(p.depth)
+	left, err := p.comparison()
+	for err == nil && p.eat("and") {
+		var right jqFilter
+		if err = p.deeper(); err != nil {
+			break
+		}
+		if right, err = p.comparison(); err == nil {
+			left = logical(left, right, false)
+		}
+	}
+	return left, err
+}
+
+// logical short-circuits: or skips b when a is truthy, and when it is not
+func logical(a, b jqFilter, or bool) jqFilter {
+	return func(env *jqEnv, in *jsonfmt.Node, emit func(*jsonfmt.Node) error) error {
+		return a(env, in, func(x *jsonfmt.Node) error {
+			if truthy(x) == or {
+				return emit(newBool(or))
+			}
+			return b(env, in, func(y *jsonfmt.Node) error {
+				return emit(newBool(truthy(y)))
+			})
+		})
+	}
+}
+
+func (p *jqParser) comparison() (jqFilter, error) {
+	left, err := p.additive()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.eat(op) {
+			right, err := p.additive()
+			if err != nil {
+				return nil, err
+			}
+			return binary(left, right, func(a, b *jsonfmt.Node) (*jsonfmt.Node, error) {
+				c := jsonfmt.Compare(a, b)
+				switch op {
+				case "==":
+					return newBool(c == 0), nil
+				case "!=":
+					return newBool(c != 0), nil
+				case "<=":
+					return newBool(c <= 0), nil
+				case ">=":
+					return newBool(c >= 0), nil
+				case "<":
+					return newBool(c < 0), nil
+				}
+				return newBool(c > 0), nil
+			}), nil
+		}
+	}
+	return left, nil
+}
+
+func (p *jqParser) additive() (jqFilter, error) {
+	defer p.restore(p.depth)
+	left, err := p.multiplicative()
+	for err == nil && (p.is("+") || p.is("-")) {
+		op := p.next().text
+		var right jqFilter
+		if err = p.deeper(); err != nil {
+			break
+		}
+		if right, err = p.multiplicative(); err == nil {
+			left = binary(left, right, arithmetic(op))
+		}
+	}
+	return left, err
+}
+
+func (p *jqParser) multiplicative() (jqFilter, error) {
+	defer p.restore(p.depth)
+	left, err := p.unary()
+	for err == nil && (p.is("*") || p.is("/") || p.is("%")) {
+		op := p.next().text
+		var right jqFilter
+		if err = p.deeper(); err != nil {
+			break
+		}
+		if right, err = p.unary(); err == nil {
+			left = binary(left, right, arithmetic(op))
+		}
+	}
+	return left, err
+}
+
+func (p *jqParser) unary() (jqFilter, error) {
+	defer p.restore(p.depth)
+	if err := p.deeper(); err != nil {
+		return nil, err
+	}
+	if !p.eat("-") {
+		return p.postfix()
+	}
+	f, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	return binary(constant(jsonfmt.NewNumber("0")), f, arithmetic("-")), nil
+}
+
+// binary runs op over every pair of outputs, the right side varying
+// slowest as in jq
+func binary(left, right jqFilter, op func(a, b *jsonfmt.Node) (*jsonfmt.Node, error)) jqFilter {
+	return func(env *jqEnv, in *jsonfmt.Node, emit func(*jsonfmt.Node) error) error {
+		return right(env, in, func(b *jsonfmt.Node) error {
+			return left(env, in, func(a *jsonfmt.Node) error {
+				if err := env.tick(1); err != nil {
+					return err
+				}
+				v, err := op(a, b)
+				if err == nil {
+					err = env.grow(v)
+				}
+				if err != nil {
+					return err
+				}
+				return emit(v)
+			})
+		})
+	}
+}
+
+func constant(n *jsonfmt.Node) jqFilter {
+	return func(_ *jqEnv, _ *jsonfmt.Node, emit func(*jsonfmt.Node) error) error {
+		return emit(n)
+	}
+}
+
+func identity(_ *jqEnv, in *jsonfmt.Node, emit func(*jsonfmt.Node) error) error {
+	return emit(in)
+}
+
+func (p *jqParser) postfix() (jqFilter, error) {
+	defer p.restore(p.depth)
+	f, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if err := p.deeper(); err != nil {
+			return nil, err
+		}
+		switch {
+		case t.kind == tokField:
+			p.next()
+			f = pipeInto(f, index(constant(jsonfmt.NewString(t.text))))
+		case t.kind == tokDot && p.toks[p.i+1].kind == tokString:
+			p.next()
+			f = pipeInto(f, index(constant(jsonfmt.NewString(p.next().text))))
+		case t.kind == tokDot && p.toks[p.i+1].text == "[" && p.toks[p.i+1].kind == tokPunct:
+			p.next()
+		case p.is("["):
+			suffix, err := p.bracket()
+			if err != nil {
+				return nil, err
+			}
+			f = pipeInto(f, suffix)
+		case p.is("?"):
+			p.next()
+			f = optional(f)
+		default:
+			return f, nil
+		}
+	}
+}
+
+func pipeInto(a, b jqFilter) jqFilter {
+	return func(env *jqEnv, in *jsonfmt.Node, emit func(*jsonfmt.Node) error) error {
+		return a(env, in, func(v *jsonfmt.Node) error {
+			return b(env, v, emit)
+		})
+	}
+}
+
+// bracket parses [], [i], [a:b], [:b] and [a:]
+func (p *jqParser) bracket() (jqFilter, error) {
+	p.next() // [
+	if p.eat("]") {
+		return iterate, nil
+	}
+	var from, to jqFilter
+	var err error
+	if !p.is(":") {
+		if from, err = p.pipe(true); err != nil {
+			return nil, err
+		}
+		if p.eat("]") {
+			return index(from), nil
+		}
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	if !p.is("]") {
+		if to, err = p.pipe(true); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect("]"); err != nil {
+		return nil, err
+	}
+	return slice(from, to), nil
+}
+
+func (p *jqParser) term() (jqFilter, error) {
+	defer p.restore(p.depth)
+	if err := p.deeper(); err != nil {
+		return nil, err
+	}
+	t := p.next()
+	switch t.kind {
+	case tokDot:
+		if p.peek().kind == tokString {
+			return index(constant(jsonfmt.NewString(p.next().text))), nil
+		}
+		return identity, nil
+	case tokRecurse:
+		return recurse, nil
+	case tokField:
+		return index(constant(jsonfmt.NewString(t.text))), nil
+	case tokNumber:
+		return constant(jsonfmt.NewNumber(t.text)), nil
+	case tokString:
+		return constant(jsonfmt.NewString(t.text)), nil
+	case tokIdent:
+		return p.identifier(t)
+	case tokEOF:
+		return nil, p.errorf(t, "the expression is incomplete")
+	}
+
+	switch t.text {
+	case "(":
+		f, err := p.pipe(true)
+		if err != nil {
+			return nil, err
+		}
+		return f, p.expect(")")
+	case "[":
+		if p.eat("]") {
+			return constant(&jsonfmt.Node{Kind: jsonfmt.Array}), nil
+		}
+		f, err := p.pipe(true)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		return func(env *jqEnv, in *jsonfmt.Node, emit func(*jsonfmt.Node) error) error {
+			arr := &jsonfmt.Node{Kind: jsonfmt.Array}
+			err := f(env, in, func(v *jsonfmt.Node) error {
+				arr.Items = append(arr.Items, v)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			return emit(arr)
+		}, nil
+	case "{":
+		return p.object()
+	}
+	return nil, p.errorf(t, "unexpected %s", describeToken(t))
+}
+
+func (p *jqParser) identifier(t jqToken) (jqFilter, error) {
+	switch t.text {
+	case "true", "false":
+		return constant(&jsonfmt.Node{Kind: jsonfmt.Bool, Value: t.text}), nil
+	case "null":
+		return constant(&jsonfmt.Node{Kind: jsonfmt.Null}), nil
+	case "if":
+		return p.conditional()
+	case "try":
+		f, err := p.postfix()
+		if err != nil {
+			return nil, err
+		}
+		return optional(f), nil
+	}
+	if what, ok := jqUnsupported[t.text]; ok {
+		return nil, p.errorf(t, "%s is not supported", what)
+	}
+
+	var args []jqFilter
+	if p.eat("(") {
+		for {
+			arg, err := p.pipe(true)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if p.eat(";") {
+				continue
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	name := fmt.Sprintf("%s/%d", t.text, len(args))
+	fn, ok := jqBuiltins[name]
+	if !ok {
+		return nil, p.errorf(t, "unknown function %s", name)
+	}
+	return func(env *jqEnv, in *jsonfmt.Node, emit func(*jsonfmt.Node) error) error {
+		return fn(env, in, args, emit)
+	}, nil
+}
+
+// conditional parses if c then a (elif c then a)* (else b)? end
+func (p *jqParser) conditional() (jqFilter, error) {
+	cond, err := p.pipe(true)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("then"); err != nil {
+		return nil, err
+	}
+	then, err := p.pipe(true)
+	if err != nil {
+		return nil, err
+	}
+	otherwise := jqFilter(identity)
+	switch {
+	case p.eat("elif"):
+		if otherwise, err = p.conditional(); err != nil {
+			return nil, err
+		}
+	case p.eat("else"):
+		if otherwise, err = p.pipe(true); err != nil {
+			return nil, err
+		}
+		fallthrough
+	default:
+		if err := p.expect("end"); err != nil {
+			return nil, err
+		}
+	}
+	return func(env *jqEnv, in *jsonfmt.Node, emit func(*jsonfmt.Node) error) error {
+		return cond(env, in, func(c *jsonfmt.Node) error {
+			if truthy(c) {
+				return then(env, in, emit)
+			}
+			return otherwise(env, in, emit)
+		})
+	}, nil
+}
+
+// object parses {a, "b": f, (k): v}; every combination of key and value
+// outputs becomes an object, as in jq
+func (p *jqParser) object() (jqFilter, error) {
+	type entry struct{ key, value jqFilter }
+	var entries []entry
+	for !p.eat("}") {
+		if len(entries) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		t := p.next()
+		var e entry
+		switch {
+		case t.kind == tokIdent || t.kind == tokString:
+			e.key = constant(jsonfmt.NewString(t.text))
+			e.value = index(e.key)
+		case t.kind == tokPunct && t.text == "(":
+			key, err := p.pipe(true)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			e.key = key
+		default:
+			return nil, p.errorf(t, "expected a key, found %s", describeToken(t))
+		}
+		if p.eat(":") {
+			value, err := p.pipe(false)
+			if err != nil {
+				return nil, err
+			}
+			e.value = value
+		} else if e.value == nil {
+			return nil, p.errorf(p.peek(), "expected : after a computed key")
+		}
+		entries = append(entries, e)
+	}
+
+	return func(env *jqEnv, in *jsonfmt.Node, emit func(*jsonfmt.Node) error) error {
+		var build func(i int, members []jsonfmt.Member) error
+		build = func(i int, members []jsonfmt.Member) error {
+			if i == len(entries) {
+				if err := env.tick(1); err != nil {
+					return err
+				}
+				obj := &jsonfmt.Node{Kind: jsonfmt.Object}
+				for _, m := range members {
+					obj.Set(m.Key, m.Value)
+				}
+				return emit(obj)
+			}
+			return entries[i].key(env, in, func(k *jsonfmt.Node) error {
+				if k.Kind != jsonfmt.String {
+					return jqErrorf("object keys must be strings, not %s", k.Kind)
+				}
+				return entries[i].value(env, in, func(v *jsonfmt.Node) error {
+					return build(i+1, append(members[:len(members):len(members)], jsonfmt.Member{Key: k.Value, Value: v}))
+				})
+			})
+		}
+		return build(0, nil)
+	}, nil
+}
+
+// optional implements f? and try f: runtime errors raised by f end its
+// outputs quietly, but errors from whatever consumes them still count
+func optional(f jqFilter) jqFilter {
+	return func(env *jqEnv, in *jsonfmt.Node, emit func(*jsonfmt.Node) error) error {
+		var downstream error
+		err := f(env, in, func(v *jsonfmt.Node) error {
+			downstream = emit(v)
+			return downstream
+		})
+		if downstream != nil {
+			return downstream
+		}
+		var runtime *jqError
+		if errors.As(err, &runtime) {
+			return nil
+		}
+		return err
+	}
+}
+
+// Paths
+
+func iterate(env *jqEnv, in *jsonfmt.Node, emit func(*jsonfmt.Node) error) error {
+	var values []*jsonfmt.Node
+	switch in.Kind {
+	case jsonfmt.Array:
+		values = in.Items
+	case jsonfmt.Object:
+		for _, m := range in.Members {
+			values = append(values, m.Value)
+		}
+	default:
+		return jqErrorf("cannot iterate over %s", describe(in))
+	}
+	if err := env.tick(len(values)); err != nil {
+		return err
+	}
+	for _, v := range values {
+		if err := emit(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func recurse(env *jqEnv, in *jsonfmt.Node, emit func(*jsonfmt.Node) error) error {
+	if err := env.tick(1); err != nil {
+		return err
+	}
+	if err := emit(in); err != nil {
+		return err
+	}
+	if in.Kind != jsonfmt.Array && in.Kind != jsonfmt.Object {
+		return nil
+	}
+	return iterate(env, in, func(v *jsonfmt.Node) error {
+		return recurse(env, v, emit)
+	})
+}
+
+func index(key jqFilter) jqFilter {
+	return func(env *jqEnv, in *jsonfmt.Node, emit func(*jsonfmt.Node) error) error {
+		return key(env, in, func(k *jsonfmt.Node) error {
+			v, err := lookup(in, k)
+			if err != nil {
+				return err
+			}
+			return emit(v)
+		})
+	}
+}
+
+func lookup(in, key *jsonfmt.Node) (*jsonfmt.Node, error) {
+	null := &jsonfmt.Node{Kind: jsonfmt.Null}
+	switch {
+	case in.Kind == jsonfmt.Object && key.Kind == jsonfmt.String:
+		if v := in.Get(key.Value); v != nil {
+			return v, nil
+		}
+		return null, nil
+	case in.Kind == jsonfmt.Array && key.Kind == jsonfmt.Number:
+		i, ok := toInt(key)
+		if !ok {
+			return null, nil
+		}
+		if i < 0 {
+			i += len(in.Items)
+		}
+		if i < 0 || i >= len(in.Items) {
+			return null, nil
+		}
+		return in.Items[i], nil
+	case in.Kind == jsonfmt.Null && (key.Kind == jsonfmt.String || key.Kind == jsonfmt.Number):
+		return null, nil
+	}
+	return nil, jqErrorf("cannot index %s with %s", describe(in), describe(key))
+}
+
+func slice(from, to jqFilter) jqFilter {
+	bound := func(f jqFilter) jqFilter {
+		if f == nil {
+			return constant(&jsonfmt.Node{Kind: jsonfmt.Null})
+		}
+		return f
+	}
+	return func(env *jqEnv, in *jsonfmt.Node, emit func(*jsonfmt.Node) error) error {
+		return bound(to)(env, in, func(b *jsonfmt.Node) error {
+			return bound(from)(env, in, func(a *jsonfmt.Node) error {
+				var start, end *int
+				for _, bound := range []struct {
+					n   *jsonfmt.Node
+					out **int
+				}{{a, &start}, {b, &end}} {
+					if bound.n.Kind == jsonfmt.Null {
+						continue
+					}
+					i, ok := toInt(bound.n)
+					if !ok {
+						return jqErrorf("slice bounds must be numbers, not %s", describe(bound.n))
+					}
+					*bound.out = &i
+				}
+				switch in.Kind {
+				case jsonfmt.Null:
+					return emit(in)
+				case jsonfmt.Array:
+					out := &jsonfmt.Node{Kind: jsonfmt.Array}
+					for _, i := range sliceIndices(len(in.Items), start, end, 1) {
+						out.Items = append(out.Items, in.Items[i])
+					}
+					return emit(out)
+				case jsonfmt.String:
+					runes := []rune(in.Value)
+					var b strings.Builder
+					for _, i := range sliceIndices(len(runes), start, end, 1) {
+						b.WriteRune(runes[i])
+					}
+					return emit(jsonfmt.NewString(b.String()))
+				}
+				return jqErrorf("cannot slice %s", describe(in))
+			})
+		})
+	}
+}
+
+// toInt floors a number to an int
+func toInt(n *jsonfmt.Node) (int, bool) {
+	x, ok := jsonfmt.Rat(n)
+	if !ok {
+		return 0, false
+	}
+	q := new(big.Int).Div(x.Num(), x.Denom()) // Euclidean, so this floors
+	if !q.IsInt64() || q.Int64() > 1<<31 || q.Int64() < -(1<<31) {
+		return 0, false
+	}
+	return int(q.Int64()), true
+}
+
+// describe names a value in error messages the way jq does
+func describe(n *jsonfmt.Node) string {
+	switch n.Kind {
+	case jsonfmt.String:
+		s := n.Value
+		if len([]rune(s)) > 20 {
+			s = string([]rune(s)[:20]) + "..."
+		}
+		return "string " + jsonfmt.Quote(s)
+	case jsonfmt.Number, jsonfmt.Bool:
+		return n.Kind.String() + " (" + n.Value + ")"
+	}
+	return n.Kind.String()
+}
+
+// Arithmetic
+
+func arithmetic(op string) func(a, b *jsonfmt.Node) (*jsonfmt.Node, error) {
+	return func(a, b *jsonfmt.Node) (*jsonfmt.Node, error) {
+		if op == "+" {
+			return add(a, b)
+		}
+		x, okA := jsonfmt.Rat(a)
+		y, okB := jsonfmt.Rat(b)
+		if okA && okB {
+			z := new(big.Rat)
+			switch op {
+			case "-":
+				z.Sub(x, y)
+			case "*":
+				if bits(x)+bits(y) > maxProductBits {
+					return nil, jqErrorf("the product of numbers of %d and %d digits is too large to work out exactly", len(a.Value), len(b.Value))
+				}
+				z.Mul(x, y)
+			case "/":
+				if y.Sign() == 0 {
+					return nil, jqErrorf("%s cannot be divided by zero", describe(a))
+				}
+				z.Quo(x, y)
+			case "%":
+				xi, _ := toInt(a)
+				yi, _ := toInt(b)
+				if yi == 0 {
+					return nil, jqErrorf("%s cannot be divided by zero", describe(a))
+				}
+				z.SetInt64(int64(xi % yi))
+			}
+			return jsonfmt.NewRat(z), nil
+		}
+		switch {
+		case op == "-" && a.Kind == jsonfmt.Array && b.Kind == jsonfmt.Array:
+			out := &jsonfmt.Node{Kind: jsonfmt.Array}
+			for _, item := range a.Items {
+				if !containsValue(b.Items, item) {
+					out.Items = append(out.Items, item)
+				}
+			}
+			return out, nil
+		case op == "/" && a.Kind == jsonfmt.String && b.Kind == jsonfmt.String:
+			return splitString(a.Value, b.Value), nil
+		}
+		return nil, jqErrorf("%s and %s cannot be combined with %s", describe(a), describe(b), op)
+	}
+}
+
+// bits is the size of x's numerator and denominator
+func bits(x *big.Rat) int {
+	return x.Num().BitLen() + x.Denom().BitLen()
+}
+
+func add(a, b *jsonfmt.Node) (*jsonfmt.Node, error) {
+	switch {
+	case a.Kind == jsonfmt.Null:
+		return b, nil
+	case b.Kind == jsonfmt.Null:
+		return a, nil
+	case a.Kind != b.Kind:
+	case a.Kind == jsonfmt.Number:
+		x, okA := jsonfmt.Rat(a)
+		y, okB := jsonfmt.Rat(b)
+		if !okA || !okB {
+			return nil, jqErrorf("%s + %s is out of range", a.Value, b.Value)
+		}
+		return jsonfmt.NewRat(new(big.Rat).Add(x, y)), nil
+	case a.Kind == jsonfmt.String:
+		return jsonfmt.NewString(a.Value + b.Value), nil
+	case a.Kind == jsonfmt.Array:
+		items := append(append([]*jsonfmt.Node{}, a.Items...), b.Items...)
+		return &jsonfmt.Node{Kind: jsonfmt.Array, Items: items}, nil
+	case a.Kind == jsonfmt.Object:
+		out := &jsonfmt.Node{Kind: jsonfmt.Object, Members: append([]jsonfmt.Member{}, a.Members...)}
+		for _, m := range b.Members {
+			out.Set(m.Key, m.Value)
+		}
+		return out, nil
+	}
+	return nil, jqErrorf("%s and %s cannot be added", describe(a), describe(b))
+}
+
+// addAll adds values in order as add does, but appends strings and arrays
+// to one buffer, since adding them pairwise copies the sum for every value
+func addAll(values []*jsonfmt.Node) (*jsonfmt.Node, error) {
+	sum := &jsonfmt.Node{Kind: jsonfmt.Null}
+	var text strings.Builder
+	var items []*jsonfmt.Node
+	current := func() *jsonfmt.Node {
+		switch sum.Kind {
+		case jsonfmt.String:
+			return jsonfmt.NewString(text.String())
+		case jsonfmt.Array:
+			return &jsonfmt.Node{Kind: jsonfmt.Array, Items: items}
+		}
+		return sum
+	}
+	for _, v := range values {
+		switch {
+		case v.Kind == jsonfmt.Null:
+			continue
+		case sum.Kind == jsonfmt.String && v.Kind == jsonfmt.String:
+			text.WriteString(v.Value)
+			continue
+		case sum.Kind == jsonfmt.Array && v.Kind == jsonfmt.Array:
+			items = append(items, v.Items...)
+			continue
+		}
+		next, err := add(current(), v)
+		if err != nil {
+			return nil, err
+		}
+		sum = next
+		text.Reset()
+		text.WriteString(sum.Value)
+		items = append([]*jsonfmt.Node{}, sum.Items...)
+	}
+	return current(), nil
+}
+
+func containsValue(list []*jsonfmt.Node, v *jsonfmt.Node) bool {
+	for _, item := range list {
+		if jsonfmt.Equal(item, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func splitString(s, sep string) *jsonfmt.Node {
+	out := &jsonfmt.Node{Kind: jsonfmt.Array}
+	if s == "" {
+		return out
+	}
+	for _, part := range strings.Split(s, sep) {
+		out.Items = append(out.Items, jsonfmt.NewString(part))
+	}
+	return out
+}
+
+// collect gathers every output of f, counting each against the query's
+// budget since none of them reach the top level on their own
+func collect(env *jqEnv, f jqFilter, in *jsonfmt.Node) ([]*jsonfmt.Node, error) {
+	var out []*jsonfmt.Node
+	err := f(env, in, func(v *jsonfmt.Node) error {
+		out = append(out, v)
+		return env.tick(1)
+	})
+	return out, err
+}
+
+func sortNodes(items []*jsonfmt.Node) []*jsonfmt.Node {
+	sorted := append([]*jsonfmt.Node{}, items...)
+	sort.SliceStable(sorted, func(i, j int) bool { return jsonfmt.Compare(sorted[i], sorted[j]) < 0 })
+	return sorted
+}
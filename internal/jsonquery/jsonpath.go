@@ -0,0 +1,857 @@
+package jsonquery
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Ndeta100/orbit2x/internal/jsonfmt"
+)
+
+// located is a node and the JSON Pointer it was reached by
+type located struct {
+	node *jsonfmt.Node
+	path string
+}
+
+type selectorKind int
+
+const (
+	selName selectorKind = iota
+	selWildcard
+	selIndex
+	selSlice
+	selFilter
+)
+
+type selector struct {
+	kind       selectorKind
+	name       string
+	index      int
+	start, end *int
+	step       int
+	filter     filterExpr
+}
+
+type segment struct {
+	descendant bool
+	selectors  []selector
+}
+
+// pathQuery starts at the root ($) or, inside a filter, at the current
+// node (@)
+type pathQuery struct {
+	absolute bool
+	segments []segment
+}
+
+// filterExpr is a condition inside [?...]
+type filterExpr interface {
+	test(c *evalCtx, current located) (bool, error)
+}
+
+// operand is one side of a comparison; a nil value means nothing was
+// selected
+type operand interface {
+	value(c *evalCtx, current located) (*jsonfmt.Node, error)
+}
+
+// pathFunctions are the RFC 9535 function extensions. nodes marks those
+// whose argument is a query rather than a value
+var pathFunctions = map[string]struct {
+	arity   int
+	nodes   bool
+	logical bool
+}{
+	"length": {arity: 1},
+	"count":  {arity: 1, nodes: true},
+	"value":  {arity: 1, nodes: true},
+	"match":  {arity: 2, logical: true},
+	"search": {arity: 2, logical: true},
+}
+
+var numberRe = regexp.MustCompile(`^-?(?:0|[1-9][0-9]*)(?:\.[0-9]+)?(?:[eE][-+]?[0-9]+)?`)
+
+// compilePath parses a JSONPath expression, or a function call over one
+func compilePath(src string) (func(*jsonfmt.Node) ([]Match, error), error) {
+	p := &pathParser{src: src}
+	p.skipSpace()
+	if !p.peek("$") {
+		fn, err := p.call()
+		if err != nil {
+			return nil, err
+		}
+		if fn.logical {
+			return nil, p.errorAt(0, "%s() returns true or false, so it only makes sense inside a filter", fn.name)
+		}
+		if err := p.end(); err != nil {
+			return nil, err
+		}
+		return func(doc *jsonfmt.Node) ([]Match, error) {
+			c := &evalCtx{root: doc}
+			v, err := fn.value(c, located{node: doc})
+			if err != nil || v == nil {
+				return nil, err
+			}
+			return []Match{{Value: v}}, nil
+		}, nil
+	}
+
+	q, err := p.query()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.end(); err != nil {
+		return nil, err
+	}
+	return func(doc *jsonfmt.Node) ([]Match, error) {
+		c := &evalCtx{root: doc}
+		nodes, err := c.query(q, located{node: doc})
+		if err != nil {
+			return nil, err
+		}
+		matches := make([]Match, len(nodes))
+		for i, l := range nodes {
+			matches[i] = Match{Path: l.path, HasPath: true, Value: l.node}
+		}
+		return matches, nil
+	}, nil
+}
+
+type pathParser struct {
+	src   string
+	pos   int
+	depth int // filters and calls nested so far
+}
+
+func (p *pathParser) errorAt(pos int, format string, args ...any) error {
+	return &SyntaxError{Offset: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *pathParser) errorf(format string, args ...any) error {
+	return p.errorAt(p.pos, format, args...)
+}
+
+// deeper counts one more level of nesting. Callers defer restore, so a
+// chain such as @.a || @.b counts one level per operand until it ends
+func (p *pathParser) deeper() error {
+	if p.depth++; p.depth > maxDepth {
+		return p.errorf("the expression nests more than %d levels deep", maxDepth)
+	}
+	return nil
+}
+
+func (p *pathParser) restore(depth int) { p.depth = depth }
+
+func (p *pathParser) skipSpace() {
+	for p.pos < len(p.src) && strings.IndexByte(" \t\r\n", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *pathParser) peek(s string) bool {
+	return strings.HasPrefix(p.src[p.pos:], s)
+}
+
+func (p *pathParser) eat(s string) bool {
+	if p.peek(s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *pathParser) end() error {
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return p.errorf("unexpected %q", p.src[p.pos:p.pos+1])
+	}
+	return nil
+}
+
+// name reads a member-name shorthand: letters, digits, _ and non-ASCII,
+// not starting with a digit
+func (p *pathParser) name() string {
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '_' || c >= 0x80 || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || p.pos > start && '0' <= c && c <= '9' {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *pathParser) query() (*pathQuery, error) {
+	q := &pathQuery{absolute: p.src[p.pos] == '$'}
+	p.pos++
+	for {
+		save := p.pos
+		p.skipSpace()
+		var seg segment
+		switch {
+		case p.eat(".."):
+			seg.descendant = true
+			if p.peek("[") {
+				sels, err := p.bracket()
+				if err != nil {
+					return nil, err
+				}
+				seg.selectors = sels
+			} else if sel, err := p.dotSelector(".."); err != nil {
+				return nil, err
+			} else {
+				seg.selectors = []selector{sel}
+			}
+		case p.eat("."):
+			sel, err := p.dotSelector(".")
+			if err != nil {
+				return nil, err
+			}
+			seg.selectors = []selector{sel}
+		case p.peek("["):
+			sels, err := p.bracket()
+			if err != nil {
+				return nil, err
+			}
+			seg.selectors = sels
+		default:
+			p.pos = save
+			return q, nil
+		}
+		q.segments = append(q.segments, seg)
+	}
+}
+
+func (p *pathParser) dotSelector(after string) (selector, error) {
+	if p.eat("*") {
+		return selector{kind: selWildcard}, nil
+	}
+	start := p.pos
+	name := p.name()
+	if name == "" {
+		return selector{}, p.errorf("expected a member name or * after %s", after)
+	}
+	if p.peek("(") {
+		return selector{}, p.errorAt(start, "functions are called as %s(@...), not as methods", name)
+	}
+	return selector{kind: selName, name: name}, nil
+}
+
+func (p *pathParser) bracket() ([]selector, error) {
+	p.pos++ // [
+	var sels []selector
+	for {
+		p.skipSpace()
+		sel, err := p.selector()
+		if err != nil {
+			return nil, err
+		}
+		sels = append(sels, sel)
+		p.skipSpace()
+		if p.eat(",") {
+			continue
+		}
+		if p.eat("]") {
+			return sels, nil
+		}
+		return nil, p.errorf("expected , or ] in brackets")
+	}
+}
+
+func (p *pathParser) selector() (selector, error) {
+	if p.pos >= len(p.src) {
+		return selector{}, p.errorf("unclosed [")
+	}
+	switch c := p.src[p.pos]; {
+	case c == '\'' || c == '"':
+		s, next, err := unquote(p.src, p.pos)
+		if err != nil {
+			return selector{}, err
+		}
+		p.pos = next
+		return selector{kind: selName, name: s}, nil
+	case c == '*':
+		p.pos++
+		return selector{kind: selWildcard}, nil
+	case c == '?':
+		p.pos++
+		f, err := p.logicalOr()
+		if err != nil {
+			return selector{}, err
+		}
+		return selector{kind: selFilter, filter: f}, nil
+	case c == '-' || c == ':' || '0' <= c && c <= '9':
+		start, err := p.optInt()
+		if err != nil {
+			return selector{}, err
+		}
+		p.skipSpace()
+		if !p.eat(":") {
+			if start == nil {
+				return selector{}, p.errorf("expected an index")
+			}
+			return selector{kind: selIndex, index: *start}, nil
+		}
+		sel := selector{kind: selSlice, start: start, step: 1}
+		p.skipSpace()
+		if sel.end, err = p.optInt(); err != nil {
+			return selector{}, err
+		}
+		p.skipSpace()
+		if p.eat(":") {
+			p.skipSpace()
+			step, err := p.optInt()
+			if err != nil {
+				return selector{}, err
+			}
+			if step != nil {
+				sel.step = *step
+			}
+		}
+		return sel, nil
+	}
+	return selector{}, p.errorf("expected a quoted name, an index, a slice, * or a ?filter")
+}
+
+func (p *pathParser) optInt() (*int, error) {
+	start := p.pos
+	p.eat("-")
+	for p.pos < len(p.src) && '0' <= p.src[p.pos] && p.src[p.pos] <= '9' {
+		p.pos++
+	}
+	if p.pos == start {
+		return nil, nil
+	}
+	i, err := strconv.Atoi(p.src[start:p.pos])
+	if err != nil {
+		return nil, p.errorAt(start, "%q is not a usable index", p.src[start:p.pos])
+	}
+	return &i, nil
+}
+
+// Filter grammar, loosest first: ||, &&, then ! and parentheses,
+// comparisons and existence tests
+
+type orExpr struct{ left, right filterExpr }
+type andExpr struct{ left, right filterExpr }
+type notExpr struct{ expr filterExpr }
+type existsExpr struct{ query *pathQuery }
+
+type comparison struct {
+	op          string
+	left, right operand
+}
+
+type literal struct{ node *jsonfmt.Node }
+type queryOperand struct{ query *pathQuery }
+
+func (p *pathParser) logicalOr() (filterExpr, error) {
+	defer p.restore(p.depth)
+	if err := p.deeper(); err != nil {
+		return nil, err
+	}
+	left, err := p.logicalAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.skipSpace(); p.eat("||"); p.skipSpace() {
+		if err := p.deeper(); err != nil {
+			return nil, err
+		}
+		right, err := p.logicalAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *pathParser) logicalAnd() (filterExpr, error) {
+	defer p.restore(p.depth)
+	if err := p.deeper(); err != nil {
+		return nil, err
+	}
+	left, err := p.basic()
+	if err != nil {
+		return nil, err
+	}
+	for p.skipSpace(); p.eat("&&"); p.skipSpace() {
+		if err := p.deeper(); err != nil {
+			return nil, err
+		}
+		right, err := p.basic()
+		if err != nil {
+			return nil, err
+		}
+		left = andExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *pathParser) basic() (filterExpr, error) {
+	p.skipSpace()
+	if p.eat("!") {
+		p.skipSpace()
+		var expr filterExpr
+		var err error
+		if p.peek("(") {
+			expr, err = p.group()
+		} else {
+			expr, err = p.test()
+		}
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{expr}, nil
+	}
+	if p.peek("(") {
+		return p.group()
+	}
+
+	start := p.pos
+	left, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	op := ""
+	for _, candidate := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.eat(candidate) {
+			op = candidate
+			break
+		}
+	}
+	if op == "" {
+		p.pos = start
+		return p.test()
+	}
+	if p.peek("=") || op == "<" && p.peek(">") {
+		return nil, p.errorf("unknown operator; use ==, !=, <, <=, > or >=")
+	}
+	l, err := p.asOperand(left, start)
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	rightStart := p.pos
+	right, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	r, err := p.asOperand(right, rightStart)
+	if err != nil {
+		return nil, err
+	}
+	return comparison{op: op, left: l, right: r}, nil
+}
+
+func (p *pathParser) group() (filterExpr, error) {
+	p.pos++ // (
+	expr, err := p.logicalOr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if !p.eat(")") {
+		return nil, p.errorf("expected )")
+	}
+	return expr, nil
+}
+
+// test is a condition without a comparison: a query that must select
+// something, or match() and search()
+func (p *pathParser) test() (filterExpr, error) {
+	start := p.pos
+	prim, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	switch v := prim.(type) {
+	case *pathQuery:
+		return existsExpr{v}, nil
+	case *call:
+		if v.logical {
+			return v, nil
+		}
+		return nil, p.errorAt(start, "%s() is a value, not a condition; compare it, as in %s(...) > 0", v.name, v.name)
+	}
+	return nil, p.errorAt(start, "a literal on its own is not a condition; compare it with something")
+}
+
+func (p *pathParser) asOperand(prim any, start int) (operand, error) {
+	switch v := prim.(type) {
+	case *pathQuery:
+		return queryOperand{v}, nil
+	case *call:
+		if v.logical {
+			return nil, p.errorAt(start, "%s() returns true or false and cannot be compared", v.name)
+		}
+		return v, nil
+	}
+	return prim.(literal), nil
+}
+
+// primary reads a literal, a query or a function call
+func (p *pathParser) primary() (any, error) {
+	defer p.restore(p.depth)
+	if err := p.deeper(); err != nil {
+		return nil, err
+	}
+	if p.pos >= len(p.src) {
+		return nil, p.errorf("the filter is incomplete")
+	}
+	switch c := p.src[p.pos]; {
+	case c == '@' || c == '$':
+		return p.query()
+	case c == '\'' || c == '"':
+		s, next, err := unquote(p.src, p.pos)
+		if err != nil {
+			return nil, err
+		}
+		p.pos = next
+		return literal{jsonfmt.NewString(s)}, nil
+	case c == '-' || '0' <= c && c <= '9':
+		num := numberRe.FindString(p.src[p.pos:])
+		if num == "" {
+			return nil, p.errorf("invalid number")
+		}
+		p.pos += len(num)
+		return literal{jsonfmt.NewNumber(num)}, nil
+	}
+
+	start := p.pos
+	switch word := p.name(); word {
+	case "true", "false":
+		return literal{&jsonfmt.Node{Kind: jsonfmt.Bool, Value: word}}, nil
+	case "null":
+		return literal{&jsonfmt.Node{Kind: jsonfmt.Null}}, nil
+	}
+	p.pos = start
+	return p.call()
+}
+
+// call is a function extension
+type call struct {
+	name    string
+	logical bool
+	query   *pathQuery // the argument of count() and value()
+	args    []operand
+	re      *regexp.Regexp // precompiled when the pattern is a literal
+}
+
+func (p *pathParser) call() (*call, error) {
+	start := p.pos
+	name := p.name()
+	if name == "" {
+		return nil, p.errorf("expected $, @, a literal or a function such as count(...)")
+	}
+	fn, ok := pathFunctions[name]
+	if !ok {
+		return nil, p.errorAt(start, "unknown function %s(); use length, count, match, search or value", name)
+	}
+	p.skipSpace()
+	if !p.eat("(") {
+		return nil, p.errorf("expected ( after %s", name)
+	}
+
+	c := &call{name: name, logical: fn.logical}
+	for i := 0; i < fn.arity; i++ {
+		if i > 0 {
+			p.skipSpace()
+			if !p.eat(",") {
+				return nil, p.errorf("%s() takes %d arguments", name, fn.arity)
+			}
+		}
+		p.skipSpace()
+		argStart := p.pos
+		prim, err := p.primary()
+		if err != nil {
+			return nil, err
+		}
+		if fn.nodes {
+			q, ok := prim.(*pathQuery)
+			if !ok {
+				return nil, p.errorAt(argStart, "%s() takes a query such as @.items[*]", name)
+			}
+			c.query = q
+			continue
+		}
+		arg, err := p.asOperand(prim, argStart)
+		if err != nil {
+			return nil, err
+		}
+		c.args = append(c.args, arg)
+	}
+	p.skipSpace()
+	if !p.eat(")") {
+		return nil, p.errorf("%s() takes %d argument(s); expected )", name, fn.arity)
+	}
+
+	if lit, ok := c.literalPattern(); ok {
+		re, err := compileRegexp(lit, name == "match")
+		if err != nil {
+			return nil, p.errorAt(start, "invalid pattern %q: %v", lit, err)
+		}
+		c.re = re
+	}
+	return c, nil
+}
+
+// literalPattern returns the pattern of match() or search() when it is a literal
+func (c *call) literalPattern() (string, bool) {
+	if !c.logical {
+		return "", false
+	}
+	lit, ok := c.args[1].(literal)
+	if !ok || lit.node.Kind != jsonfmt.String {
+		return "", false
+	}
+	return lit.node.Value, true
+}
+
+// compileRegexp anchors match() patterns so the whole string must match
+func compileRegexp(pattern string, whole bool) (*regexp.Regexp, error) {
+	if whole {
+		pattern = `^(?:` + pattern + `)$`
+	}
+	return regexp.Compile(pattern)
+}
+
+// evalCtx carries the document root and a budget on the work a query does
+type evalCtx struct {
+	root  *jsonfmt.Node
+	spent int
+}
+
+func (c *evalCtx) spend(n int) error {
+	c.spent += n
+	if c.spent > maxResults {
+		return fmt.Errorf("the query visits more than %d values; narrow it down", maxResults)
+	}
+	return nil
+}
+
+func (c *evalCtx) query(q *pathQuery, current located) ([]located, error) {
+	nodes := []located{current}
+	if q.absolute {
+		nodes = []located{{node: c.root}}
+	}
+	for _, seg := range q.segments {
+		var next []located
+		for _, l := range nodes {
+			targets := []located{l}
+			if seg.descendant {
+				targets = descendants(l, nil)
+			}
+			if err := c.spend(len(targets)); err != nil {
+				return nil, err
+			}
+			for _, t := range targets {
+				for _, sel := range seg.selectors {
+					var err error
+					if next, err = c.apply(sel, t, next); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+		if err := c.spend(len(next)); err != nil {
+			return nil, err
+		}
+		nodes = next
+	}
+	return nodes, nil
+}
+
+func (c *evalCtx) apply(sel selector, l located, out []located) ([]located, error) {
+	n := l.node
+	switch sel.kind {
+	case selName:
+		if n.Kind == jsonfmt.Object {
+			if v := n.Get(sel.name); v != nil {
+				out = append(out, located{v, l.path + "/" + jsonfmt.EscapePointer(sel.name)})
+			}
+		}
+	case selWildcard:
+		out = children(l, out)
+	case selIndex:
+		if n.Kind == jsonfmt.Array {
+			i := sel.index
+			if i < 0 {
+				i += len(n.Items)
+			}
+			if i >= 0 && i < len(n.Items) {
+				out = append(out, located{n.Items[i], l.path + "/" + strconv.Itoa(i)})
+			}
+		}
+	case selSlice:
+		if n.Kind == jsonfmt.Array {
+			for _, i := range sliceIndices(len(n.Items), sel.start, sel.end, sel.step) {
+				out = append(out, located{n.Items[i], l.path + "/" + strconv.Itoa(i)})
+			}
+		}
+	case selFilter:
+		for _, child := range children(l, nil) {
+			ok, err := sel.filter.test(c, child)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				out = append(out, child)
+			}
+		}
+	}
+	return out, nil
+}
+
+func children(l located, out []located) []located {
+	switch l.node.Kind {
+	case jsonfmt.Array:
+		for i, item := range l.node.Items {
+			out = append(out, located{item, l.path + "/" + strconv.Itoa(i)})
+		}
+	case jsonfmt.Object:
+		for _, m := range l.node.Members {
+			out = append(out, located{m.Value, l.path + "/" + jsonfmt.EscapePointer(m.Key)})
+		}
+	}
+	return out
+}
+
+// descendants lists a node and everything below it in document order
+func descendants(l located, out []located) []located {
+	out = append(out, l)
+	for _, child := range children(l, nil) {
+		out = descendants(child, out)
+	}
+	return out
+}
+
+func (e orExpr) test(c *evalCtx, cur located) (bool, error) {
+	ok, err := e.left.test(c, cur)
+	if err != nil || ok {
+		return ok, err
+	}
+	return e.right.test(c, cur)
+}
+
+func (e andExpr) test(c *evalCtx, cur located) (bool, error) {
+	ok, err := e.left.test(c, cur)
+	if err != nil || !ok {
+		return false, err
+	}
+	return e.right.test(c, cur)
+}
+
+func (e notExpr) test(c *evalCtx, cur located) (bool, error) {
+	ok, err := e.expr.test(c, cur)
+	return !ok, err
+}
+
+func (e existsExpr) test(c *evalCtx, cur located) (bool, error) {
+	nodes, err := c.query(e.query, cur)
+	return len(nodes) > 0, err
+}
+
+func (e comparison) test(c *evalCtx, cur located) (bool, error) {
+	a, err := e.left.value(c, cur)
+	if err != nil {
+		return false, err
+	}
+	b, err := e.right.value(c, cur)
+	if err != nil {
+		return false, err
+	}
+	switch e.op {
+	case "==":
+		return equalOrBothMissing(a, b), nil
+	case "!=":
+		return !equalOrBothMissing(a, b), nil
+	case "<":
+		return less(a, b), nil
+	case ">":
+		return less(b, a), nil
+	case "<=":
+		return less(a, b) || equalOrBothMissing(a, b), nil
+	}
+	return less(b, a) || equalOrBothMissing(a, b), nil
+}
+
+func equalOrBothMissing(a, b *jsonfmt.Node) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return jsonfmt.Equal(a, b)
+}
+
+// less only orders numbers with numbers and strings with strings
+func less(a, b *jsonfmt.Node) bool {
+	if a == nil || b == nil || a.Kind != b.Kind || a.Kind != jsonfmt.Number && a.Kind != jsonfmt.String {
+		return false
+	}
+	return jsonfmt.Compare(a, b) < 0
+}
+
+func (l literal) value(*evalCtx, located) (*jsonfmt.Node, error) {
+	return l.node, nil
+}
+
+// value of a query is the node it selects, or nothing unless it selects
+// exactly one
+func (q queryOperand) value(c *evalCtx, cur located) (*jsonfmt.Node, error) {
+	nodes, err := c.query(q.query, cur)
+	if err != nil || len(nodes) != 1 {
+		return nil, err
+	}
+	return nodes[0].node, nil
+}
+
+func (f *call) value(c *evalCtx, cur located) (*jsonfmt.Node, error) {
+	switch f.name {
+	case "count", "value":
+		nodes, err := c.query(f.query, cur)
+		if err != nil {
+			return nil, err
+		}
+		if f.name == "count" {
+			return newInt(len(nodes)), nil
+		}
+		if len(nodes) != 1 {
+			return nil, nil
+		}
+		return nodes[0].node, nil
+	}
+	// length
+	v, err := f.args[0].value(c, cur)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	if n, ok := length(v); ok {
+		return newInt(n), nil
+	}
+	return nil, nil
+}
+
+func (f *call) test(c *evalCtx, cur located) (bool, error) {
+	s, err := f.args[0].value(c, cur)
+	if err != nil || s == nil || s.Kind != jsonfmt.String {
+		return false, err
+	}
+	re := f.re
+	if re == nil {
+		pattern, err := f.args[1].value(c, cur)
+		if err != nil || pattern == nil || pattern.Kind != jsonfmt.String {
+			return false, err
+		}
+		// An invalid pattern from the document simply does not match
+		if re, err = compileRegexp(pattern.Value, f.name == "match"); err != nil {
+			return false, nil
+		}
+	}
+	return re.MatchString(s.Value), nil
+}
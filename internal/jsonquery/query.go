@@ -0,0 +1,244 @@
+// Package jsonquery runs JSONPath (RFC 9535) and a subset of jq against
+// jsonfmt trees, so YAML documents can be queried once they are parsed and
+// number literals come back exactly as written.
+//
+// JSONPath supports names, indexes, slices, wildcards, unions, recursive
+// descent and filters with the length, count, match, search and value
+// functions. A whole expression may also be one of those functions, as in
+// count($..book[*]). The jq subset covers paths, iteration, pipes, commas,
+// object and array construction, arithmetic, comparisons, if/then/else,
+// the alternative operator and the common builtins such as select, map,
+// length and keys
+package jsonquery
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/Ndeta100/orbit2x/internal/jsonfmt"
+)
+
+// Query languages
+const (
+	JSONPath = "JSONPath"
+	JQ       = "jq"
+)
+
+// maxResults bounds the values a query may produce, so recursive descent
+// over a large document cannot run away
+const maxResults = 100000
+
+// maxBytes bounds the size of the values a jq query builds, since adding
+// strings or arrays to themselves doubles them with every step
+const maxBytes = 64 << 20
+
+// maxProductBits bounds exact multiplication, as maxBytes cannot stop a
+// product that is slow to work out before its size is known
+const maxProductBits = 1 << 16
+
+// maxDepth bounds how deeply an expression nests, since parsing it and
+// running it both recurse once per level
+const maxDepth = 256
+
+// Match is one result. Path is a JSON Pointer to where the value was
+// found; jq results and computed values have no path
+type Match struct {
+	Path    string
+	HasPath bool
+	Value   *jsonfmt.Node
+}
+
+// SyntaxError is a malformed expression. Offset is in bytes
+type SyntaxError struct {
+	Offset int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("column %d: %s", e.Offset+1, e.Msg)
+}
+
+// Column is the 1-based rune column of the error
+func (e *SyntaxError) Column(expr string) int {
+	if e.Offset > len(expr) {
+		return len([]rune(expr)) + 1
+	}
+	return len([]rune(expr[:e.Offset])) + 1
+}
+
+// Detect picks the language of an expression: JSONPath starts with $ or is
+// a function call on a $ query, anything else is jq
+func Detect(expr string) string {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "$") {
+		return JSONPath
+	}
+	if i := strings.IndexByte(expr, '('); i > 0 {
+		_, ok := pathFunctions[strings.TrimSpace(expr[:i])]
+		if ok && strings.HasPrefix(strings.TrimSpace(expr[i+1:]), "$") {
+			return JSONPath
+		}
+	}
+	return JQ
+}
+
+// Run evaluates an expression in the given language against a document
+func Run(expr, language string, doc *jsonfmt.Node) ([]Match, error) {
+	if strings.TrimSpace(expr) == "" {
+		return nil, &SyntaxError{Msg: "the expression is empty"}
+	}
+	if language == JSONPath {
+		q, err := compilePath(expr)
+		if err != nil {
+			return nil, err
+		}
+		return q(doc)
+	}
+	f, err := compileJQ(expr)
+	if err != nil {
+		return nil, err
+	}
+	values, err := f.run(doc)
+	matches := make([]Match, len(values))
+	for i, v := range values {
+		matches[i] = Match{Value: v}
+	}
+	return matches, err
+}
+
+// truthy follows jq: only false and null are false
+func truthy(n *jsonfmt.Node) bool {
+	return n.Kind != jsonfmt.Null && !(n.Kind == jsonfmt.Bool && n.Value == "false")
+}
+
+func newBool(b bool) *jsonfmt.Node {
+	if b {
+		return &jsonfmt.Node{Kind: jsonfmt.Bool, Value: "true"}
+	}
+	return &jsonfmt.Node{Kind: jsonfmt.Bool, Value: "false"}
+}
+
+func newInt(i int) *jsonfmt.Node {
+	return jsonfmt.NewNumber(fmt.Sprint(i))
+}
+
+// length is the rune count of a string or the size of a container
+func length(n *jsonfmt.Node) (int, bool) {
+	switch n.Kind {
+	case jsonfmt.String:
+		return len([]rune(n.Value)), true
+	case jsonfmt.Array:
+		return len(n.Items), true
+	case jsonfmt.Object:
+		return len(n.Members), true
+	}
+	return 0, false
+}
+
+// sliceIndices follows RFC 9535: negative bounds count from the end and
+// a negative step walks backwards
+func sliceIndices(n int, start, end *int, step int) []int {
+	if step == 0 {
+		return nil
+	}
+	norm := func(i int) int {
+		if i < 0 {
+			return n + i
+		}
+		return i
+	}
+	clamp := func(i, lo, hi int) int {
+		return max(lo, min(i, hi))
+	}
+	var out []int
+	if step > 0 {
+		lower, upper := 0, n
+		if start != nil {
+			lower = clamp(norm(*start), 0, n)
+		}
+		if end != nil {
+			upper = clamp(norm(*end), 0, n)
+		}
+		for i := lower; i < upper; i += step {
+			out = append(out, i)
+		}
+		return out
+	}
+	upper, lower := n-1, -1
+	if start != nil {
+		upper = clamp(norm(*start), -1, n-1)
+	}
+	if end != nil {
+		lower = clamp(norm(*end), -1, n-1)
+	}
+	for i := upper; i > lower; i += step {
+		out = append(out, i)
+	}
+	return out
+}
+
+// unquote reads the string literal whose opening quote is at src[pos],
+// returning its value and the offset after the closing quote. Escapes are
+// JSON's plus \' for single-quoted JSONPath names
+func unquote(src string, pos int) (string, int, error) {
+	quote := src[pos]
+	var b strings.Builder
+	for i := pos + 1; i < len(src); {
+		c := src[i]
+		switch {
+		case c == quote:
+			return b.String(), i + 1, nil
+		case c < 0x20:
+			return "", 0, &SyntaxError{Offset: i, Msg: "control characters must be escaped in strings"}
+		case c != '\\':
+			b.WriteByte(c)
+			i++
+			continue
+		}
+		if i+1 >= len(src) {
+			break
+		}
+		switch e := src[i+1]; e {
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '/', '\\', '"', '\'':
+			b.WriteByte(e)
+		case 'u':
+			r, ok := hex4(src, i+2)
+			if !ok {
+				return "", 0, &SyntaxError{Offset: i, Msg: `\u must be followed by four hex digits`}
+			}
+			i += 6
+			if utf16.IsSurrogate(r) && strings.HasPrefix(src[i:], `\u`) {
+				if low, ok := hex4(src, i+2); ok {
+					r = utf16.DecodeRune(r, low)
+					i += 6
+				}
+			}
+			b.WriteRune(r)
+			continue
+		default:
+			return "", 0, &SyntaxError{Offset: i, Msg: fmt.Sprintf(`unknown escape \%c`, e)}
+		}
+		i += 2
+	}
+	return "", 0, &SyntaxError{Offset: pos, Msg: "unterminated string"}
+}
+
+func hex4(src string, i int) (rune, bool) {
+	if i+4 > len(src) {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(src[i:i+4], 16, 32)
+	return rune(n), err == nil
+}
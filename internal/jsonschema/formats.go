@@ -0,0 +1,77 @@
+package jsonschema
+
+import (
+	"net/mail"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	hostnameRe = regexp.MustCompile(`^(?i)[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?(\.[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?)*\.?$`)
+	uuidRe     = regexp.MustCompile(`^(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+	durationRe = regexp.MustCompile(`^P(?:\d+W|(?:\d+Y)?(?:\d+M)?(?:\d+D)?(?:T(?:\d+H)?(?:\d+M)?(?:\d+(?:\.\d+)?S)?)?)$`)
+	pointerRe  = regexp.MustCompile(`^(?:/(?:[^~/]|~[01])*)*$`)
+)
+
+// formats are asserted rather than treated as annotations, since catching
+// a malformed date is usually why a schema names one. Unknown formats pass
+var formats = map[string]func(string) bool{
+	"date-time": func(s string) bool {
+		_, err := time.Parse(time.RFC3339Nano, strings.ToUpper(s))
+		return err == nil
+	},
+	"date": func(s string) bool {
+		_, err := time.Parse(time.DateOnly, s)
+		return err == nil
+	},
+	"time": func(s string) bool {
+		_, err := time.Parse(time.RFC3339Nano, "2000-01-01T"+strings.ToUpper(s))
+		return err == nil
+	},
+	"duration": func(s string) bool {
+		return s != "P" && !strings.HasSuffix(s, "T") && durationRe.MatchString(s)
+	},
+	"email": func(s string) bool {
+		a, err := mail.ParseAddress(s)
+		return err == nil && a.Address == s
+	},
+	"hostname": func(s string) bool {
+		return len(s) <= 253 && hostnameRe.MatchString(s)
+	},
+	"ipv4": func(s string) bool {
+		a, err := netip.ParseAddr(s)
+		return err == nil && a.Is4()
+	},
+	"ipv6": func(s string) bool {
+		a, err := netip.ParseAddr(s)
+		return err == nil && a.Is6() && a.Zone() == ""
+	},
+	"uri": func(s string) bool {
+		u, err := url.Parse(s)
+		return err == nil && u.IsAbs()
+	},
+	"uri-reference": func(s string) bool {
+		_, err := url.Parse(s)
+		return err == nil
+	},
+	"uuid":         uuidRe.MatchString,
+	"json-pointer": pointerRe.MatchString,
+	"regex": func(s string) bool {
+		_, err := regexp.Compile(s)
+		return err == nil
+	},
+}
+
+// detectFormat names the first format a sample string satisfies, for
+// schema inference. Only formats unlikely to match by accident are tried
+func detectFormat(s string) string {
+	for _, name := range []string{"date-time", "date", "uuid", "email", "ipv4", "ipv6", "uri"} {
+		if formats[name](s) {
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,138 @@
+package jsonschema
+
+import "github.com/Ndeta100/orbit2x/internal/jsonfmt"
+
+// shape accumulates what has been seen at one place in a sample. Array
+// items are merged into a single shape, so a list of records yields one
+// record schema whose required keys are those present in every record
+type shape struct {
+	types   []string // in first-seen order
+	format  string
+	formats int // strings matching format; it is kept only if all match
+	strings int
+
+	keys    []string
+	props   map[string]*shape
+	seen    map[string]int
+	objects int
+
+	items *shape
+}
+
+// Infer builds a schema that the sample satisfies
+func Infer(sample *jsonfmt.Node, draft Draft) *jsonfmt.Node {
+	s := &shape{}
+	s.observe(sample)
+	schema := s.schema()
+	out := &jsonfmt.Node{Kind: jsonfmt.Object}
+	out.Set("$schema", jsonfmt.NewString(draft.SchemaURI()))
+	out.Members = append(out.Members, schema.Members...)
+	return out
+}
+
+func (s *shape) addType(name string) {
+	for _, t := range s.types {
+		if t == name {
+			return
+		}
+	}
+	s.types = append(s.types, name)
+}
+
+func (s *shape) observe(n *jsonfmt.Node) {
+	s.addType(typeName(n))
+	switch n.Kind {
+	case jsonfmt.String:
+		s.strings++
+		if f := detectFormat(n.Value); f != "" && (s.format == "" || s.format == f) {
+			s.format = f
+			s.formats++
+		}
+	case jsonfmt.Array:
+		if s.items == nil {
+			s.items = &shape{}
+		}
+		for _, item := range n.Items {
+			s.items.observe(item)
+		}
+	case jsonfmt.Object:
+		if s.props == nil {
+			s.props = map[string]*shape{}
+			s.seen = map[string]int{}
+		}
+		s.objects++
+		counted := map[string]bool{}
+		for _, m := range n.Members {
+			p, ok := s.props[m.Key]
+			if !ok {
+				p = &shape{}
+				s.props[m.Key] = p
+				s.keys = append(s.keys, m.Key)
+			}
+			p.observe(m.Value)
+			if !counted[m.Key] {
+				counted[m.Key] = true
+				s.seen[m.Key]++
+			}
+		}
+	}
+}
+
+func (s *shape) schema() *jsonfmt.Node {
+	out := &jsonfmt.Node{Kind: jsonfmt.Object}
+	types := s.types
+	// An integer seen alongside a fraction is just a number
+	if contains(types, "integer") && contains(types, "number") {
+		types = remove(types, "integer")
+	}
+	if len(types) == 1 {
+		out.Set("type", jsonfmt.NewString(types[0]))
+	} else if len(types) > 1 {
+		list := &jsonfmt.Node{Kind: jsonfmt.Array}
+		for _, t := range types {
+			list.Items = append(list.Items, jsonfmt.NewString(t))
+		}
+		out.Set("type", list)
+	}
+
+	if s.format != "" && s.formats == s.strings {
+		out.Set("format", jsonfmt.NewString(s.format))
+	}
+	if s.items != nil && len(s.items.types) > 0 {
+		out.Set("items", s.items.schema())
+	}
+	if s.props != nil {
+		props := &jsonfmt.Node{Kind: jsonfmt.Object}
+		required := &jsonfmt.Node{Kind: jsonfmt.Array}
+		for _, k := range s.keys {
+			props.Set(k, s.props[k].schema())
+			if s.seen[k] == s.objects {
+				required.Items = append(required.Items, jsonfmt.NewString(k))
+			}
+		}
+		out.Set("properties", props)
+		if len(required.Items) > 0 {
+			out.Set("required", required)
+		}
+	}
+	return out
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func remove(list []string, s string) []string {
+	var out []string
+	for _, v := range list {
+		if v != s {
+			out = append(out, v)
+		}
+	}
+	return out
+}
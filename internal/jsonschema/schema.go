@@ -0,0 +1,226 @@
+// Package jsonschema validates documents against JSON Schema draft-07 and
+// 2020-12 and infers schemas from samples. Schemas and documents are
+// jsonfmt trees, so number literals are compared exactly.
+//
+// References must point inside the schema (#, JSON Pointers, $anchor and
+// embedded $id); remote documents are never fetched
+package jsonschema
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Ndeta100/orbit2x/internal/jsonfmt"
+)
+
+// Draft is a JSON Schema dialect
+type Draft int
+
+const (
+	Draft7 Draft = iota
+	Draft2020
+)
+
+func (d Draft) String() string {
+	if d == Draft7 {
+		return "draft-07"
+	}
+	return "2020-12"
+}
+
+// SchemaURI is the $schema of a dialect
+func (d Draft) SchemaURI() string {
+	if d == Draft7 {
+		return "http://json-schema.org/draft-07/schema#"
+	}
+	return "https://json-schema.org/draft/2020-12/schema"
+}
+
+// Violation is one way a document fails a schema. Both paths are JSON
+// Pointers: InstancePath into the document, SchemaPath to the keyword
+type Violation struct {
+	InstancePath string
+	SchemaPath   string
+	Message      string
+}
+
+// Schema is a checked schema ready to validate documents
+type Schema struct {
+	Draft   Draft
+	Ignored []string // keywords this validator does not evaluate, by schema path
+
+	root     *jsonfmt.Node
+	patterns map[string]*regexp.Regexp
+	anchors  map[string]*jsonfmt.Node // "#name" and embedded $id values
+}
+
+// unsupported keywords are reported rather than silently skipped
+var unsupported = map[string]bool{
+	"unevaluatedProperties": true,
+	"unevaluatedItems":      true,
+	"$dynamicRef":           true,
+	"$recursiveRef":         true,
+}
+
+// Compile checks a schema: the dialect is supported, every pattern compiles
+// and every $ref resolves
+func Compile(root *jsonfmt.Node) (*Schema, error) {
+	if root.Kind != jsonfmt.Object && root.Kind != jsonfmt.Bool {
+		return nil, fmt.Errorf("a schema must be an object or a boolean, not a %s", root.Kind)
+	}
+	s := &Schema{
+		Draft:    Draft2020,
+		root:     root,
+		patterns: map[string]*regexp.Regexp{},
+		anchors:  map[string]*jsonfmt.Node{},
+	}
+	if uri := root.Get("$schema"); uri != nil {
+		switch {
+		case strings.Contains(uri.Value, "draft-07"), strings.Contains(uri.Value, "draft-06"):
+			s.Draft = Draft7
+		case strings.Contains(uri.Value, "2020-12"), strings.Contains(uri.Value, "2019-09"):
+		default:
+			return nil, fmt.Errorf("unsupported $schema %q; use draft-07 or 2020-12", uri.Value)
+		}
+	}
+
+	var refs []struct{ ref, path string }
+	var err error
+	walk(root, "", func(n *jsonfmt.Node, path string) {
+		if err != nil || n.Kind != jsonfmt.Object {
+			return
+		}
+		for _, m := range n.Members {
+			switch {
+			case unsupported[m.Key]:
+				s.Ignored = append(s.Ignored, path+"/"+m.Key)
+			case m.Key == "$anchor" && m.Value.Kind == jsonfmt.String:
+				s.anchors["#"+m.Value.Value] = n
+			case m.Key == "$id" && m.Value.Kind == jsonfmt.String && path != "":
+				// Draft-07 spells anchors as "$id": "#name"
+				s.anchors[m.Value.Value] = n
+			case m.Key == "$ref" && m.Value.Kind == jsonfmt.String:
+				refs = append(refs, struct{ ref, path string }{m.Value.Value, path + "/$ref"})
+			case m.Key == "pattern" && m.Value.Kind == jsonfmt.String:
+				err = s.compilePattern(m.Value.Value, path+"/pattern")
+			case m.Key == "patternProperties" && m.Value.Kind == jsonfmt.Object:
+				for _, p := range m.Value.Members {
+					if err == nil {
+						err = s.compilePattern(p.Key, path+"/patternProperties")
+					}
+				}
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range refs {
+		if _, ok := s.resolve(r.ref); !ok {
+			return nil, fmt.Errorf("%s: cannot resolve %q; only references inside this schema are supported", r.path, r.ref)
+		}
+	}
+	return s, nil
+}
+
+func (s *Schema) compilePattern(pattern, path string) error {
+	if _, ok := s.patterns[pattern]; ok {
+		return nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("%s: pattern %q is not supported: %v", path, pattern, err)
+	}
+	s.patterns[pattern] = re
+	return nil
+}
+
+// resolve finds the schema a $ref points at
+func (s *Schema) resolve(ref string) (*jsonfmt.Node, bool) {
+	if n, ok := s.anchors[ref]; ok {
+		return n, true
+	}
+	base, fragment, _ := strings.Cut(ref, "#")
+	root := s.root
+	if base != "" {
+		n, ok := s.anchors[base]
+		if !ok {
+			return nil, false
+		}
+		root = n
+	}
+	if fragment == "" {
+		return root, true
+	}
+	if !strings.HasPrefix(fragment, "/") {
+		n, ok := s.anchors["#"+fragment]
+		return n, ok
+	}
+
+	n := root
+	for _, seg := range strings.Split(fragment[1:], "/") {
+		if unescaped, err := url.PathUnescape(seg); err == nil {
+			seg = unescaped
+		}
+		seg = strings.NewReplacer("~1", "/", "~0", "~").Replace(seg)
+		switch n.Kind {
+		case jsonfmt.Object:
+			n = n.Get(seg)
+		case jsonfmt.Array:
+			i, err := strconv.Atoi(seg)
+			if err != nil || i < 0 || i >= len(n.Items) {
+				return nil, false
+			}
+			n = n.Items[i]
+		default:
+			n = nil
+		}
+		if n == nil {
+			return nil, false
+		}
+	}
+	return n, true
+}
+
+// Keywords whose values are a schema, an array of schemas or a map of
+// schemas. Everything else (enum, const, default, examples...) is data
+var (
+	schemaKeywords = map[string]bool{
+		"additionalProperties": true, "propertyNames": true, "items": true,
+		"additionalItems": true, "contains": true, "not": true, "if": true,
+		"then": true, "else": true, "unevaluatedProperties": true, "unevaluatedItems": true,
+	}
+	schemaArrayKeywords = map[string]bool{"allOf": true, "anyOf": true, "oneOf": true, "prefixItems": true, "items": true}
+	schemaMapKeywords   = map[string]bool{
+		"properties": true, "patternProperties": true, "$defs": true,
+		"definitions": true, "dependentSchemas": true, "dependencies": true,
+	}
+)
+
+// walk visits every subschema
+func walk(n *jsonfmt.Node, path string, visit func(*jsonfmt.Node, string)) {
+	visit(n, path)
+	if n.Kind != jsonfmt.Object {
+		return
+	}
+	for _, m := range n.Members {
+		p := path + "/" + jsonfmt.EscapePointer(m.Key)
+		switch {
+		case m.Value.Kind == jsonfmt.Array && schemaArrayKeywords[m.Key]:
+			for i, item := range m.Value.Items {
+				walk(item, p+"/"+strconv.Itoa(i), visit)
+			}
+		case schemaKeywords[m.Key]:
+			walk(m.Value, p, visit)
+		case m.Value.Kind == jsonfmt.Object && schemaMapKeywords[m.Key]:
+			for _, sub := range m.Value.Members {
+				if sub.Value.Kind == jsonfmt.Object || sub.Value.Kind == jsonfmt.Bool {
+					walk(sub.Value, p+"/"+jsonfmt.EscapePointer(sub.Key), visit)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,403 @@
+package jsonschema
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Ndeta100/orbit2x/internal/jsonfmt"
+)
+
+// maxRefDepth stops recursive schemas from looping on cyclic references
+const maxRefDepth = 256
+
+// maxSteps bounds the subschemas one Validate evaluates, since combinators
+// over references can branch exponentially
+const maxSteps = 1 << 20
+
+// maxRepeats bounds how many repeated items uniqueItems lists
+const maxRepeats = 20
+
+// Validate returns every violation, in document order
+func (s *Schema) Validate(doc *jsonfmt.Node) []Violation {
+	v := validator{s: s, run: &run{refs: map[[2]*jsonfmt.Node]bool{}}}
+	v.validate(s.root, doc, "", "", 0)
+	if v.run.steps > maxSteps {
+		return []Violation{{Message: fmt.Sprintf("checking this document takes more than %d steps; the schema may refer to itself too often", maxSteps)}}
+	}
+	return v.out
+}
+
+type validator struct {
+	s   *Schema
+	out []Violation
+	run *run
+}
+
+// run is what a validation shares with the trial validations under it
+type run struct {
+	steps int
+	refs  map[[2]*jsonfmt.Node]bool // $ref targets being applied, with their instance
+}
+
+func (v *validator) fail(ipath, spath, format string, args ...any) {
+	v.out = append(v.out, Violation{InstancePath: ipath, SchemaPath: spath, Message: fmt.Sprintf(format, args...)})
+}
+
+// try validates without recording, for keywords that combine outcomes
+func (v *validator) try(schema, inst *jsonfmt.Node, ipath, spath string, depth int) []Violation {
+	sub := validator{s: v.s, run: v.run}
+	sub.validate(schema, inst, ipath, spath, depth)
+	return sub.out
+}
+
+func (v *validator) validate(schema, inst *jsonfmt.Node, ipath, spath string, depth int) {
+	if v.run.steps++; v.run.steps > maxSteps {
+		return
+	}
+	if schema.Kind == jsonfmt.Bool {
+		if schema.Value == "false" {
+			v.fail(ipath, spath, "no value is allowed here")
+		}
+		return
+	}
+	if schema.Kind != jsonfmt.Object {
+		return
+	}
+
+	if ref := schema.Get("$ref"); ref != nil && ref.Kind == jsonfmt.String {
+		if depth >= maxRefDepth {
+			v.fail(ipath, spath+"/$ref", "references nest too deeply; the schema may be cyclic")
+			return
+		}
+		if target, ok := v.s.resolve(ref.Value); ok {
+			// Coming back to the same schema for the same value means the
+			// references loop without reaching into the value
+			key := [2]*jsonfmt.Node{target, inst}
+			if v.run.refs[key] {
+				v.fail(ipath, spath+"/$ref", "%s leads back to itself without checking anything; the schema is cyclic", ref.Value)
+				return
+			}
+			v.run.refs[key] = true
+			v.validate(target, inst, ipath, spath+"/$ref", depth+1)
+			delete(v.run.refs, key)
+		}
+		// Before 2019-09, keywords next to $ref are ignored
+		if v.s.Draft == Draft7 {
+			return
+		}
+	}
+
+	v.generic(schema, inst, ipath, spath)
+	switch inst.Kind {
+	case jsonfmt.Number:
+		v.number(schema, inst, ipath, spath)
+	case jsonfmt.String:
+		v.string(schema, inst, ipath, spath)
+	case jsonfmt.Array:
+		v.array(schema, inst, ipath, spath, depth)
+	case jsonfmt.Object:
+		v.object(schema, inst, ipath, spath, depth)
+	}
+	v.combinators(schema, inst, ipath, spath, depth)
+}
+
+func (v *validator) generic(schema, inst *jsonfmt.Node, ipath, spath string) {
+	if t := schema.Get("type"); t != nil {
+		var allowed []string
+		if t.Kind == jsonfmt.Array {
+			for _, item := range t.Items {
+				allowed = append(allowed, item.Value)
+			}
+		} else {
+			allowed = []string{t.Value}
+		}
+		ok := false
+		for _, name := range allowed {
+			if hasType(inst, name) {
+				ok = true
+			}
+		}
+		if !ok {
+			v.fail(ipath, spath+"/type", "expected %s, got %s", strings.Join(allowed, " or "), typeName(inst))
+		}
+	}
+
+	if c := schema.Get("const"); c != nil && !jsonfmt.Equal(c, inst) {
+		v.fail(ipath, spath+"/const", "must be %s", jsonfmt.Format(c, jsonfmt.Options{Minify: true}))
+	}
+	if e := schema.Get("enum"); e != nil && e.Kind == jsonfmt.Array {
+		found := false
+		for _, item := range e.Items {
+			if jsonfmt.Equal(item, inst) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.fail(ipath, spath+"/enum", "must be one of %s", jsonfmt.Format(e, jsonfmt.Options{Minify: true}))
+		}
+	}
+}
+
+func (v *validator) number(schema, inst *jsonfmt.Node, ipath, spath string) {
+	x, ok := jsonfmt.Rat(inst)
+	if !ok {
+		return
+	}
+	limit := func(keyword string, bad func(cmp int) bool, relation string) {
+		l, ok := jsonfmt.Rat(schema.Get(keyword))
+		if ok && bad(x.Cmp(l)) {
+			v.fail(ipath, spath+"/"+keyword, "%s must be %s %s", inst.Value, relation, schema.Get(keyword).Value)
+		}
+	}
+	limit("minimum", func(c int) bool { return c < 0 }, "at least")
+	limit("maximum", func(c int) bool { return c > 0 }, "at most")
+	limit("exclusiveMinimum", func(c int) bool { return c <= 0 }, "greater than")
+	limit("exclusiveMaximum", func(c int) bool { return c >= 0 }, "less than")
+
+	if m, ok := jsonfmt.Rat(schema.Get("multipleOf")); ok && m.Sign() > 0 {
+		if !new(big.Rat).Quo(x, m).IsInt() {
+			v.fail(ipath, spath+"/multipleOf", "%s is not a multiple of %s", inst.Value, schema.Get("multipleOf").Value)
+		}
+	}
+}
+
+func (v *validator) string(schema, inst *jsonfmt.Node, ipath, spath string) {
+	length := utf8.RuneCountInString(inst.Value)
+	if n, ok := count(schema.Get("minLength")); ok && length < n {
+		v.fail(ipath, spath+"/minLength", "must be at least %d characters, got %d", n, length)
+	}
+	if n, ok := count(schema.Get("maxLength")); ok && length > n {
+		v.fail(ipath, spath+"/maxLength", "must be at most %d characters, got %d", n, length)
+	}
+	if p := schema.Get("pattern"); p != nil && p.Kind == jsonfmt.String {
+		if re := v.s.patterns[p.Value]; re != nil && !re.MatchString(inst.Value) {
+			v.fail(ipath, spath+"/pattern", "%q does not match the pattern %q", inst.Value, p.Value)
+		}
+	}
+	if f := schema.Get("format"); f != nil && f.Kind == jsonfmt.String {
+		if check, ok := formats[f.Value]; ok && !check(inst.Value) {
+			v.fail(ipath, spath+"/format", "%q is not a valid %s", inst.Value, f.Value)
+		}
+	}
+}
+
+func (v *validator) array(schema, inst *jsonfmt.Node, ipath, spath string, depth int) {
+	items := inst.Items
+	if n, ok := count(schema.Get("minItems")); ok && len(items) < n {
+		v.fail(ipath, spath+"/minItems", "must have at least %d items, got %d", n, len(items))
+	}
+	if n, ok := count(schema.Get("maxItems")); ok && len(items) > n {
+		v.fail(ipath, spath+"/maxItems", "must have at most %d items, got %d", n, len(items))
+	}
+
+	// Tuple items come from prefixItems (2020-12) or an items array
+	// (draft-07); the schema for the rest from items or additionalItems
+	var tuple []*jsonfmt.Node
+	tupleKey, restKey := "", "items"
+	if p := schema.Get("prefixItems"); p != nil && p.Kind == jsonfmt.Array {
+		tuple, tupleKey = p.Items, "prefixItems"
+	} else if p := schema.Get("items"); p != nil && p.Kind == jsonfmt.Array {
+		tuple, tupleKey, restKey = p.Items, "items", "additionalItems"
+	}
+	rest := schema.Get(restKey)
+	for i, item := range items {
+		child := ipath + "/" + strconv.Itoa(i)
+		if i < len(tuple) {
+			v.validate(tuple[i], item, child, spath+"/"+tupleKey+"/"+strconv.Itoa(i), depth)
+		} else if rest != nil {
+			v.validate(rest, item, child, spath+"/"+restKey, depth)
+		}
+	}
+
+	if c := schema.Get("contains"); c != nil {
+		matches := 0
+		for i, item := range items {
+			if len(v.try(c, item, ipath+"/"+strconv.Itoa(i), spath+"/contains", depth)) == 0 {
+				matches++
+			}
+		}
+		minC, hasMin := count(schema.Get("minContains"))
+		if !hasMin {
+			minC = 1
+		}
+		if matches < minC {
+			v.fail(ipath, spath+"/contains", "must contain at least %d matching item(s), found %d", minC, matches)
+		}
+		if maxC, ok := count(schema.Get("maxContains")); ok && matches > maxC {
+			v.fail(ipath, spath+"/maxContains", "must contain at most %d matching item(s), found %d", maxC, matches)
+		}
+	}
+
+	if u := schema.Get("uniqueItems"); u != nil && u.Value == "true" {
+		v.run.steps += len(items)
+		first := make(map[string]int, len(items))
+		repeats := 0
+		for i, item := range items {
+			key := jsonfmt.Key(item)
+			j, seen := first[key]
+			if !seen {
+				first[key] = i
+				continue
+			}
+			if repeats++; repeats <= maxRepeats {
+				v.fail(ipath, spath+"/uniqueItems", "items %d and %d are equal; items must be unique", j, i)
+			}
+		}
+		if repeats > maxRepeats {
+			v.fail(ipath, spath+"/uniqueItems", "and %d more items repeat earlier ones", repeats-maxRepeats)
+		}
+	}
+}
+
+func (v *validator) object(schema, inst *jsonfmt.Node, ipath, spath string, depth int) {
+	members := inst.Members
+	if n, ok := count(schema.Get("minProperties")); ok && len(members) < n {
+		v.fail(ipath, spath+"/minProperties", "must have at least %d properties, got %d", n, len(members))
+	}
+	if n, ok := count(schema.Get("maxProperties")); ok && len(members) > n {
+		v.fail(ipath, spath+"/maxProperties", "must have at most %d properties, got %d", n, len(members))
+	}
+	if req := schema.Get("required"); req != nil && req.Kind == jsonfmt.Array {
+		for _, name := range req.Items {
+			if inst.Get(name.Value) == nil {
+				v.fail(ipath, spath+"/required", "missing required property %q", name.Value)
+			}
+		}
+	}
+
+	props := schema.Get("properties")
+	patterns := schema.Get("patternProperties")
+	additional := schema.Get("additionalProperties")
+	names := schema.Get("propertyNames")
+	for _, m := range members {
+		child := ipath + "/" + jsonfmt.EscapePointer(m.Key)
+		matched := false
+		if p := props.Get(m.Key); p != nil {
+			matched = true
+			v.validate(p, m.Value, child, spath+"/properties/"+jsonfmt.EscapePointer(m.Key), depth)
+		}
+		if patterns != nil && patterns.Kind == jsonfmt.Object {
+			for _, p := range patterns.Members {
+				if re := v.s.patterns[p.Key]; re != nil && re.MatchString(m.Key) {
+					matched = true
+					v.validate(p.Value, m.Value, child, spath+"/patternProperties/"+jsonfmt.EscapePointer(p.Key), depth)
+				}
+			}
+		}
+		if !matched && additional != nil {
+			if additional.Kind == jsonfmt.Bool && additional.Value == "false" {
+				v.fail(child, spath+"/additionalProperties", "property %q is not allowed", m.Key)
+			} else {
+				v.validate(additional, m.Value, child, spath+"/additionalProperties", depth)
+			}
+		}
+		if names != nil {
+			for _, violation := range v.try(names, jsonfmt.NewString(m.Key), child, spath+"/propertyNames", depth) {
+				v.fail(child, violation.SchemaPath, "invalid property name: %s", violation.Message)
+			}
+		}
+	}
+
+	// dependentRequired and dependentSchemas, or draft-07 dependencies
+	// which holds either form
+	for _, keyword := range []string{"dependentRequired", "dependentSchemas", "dependencies"} {
+		deps := schema.Get(keyword)
+		if deps == nil || deps.Kind != jsonfmt.Object {
+			continue
+		}
+		for _, d := range deps.Members {
+			if inst.Get(d.Key) == nil {
+				continue
+			}
+			dpath := spath + "/" + keyword + "/" + jsonfmt.EscapePointer(d.Key)
+			if d.Value.Kind == jsonfmt.Array {
+				for _, name := range d.Value.Items {
+					if inst.Get(name.Value) == nil {
+						v.fail(ipath, dpath, "property %q is required when %q is present", name.Value, d.Key)
+					}
+				}
+			} else {
+				v.validate(d.Value, inst, ipath, dpath, depth)
+			}
+		}
+	}
+}
+
+func (v *validator) combinators(schema, inst *jsonfmt.Node, ipath, spath string, depth int) {
+	if all := schema.Get("allOf"); all != nil && all.Kind == jsonfmt.Array {
+		for i, sub := range all.Items {
+			v.validate(sub, inst, ipath, spath+"/allOf/"+strconv.Itoa(i), depth)
+		}
+	}
+
+	for _, keyword := range []string{"anyOf", "oneOf"} {
+		subs := schema.Get(keyword)
+		if subs == nil || subs.Kind != jsonfmt.Array {
+			continue
+		}
+		var passed []string
+		var closest []Violation
+		for i, sub := range subs.Items {
+			out := v.try(sub, inst, ipath, spath+"/"+keyword+"/"+strconv.Itoa(i), depth)
+			if len(out) == 0 {
+				passed = append(passed, strconv.Itoa(i))
+			} else if closest == nil || len(out) < len(closest) {
+				closest = out
+			}
+		}
+		switch {
+		case len(passed) == 0:
+			v.fail(ipath, spath+"/"+keyword, "does not match any of the %d schemas in %s; the closest failed with:", len(subs.Items), keyword)
+			v.out = append(v.out, closest...)
+		case keyword == "oneOf" && len(passed) > 1:
+			v.fail(ipath, spath+"/oneOf", "matches schemas %s in oneOf; exactly one must match", strings.Join(passed, ", "))
+		}
+	}
+
+	if not := schema.Get("not"); not != nil {
+		if len(v.try(not, inst, ipath, spath+"/not", depth)) == 0 {
+			v.fail(ipath, spath+"/not", "must not match the schema in not")
+		}
+	}
+
+	if cond := schema.Get("if"); cond != nil {
+		if len(v.try(cond, inst, ipath, spath+"/if", depth)) == 0 {
+			if then := schema.Get("then"); then != nil {
+				v.validate(then, inst, ipath, spath+"/then", depth)
+			}
+		} else if els := schema.Get("else"); els != nil {
+			v.validate(els, inst, ipath, spath+"/else", depth)
+		}
+	}
+}
+
+func hasType(n *jsonfmt.Node, name string) bool {
+	switch name {
+	case "integer":
+		x, ok := jsonfmt.Rat(n)
+		return ok && x.IsInt()
+	case "number":
+		return n.Kind == jsonfmt.Number
+	}
+	return n.Kind.String() == name
+}
+
+func typeName(n *jsonfmt.Node) string {
+	if hasType(n, "integer") {
+		return "integer"
+	}
+	return n.Kind.String()
+}
+
+// count reads a non-negative integer keyword
+func count(n *jsonfmt.Node) (int, bool) {
+	x, ok := jsonfmt.Rat(n)
+	if !ok || !x.IsInt() || x.Sign() < 0 || !x.Num().IsInt64() {
+		return 0, false
+	}
+	return int(x.Num().Int64()), true
+}
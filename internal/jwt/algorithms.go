@@ -0,0 +1,196 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Family groups algorithms by the kind of key they use
+type Family string
+
+const (
+	HMAC   Family = "HMAC"
+	RSA    Family = "RSA PKCS#1 v1.5"
+	RSAPSS Family = "RSA-PSS"
+	ECDSA  Family = "ECDSA"
+	EdDSA  Family = "EdDSA"
+)
+
+// Algorithm is a JWS "alg" value
+type Algorithm struct {
+	Name   string
+	Family Family
+	Hash   crypto.Hash
+	Curve  elliptic.Curve // ECDSA only
+}
+
+// algorithms lists the supported JWS algorithms in display order
+var algorithms = []Algorithm{
+	{Name: "HS256", Family: HMAC, Hash: crypto.SHA256},
+	{Name: "HS384", Family: HMAC, Hash: crypto.SHA384},
+	{Name: "HS512", Family: HMAC, Hash: crypto.SHA512},
+	{Name: "RS256", Family: RSA, Hash: crypto.SHA256},
+	{Name: "RS384", Family: RSA, Hash: crypto.SHA384},
+	{Name: "RS512", Family: RSA, Hash: crypto.SHA512},
+	{Name: "PS256", Family: RSAPSS, Hash: crypto.SHA256},
+	{Name: "PS384", Family: RSAPSS, Hash: crypto.SHA384},
+	{Name: "PS512", Family: RSAPSS, Hash: crypto.SHA512},
+	{Name: "ES256", Family: ECDSA, Hash: crypto.SHA256, Curve: elliptic.P256()},
+	{Name: "ES384", Family: ECDSA, Hash: crypto.SHA384, Curve: elliptic.P384()},
+	{Name: "ES512", Family: ECDSA, Hash: crypto.SHA512, Curve: elliptic.P521()},
+	{Name: "EdDSA", Family: EdDSA},
+}
+
+// Algorithms returns every supported algorithm
+func Algorithms() []Algorithm {
+	return append([]Algorithm(nil), algorithms...)
+}
+
+// LookupAlgorithm finds an algorithm by its "alg" name
+func LookupAlgorithm(name string) (Algorithm, bool) {
+	for _, a := range algorithms {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return Algorithm{}, false
+}
+
+// Accepts reports whether key has the right type for the algorithm
+func (a Algorithm) Accepts(key Key) bool {
+	switch a.Family {
+	case HMAC:
+		return key.Secret != nil
+	case RSA, RSAPSS:
+		_, ok := key.Public.(*rsa.PublicKey)
+		return ok
+	case ECDSA:
+		pub, ok := key.Public.(*ecdsa.PublicKey)
+		return ok && pub.Curve == a.Curve
+	case EdDSA:
+		_, ok := key.Public.(ed25519.PublicKey)
+		return ok
+	}
+	return false
+}
+
+// CanSign reports whether key holds the private half needed to sign
+func (a Algorithm) CanSign(key Key) bool {
+	if a.Family == HMAC {
+		return key.Secret != nil
+	}
+	return key.Private != nil && a.Accepts(key)
+}
+
+func (a Algorithm) digest(input []byte) []byte {
+	h := a.Hash.New()
+	h.Write(input)
+	return h.Sum(nil)
+}
+
+// Sign signs input with the key, producing a JWS signature
+func (a Algorithm) Sign(key Key, input []byte) ([]byte, error) {
+	if !a.CanSign(key) {
+		return nil, fmt.Errorf("%s needs %s", a.Name, a.keyDescription(true))
+	}
+
+	switch a.Family {
+	case HMAC:
+		mac := hmac.New(a.Hash.New, key.Secret)
+		mac.Write(input)
+		return mac.Sum(nil), nil
+	case RSA:
+		return rsa.SignPKCS1v15(rand.Reader, key.Private.(*rsa.PrivateKey), a.Hash, a.digest(input))
+	case RSAPSS:
+		return rsa.SignPSS(rand.Reader, key.Private.(*rsa.PrivateKey), a.Hash, a.digest(input),
+			&rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	case ECDSA:
+		// JWS uses the fixed-width R || S form rather than ASN.1
+		r, s, err := ecdsa.Sign(rand.Reader, key.Private.(*ecdsa.PrivateKey), a.digest(input))
+		if err != nil {
+			return nil, err
+		}
+		size := a.coordinateSize()
+		sig := make([]byte, 2*size)
+		r.FillBytes(sig[:size])
+		s.FillBytes(sig[size:])
+		return sig, nil
+	case EdDSA:
+		return ed25519.Sign(key.Private.(ed25519.PrivateKey), input), nil
+	}
+	return nil, fmt.Errorf("unsupported algorithm %s", a.Name)
+}
+
+// Verify checks a JWS signature over input
+func (a Algorithm) Verify(key Key, input, sig []byte) error {
+	if !a.Accepts(key) {
+		return fmt.Errorf("%s needs %s", a.Name, a.keyDescription(false))
+	}
+
+	invalid := errors.New("signature does not match")
+	switch a.Family {
+	case HMAC:
+		mac := hmac.New(a.Hash.New, key.Secret)
+		mac.Write(input)
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return invalid
+		}
+	case RSA:
+		if rsa.VerifyPKCS1v15(key.Public.(*rsa.PublicKey), a.Hash, a.digest(input), sig) != nil {
+			return invalid
+		}
+	case RSAPSS:
+		if rsa.VerifyPSS(key.Public.(*rsa.PublicKey), a.Hash, a.digest(input), sig,
+			&rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto}) != nil {
+			return invalid
+		}
+	case ECDSA:
+		size := a.coordinateSize()
+		if len(sig) != 2*size {
+			return fmt.Errorf("%s signatures are %d bytes, got %d", a.Name, 2*size, len(sig))
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(key.Public.(*ecdsa.PublicKey), a.digest(input), r, s) {
+			return invalid
+		}
+	case EdDSA:
+		if !ed25519.Verify(key.Public.(ed25519.PublicKey), input, sig) {
+			return invalid
+		}
+	}
+	return nil
+}
+
+// coordinateSize is the byte length of an ECDSA coordinate on the curve
+func (a Algorithm) coordinateSize() int {
+	return (a.Curve.Params().BitSize + 7) / 8
+}
+
+func (a Algorithm) keyDescription(private bool) string {
+	kind := "public"
+	if private {
+		kind = "private"
+	}
+	switch a.Family {
+	case HMAC:
+		return "a shared secret"
+	case RSA, RSAPSS:
+		return "an RSA " + kind + " key"
+	case ECDSA:
+		return fmt.Sprintf("an ECDSA %s key on %s", kind, a.Curve.Params().Name)
+	case EdDSA:
+		return "an Ed25519 " + kind + " key"
+	}
+	return "a key"
+}
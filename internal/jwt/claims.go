@@ -0,0 +1,216 @@
+package jwt
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Claim is a payload member with an explanation of what it means
+type Claim struct {
+	Name        string
+	Value       string
+	Description string
+	Time        string // NumericDate claims as a UTC date
+	Status      string // expiry or validity, relative to now
+	Severity    string // "ok", "warning" or "danger" when Status is set
+}
+
+// claimDescriptions explains the registered claims (RFC 7519 section 4.1)
+// and the common OpenID Connect ones
+var claimDescriptions = map[string]string{
+	"iss":            "Issuer: who created and signed the token",
+	"sub":            "Subject: the principal the token is about",
+	"aud":            "Audience: who the token is intended for",
+	"exp":            "Expiration time: the token must be rejected after this",
+	"nbf":            "Not before: the token must be rejected before this",
+	"iat":            "Issued at: when the token was created",
+	"jti":            "JWT ID: a unique identifier, used to prevent replay",
+	"auth_time":      "Authentication time: when the user last logged in",
+	"nonce":          "Nonce: ties an ID token to the client's authentication request",
+	"azp":            "Authorized party: the client the token was issued to",
+	"scope":          "Scope: the permissions granted",
+	"scp":            "Scope: the permissions granted",
+	"client_id":      "Client ID: the OAuth client that requested the token",
+	"sid":            "Session ID",
+	"name":           "Full name of the user",
+	"email":          "Email address of the user",
+	"email_verified": "Whether the email address has been verified",
+	"roles":          "Roles granted to the subject",
+	"groups":         "Groups the subject belongs to",
+}
+
+// timeClaims hold NumericDate values (seconds since the Unix epoch)
+var timeClaims = map[string]bool{"exp": true, "nbf": true, "iat": true, "auth_time": true}
+
+// ExplainClaims explains every payload member, interpreting dates
+// relative to now
+func (t *Token) ExplainClaims(now time.Time) []Claim {
+	claims := make([]Claim, 0, len(t.Claims))
+	for _, m := range t.Claims {
+		c := Claim{
+			Name:        m.Name,
+			Value:       displayValue(m.Value),
+			Description: claimDescriptions[m.Name],
+		}
+		if timeClaims[m.Name] {
+			explainTime(&c, m.Value, now)
+		}
+		claims = append(claims, c)
+	}
+	return claims
+}
+
+// displayValue shows strings without quotes and everything else as JSON
+func displayValue(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	return string(raw)
+}
+
+func explainTime(c *Claim, raw json.RawMessage, now time.Time) {
+	var seconds float64
+	if err := json.Unmarshal(raw, &seconds); err != nil {
+		c.Status = "Not a NumericDate; must be seconds since 1970-01-01"
+		c.Severity = "danger"
+		return
+	}
+	if seconds > 1e11 {
+		c.Status = "Looks like milliseconds; JWT dates are in seconds"
+		c.Severity = "danger"
+		return
+	}
+
+	sec, frac := math.Modf(seconds)
+	at := time.Unix(int64(sec), int64(frac*1e9)).UTC()
+	c.Time = at.Format("Mon, 02 Jan 2006 15:04:05 UTC")
+	d := at.Sub(now)
+
+	switch c.Name {
+	case "exp":
+		if d <= 0 {
+			c.Status, c.Severity = "Expired "+relative(d), "danger"
+		} else {
+			c.Status, c.Severity = "Expires "+relative(d), "ok"
+		}
+	case "nbf":
+		if d > 0 {
+			c.Status, c.Severity = "Not valid yet; becomes valid "+relative(d), "warning"
+		} else {
+			c.Status, c.Severity = "Valid since "+relative(d), "ok"
+		}
+	default:
+		if d > time.Minute {
+			c.Status, c.Severity = "In the future ("+relative(d)+"); check the issuer's clock", "warning"
+		} else {
+			c.Status, c.Severity = relative(d), "ok"
+		}
+	}
+}
+
+// relative formats a duration as "in 3 hours" or "2 days ago"
+func relative(d time.Duration) string {
+	past := d < 0
+	if past {
+		d = -d
+	}
+
+	var amount string
+	switch {
+	case d < time.Minute:
+		amount = plural(int(d/time.Second), "second")
+	case d < time.Hour:
+		amount = plural(int(d/time.Minute), "minute")
+	case d < 48*time.Hour:
+		amount = plural(int(d/time.Hour), "hour")
+	case d < 60*24*time.Hour:
+		amount = plural(int(d/(24*time.Hour)), "day")
+	case d < 730*24*time.Hour:
+		amount = plural(int(d/(30*24*time.Hour)), "month")
+	default:
+		amount = plural(int(d/(365*24*time.Hour)), "year")
+	}
+	if past {
+		return amount + " ago"
+	}
+	return "in " + amount
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+// Finding is a security observation about a token or key
+type Finding struct {
+	Severity string // "danger", "warning" or "info"
+	Message  string
+}
+
+// Findings flags risky header parameters and claims
+func (t *Token) Findings() []Finding {
+	var findings []Finding
+	if strings.EqualFold(t.Alg, "none") {
+		findings = append(findings, Finding{"danger",
+			`alg is "none": the token is unsigned and anyone can forge it. Never accept unsecured tokens`})
+		if len(t.Signature) > 0 {
+			findings = append(findings, Finding{"danger", `alg is "none" yet a signature is present, a sign of tampering`})
+		}
+	} else if _, ok := LookupAlgorithm(t.Alg); !ok {
+		findings = append(findings, Finding{"warning", fmt.Sprintf("Unknown algorithm %q", t.Alg)})
+	}
+
+	var header map[string]json.RawMessage
+	if json.Unmarshal(t.Header, &header) == nil {
+		for _, name := range []string{"jku", "x5u", "jwk"} {
+			if _, ok := header[name]; ok {
+				findings = append(findings, Finding{"warning", fmt.Sprintf(
+					"Header carries %q; verifiers must not trust keys named by the token itself", name)})
+			}
+		}
+		if _, ok := header["crit"]; ok {
+			findings = append(findings, Finding{"info", `Header lists "crit" extensions that verifiers must understand`})
+		}
+	}
+
+	if _, ok := t.Claim("exp"); !ok {
+		findings = append(findings, Finding{"warning", `No "exp" claim: the token never expires`})
+	}
+	return findings
+}
+
+// weakSecrets are placeholder secrets from tutorials and defaults
+var weakSecrets = map[string]bool{
+	"secret": true, "your-256-bit-secret": true, "your-384-bit-secret": true,
+	"your-512-bit-secret": true, "password": true, "changeme": true, "secretkey": true,
+	"secret-key": true, "jwt-secret": true, "jwtsecret": true, "mysecret": true,
+	"my-secret": true, "supersecret": true, "test": true, "key": true, "123456": true,
+}
+
+// KeyFindings flags weak keys and key types that do not fit the algorithm
+func KeyFindings(alg Algorithm, keys []Key) []Finding {
+	var findings []Finding
+	for _, k := range keys {
+		switch {
+		case alg.Family == HMAC && k.Public != nil:
+			findings = append(findings, Finding{"danger", fmt.Sprintf(
+				"The token claims %s but the key is a public key. Verifying HMAC with a public key is the classic algorithm confusion attack", alg.Name)})
+		case k.Secret != nil && weakSecrets[strings.ToLower(string(k.Secret))]:
+			findings = append(findings, Finding{"danger", "The secret is a well-known placeholder and trivially guessable"})
+		case k.Secret != nil && alg.Family == HMAC && len(k.Secret) < alg.Hash.Size():
+			findings = append(findings, Finding{"warning", fmt.Sprintf(
+				"The secret is %d bytes; RFC 7518 requires at least %d bytes for %s", len(k.Secret), alg.Hash.Size(), alg.Name)})
+		}
+		if pub, ok := k.Public.(*rsa.PublicKey); ok && pub.N.BitLen() < 2048 {
+			findings = append(findings, Finding{"danger", fmt.Sprintf("RSA key is only %d bits; use at least 2048", pub.N.BitLen())})
+		}
+	}
+	return findings
+}
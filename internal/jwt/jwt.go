@@ -0,0 +1,149 @@
+// Package jwt decodes, verifies and signs JSON Web Tokens in the compact JWS
+// serialisation (RFC 7519, RFC 7515). It works on raw JSON so the header and
+// claims are shown exactly as they appear in the token
+package jwt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrAlgNone is returned when asked to verify an unsecured token
+var ErrAlgNone = errors.New(`token uses alg "none" and carries no signature`)
+
+// Token is a decoded compact JWS
+type Token struct {
+	Raw       string
+	Header    json.RawMessage
+	Payload   json.RawMessage
+	Signature []byte
+
+	Alg string
+	Kid string
+	Typ string
+
+	// Claims are the top-level payload members in token order
+	Claims []Member
+}
+
+// Member is one top-level member of a JSON object, kept in document order
+type Member struct {
+	Name  string
+	Value json.RawMessage
+}
+
+// Parse splits a token into its segments and decodes the header and
+// payload. The signature is not checked
+func Parse(raw string) (*Token, error) {
+	raw = strings.TrimSpace(raw)
+	raw = strings.TrimPrefix(raw, "Bearer ")
+	parts := strings.Split(raw, ".")
+	switch len(parts) {
+	case 3:
+	case 5:
+		return nil, errors.New("this is an encrypted token (JWE); only signed tokens (JWS) can be decoded")
+	default:
+		return nil, fmt.Errorf("a JWT has 3 dot-separated segments, found %d", len(parts))
+	}
+
+	header, err := decodeSegment(parts[0], "header")
+	if err != nil {
+		return nil, err
+	}
+	payload, err := decodeSegment(parts[1], "payload")
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("signature is not valid Base64URL: %w", err)
+	}
+
+	tok := &Token{Raw: raw, Header: header, Payload: payload, Signature: signature}
+
+	var fields struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+		Typ string `json:"typ"`
+	}
+	if err := json.Unmarshal(header, &fields); err != nil {
+		return nil, fmt.Errorf("header is not a JSON object: %w", err)
+	}
+	if fields.Alg == "" {
+		return nil, errors.New(`header has no "alg" member`)
+	}
+	tok.Alg, tok.Kid, tok.Typ = fields.Alg, fields.Kid, fields.Typ
+
+	tok.Claims, err = objectMembers(payload)
+	if err != nil {
+		return nil, fmt.Errorf("payload is not a JSON object: %w", err)
+	}
+	return tok, nil
+}
+
+// SigningInput is the part of the token covered by the signature
+func (t *Token) SigningInput() string {
+	return t.Raw[:strings.LastIndexByte(t.Raw, '.')]
+}
+
+// Claim returns the raw value of a payload member
+func (t *Token) Claim(name string) (json.RawMessage, bool) {
+	for _, m := range t.Claims {
+		if m.Name == name {
+			return m.Value, true
+		}
+	}
+	return nil, false
+}
+
+// decodeSegment decodes a Base64URL segment that must hold JSON. Padded
+// segments are tolerated since some libraries emit them
+func decodeSegment(segment, name string) (json.RawMessage, error) {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(segment, "="))
+	if err != nil {
+		return nil, fmt.Errorf("%s is not valid Base64URL: %w", name, err)
+	}
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("%s does not decode to valid JSON", name)
+	}
+	return data, nil
+}
+
+// objectMembers lists the members of a JSON object in document order
+func objectMembers(data []byte) ([]Member, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return nil, errors.New("expected an object")
+	}
+
+	var members []Member
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		members = append(members, Member{Name: key.(string), Value: value})
+	}
+	return members, nil
+}
+
+// Indent pretty-prints raw JSON, returning it unchanged if it is invalid
+func Indent(data []byte) string {
+	var b bytes.Buffer
+	if err := json.Indent(&b, data, "", "  "); err != nil {
+		return string(data)
+	}
+	return b.String()
+}
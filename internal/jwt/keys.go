@@ -0,0 +1,347 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Key is signing or verification material: a shared secret, or a public
+// key with its private half when one was supplied
+type Key struct {
+	ID        string // "kid" from a JWK
+	Algorithm string // "alg" from a JWK
+	Source    string // where the key came from, for display
+	Secret    []byte
+	Public    crypto.PublicKey
+	Private   crypto.Signer
+}
+
+// Describe summarises the key type and size
+func (k Key) Describe() string {
+	var desc string
+	switch pub := k.Public.(type) {
+	case *rsa.PublicKey:
+		desc = fmt.Sprintf("RSA %d-bit", pub.N.BitLen())
+	case *ecdsa.PublicKey:
+		desc = "ECDSA " + pub.Curve.Params().Name
+	case ed25519.PublicKey:
+		desc = "Ed25519"
+	default:
+		desc = fmt.Sprintf("%d-byte secret", len(k.Secret))
+	}
+	if k.Private != nil {
+		desc += " private key"
+	} else if k.Public != nil {
+		desc += " public key"
+	}
+	if k.ID != "" {
+		desc += fmt.Sprintf(" (kid %q)", k.ID)
+	}
+	return desc
+}
+
+// IsKeyMaterial reports whether text looks like a PEM block or a JWK/JWKS
+// document rather than a shared secret
+func IsKeyMaterial(text string) bool {
+	text = strings.TrimSpace(text)
+	return strings.HasPrefix(text, "-----BEGIN") || strings.HasPrefix(text, "{")
+}
+
+// ParseKeys reads every key in a PEM bundle, a single JWK or a JWKS
+func ParseKeys(text string) ([]Key, error) {
+	text = strings.TrimSpace(text)
+	switch {
+	case strings.HasPrefix(text, "-----BEGIN"):
+		return parsePEM(text)
+	case strings.HasPrefix(text, "{"):
+		return parseJWKS(text)
+	}
+	return nil, errors.New("expected a PEM key or certificate, a JWK or a JWKS document")
+}
+
+func parsePEM(text string) ([]Key, error) {
+	var keys []Key
+	rest := []byte(text)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		key, err := pemKey(block)
+		if err != nil {
+			return nil, fmt.Errorf("%s block: %w", block.Type, err)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no PEM blocks found")
+	}
+	return keys, nil
+}
+
+func pemKey(block *pem.Block) (Key, error) {
+	key := Key{Source: "PEM " + strings.ToLower(block.Type)}
+	var parsed any
+	var err error
+	switch block.Type {
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+			parsed = cert.PublicKey
+		}
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "ENCRYPTED PRIVATE KEY":
+		return key, errors.New("encrypted private keys are not supported; decrypt it first")
+	default:
+		return key, errors.New("unsupported PEM block type")
+	}
+	if err != nil {
+		return key, err
+	}
+	return withKey(key, parsed)
+}
+
+// withKey stores a parsed public or private key, deriving the public half
+// from a private one
+func withKey(key Key, parsed any) (Key, error) {
+	switch k := parsed.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		key.Public = k
+	case *rsa.PrivateKey:
+		key.Private, key.Public = k, &k.PublicKey
+	case *ecdsa.PrivateKey:
+		key.Private, key.Public = k, &k.PublicKey
+	case ed25519.PrivateKey:
+		key.Private, key.Public = k, k.Public()
+	default:
+		return key, fmt.Errorf("unsupported key type %T", parsed)
+	}
+	return key, nil
+}
+
+// jwk holds the JWK members used by the supported key types (RFC 7517,
+// RFC 7518 section 6, RFC 8037)
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	D   string `json:"d"`
+	P   string `json:"p"`
+	Q   string `json:"q"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+func parseJWKS(text string) ([]Key, error) {
+	var set struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := json.Unmarshal([]byte(text), &set); err != nil {
+		return nil, fmt.Errorf("invalid JWK JSON: %w", err)
+	}
+	raw := set.Keys
+	if raw == nil {
+		raw = []json.RawMessage{json.RawMessage(text)}
+	}
+
+	var keys []Key
+	for i, r := range raw {
+		var j jwk
+		if err := json.Unmarshal(r, &j); err != nil {
+			return nil, fmt.Errorf("key %d: %w", i+1, err)
+		}
+		if j.Use == "enc" {
+			continue
+		}
+		key, err := j.key()
+		if err != nil {
+			return nil, fmt.Errorf("key %d (kid %q): %w", i+1, j.Kid, err)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("the JWKS contains no signing keys")
+	}
+	return keys, nil
+}
+
+func (j jwk) key() (Key, error) {
+	key := Key{ID: j.Kid, Algorithm: j.Alg, Source: "JWK"}
+	switch j.Kty {
+	case "oct":
+		secret, err := b64(j.K, "k")
+		key.Secret = secret
+		return key, err
+	case "RSA":
+		return j.rsaKey(key)
+	case "EC":
+		return j.ecKey(key)
+	case "OKP":
+		return j.okpKey(key)
+	case "":
+		return key, errors.New(`missing "kty"`)
+	}
+	return key, fmt.Errorf("unsupported key type %q", j.Kty)
+}
+
+func (j jwk) rsaKey(key Key) (Key, error) {
+	n, err := b64Int(j.N, "n")
+	if err != nil {
+		return key, err
+	}
+	e, err := b64Int(j.E, "e")
+	if err != nil {
+		return key, err
+	}
+	if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+		return key, errors.New("unusable RSA exponent")
+	}
+	pub := &rsa.PublicKey{N: n, E: int(e.Int64())}
+	if j.D == "" {
+		key.Public = pub
+		return key, nil
+	}
+
+	d, err := b64Int(j.D, "d")
+	if err != nil {
+		return key, err
+	}
+	p, err := b64Int(j.P, "p")
+	if err != nil {
+		return key, err
+	}
+	q, err := b64Int(j.Q, "q")
+	if err != nil {
+		return key, err
+	}
+	priv := &rsa.PrivateKey{PublicKey: *pub, D: d, Primes: []*big.Int{p, q}}
+	if err := priv.Validate(); err != nil {
+		return key, err
+	}
+	priv.Precompute()
+	return withKey(key, priv)
+}
+
+func (j jwk) ecKey(key Key) (Key, error) {
+	curves := map[string]struct {
+		elliptic.Curve
+		dh ecdh.Curve
+	}{
+		"P-256": {elliptic.P256(), ecdh.P256()},
+		"P-384": {elliptic.P384(), ecdh.P384()},
+		"P-521": {elliptic.P521(), ecdh.P521()},
+	}
+	c, ok := curves[j.Crv]
+	if !ok {
+		return key, fmt.Errorf("unsupported curve %q", j.Crv)
+	}
+	curve := c.Curve
+	x, err := b64Int(j.X, "x")
+	if err != nil {
+		return key, err
+	}
+	y, err := b64Int(j.Y, "y")
+	if err != nil {
+		return key, err
+	}
+	if !curve.IsOnCurve(x, y) {
+		return key, errors.New("point is not on the curve")
+	}
+	pub := &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+	if j.D == "" {
+		key.Public = pub
+		return key, nil
+	}
+
+	d, err := b64Int(j.D, "d")
+	if err != nil {
+		return key, err
+	}
+	// The signer only uses d, so a d that does not match x and y would sign
+	// tokens the stated public key can never verify
+	size := (curve.Params().BitSize + 7) / 8
+	var priv *ecdh.PrivateKey
+	if d.BitLen() <= size*8 {
+		priv, _ = c.dh.NewPrivateKey(d.FillBytes(make([]byte, size))) // fails unless 0 < d < N
+	}
+	if priv == nil {
+		return key, errors.New("private key is out of range for the curve")
+	}
+	point := priv.PublicKey().Bytes() // 0x04 || X || Y
+	if new(big.Int).SetBytes(point[1:1+size]).Cmp(x) != 0 || new(big.Int).SetBytes(point[1+size:]).Cmp(y) != 0 {
+		return key, errors.New("private key does not match the public key x and y")
+	}
+	return withKey(key, &ecdsa.PrivateKey{PublicKey: *pub, D: d})
+}
+
+func (j jwk) okpKey(key Key) (Key, error) {
+	if j.Crv != "Ed25519" {
+		return key, fmt.Errorf("unsupported curve %q", j.Crv)
+	}
+	x, err := b64(j.X, "x")
+	if err != nil {
+		return key, err
+	}
+	if len(x) != ed25519.PublicKeySize {
+		return key, errors.New("Ed25519 public keys are 32 bytes")
+	}
+	if j.D == "" {
+		key.Public = ed25519.PublicKey(x)
+		return key, nil
+	}
+
+	d, err := b64(j.D, "d")
+	if err != nil {
+		return key, err
+	}
+	if len(d) != ed25519.SeedSize {
+		return key, errors.New("Ed25519 private keys are 32 bytes")
+	}
+	return withKey(key, ed25519.NewKeyFromSeed(d))
+}
+
+func b64(value, member string) ([]byte, error) {
+	if value == "" {
+		return nil, fmt.Errorf("missing %q", member)
+	}
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+	if err != nil {
+		return nil, fmt.Errorf("%q is not valid Base64URL", member)
+	}
+	return data, nil
+}
+
+func b64Int(value, member string) (*big.Int, error) {
+	data, err := b64(value, member)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
@@ -0,0 +1,124 @@
+package jwt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Verify checks the token signature against the candidate keys and returns
+// the key that verified it. When the token names a kid that is present in
+// the key set, only that key is tried
+func Verify(t *Token, keys []Key) (Key, error) {
+	if strings.EqualFold(t.Alg, "none") {
+		return Key{}, ErrAlgNone
+	}
+	alg, ok := LookupAlgorithm(t.Alg)
+	if !ok {
+		return Key{}, fmt.Errorf("unsupported algorithm %q", t.Alg)
+	}
+
+	candidates := keys
+	if t.Kid != "" {
+		var byID []Key
+		for _, k := range keys {
+			if k.ID == t.Kid {
+				byID = append(byID, k)
+			}
+		}
+		if len(byID) > 0 {
+			candidates = byID
+		}
+	}
+
+	var suitable []Key
+	for _, k := range candidates {
+		if (k.Algorithm == "" || k.Algorithm == alg.Name) && alg.Accepts(k) {
+			suitable = append(suitable, k)
+		}
+	}
+	if len(suitable) == 0 {
+		return Key{}, fmt.Errorf("no supplied key can verify %s; it needs %s", alg.Name, alg.keyDescription(false))
+	}
+
+	input := []byte(t.SigningInput())
+	for _, k := range suitable {
+		if alg.Verify(k, input, t.Signature) == nil {
+			return k, nil
+		}
+	}
+	if len(suitable) > 1 {
+		return Key{}, fmt.Errorf("signature does not match any of the %d candidate keys", len(suitable))
+	}
+	return Key{}, errors.New("signature does not match")
+}
+
+// Sign builds a compact JWS over a JSON object payload. The payload keeps
+// its member order; only insignificant whitespace is removed
+func Sign(alg Algorithm, key Key, kid string, payload []byte) (string, error) {
+	if _, err := objectMembers(payload); err != nil {
+		return "", fmt.Errorf("payload must be a JSON object: %w", err)
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, payload); err != nil {
+		return "", fmt.Errorf("payload is not valid JSON: %w", err)
+	}
+
+	header, err := json.Marshal(struct {
+		Alg string `json:"alg"`
+		Typ string `json:"typ"`
+		Kid string `json:"kid,omitempty"`
+	}{alg.Name, "JWT", kid})
+	if err != nil {
+		return "", err
+	}
+
+	input := base64.RawURLEncoding.EncodeToString(header) + "." +
+		base64.RawURLEncoding.EncodeToString(compact.Bytes())
+	sig, err := alg.Sign(key, []byte(input))
+	if err != nil {
+		return "", err
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// SetClaim sets a member of a JSON object payload, replacing it in place if
+// present and appending it otherwise, so the other members keep their order
+func SetClaim(payload []byte, name string, value any) ([]byte, error) {
+	members, err := objectMembers(payload)
+	if err != nil {
+		return nil, fmt.Errorf("payload must be a JSON object: %w", err)
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	replaced := false
+	for i := range members {
+		if members[i].Name == name {
+			members[i].Value = encoded
+			replaced = true
+		}
+	}
+	if !replaced {
+		members = append(members, Member{Name: name, Value: encoded})
+	}
+
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, m := range members {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(m.Name)
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(m.Value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
@@ -0,0 +1,90 @@
+package passgen
+
+import (
+	_ "embed"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	MinWords = 3
+	MaxWords = 20
+)
+
+// wordlist.txt maps four dice rolls to a word, so passphrases can also be
+// made offline with real dice
+//
+//go:embed wordlist.txt
+var wordlistFile string
+
+var words, rolls = parseWordlist(wordlistFile)
+
+func parseWordlist(text string) (words, rolls []string) {
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		roll, word, _ := strings.Cut(line, "\t")
+		rolls = append(rolls, roll)
+		words = append(words, word)
+	}
+	return words, rolls
+}
+
+// WordlistSize is the number of words a passphrase word is drawn from
+func WordlistSize() int {
+	return len(words)
+}
+
+// PassphraseOptions controls diceware generation
+type PassphraseOptions struct {
+	Words      int
+	Separator  string
+	Capitalize bool
+	AddNumber  bool // append a random digit to one random word
+}
+
+// Passphrase is a generated passphrase with the dice rolls behind each word
+type Passphrase struct {
+	Value   string
+	Words   []string
+	Rolls   []string
+	Entropy float64 // bits
+}
+
+// GeneratePassphrase picks words uniformly from the embedded wordlist
+func GeneratePassphrase(opts PassphraseOptions) (Passphrase, error) {
+	if opts.Words < MinWords || opts.Words > MaxWords {
+		return Passphrase{}, fmt.Errorf("word count must be between %d and %d", MinWords, MaxWords)
+	}
+
+	p := Passphrase{Entropy: float64(opts.Words) * math.Log2(float64(len(words)))}
+	parts := make([]string, opts.Words)
+	for i := range parts {
+		n, err := randInt(len(words))
+		if err != nil {
+			return Passphrase{}, err
+		}
+		p.Words = append(p.Words, words[n])
+		p.Rolls = append(p.Rolls, rolls[n])
+		parts[i] = words[n]
+		if opts.Capitalize {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+
+	if opts.AddNumber {
+		at, err := randInt(len(parts))
+		if err != nil {
+			return Passphrase{}, err
+		}
+		digit, err := randInt(10)
+		if err != nil {
+			return Passphrase{}, err
+		}
+		parts[at] += strconv.Itoa(digit)
+		p.Entropy += math.Log2(float64(10 * len(parts)))
+	}
+
+	p.Value = strings.Join(parts, opts.Separator)
+	return p, nil
+}
@@ -0,0 +1,185 @@
+// Package passgen generates random passwords and diceware passphrases from
+// crypto/rand and estimates the strength of existing passwords
+package passgen
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+const (
+	MinLength = 4
+	MaxLength = 256
+	MaxCount  = 50
+)
+
+// ambiguous characters are easy to confuse when read or typed
+const ambiguous = "0O1lI|`'\"5S2Z8B"
+
+// Class is a set of characters a password can draw from
+type Class struct {
+	ID    string
+	Name  string
+	Chars string
+}
+
+// classes lists the character classes in display order
+var classes = []Class{
+	{ID: "lower", Name: "Lowercase (a-z)", Chars: "abcdefghijklmnopqrstuvwxyz"},
+	{ID: "upper", Name: "Uppercase (A-Z)", Chars: "ABCDEFGHIJKLMNOPQRSTUVWXYZ"},
+	{ID: "digits", Name: "Digits (0-9)", Chars: "0123456789"},
+	{ID: "symbols", Name: "Symbols (!@#...)", Chars: "!@#$%^&*()-_=+[]{};:,.<>/?~|`'\""},
+}
+
+// Classes returns every character class
+func Classes() []Class {
+	return append([]Class(nil), classes...)
+}
+
+// Options controls password generation
+type Options struct {
+	Length           int
+	Classes          []string // class IDs to draw from
+	Custom           string   // extra characters added to the pool
+	ExcludeAmbiguous bool
+	Exclude          string // further characters to leave out
+	RequireEach      bool   // at least one character from every chosen class
+}
+
+// Password is a generated password and the entropy of the process that
+// produced it
+type Password struct {
+	Value    string
+	PoolSize int
+	Entropy  float64 // bits, slightly high when every class is required
+}
+
+// Generate builds a random password. Characters are drawn uniformly from
+// the pool; with RequireEach one position per class is drawn from that class
+func Generate(opts Options) (Password, error) {
+	if opts.Length < MinLength || opts.Length > MaxLength {
+		return Password{}, fmt.Errorf("length must be between %d and %d", MinLength, MaxLength)
+	}
+
+	excluded := opts.Exclude
+	if opts.ExcludeAmbiguous {
+		excluded += ambiguous
+	}
+	keep := func(chars string) []rune {
+		var out []rune
+		seen := map[rune]bool{}
+		for _, c := range chars {
+			if !seen[c] && !strings.ContainsRune(excluded, c) {
+				seen[c] = true
+				out = append(out, c)
+			}
+		}
+		return out
+	}
+
+	var sets [][]rune
+	for _, id := range opts.Classes {
+		c, ok := lookupClass(id)
+		if !ok {
+			return Password{}, fmt.Errorf("unknown character class %q", id)
+		}
+		if set := keep(c.Chars); len(set) > 0 {
+			sets = append(sets, set)
+		}
+	}
+	if custom := keep(opts.Custom); len(custom) > 0 {
+		sets = append(sets, custom)
+	}
+
+	pool := keep(string(joinSets(sets)))
+	if len(pool) < 2 {
+		return Password{}, errors.New("choose at least one character class")
+	}
+	if opts.RequireEach && len(sets) > opts.Length {
+		return Password{}, fmt.Errorf("a %d-character password cannot contain all %d classes", opts.Length, len(sets))
+	}
+
+	out := make([]rune, opts.Length)
+	for i := range out {
+		c, err := pick(pool)
+		if err != nil {
+			return Password{}, err
+		}
+		out[i] = c
+	}
+
+	if opts.RequireEach {
+		// Overwrite distinct random positions, one per class
+		positions, err := permutation(opts.Length)
+		if err != nil {
+			return Password{}, err
+		}
+		for i, set := range sets {
+			c, err := pick(set)
+			if err != nil {
+				return Password{}, err
+			}
+			out[positions[i]] = c
+		}
+	}
+
+	return Password{
+		Value:    string(out),
+		PoolSize: len(pool),
+		Entropy:  float64(opts.Length) * math.Log2(float64(len(pool))),
+	}, nil
+}
+
+func lookupClass(id string) (Class, bool) {
+	for _, c := range classes {
+		if c.ID == id {
+			return c, true
+		}
+	}
+	return Class{}, false
+}
+
+func joinSets(sets [][]rune) []rune {
+	var all []rune
+	for _, s := range sets {
+		all = append(all, s...)
+	}
+	return all
+}
+
+// randInt returns a uniform integer in [0, n)
+func randInt(n int) (int, error) {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(v.Int64()), nil
+}
+
+func pick(set []rune) (rune, error) {
+	i, err := randInt(len(set))
+	if err != nil {
+		return 0, err
+	}
+	return set[i], nil
+}
+
+// permutation returns a random ordering of 0..n-1 (Fisher-Yates)
+func permutation(n int) ([]int, error) {
+	p := make([]int, n)
+	for i := range p {
+		p[i] = i
+	}
+	for i := n - 1; i > 0; i-- {
+		j, err := randInt(i + 1)
+		if err != nil {
+			return nil, err
+		}
+		p[i], p[j] = p[j], p[i]
+	}
+	return p, nil
+}
@@ -0,0 +1,459 @@
+package passgen
+
+import (
+	_ "embed"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxAnalyzeLength bounds the quadratic pattern search
+const MaxAnalyzeLength = 256
+
+// common_passwords.txt lists frequently leaked passwords, most common first
+//
+//go:embed common_passwords.txt
+var commonFile string
+
+var commonRank = func() map[string]int {
+	ranks := map[string]int{}
+	for i, p := range strings.Fields(commonFile) {
+		if _, ok := ranks[p]; !ok {
+			ranks[p] = i + 1
+		}
+	}
+	return ranks
+}()
+
+// leet undoes common character substitutions before the list lookup
+var leet = map[rune]rune{'0': 'o', '1': 'i', '3': 'e', '4': 'a', '@': 'a', '$': 's', '5': 's', '7': 't', '!': 'i', '+': 't'}
+
+// keyboardLines are rows, shifted rows and columns of a US QWERTY keyboard.
+// A walk is a run found in a line or its reverse
+var keyboardLines = []string{
+	"`1234567890-=", "~!@#$%^&*()_+",
+	"qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./",
+	"qwertyuiop{}|", "asdfghjkl:\"", "zxcvbnm<>?",
+	"1qaz", "2wsx", "3edc", "4rfv", "5tgb", "6yhn", "7ujm", "8ik,", "9ol.", "0p;/",
+	"!qaz", "@wsx", "#edc", "$rfv", "%tgb", "^yhn", "&ujm", "*ik<", "(ol>", ")p:?",
+}
+
+var separatedDate = regexp.MustCompile(`\d{4}[-/._]\d{1,2}[-/._]\d{1,2}|\d{1,2}[-/._]\d{1,2}[-/._](?:\d{4}|\d{2})`)
+
+// Match is a pattern found in a password and the guesses it is worth
+type Match struct {
+	Kind    string // "common", "keyboard", "sequence", "repeat" or "date"
+	Token   string
+	Start   int // rune offsets
+	End     int
+	Bits    float64
+	Message string
+}
+
+// Report is a password strength estimate
+type Report struct {
+	Length       int
+	PoolSize     int
+	NaiveEntropy float64 // length × log2(pool), what a generator would claim
+	Entropy      float64 // after accounting for the patterns found
+	Score        int     // 0 (very weak) to 4 (very strong)
+	Label        string
+	Matches      []Match
+	CrackTimes   []CrackTime
+	Suggestions  []string
+}
+
+// CrackTime is the expected time to guess the password in one scenario
+type CrackTime struct {
+	Scenario string
+	Rate     float64 // guesses per second
+	Time     string
+}
+
+var scenarios = []CrackTime{
+	{Scenario: "Online, rate limited", Rate: 10},
+	{Scenario: "Offline, slow hash (bcrypt, Argon2)", Rate: 1e4},
+	{Scenario: "Offline, fast hash (MD5, SHA-1) on GPUs", Rate: 1e10},
+}
+
+// Analyze estimates the strength of a password. Each position is either
+// covered by a detected pattern or brute forced from the character pool, and
+// the cheapest covering is taken as the attacker's strategy
+func Analyze(password string) (Report, error) {
+	if utf8.RuneCountInString(password) > MaxAnalyzeLength {
+		return Report{}, fmt.Errorf("passwords longer than %d characters are not analyzed", MaxAnalyzeLength)
+	}
+	runes := []rune(password)
+	n := len(runes)
+	report := Report{Length: n, PoolSize: poolSize(runes)}
+	if n == 0 {
+		report.Label = scoreLabels[0]
+		return report, nil
+	}
+	charBits := math.Log2(float64(report.PoolSize))
+	report.NaiveEntropy = float64(n) * charBits
+
+	matches := findMatches(runes, report.PoolSize)
+	ending := make([][]Match, n+1)
+	for _, m := range matches {
+		ending[m.End] = append(ending[m.End], m)
+	}
+
+	// best[i] is the cheapest way to guess the first i characters
+	best := make([]float64, n+1)
+	via := make([]*Match, n+1)
+	for i := 1; i <= n; i++ {
+		best[i] = best[i-1] + charBits
+		for k := range ending[i] {
+			m := &ending[i][k]
+			if cost := best[m.Start] + m.Bits; cost < best[i] {
+				best[i] = cost
+				via[i] = m
+			}
+		}
+	}
+	for i := n; i > 0; {
+		if m := via[i]; m != nil {
+			report.Matches = append([]Match{*m}, report.Matches...)
+			i = m.Start
+		} else {
+			i--
+		}
+	}
+
+	report.Entropy = math.Min(best[n], report.NaiveEntropy)
+	report.Score, report.Label = Rate(report.Entropy)
+	for _, s := range scenarios {
+		// On average half the space is searched
+		s.Time = humanDuration(math.Pow(2, report.Entropy-1) / s.Rate)
+		report.CrackTimes = append(report.CrackTimes, s)
+	}
+	report.Suggestions = suggestions(report)
+	return report, nil
+}
+
+var scoreLabels = []string{"Very weak", "Weak", "Fair", "Strong", "Very strong"}
+
+// Rate turns bits of entropy into a 0-4 score and its label
+func Rate(bits float64) (int, string) {
+	score := 4
+	switch {
+	case bits < 28:
+		score = 0
+	case bits < 36:
+		score = 1
+	case bits < 60:
+		score = 2
+	case bits < 80:
+		score = 3
+	}
+	return score, scoreLabels[score]
+}
+
+// poolSize sums the sizes of the character classes present
+func poolSize(runes []rune) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range runes {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < utf8.RuneSelf:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+	size := 0
+	for _, c := range []struct {
+		present bool
+		size    int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if c.present {
+			size += c.size
+		}
+	}
+	return max(size, 2)
+}
+
+func findMatches(runes []rune, pool int) []Match {
+	var matches []Match
+	matches = append(matches, commonMatches(runes)...)
+	matches = append(matches, keyboardMatches(runes)...)
+	matches = append(matches, sequenceMatches(runes)...)
+	matches = append(matches, repeatMatches(runes, pool)...)
+	matches = append(matches, dateMatches(runes)...)
+	return matches
+}
+
+// commonMatches finds common passwords anywhere in the password, after
+// lowercasing and undoing leetspeak
+func commonMatches(runes []rune) []Match {
+	plain := make([]rune, len(runes))
+	for i, r := range runes {
+		if l, ok := leet[r]; ok {
+			plain[i] = l
+		} else {
+			plain[i] = unicode.ToLower(r)
+		}
+	}
+
+	var matches []Match
+	for i := range runes {
+		for j := i + 1; j <= len(runes); j++ {
+			whole := i == 0 && j == len(runes)
+			if j-i < 4 && !whole {
+				continue
+			}
+			// The digits of "123456" would otherwise turn into letters
+			rank, ok := commonRank[strings.ToLower(string(runes[i:j]))]
+			substituted := false
+			if !ok {
+				rank, ok = commonRank[string(plain[i:j])]
+				substituted = ok
+			}
+			if !ok {
+				continue
+			}
+			token := string(runes[i:j])
+			bits := math.Log2(float64(rank + 1))
+			if token != strings.ToLower(token) {
+				bits++
+			}
+			if substituted {
+				bits++
+			}
+			msg := fmt.Sprintf("%q is #%d on the common password list", token, rank)
+			if substituted {
+				msg += " (letter substitutions do not help)"
+			}
+			matches = append(matches, Match{Kind: "common", Token: token, Start: i, End: j, Bits: bits, Message: msg})
+		}
+	}
+	return matches
+}
+
+func keyboardMatches(runes []rune) []Match {
+	var lines []string
+	for _, l := range keyboardLines {
+		lines = append(lines, l, reverse(l))
+	}
+	inLine := func(s string) bool {
+		for _, l := range lines {
+			if strings.Contains(l, s) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var matches []Match
+	for i := 0; i < len(runes); {
+		j := i + 1
+		for j < len(runes) && inLine(strings.ToLower(string(runes[i:j+1]))) {
+			j++
+		}
+		if j-i >= 4 {
+			token := string(runes[i:j])
+			matches = append(matches, Match{
+				Kind: "keyboard", Token: token, Start: i, End: j,
+				// Starting key and direction, then the length
+				Bits:    math.Log2(float64(2*len(keyboardLines)*13)) + math.Log2(float64(j-i)),
+				Message: fmt.Sprintf("%q is a keyboard walk", token),
+			})
+			i = j
+		} else {
+			i++
+		}
+	}
+	return matches
+}
+
+// sequenceMatches finds runs like "abc", "9876" or "mnop"
+func sequenceMatches(runes []rune) []Match {
+	var matches []Match
+	for i := 0; i+2 < len(runes); {
+		delta := runes[i+1] - runes[i]
+		j := i + 1
+		if (delta == 1 || delta == -1) && unicode.IsLetter(runes[i]) == unicode.IsLetter(runes[i+1]) {
+			for j+1 < len(runes) && runes[j+1]-runes[j] == delta && unicode.IsLetter(runes[j+1]) == unicode.IsLetter(runes[i]) {
+				j++
+			}
+		}
+		if j-i+1 >= 3 && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+			base := 26.0
+			if unicode.IsDigit(runes[i]) {
+				base = 10
+			}
+			token := string(runes[i : j+1])
+			matches = append(matches, Match{
+				Kind: "sequence", Token: token, Start: i, End: j + 1,
+				Bits:    math.Log2(base * 2 * float64(j-i+1)),
+				Message: fmt.Sprintf("%q is an easy to guess sequence", token),
+			})
+			i = j + 1
+		} else {
+			i++
+		}
+	}
+	return matches
+}
+
+// repeatMatches finds repeated characters ("aaaa") and repeated chunks
+// ("abcabc"); a repeat costs the first copy plus the repeat count
+func repeatMatches(runes []rune, pool int) []Match {
+	var matches []Match
+	charBits := math.Log2(float64(pool))
+	for i := range runes {
+		for unit := 1; i+2*unit <= len(runes); unit++ {
+			count := 1
+			for i+(count+1)*unit <= len(runes) && string(runes[i+count*unit:i+(count+1)*unit]) == string(runes[i:i+unit]) {
+				count++
+			}
+			if count < 2 || (unit == 1 && count < 3) {
+				continue
+			}
+			end := i + count*unit
+			token := string(runes[i:end])
+			matches = append(matches, Match{
+				Kind: "repeat", Token: token, Start: i, End: end,
+				Bits:    float64(unit)*charBits + math.Log2(float64(count)),
+				Message: fmt.Sprintf("%q repeats %q %d times", token, string(runes[i:i+unit]), count),
+			})
+		}
+	}
+	return matches
+}
+
+// dateMatches finds years and dates with or without separators
+func dateMatches(runes []rune) []Match {
+	var matches []Match
+	dateBits := math.Log2(366 * 150)
+	add := func(start, end int, bits float64, what string) {
+		token := string(runes[start:end])
+		matches = append(matches, Match{
+			Kind: "date", Token: token, Start: start, End: end, Bits: bits,
+			Message: fmt.Sprintf("%q looks like %s", token, what),
+		})
+	}
+
+	digitsAt := func(i, n int) (string, bool) {
+		if i+n > len(runes) {
+			return "", false
+		}
+		for _, r := range runes[i : i+n] {
+			if r < '0' || r > '9' {
+				return "", false
+			}
+		}
+		return string(runes[i : i+n]), true
+	}
+	for i := range runes {
+		if s, ok := digitsAt(i, 8); ok && (validDate(s[:4], s[4:6], s[6:]) || validDate(s[4:], s[2:4], s[:2]) || validDate(s[4:], s[:2], s[2:4])) {
+			add(i, i+8, dateBits, "a date")
+		}
+		if s, ok := digitsAt(i, 6); ok && (validDate(s[4:], s[2:4], s[:2]) || validDate(s[4:], s[:2], s[2:4]) || validDate(s[:2], s[2:4], s[4:])) {
+			add(i, i+6, dateBits, "a date")
+		}
+		if s, ok := digitsAt(i, 4); ok {
+			if y, _ := strconv.Atoi(s); y >= 1900 && y < 2050 {
+				add(i, i+4, math.Log2(150), "a year")
+			}
+		}
+	}
+
+	s := string(runes)
+	for _, loc := range separatedDate.FindAllStringIndex(s, -1) {
+		parts := strings.FieldsFunc(s[loc[0]:loc[1]], func(r rune) bool { return strings.ContainsRune("-/._", r) })
+		if validDate(parts[0], parts[1], parts[2]) || validDate(parts[2], parts[1], parts[0]) || validDate(parts[2], parts[0], parts[1]) {
+			start := utf8.RuneCountInString(s[:loc[0]])
+			add(start, start+utf8.RuneCountInString(s[loc[0]:loc[1]]), dateBits+2, "a date")
+		}
+	}
+	return matches
+}
+
+// validDate accepts two or four digit years
+func validDate(year, month, day string) bool {
+	y, err1 := strconv.Atoi(year)
+	m, err2 := strconv.Atoi(month)
+	d, err3 := strconv.Atoi(day)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return false
+	}
+	if len(year) == 4 && (y < 1900 || y >= 2050) {
+		return false
+	}
+	return len(year) != 3 && len(year) != 1 && m >= 1 && m <= 12 && d >= 1 && d <= 31
+}
+
+func suggestions(r Report) []string {
+	if r.Score == len(scoreLabels)-1 {
+		return nil
+	}
+	var out []string
+	if r.Length < 12 {
+		out = append(out, "Use at least 12 characters; length adds more strength than symbols")
+	}
+	kinds := map[string]bool{}
+	for _, m := range r.Matches {
+		kinds[m.Kind] = true
+	}
+	if kinds["common"] {
+		out = append(out, "Avoid common passwords and words, even with capitals or substitutions like @ for a")
+	}
+	if kinds["keyboard"] || kinds["sequence"] || kinds["repeat"] {
+		out = append(out, "Avoid keyboard walks, sequences and repeated characters")
+	}
+	if kinds["date"] {
+		out = append(out, "Avoid dates and years, which are easy to guess from personal details")
+	}
+	if r.Score < 3 {
+		out = append(out, "A generated password or a passphrase of five or more random words is far stronger")
+	}
+	return out
+}
+
+func reverse(s string) string {
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
+
+// humanDuration describes a number of seconds
+func humanDuration(seconds float64) string {
+	units := []struct {
+		one, many string
+		size      float64
+	}{
+		{"century", "centuries", 100 * 365.25 * 86400},
+		{"year", "years", 365.25 * 86400},
+		{"month", "months", 30.44 * 86400},
+		{"day", "days", 86400},
+		{"hour", "hours", 3600},
+		{"minute", "minutes", 60},
+		{"second", "seconds", 1},
+	}
+	if seconds >= 1e6*units[0].size {
+		return "millions of centuries"
+	}
+	for _, u := range units {
+		if seconds >= u.size {
+			n := int(seconds / u.size)
+			if n == 1 {
+				return "1 " + u.one
+			}
+			return fmt.Sprintf("%d %s", n, u.many)
+		}
+	}
+	return "instant"
+}
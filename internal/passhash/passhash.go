@@ -0,0 +1,514 @@
+// Package passhash generates and verifies password hashes with Argon2id,
+// scrypt, PBKDF2 and bcrypt, encoded as PHC strings
+// (https://github.com/P-H-C/phc-string-format)
+package passhash
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Limits keep a single request from exhausting the server. They apply to
+// hashes being verified as well as generated, since the parameters of a
+// pasted hash are attacker controlled
+const (
+	MaxArgon2Memory      = 256 * 1024 // KiB
+	MaxArgon2Iterations  = 16
+	MaxArgon2Parallelism = 16
+	MaxScryptLogN        = 20
+	MaxScryptR           = 32
+	MaxScryptP           = 16
+	MaxScryptMemory      = 256 << 20 // bytes
+	MaxPBKDF2Iterations  = 10_000_000
+	MaxBcryptCost        = 16
+	MinSaltLength        = 8
+	MaxSaltLength        = 64
+	MinKeyLength         = 16
+	MaxKeyLength         = 64
+)
+
+var errUnknownFormat = errors.New("unrecognised hash format (expected $argon2id$, $scrypt$, $pbkdf2-sha256$ or bcrypt)")
+
+// pbkdf2Digests are the PRFs PBKDF2 can be used with
+var pbkdf2Digests = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// Argon2Params are the tunable Argon2id parameters. Memory is in KiB
+type Argon2Params struct {
+	Memory      int
+	Iterations  int
+	Parallelism int
+	SaltLength  int
+	KeyLength   int
+}
+
+// ScryptParams are the tunable scrypt parameters. N is 2^LogN
+type ScryptParams struct {
+	LogN       int
+	R          int
+	P          int
+	SaltLength int
+	KeyLength  int
+}
+
+// PBKDF2Params are the tunable PBKDF2 parameters
+type PBKDF2Params struct {
+	Digest     string
+	Iterations int
+	SaltLength int
+	KeyLength  int
+}
+
+// Defaults follow the OWASP password storage recommendations
+var (
+	DefaultArgon2 = Argon2Params{Memory: 64 * 1024, Iterations: 3, Parallelism: 4, SaltLength: 16, KeyLength: 32}
+	DefaultScrypt = ScryptParams{LogN: 17, R: 8, P: 1, SaltLength: 16, KeyLength: 32}
+	DefaultPBKDF2 = PBKDF2Params{Digest: "sha256", Iterations: 600_000, SaltLength: 16, KeyLength: 32}
+)
+
+// Param is a named parameter read from or written to a hash
+type Param struct {
+	Name  string
+	Value string
+}
+
+// Hash is a parsed password hash
+type Hash struct {
+	Algorithm string
+	Params    []Param
+	Salt      []byte
+	Key       []byte
+
+	argon2     *Argon2Params
+	argon2Type string
+	scrypt     *ScryptParams
+	pbkdf2     *PBKDF2Params
+	bcryptHash []byte
+}
+
+// Validate checks the parameters against the server limits
+func (p Argon2Params) Validate() error {
+	if err := p.validateCost(); err != nil {
+		return err
+	}
+	return validateLengths(p.SaltLength, p.KeyLength)
+}
+
+// validateCost checks only the resource parameters, so hashes made
+// elsewhere with unusual salt lengths can still be verified
+func (p Argon2Params) validateCost() error {
+	if p.Memory < 8*p.Parallelism || p.Memory > MaxArgon2Memory {
+		return fmt.Errorf("Argon2 memory must be between 8×parallelism and %d KiB", MaxArgon2Memory)
+	}
+	if p.Iterations < 1 || p.Iterations > MaxArgon2Iterations {
+		return fmt.Errorf("Argon2 iterations must be between 1 and %d", MaxArgon2Iterations)
+	}
+	if p.Parallelism < 1 || p.Parallelism > MaxArgon2Parallelism {
+		return fmt.Errorf("Argon2 parallelism must be between 1 and %d", MaxArgon2Parallelism)
+	}
+	return nil
+}
+
+// Validate checks the parameters against the server limits
+func (p ScryptParams) Validate() error {
+	if err := p.validateCost(); err != nil {
+		return err
+	}
+	return validateLengths(p.SaltLength, p.KeyLength)
+}
+
+// validateCost checks only the resource parameters
+func (p ScryptParams) validateCost() error {
+	if p.LogN < 1 || p.LogN > MaxScryptLogN {
+		return fmt.Errorf("scrypt log2(N) must be between 1 and %d", MaxScryptLogN)
+	}
+	if p.R < 1 || p.R > MaxScryptR {
+		return fmt.Errorf("scrypt r must be between 1 and %d", MaxScryptR)
+	}
+	if p.P < 1 || p.P > MaxScryptP {
+		return fmt.Errorf("scrypt p must be between 1 and %d", MaxScryptP)
+	}
+	if 128*p.R*(1<<p.LogN) > MaxScryptMemory {
+		return fmt.Errorf("scrypt parameters need more than %d MiB of memory", MaxScryptMemory>>20)
+	}
+	return nil
+}
+
+// Validate checks the parameters against the server limits
+func (p PBKDF2Params) Validate() error {
+	if _, ok := pbkdf2Digests[p.Digest]; !ok {
+		return fmt.Errorf("unsupported PBKDF2 digest %q", p.Digest)
+	}
+	if p.Iterations < 1 || p.Iterations > MaxPBKDF2Iterations {
+		return fmt.Errorf("PBKDF2 iterations must be between 1 and %d", MaxPBKDF2Iterations)
+	}
+	return validateLengths(p.SaltLength, p.KeyLength)
+}
+
+// validateLengths checks salt and key lengths in bytes
+func validateLengths(saltLength, keyLength int) error {
+	if saltLength < MinSaltLength || saltLength > MaxSaltLength {
+		return fmt.Errorf("salt length must be between %d and %d bytes", MinSaltLength, MaxSaltLength)
+	}
+	if keyLength < MinKeyLength || keyLength > MaxKeyLength {
+		return fmt.Errorf("key length must be between %d and %d bytes", MinKeyLength, MaxKeyLength)
+	}
+	return nil
+}
+
+// HashArgon2id hashes a password with Argon2id and a random salt
+func HashArgon2id(password []byte, p Argon2Params) (string, error) {
+	if err := p.Validate(); err != nil {
+		return "", err
+	}
+	salt, err := newSalt(p.SaltLength)
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey(password, salt, uint32(p.Iterations), uint32(p.Memory), uint8(p.Parallelism), uint32(p.KeyLength))
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism, b64(salt), b64(key)), nil
+}
+
+// HashScrypt hashes a password with scrypt and a random salt
+func HashScrypt(password []byte, p ScryptParams) (string, error) {
+	if err := p.Validate(); err != nil {
+		return "", err
+	}
+	salt, err := newSalt(p.SaltLength)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := scrypt.Key(password, salt, 1<<p.LogN, p.R, p.P, p.KeyLength)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("$scrypt$ln=%d,r=%d,p=%d$%s$%s", p.LogN, p.R, p.P, b64(salt), b64(key)), nil
+}
+
+// HashPBKDF2 hashes a password with PBKDF2 and a random salt
+func HashPBKDF2(password []byte, p PBKDF2Params) (string, error) {
+	if err := p.Validate(); err != nil {
+		return "", err
+	}
+	salt, err := newSalt(p.SaltLength)
+	if err != nil {
+		return "", err
+	}
+
+	key := pbkdf2.Key(password, salt, p.Iterations, p.KeyLength, pbkdf2Digests[p.Digest])
+	return fmt.Sprintf("$pbkdf2-%s$i=%d$%s$%s", p.Digest, p.Iterations, b64(salt), b64(key)), nil
+}
+
+// HashBcrypt hashes a password with bcrypt at the given cost
+func HashBcrypt(password []byte, cost int) (string, error) {
+	if cost < bcrypt.MinCost || cost > MaxBcryptCost {
+		return "", fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, MaxBcryptCost)
+	}
+	hashed, err := bcrypt.GenerateFromPassword(password, cost)
+	return string(hashed), err
+}
+
+// Parse reads the algorithm and parameters out of an encoded hash. Besides
+// PHC strings it accepts bcrypt, passlib's "$pbkdf2-sha256$29000$..." and
+// Django's "pbkdf2_sha256$..." formats
+func Parse(encoded string) (*Hash, error) {
+	encoded = strings.TrimSpace(encoded)
+	switch {
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		return parseBcrypt(encoded)
+	case strings.HasPrefix(encoded, "pbkdf2_"):
+		return parseDjangoPBKDF2(encoded)
+	case !strings.HasPrefix(encoded, "$"):
+		return nil, errUnknownFormat
+	}
+
+	fields := strings.Split(encoded[1:], "$")
+	id := fields[0]
+	switch {
+	case id == "argon2id" || id == "argon2i":
+		return parseArgon2(id, fields[1:])
+	case id == "scrypt":
+		return parseScrypt(fields[1:])
+	case strings.HasPrefix(id, "pbkdf2-"):
+		return parsePBKDF2(strings.TrimPrefix(id, "pbkdf2-"), fields[1:])
+	}
+	return nil, errUnknownFormat
+}
+
+// Verify checks a password against an encoded hash in constant time
+func Verify(password []byte, encoded string) (bool, *Hash, error) {
+	h, err := Parse(encoded)
+	if err != nil {
+		return false, nil, err
+	}
+
+	var key []byte
+	switch {
+	case h.bcryptHash != nil:
+		err := bcrypt.CompareHashAndPassword(h.bcryptHash, password)
+		if err != nil && !errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, h, err
+		}
+		return err == nil, h, nil
+	case h.argon2 != nil:
+		p := h.argon2
+		if h.argon2Type == "argon2i" {
+			key = argon2.Key(password, h.Salt, uint32(p.Iterations), uint32(p.Memory), uint8(p.Parallelism), uint32(len(h.Key)))
+		} else {
+			key = argon2.IDKey(password, h.Salt, uint32(p.Iterations), uint32(p.Memory), uint8(p.Parallelism), uint32(len(h.Key)))
+		}
+	case h.scrypt != nil:
+		p := h.scrypt
+		key, err = scrypt.Key(password, h.Salt, 1<<p.LogN, p.R, p.P, len(h.Key))
+		if err != nil {
+			return false, h, err
+		}
+	case h.pbkdf2 != nil:
+		p := h.pbkdf2
+		key = pbkdf2.Key(password, h.Salt, p.Iterations, len(h.Key), pbkdf2Digests[p.Digest])
+	}
+
+	return subtle.ConstantTimeCompare(key, h.Key) == 1, h, nil
+}
+
+// parseArgon2 reads "v=19$m=..,t=..,p=..$salt$hash"
+func parseArgon2(id string, fields []string) (*Hash, error) {
+	if len(fields) != 4 {
+		return nil, fmt.Errorf("%s hash must have version, parameter, salt and hash fields", id)
+	}
+	if fields[0] != fmt.Sprintf("v=%d", argon2.Version) {
+		return nil, fmt.Errorf("unsupported Argon2 version %q (only v=%d is supported)", fields[0], argon2.Version)
+	}
+
+	values, err := phcParams(fields[1], "m", "t", "p")
+	if err != nil {
+		return nil, err
+	}
+	salt, key, err := saltAndKey(fields[2], fields[3])
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Argon2Params{Memory: values["m"], Iterations: values["t"], Parallelism: values["p"], SaltLength: len(salt), KeyLength: len(key)}
+	if err := p.validateCost(); err != nil {
+		return nil, err
+	}
+	return &Hash{
+		Algorithm: map[string]string{"argon2id": "Argon2id", "argon2i": "Argon2i"}[id],
+		Params: []Param{
+			{Name: "Version", Value: strconv.Itoa(argon2.Version)},
+			{Name: "Memory", Value: fmt.Sprintf("%d KiB", p.Memory)},
+			{Name: "Iterations", Value: strconv.Itoa(p.Iterations)},
+			{Name: "Parallelism", Value: strconv.Itoa(p.Parallelism)},
+			{Name: "Salt Length", Value: fmt.Sprintf("%d bytes", len(salt))},
+			{Name: "Key Length", Value: fmt.Sprintf("%d bytes", len(key))},
+		},
+		Salt:       salt,
+		Key:        key,
+		argon2:     p,
+		argon2Type: id,
+	}, nil
+}
+
+// parseScrypt reads "ln=..,r=..,p=..$salt$hash"
+func parseScrypt(fields []string) (*Hash, error) {
+	if len(fields) != 3 {
+		return nil, errors.New("scrypt hash must have parameter, salt and hash fields")
+	}
+
+	values, err := phcParams(fields[0], "ln", "r", "p")
+	if err != nil {
+		return nil, err
+	}
+	salt, key, err := saltAndKey(fields[1], fields[2])
+	if err != nil {
+		return nil, err
+	}
+
+	p := &ScryptParams{LogN: values["ln"], R: values["r"], P: values["p"], SaltLength: len(salt), KeyLength: len(key)}
+	if err := p.validateCost(); err != nil {
+		return nil, err
+	}
+	return &Hash{
+		Algorithm: "scrypt",
+		Params: []Param{
+			{Name: "N", Value: fmt.Sprintf("%d (2^%d)", 1<<p.LogN, p.LogN)},
+			{Name: "r", Value: strconv.Itoa(p.R)},
+			{Name: "p", Value: strconv.Itoa(p.P)},
+			{Name: "Memory", Value: fmt.Sprintf("%d KiB", 128*p.R*(1<<p.LogN)/1024)},
+			{Name: "Salt Length", Value: fmt.Sprintf("%d bytes", len(salt))},
+			{Name: "Key Length", Value: fmt.Sprintf("%d bytes", len(key))},
+		},
+		Salt:   salt,
+		Key:    key,
+		scrypt: p,
+	}, nil
+}
+
+// parsePBKDF2 reads the PHC form "i=..$salt$hash" and the passlib form
+// "29000$salt$hash", which uses '.' in place of '+' in its base64
+func parsePBKDF2(digest string, fields []string) (*Hash, error) {
+	if len(fields) != 3 {
+		return nil, errors.New("PBKDF2 hash must have parameter, salt and hash fields")
+	}
+
+	format := "PHC"
+	var iterations int
+	if n, err := strconv.Atoi(fields[0]); err == nil {
+		format = "passlib"
+		iterations = n
+		fields[1] = strings.ReplaceAll(fields[1], ".", "+")
+		fields[2] = strings.ReplaceAll(fields[2], ".", "+")
+	} else {
+		values, err := phcParams(fields[0], "i")
+		if err != nil {
+			return nil, err
+		}
+		iterations = values["i"]
+	}
+
+	salt, key, err := saltAndKey(fields[1], fields[2])
+	if err != nil {
+		return nil, err
+	}
+	return newPBKDF2Hash(digest, iterations, salt, key, format)
+}
+
+// parseDjangoPBKDF2 reads Django's "pbkdf2_sha256$iterations$salt$hash",
+// where the salt is used as plain text and the hash is padded base64
+func parseDjangoPBKDF2(encoded string) (*Hash, error) {
+	fields := strings.Split(encoded, "$")
+	if len(fields) != 4 {
+		return nil, errors.New("Django PBKDF2 hash must have algorithm, iterations, salt and hash fields")
+	}
+
+	iterations, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid PBKDF2 iteration count %q", fields[1])
+	}
+	key, err := base64.StdEncoding.DecodeString(fields[3])
+	if err != nil {
+		return nil, fmt.Errorf("hash is not valid base64: %v", err)
+	}
+
+	return newPBKDF2Hash(strings.TrimPrefix(fields[0], "pbkdf2_"), iterations, []byte(fields[2]), key, "Django")
+}
+
+// newPBKDF2Hash validates parsed PBKDF2 fields and describes them
+func newPBKDF2Hash(digest string, iterations int, salt, key []byte, format string) (*Hash, error) {
+	p := &PBKDF2Params{Digest: digest, Iterations: iterations, SaltLength: len(salt), KeyLength: len(key)}
+	if _, ok := pbkdf2Digests[digest]; !ok {
+		return nil, fmt.Errorf("unsupported PBKDF2 digest %q", digest)
+	}
+	if iterations < 1 || iterations > MaxPBKDF2Iterations {
+		return nil, fmt.Errorf("PBKDF2 iterations must be between 1 and %d", MaxPBKDF2Iterations)
+	}
+	if len(key) == 0 || len(key) > 2*MaxKeyLength {
+		return nil, errors.New("PBKDF2 hash has an invalid length")
+	}
+
+	return &Hash{
+		Algorithm: "PBKDF2-" + strings.ToUpper(digest),
+		Params: []Param{
+			{Name: "Format", Value: format},
+			{Name: "Iterations", Value: strconv.Itoa(iterations)},
+			{Name: "Salt Length", Value: fmt.Sprintf("%d bytes", len(salt))},
+			{Name: "Key Length", Value: fmt.Sprintf("%d bytes", len(key))},
+		},
+		Salt:   salt,
+		Key:    key,
+		pbkdf2: p,
+	}, nil
+}
+
+// parseBcrypt reads the cost out of a modular crypt bcrypt hash
+func parseBcrypt(encoded string) (*Hash, error) {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("invalid bcrypt hash: %v", err)
+	}
+	if cost > MaxBcryptCost {
+		return nil, fmt.Errorf("bcrypt cost %d is above the supported maximum of %d", cost, MaxBcryptCost)
+	}
+	return &Hash{
+		Algorithm: "bcrypt",
+		Params: []Param{
+			{Name: "Variant", Value: encoded[1:3]},
+			{Name: "Cost", Value: fmt.Sprintf("%d (2^%d rounds)", cost, cost)},
+		},
+		bcryptHash: []byte(encoded),
+	}, nil
+}
+
+// phcParams parses "k=v,k=v" and requires every named key to be present
+func phcParams(s string, required ...string) (map[string]int, error) {
+	values := make(map[string]int)
+	for _, pair := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid parameter %q", pair)
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %s must be a number", k)
+		}
+		values[k] = n
+	}
+
+	for _, k := range required {
+		if _, ok := values[k]; !ok {
+			return nil, fmt.Errorf("missing parameter %q", k)
+		}
+	}
+	return values, nil
+}
+
+// saltAndKey decodes the base64 salt and hash fields of a PHC string
+func saltAndKey(salt, key string) ([]byte, []byte, error) {
+	s, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(salt, "="))
+	if err != nil {
+		return nil, nil, fmt.Errorf("salt is not valid base64: %v", err)
+	}
+	k, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(key, "="))
+	if err != nil {
+		return nil, nil, fmt.Errorf("hash is not valid base64: %v", err)
+	}
+	if len(k) == 0 || len(k) > 2*MaxKeyLength {
+		return nil, nil, errors.New("hash has an invalid length")
+	}
+	return s, k, nil
+}
+
+// newSalt returns n random bytes
+func newSalt(n int) ([]byte, error) {
+	salt := make([]byte, n)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// b64 encodes bytes in the unpadded base64 used by PHC strings
+func b64(b []byte) string {
+	return base64.RawStdEncoding.EncodeToString(b)
+}
@@ -0,0 +1,274 @@
+// Package symcrypt encrypts data with an AEAD cipher and packs everything
+// needed to decrypt it, apart from the key, into a self-describing envelope.
+//
+// Envelope layout (all integers big-endian):
+//
+//	magic "O2XE" | version 1 | cipher | kdf
+//	kdf 1 (Argon2id): memory uint32 | iterations uint8 | parallelism uint8 | salt length uint8 | salt
+//	nonce length uint8 | nonce | ciphertext and tag
+//
+// The header is authenticated as additional data, so changing any parameter
+// makes decryption fail
+package symcrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+
+	"github.com/Ndeta100/orbit2x/internal/passhash"
+)
+
+const (
+	magic   = "O2XE"
+	version = 1
+
+	kdfRaw      = 0
+	kdfArgon2id = 1
+)
+
+// ErrDecrypt hides whether the key, the passphrase or the data was wrong
+var ErrDecrypt = errors.New("decryption failed: wrong key or passphrase, or the data was modified")
+
+// Cipher is a supported AEAD
+type Cipher struct {
+	ID        string
+	Name      string
+	KeySize   int
+	NonceSize int
+	code      byte
+	new       func(key []byte) (cipher.AEAD, error)
+}
+
+// ciphers lists the supported AEADs in display order
+var ciphers = []Cipher{
+	{ID: "aes-256-gcm", Name: "AES-256-GCM", KeySize: 32, NonceSize: 12, code: 1, new: newGCM},
+	{ID: "xchacha20-poly1305", Name: "XChaCha20-Poly1305", KeySize: chacha20poly1305.KeySize,
+		NonceSize: chacha20poly1305.NonceSizeX, code: 2, new: chacha20poly1305.NewX},
+}
+
+// Ciphers returns every supported cipher
+func Ciphers() []Cipher {
+	return append([]Cipher(nil), ciphers...)
+}
+
+// Lookup finds a cipher by ID
+func Lookup(id string) (Cipher, bool) {
+	for _, c := range ciphers {
+		if c.ID == id {
+			return c, true
+		}
+	}
+	return Cipher{}, false
+}
+
+func byCode(code byte) (Cipher, bool) {
+	for _, c := range ciphers {
+		if c.code == code {
+			return c, true
+		}
+	}
+	return Cipher{}, false
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Key is either a passphrase, stretched with Argon2id, or a raw key
+type Key struct {
+	Passphrase []byte
+	Raw        []byte
+	Argon2     passhash.Argon2Params
+}
+
+// Header describes an envelope
+type Header struct {
+	Cipher Cipher
+	KDF    string // "Argon2id" or "Raw key"
+	Argon2 passhash.Argon2Params
+	Salt   []byte
+	Nonce  []byte
+}
+
+// Encrypt seals plaintext and returns the envelope
+func Encrypt(c Cipher, key Key, plaintext []byte) ([]byte, Header, error) {
+	h := Header{Cipher: c, Nonce: make([]byte, c.NonceSize)}
+	if _, err := rand.Read(h.Nonce); err != nil {
+		return nil, h, err
+	}
+
+	var k []byte
+	if key.Raw != nil {
+		h.KDF = "Raw key"
+		k = key.Raw
+	} else {
+		h.KDF = "Argon2id"
+		h.Argon2 = key.Argon2
+		h.Argon2.KeyLength = c.KeySize
+		if err := h.Argon2.Validate(); err != nil {
+			return nil, h, err
+		}
+		h.Salt = make([]byte, h.Argon2.SaltLength)
+		if _, err := rand.Read(h.Salt); err != nil {
+			return nil, h, err
+		}
+		k = deriveKey(key.Passphrase, h)
+	}
+
+	aead, err := newAEAD(c, k)
+	if err != nil {
+		return nil, h, err
+	}
+	header := h.marshal()
+	return aead.Seal(header, h.Nonce, plaintext, header), h, nil
+}
+
+// Decrypt opens an envelope. The key must match the envelope's KDF: a
+// passphrase for Argon2id envelopes and a raw key otherwise
+func Decrypt(envelope []byte, key Key) ([]byte, Header, error) {
+	h, n, err := parseHeader(envelope)
+	if err != nil {
+		return nil, h, err
+	}
+
+	var k []byte
+	switch {
+	case h.KDF == "Raw key" && key.Raw == nil:
+		return nil, h, errors.New("this envelope was encrypted with a raw key, not a passphrase")
+	case h.KDF == "Raw key":
+		k = key.Raw
+	case key.Passphrase == nil:
+		return nil, h, errors.New("this envelope was encrypted with a passphrase, not a raw key")
+	default:
+		k = deriveKey(key.Passphrase, h)
+	}
+
+	aead, err := newAEAD(h.Cipher, k)
+	if err != nil {
+		return nil, h, err
+	}
+	plaintext, err := aead.Open(nil, h.Nonce, envelope[n:], envelope[:n])
+	if err != nil {
+		return nil, h, ErrDecrypt
+	}
+	return plaintext, h, nil
+}
+
+func newAEAD(c Cipher, key []byte) (cipher.AEAD, error) {
+	if len(key) != c.KeySize {
+		return nil, fmt.Errorf("%s needs a %d-byte key, got %d bytes", c.Name, c.KeySize, len(key))
+	}
+	return c.new(key)
+}
+
+func deriveKey(passphrase []byte, h Header) []byte {
+	p := h.Argon2
+	return argon2.IDKey(passphrase, h.Salt, uint32(p.Iterations), uint32(p.Memory), uint8(p.Parallelism), uint32(h.Cipher.KeySize))
+}
+
+func (h Header) marshal() []byte {
+	var b bytes.Buffer
+	b.WriteString(magic)
+	b.WriteByte(version)
+	b.WriteByte(h.Cipher.code)
+	if h.KDF == "Argon2id" {
+		b.WriteByte(kdfArgon2id)
+		binary.Write(&b, binary.BigEndian, uint32(h.Argon2.Memory))
+		b.WriteByte(byte(h.Argon2.Iterations))
+		b.WriteByte(byte(h.Argon2.Parallelism))
+		b.WriteByte(byte(len(h.Salt)))
+		b.Write(h.Salt)
+	} else {
+		b.WriteByte(kdfRaw)
+	}
+	b.WriteByte(byte(len(h.Nonce)))
+	b.Write(h.Nonce)
+	return b.Bytes()
+}
+
+// parseHeader reads and validates an envelope header, returning its length
+func parseHeader(data []byte) (Header, int, error) {
+	var h Header
+	r := bytes.NewReader(data)
+	truncated := errors.New("envelope is truncated")
+
+	prefix := make([]byte, len(magic)+3)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return h, 0, truncated
+	}
+	if string(prefix[:len(magic)]) != magic {
+		return h, 0, errors.New("not an encrypted envelope from this tool")
+	}
+	if prefix[4] != version {
+		return h, 0, fmt.Errorf("unsupported envelope version %d", prefix[4])
+	}
+	c, ok := byCode(prefix[5])
+	if !ok {
+		return h, 0, fmt.Errorf("unknown cipher %d", prefix[5])
+	}
+	h.Cipher = c
+
+	switch prefix[6] {
+	case kdfRaw:
+		h.KDF = "Raw key"
+	case kdfArgon2id:
+		h.KDF = "Argon2id"
+		var params struct {
+			Memory      uint32
+			Iterations  uint8
+			Parallelism uint8
+			SaltLength  uint8
+		}
+		if binary.Read(r, binary.BigEndian, &params) != nil {
+			return h, 0, truncated
+		}
+		h.Argon2 = passhash.Argon2Params{
+			Memory:      int(params.Memory),
+			Iterations:  int(params.Iterations),
+			Parallelism: int(params.Parallelism),
+			SaltLength:  int(params.SaltLength),
+			KeyLength:   c.KeySize,
+		}
+		// Refuse costs the encrypt side would not have produced
+		if err := h.Argon2.Validate(); err != nil {
+			return h, 0, fmt.Errorf("envelope has invalid Argon2 parameters: %w", err)
+		}
+		h.Salt = make([]byte, params.SaltLength)
+		if _, err := io.ReadFull(r, h.Salt); err != nil {
+			return h, 0, truncated
+		}
+	default:
+		return h, 0, fmt.Errorf("unknown key derivation %d", prefix[6])
+	}
+
+	nonceLen, err := r.ReadByte()
+	if err != nil {
+		return h, 0, truncated
+	}
+	if int(nonceLen) != c.NonceSize {
+		return h, 0, fmt.Errorf("%s nonces are %d bytes, envelope has %d", c.Name, c.NonceSize, nonceLen)
+	}
+	h.Nonce = make([]byte, nonceLen)
+	if _, err := io.ReadFull(r, h.Nonce); err != nil {
+		return h, 0, truncated
+	}
+
+	// Every AEAD here appends a 16-byte tag
+	if r.Len() < 16 {
+		return h, 0, truncated
+	}
+	return h, len(data) - r.Len(), nil
+}
@@ -0,0 +1,367 @@
+// Package xlsx reads and writes Excel workbooks. An XLSX file is a zip of
+// SpreadsheetML parts, so the standard library's zip and XML packages are
+// all it takes for plain tabular data; formulas, merged cells and styling
+// beyond a bold header are out of scope.
+//
+// Cells are jsonfmt nodes: numbers keep their literal, booleans are
+// booleans, and dates are strings tagged jsonfmt.DateTime
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ndeta100/orbit2x/internal/jsonfmt"
+)
+
+// Sheet is one worksheet of rows of cells
+type Sheet struct {
+	Name   string
+	Rows   [][]*jsonfmt.Node // a nil cell is empty
+	Header bool              // the first row names the columns and is written bold
+}
+
+// maxUnzipped bounds the decompressed bytes of all the parts read, against
+// zip bombs
+const maxUnzipped = 64 << 20
+
+// maxCells bounds a workbook's cells once each sheet's rows are padded to
+// its widest, since a few bytes of sparse XML can reach far-off cells
+const maxCells = 1 << 22
+
+type workbookXML struct {
+	Pr struct {
+		Date1904 string `xml:"date1904,attr"`
+	} `xml:"workbookPr"`
+	Sheets []struct {
+		Name  string     `xml:"name,attr"`
+		Attrs []xml.Attr `xml:",any,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type relsXML struct {
+	Rels []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type richText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (rt richText) text() string {
+	if len(rt.Runs) == 0 {
+		return rt.T
+	}
+	var b strings.Builder
+	for _, r := range rt.Runs {
+		b.WriteString(r.T)
+	}
+	return b.String()
+}
+
+type stylesXML struct {
+	NumFmts []struct {
+		ID   int    `xml:"numFmtId,attr"`
+		Code string `xml:"formatCode,attr"`
+	} `xml:"numFmts>numFmt"`
+	CellXfs []struct {
+		NumFmtID int `xml:"numFmtId,attr"`
+	} `xml:"cellXfs>xf"`
+}
+
+type sheetXML struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			R      string   `xml:"r,attr"`
+			T      string   `xml:"t,attr"`
+			S      int      `xml:"s,attr"`
+			V      *string  `xml:"v"`
+			Inline richText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// Read gives every worksheet of an XLSX file, in workbook order
+func Read(data []byte) ([]Sheet, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("not an XLSX file: %v", err)
+	}
+	parts := map[string]*zip.File{}
+	for _, f := range zr.File {
+		parts[strings.TrimPrefix(f.Name, "/")] = f
+	}
+	unzipped := 0
+	read := func(name string, v any) (bool, error) {
+		f := parts[name]
+		if f == nil {
+			return false, nil
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return false, err
+		}
+		defer rc.Close()
+		body, err := io.ReadAll(io.LimitReader(rc, int64(maxUnzipped-unzipped+1)))
+		if err != nil {
+			return false, err
+		}
+		if unzipped += len(body); unzipped > maxUnzipped {
+			return false, fmt.Errorf("the workbook is larger than %d MB once unzipped", maxUnzipped>>20)
+		}
+		if err := xml.Unmarshal(body, v); err != nil {
+			return false, fmt.Errorf("%s: %v", name, err)
+		}
+		return true, nil
+	}
+
+	var wb workbookXML
+	if ok, err := read("xl/workbook.xml", &wb); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, fmt.Errorf("not an XLSX file: there is no xl/workbook.xml")
+	}
+	var rels relsXML
+	if _, err := read("xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+	targets := map[string]string{}
+	for _, rel := range rels.Rels {
+		if strings.HasPrefix(rel.Target, "/") {
+			targets[rel.ID] = strings.TrimPrefix(rel.Target, "/")
+		} else {
+			targets[rel.ID] = path.Join("xl", rel.Target)
+		}
+	}
+
+	var sst struct {
+		Items []richText `xml:"si"`
+	}
+	if _, err := read("xl/sharedStrings.xml", &sst); err != nil {
+		return nil, err
+	}
+	var styles stylesXML
+	if _, err := read("xl/styles.xml", &styles); err != nil {
+		return nil, err
+	}
+	custom := map[int]string{}
+	for _, f := range styles.NumFmts {
+		custom[f.ID] = f.Code
+	}
+	formats := make([]numberFormat, len(styles.CellXfs))
+	for i, xf := range styles.CellXfs {
+		formats[i] = formatKind(xf.NumFmtID, custom[xf.NumFmtID])
+	}
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	if wb.Pr.Date1904 == "1" || wb.Pr.Date1904 == "true" {
+		epoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	var sheets []Sheet
+	cellCount := 0                // in the sheets read so far, padded
+	readBy := map[string]string{} // worksheet parts read, by sheet name
+	for _, s := range wb.Sheets {
+		var target string
+		for _, a := range s.Attrs {
+			if a.Name.Local == "id" {
+				target = targets[a.Value]
+			}
+		}
+		if other, ok := readBy[target]; ok && target != "" {
+			return nil, fmt.Errorf("sheets %q and %q share one worksheet part", other, s.Name)
+		}
+		readBy[target] = s.Name
+		var ws sheetXML
+		if ok, err := read(target, &ws); err != nil {
+			return nil, err
+		} else if !ok {
+			return nil, fmt.Errorf("sheet %q has no worksheet part", s.Name)
+		}
+
+		sheet := Sheet{Name: s.Name}
+		width := 0
+		for _, row := range ws.Rows {
+			r := len(sheet.Rows)
+			if row.R > 0 {
+				r = row.R - 1
+			}
+			if r < len(sheet.Rows) {
+				r = len(sheet.Rows) // rows out of order; keep them in file order
+			}
+			cells := []*jsonfmt.Node{}
+			for _, c := range row.Cells {
+				col := len(cells)
+				if c.R != "" {
+					if parsed, ok := columnIndex(c.R); ok && parsed >= col {
+						col = parsed
+					}
+				}
+				if col >= maxColumns {
+					return nil, fmt.Errorf("sheet %q: cell %s is beyond the last column", s.Name, c.R)
+				}
+				format := plain
+				if c.S >= 0 && c.S < len(formats) {
+					format = formats[c.S]
+				}
+				v, err := cellValue(c.T, c.V, c.Inline, sst.Items, format, epoch)
+				if err != nil {
+					return nil, fmt.Errorf("sheet %q, cell %s: %v", s.Name, c.R, err)
+				}
+				for len(cells) < col {
+					cells = append(cells, nil)
+				}
+				cells = append(cells, v)
+			}
+			if r >= maxRows {
+				return nil, fmt.Errorf("sheet %q: row %d is beyond the last row", s.Name, r+1)
+			}
+			if width = max(width, len(cells)); cellCount+(r+1)*width > maxCells {
+				return nil, fmt.Errorf("sheet %q: the workbook has more than %d cells, counting the empty ones up to the last row and column", s.Name, maxCells)
+			}
+			for len(sheet.Rows) < r {
+				sheet.Rows = append(sheet.Rows, nil)
+			}
+			sheet.Rows = append(sheet.Rows, cells)
+		}
+		sheet.Rows = trim(sheet.Rows)
+		cellCount += len(sheet.Rows) * width
+		sheets = append(sheets, sheet)
+	}
+	return sheets, nil
+}
+
+// cellValue types one cell by its t attribute and, for numbers, its style
+func cellValue(t string, v *string, inline richText, shared []richText, format numberFormat, epoch time.Time) (*jsonfmt.Node, error) {
+	if t == "inlineStr" {
+		return jsonfmt.NewString(inline.text()), nil
+	}
+	if v == nil {
+		return nil, nil
+	}
+	switch t {
+	case "s":
+		i, err := strconv.Atoi(*v)
+		if err != nil || i < 0 || i >= len(shared) {
+			return nil, fmt.Errorf("shared string %q does not exist", *v)
+		}
+		return jsonfmt.NewString(shared[i].text()), nil
+	case "b":
+		return &jsonfmt.Node{Kind: jsonfmt.Bool, Value: strconv.FormatBool(*v == "1" || *v == "true")}, nil
+	case "str", "e":
+		return jsonfmt.NewString(*v), nil
+	case "d":
+		return &jsonfmt.Node{Kind: jsonfmt.String, Value: *v, Tag: jsonfmt.DateTime}, nil
+	}
+	literal := strings.TrimSpace(*v)
+	if literal == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(literal, 64)
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, fmt.Errorf("%q is not a number", literal)
+	}
+	if format != plain {
+		return &jsonfmt.Node{Kind: jsonfmt.String, Value: formatSerial(f, format, epoch), Tag: jsonfmt.DateTime}, nil
+	}
+	if !jsonfmt.IsNumber(literal) {
+		literal = strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return jsonfmt.NewNumber(literal), nil
+}
+
+// formatSerial writes a date serial as an ISO time when its format shows
+// only a time, and otherwise as a date, with the time when there is one
+func formatSerial(serial float64, format numberFormat, epoch time.Time) string {
+	days := math.Floor(serial)
+	seconds := math.Round((serial - days) * 86400)
+	if epoch.Year() == 1899 && serial < 61 {
+		// Excel counts a 29 February 1900 that never was, so serials before
+		// it are a day behind the ones after
+		days++
+	}
+	t := epoch.AddDate(0, 0, int(days)).Add(time.Duration(seconds) * time.Second)
+	switch {
+	case format == timeOnly:
+		return t.Format("15:04:05")
+	case seconds == 0:
+		return t.Format("2006-01-02")
+	}
+	return t.Format("2006-01-02T15:04:05")
+}
+
+// quotedOrBracketed matches the parts of a number format that are shown
+// literally or are not digits: quoted text, [colours] and escapes
+var quotedOrBracketed = regexp.MustCompile(`"[^"]*"|\[[^\]]*\]|\\.|_.|\*.`)
+
+// numberFormat is what a cell's number format makes of its number
+type numberFormat int
+
+const (
+	plain numberFormat = iota
+	dateTime
+	timeOnly
+)
+
+// formatKind sorts a number format by its built-in id or, for a custom
+// format, by the date and time letters in its code
+func formatKind(id int, code string) numberFormat {
+	switch {
+	case id >= 18 && id <= 21, id >= 45 && id <= 47:
+		return timeOnly
+	case id >= 14 && id <= 22, id >= 27 && id <= 36, id >= 50 && id <= 58:
+		return dateTime
+	case code == "":
+		return plain
+	}
+	code = strings.ToLower(quotedOrBracketed.ReplaceAllString(code, ""))
+	switch {
+	case strings.ContainsAny(code, "dy"), strings.Contains(code, "m") && !strings.ContainsAny(code, "hs"):
+		return dateTime
+	case strings.ContainsAny(code, "hs"):
+		return timeOnly
+	}
+	return plain
+}
+
+// columnIndex reads the column of a reference such as "AB12"
+func columnIndex(ref string) (int, bool) {
+	col := 0
+	i := 0
+	for i < len(ref) && ref[i] >= 'A' && ref[i] <= 'Z' {
+		col = col*26 + int(ref[i]-'A') + 1
+		i++
+	}
+	if i == 0 || col > maxColumns {
+		return 0, false
+	}
+	return col - 1, true
+}
+
+// trim drops trailing empty rows and empty cells at the ends of rows
+func trim(rows [][]*jsonfmt.Node) [][]*jsonfmt.Node {
+	for i, row := range rows {
+		for len(row) > 0 && row[len(row)-1] == nil {
+			row = row[:len(row)-1]
+		}
+		rows[i] = row
+	}
+	for len(rows) > 0 && len(rows[len(rows)-1]) == 0 {
+		rows = rows[:len(rows)-1]
+	}
+	return rows
+}
@@ -0,0 +1,311 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Ndeta100/orbit2x/internal/jsonfmt"
+)
+
+// Limits of the format
+const (
+	maxRows      = 1 << 20
+	maxColumns   = 1 << 14
+	maxCellText  = 32767
+	maxSheetName = 31
+	maxDigits    = 15 // significant digits a cell's double holds exactly
+)
+
+// Styles written to styles.xml, by index into cellXfs
+const (
+	styleNormal = iota
+	styleBold
+	styleDate
+	styleDateTime
+	styleTime
+)
+
+const stylesPart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm:ss"/></numFmts>` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="5">` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+	`<xf numFmtId="14" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="21" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`</cellXfs>` +
+	`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+	`</styleSheet>`
+
+// Write builds a workbook of sheets. Numbers, booleans and dates become
+// typed cells, nested values are written as JSON text, and a header row is
+// bold and frozen. What Excel cannot hold exactly is reported as a warning
+func Write(sheets []Sheet) ([]byte, []string, error) {
+	if len(sheets) == 0 {
+		return nil, nil, fmt.Errorf("a workbook needs at least one sheet")
+	}
+	var warnings []string
+	names := sheetNames(sheets, &warnings)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	add := func(name, body string) error {
+		f, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write([]byte(body))
+		return err
+	}
+
+	var types, workbook, rels strings.Builder
+	var bodies []string
+	types.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	workbook.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	rels.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+
+	for i, sheet := range sheets {
+		n := strconv.Itoa(i + 1)
+		types.WriteString(`<Override PartName="/xl/worksheets/sheet` + n + `.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`)
+		workbook.WriteString(`<sheet name="` + escape(names[i]) + `" sheetId="` + n + `" r:id="rId` + n + `"/>`)
+		rels.WriteString(`<Relationship Id="rId` + n + `" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet` + n + `.xml"/>`)
+
+		body, more, err := worksheet(sheet, names[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		warnings = append(warnings, more...)
+		bodies = append(bodies, body)
+	}
+	types.WriteString(`</Types>`)
+	workbook.WriteString(`</sheets></workbook>`)
+	rels.WriteString(`<Relationship Id="rId` + strconv.Itoa(len(sheets)+1) + `" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/></Relationships>`)
+
+	for _, part := range []struct{ name, body string }{
+		{"[Content_Types].xml", types.String()},
+		{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+		{"xl/workbook.xml", workbook.String()},
+		{"xl/_rels/workbook.xml.rels", rels.String()},
+		{"xl/styles.xml", stylesPart},
+	} {
+		if err := add(part.name, part.body); err != nil {
+			return nil, nil, err
+		}
+	}
+	for i, body := range bodies {
+		if err := add("xl/worksheets/sheet"+strconv.Itoa(i+1)+".xml", body); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), warnings, nil
+}
+
+// worksheet writes one sheet's part, with strings inline so the workbook
+// needs no shared string table
+func worksheet(sheet Sheet, name string) (string, []string, error) {
+	if len(sheet.Rows) > maxRows {
+		return "", nil, fmt.Errorf("sheet %q has %d rows, but Excel holds at most %d", name, len(sheet.Rows), maxRows)
+	}
+	var warnings []string
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	if sheet.Header && len(sheet.Rows) > 1 {
+		b.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	}
+	b.WriteString(`<sheetData>`)
+
+	for r, row := range sheet.Rows {
+		if len(row) > maxColumns {
+			return "", nil, fmt.Errorf("sheet %q, row %d has %d cells, but Excel holds at most %d", name, r+1, len(row), maxColumns)
+		}
+		b.WriteString(`<row r="` + strconv.Itoa(r+1) + `">`)
+		for c, v := range row {
+			if v == nil || v.Kind == jsonfmt.Null {
+				continue
+			}
+			ref := columnName(c) + strconv.Itoa(r+1)
+			style := styleNormal
+			if sheet.Header && r == 0 {
+				style = styleBold
+			}
+			attrs := ` r="` + ref + `"`
+
+			switch v.Kind {
+			case jsonfmt.Bool:
+				b.WriteString(`<c` + attrs + styleAttr(style) + ` t="b"><v>` + boolValue(v.Value) + `</v></c>`)
+				continue
+			case jsonfmt.Number:
+				if significantDigits(v.Value) <= maxDigits {
+					b.WriteString(`<c` + attrs + styleAttr(style) + `><v>` + v.Value + `</v></c>`)
+					continue
+				}
+				warnings = append(warnings, fmt.Sprintf("%s!%s: %s has more digits than Excel keeps, so it was written as text", name, ref, v.Value))
+			case jsonfmt.String:
+				if serial, dateStyle, ok := dateSerial(v); ok && style == styleNormal {
+					b.WriteString(`<c` + attrs + styleAttr(dateStyle) + `><v>` + serial + `</v></c>`)
+					continue
+				}
+			}
+
+			text := v.Value
+			if v.Kind == jsonfmt.Object || v.Kind == jsonfmt.Array {
+				text = strings.TrimSuffix(jsonfmt.Format(v, jsonfmt.Options{Minify: true}), "\n")
+			}
+			if utf8.RuneCountInString(text) > maxCellText {
+				text = string([]rune(text)[:maxCellText])
+				warnings = append(warnings, fmt.Sprintf("%s!%s is longer than the %d characters a cell holds, so it was cut", name, ref, maxCellText))
+			}
+			b.WriteString(`<c` + attrs + styleAttr(style) + ` t="inlineStr"><is><t xml:space="preserve">` + escape(text) + `</t></is></c>`)
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	return b.String(), warnings, nil
+}
+
+func styleAttr(style int) string {
+	if style == styleNormal {
+		return ""
+	}
+	return ` s="` + strconv.Itoa(style) + `"`
+}
+
+func boolValue(s string) string {
+	if s == "true" {
+		return "1"
+	}
+	return "0"
+}
+
+// dateLayouts are the ISO forms a string may take to become a date cell
+var dateLayouts = []struct {
+	layout string
+	style  int
+}{
+	{"2006-01-02", styleDate},
+	{"2006-01-02T15:04:05", styleDateTime},
+	{"2006-01-02 15:04:05", styleDateTime},
+	{"2006-01-02T15:04:05Z07:00", styleDateTime},
+	{"15:04:05", styleTime},
+}
+
+// dateSerial gives the serial and style of a string that is an ISO date or
+// time. Excel dates have no zone, so one is dropped
+func dateSerial(v *jsonfmt.Node) (string, int, bool) {
+	for _, d := range dateLayouts {
+		t, err := time.Parse(d.layout, v.Value)
+		if err != nil {
+			continue
+		}
+		if d.style == styleTime {
+			seconds := t.Hour()*3600 + t.Minute()*60 + t.Second()
+			return strconv.FormatFloat(float64(seconds)/86400, 'g', -1, 64), d.style, true
+		}
+		local := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+		days := local.Sub(time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)).Hours() / 24
+		if days < 61 {
+			// Excel's serials before March 1900 count a leap day that never
+			// was, so such early dates stay text
+			return "", 0, false
+		}
+		return strconv.FormatFloat(math.Round(days*86400)/86400, 'g', -1, 64), d.style, true
+	}
+	return "", 0, false
+}
+
+// significantDigits counts the digits of a number literal's mantissa,
+// ignoring leading zeros
+func significantDigits(literal string) int {
+	mantissa, _, _ := strings.Cut(strings.ToLower(literal), "e")
+	digits := strings.TrimLeft(strings.NewReplacer("-", "", ".", "").Replace(mantissa), "0")
+	if strings.Contains(mantissa, ".") {
+		digits = strings.TrimRight(digits, "0")
+	}
+	return len(digits)
+}
+
+// columnName gives the letters of a 0-based column: A, B... Z, AA...
+func columnName(c int) string {
+	name := ""
+	for c++; c > 0; c = (c - 1) / 26 {
+		name = string(rune('A'+(c-1)%26)) + name
+	}
+	return name
+}
+
+// sheetNames makes names Excel accepts: at most 31 characters, none of
+// []:*?/\, not blank and not repeated regardless of case
+func sheetNames(sheets []Sheet, warnings *[]string) []string {
+	names := make([]string, len(sheets))
+	taken := map[string]bool{}
+	for i, s := range sheets {
+		name := strings.Map(func(r rune) rune {
+			if strings.ContainsRune(`[]:*?/\`, r) || r < ' ' {
+				return '_'
+			}
+			return r
+		}, s.Name)
+		name = strings.Trim(name, "'")
+		if strings.TrimSpace(name) == "" {
+			name = "Sheet" + strconv.Itoa(i+1)
+		}
+		if utf8.RuneCountInString(name) > maxSheetName {
+			name = string([]rune(name)[:maxSheetName])
+		}
+		base := []rune(name)
+		for n := 2; taken[strings.ToLower(name)]; n++ {
+			suffix := " (" + strconv.Itoa(n) + ")"
+			name = string(base[:min(len(base), maxSheetName-len(suffix))]) + suffix
+		}
+		taken[strings.ToLower(name)] = true
+		if name != s.Name && s.Name != "" {
+			*warnings = append(*warnings, fmt.Sprintf("sheet %q was renamed %q, a name Excel accepts", s.Name, name))
+		}
+		names[i] = name
+	}
+	return names
+}
+
+// escape escapes XML text, dropping control characters XML cannot hold
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '&':
+			b.WriteString("&amp;")
+		case r == '<':
+			b.WriteString("&lt;")
+		case r == '>':
+			b.WriteString("&gt;")
+		case r == '"':
+			b.WriteString("&quot;")
+		case r < ' ' && r != '\t' && r != '\n' && r != '\r', r == 0xFFFE, r == 0xFFFF:
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,186 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/Ndeta100/orbit2x/internal/jsonfmt"
+)
+
+func cells(t *testing.T, row string) []*jsonfmt.Node {
+	t.Helper()
+	n, _, err := jsonfmt.Parse([]byte(row))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n.Items
+}
+
+func rowText(row []*jsonfmt.Node) string {
+	arr := &jsonfmt.Node{Kind: jsonfmt.Array}
+	for _, v := range row {
+		if v == nil {
+			v = &jsonfmt.Node{Kind: jsonfmt.Null}
+		}
+		arr.Items = append(arr.Items, v)
+	}
+	return strings.TrimSuffix(jsonfmt.Format(arr, jsonfmt.Options{Minify: true}), "\n")
+}
+
+func zipParts(parts map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range parts {
+		f, _ := zw.Create(name)
+		f.Write([]byte(body))
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	date := &jsonfmt.Node{Kind: jsonfmt.String, Value: "2024-02-29", Tag: jsonfmt.DateTime}
+	sheets := []Sheet{
+		{Name: "People", Header: true, Rows: [][]*jsonfmt.Node{
+			cells(t, `["name","age","active","joined","zip"]`),
+			append(cells(t, `["Ada & <Co>",36,true]`), date, jsonfmt.NewString("00123")),
+			cells(t, `["Bo",1.5,false,"2024-03-01T12:30:00",null]`),
+			cells(t, `["",null,null,"09:15:00","x"]`),
+		}},
+		{Name: "Empty"},
+	}
+	data, warnings, err := Write(sheets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) > 0 {
+		t.Errorf("warnings: %v", warnings)
+	}
+
+	got, err := Read(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Name != "People" || got[1].Name != "Empty" || len(got[1].Rows) != 0 {
+		t.Fatalf("sheets = %+v", got)
+	}
+	want := []string{
+		`["name","age","active","joined","zip"]`,
+		`["Ada & <Co>",36,true,"2024-02-29","00123"]`,
+		`["Bo",1.5,false,"2024-03-01T12:30:00"]`,
+		`["",null,null,"09:15:00","x"]`,
+	}
+	for i, row := range got[0].Rows {
+		if rowText(row) != want[i] {
+			t.Errorf("row %d = %s, want %s", i+1, rowText(row), want[i])
+		}
+	}
+	if joined := got[0].Rows[1][3]; joined.Tag != jsonfmt.DateTime {
+		t.Errorf("date cell lost its tag: %+v", joined)
+	}
+}
+
+func TestWriteWarnings(t *testing.T) {
+	_, warnings, err := Write([]Sheet{
+		{Name: "a/b", Rows: [][]*jsonfmt.Node{cells(t, `[12345678901234567890, 0.1]`)}},
+		{Name: "A_B"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	joined := strings.Join(warnings, "\n")
+	for _, want := range []string{`"a/b" was renamed "a_b"`, `"A_B" was renamed "A_B (2)"`, "12345678901234567890 has more digits"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("warnings %q do not mention %q", joined, want)
+		}
+	}
+}
+
+// TestReadExcel reads parts written the way Excel writes them: shared and
+// rich strings, sparse cells, styled dates, formulas and the 1904 system
+func TestReadExcel(t *testing.T) {
+	parts := map[string]string{
+		"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+			<workbookPr date1904="1"/><sheets><sheet name="Data" sheetId="7" r:id="rId3"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+			<Relationship Id="rId3" Target="/xl/worksheets/data.xml"/></Relationships>`,
+		"xl/sharedStrings.xml": `<sst><si><t>id</t></si><si><r><t>ri</t></r><r><t>ch</t></r><rPh><t>x</t></rPh></si></sst>`,
+		"xl/styles.xml": `<styleSheet><numFmts><numFmt numFmtId="170" formatCode="&quot;Day&quot; dd/mm"/><numFmt numFmtId="171" formatCode="[Red]0.00"/></numFmts>
+			<cellXfs><xf numFmtId="0"/><xf numFmtId="170"/><xf numFmtId="171"/><xf numFmtId="14"/></cellXfs></styleSheet>`,
+		"xl/worksheets/data.xml": `<worksheet><sheetData>
+			<row r="1"><c r="A1" t="s"><v>0</v></c><c r="C1" t="s"><v>1</v></c></row>
+			<row r="3"><c r="A3"><v>1.50</v></c><c r="B3" s="1"><v>0</v></c><c r="C3" s="2"><v>2.5</v></c><c r="D3" t="str"><f>A1</f><v>id</v></c><c r="E3" t="e"><v>#DIV/0!</v></c><c r="F3" s="3"><v>1</v></c></row>
+		</sheetData></worksheet>`,
+	}
+	sheets, err := Read(zipParts(parts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sheets) != 1 || sheets[0].Name != "Data" {
+		t.Fatalf("sheets = %+v", sheets)
+	}
+	want := []string{
+		`["id",null,"rich"]`,
+		`[]`,
+		`[1.50,"1904-01-01",2.5,"id","#DIV/0!","1904-01-02"]`,
+	}
+	if len(sheets[0].Rows) != len(want) {
+		t.Fatalf("got %d rows, want %d", len(sheets[0].Rows), len(want))
+	}
+	for i, row := range sheets[0].Rows {
+		if rowText(row) != want[i] {
+			t.Errorf("row %d = %s, want %s", i+1, rowText(row), want[i])
+		}
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	if _, err := Read([]byte("name,age\n")); err == nil || !strings.Contains(err.Error(), "not an XLSX file") {
+		t.Errorf("CSV read as XLSX: %v", err)
+	}
+
+	// One far-off cell per row is tiny as XML but huge once rows are padded
+	sparse := strings.Repeat(`<row><c r="XFD1"><v>1</v></c></row>`, 5000)
+	data := zipParts(map[string]string{
+		"xl/workbook.xml":            `<workbook><sheets><sheet name="S" r:id="rId1" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships><Relationship Id="rId1" Target="worksheets/s.xml"/></Relationships>`,
+		"xl/worksheets/s.xml":        `<worksheet><sheetData>` + sparse + `</sheetData></worksheet>`,
+	})
+	if _, err := Read(data); err == nil || !strings.Contains(err.Error(), "cells") {
+		t.Errorf("sparse sheet read: %v", err)
+	}
+
+	// Sheets that each fit the budget but not together, or that all point
+	// at one part, would otherwise be unzipped and parsed over and over
+	padding := strings.Repeat(" ", maxUnzipped/2)
+	data = zipParts(map[string]string{
+		"xl/workbook.xml":            `<workbook><sheets><sheet name="A" r:id="rId1" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"/><sheet name="B" r:id="rId2" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships><Relationship Id="rId1" Target="worksheets/a.xml"/><Relationship Id="rId2" Target="worksheets/b.xml"/></Relationships>`,
+		"xl/worksheets/a.xml":        `<worksheet>` + padding + `</worksheet>`,
+		"xl/worksheets/b.xml":        `<worksheet>` + padding + `</worksheet>`,
+	})
+	if _, err := Read(data); err == nil || !strings.Contains(err.Error(), "once unzipped") {
+		t.Errorf("oversized workbook read: %v", err)
+	}
+	data = zipParts(map[string]string{
+		"xl/workbook.xml":            `<workbook><sheets><sheet name="A" r:id="rId1" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"/><sheet name="B" r:id="rId2" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships><Relationship Id="rId1" Target="worksheets/s.xml"/><Relationship Id="rId2" Target="/xl/worksheets/s.xml"/></Relationships>`,
+		"xl/worksheets/s.xml":        `<worksheet><sheetData/></worksheet>`,
+	})
+	if _, err := Read(data); err == nil || !strings.Contains(err.Error(), "share one worksheet part") {
+		t.Errorf("shared worksheet read: %v", err)
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	for c, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 701: "ZZ", 702: "AAA", maxColumns - 1: "XFD"} {
+		if got := columnName(c); got != want {
+			t.Errorf("columnName(%d) = %s, want %s", c, got, want)
+		}
+		if got, ok := columnIndex(want + "12"); !ok || got != c {
+			t.Errorf("columnIndex(%s12) = %d, want %d", want, got, c)
+		}
+	}
+}
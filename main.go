@@ -35,15 +35,51 @@ func main() {
 	router.Get("/subnet", handlers.Make(handlers.HandleSubnetIndex))
 	router.Post("/subnet/calculate-cidr", handlers.Make(handlers.HandleSubnetCalculateCIDR))
 	router.Post("/subnet/calculate-mask", handlers.Make(handlers.HandleSubnetCalculateMask))
+	router.Post("/subnet/eui64", handlers.Make(handlers.HandleSubnetEUI64))
+	router.Post("/subnet/plan", handlers.Make(handlers.HandleSubnetPlan))
+	router.Post("/subnet/plan/export", handlers.Make(handlers.HandleSubnetPlanExport))
+	router.Post("/subnet/split", handlers.Make(handlers.HandleSubnetSplit))
+	router.Post("/subnet/aggregate", handlers.Make(handlers.HandleSubnetAggregate))
+	router.Post("/subnet/compare", handlers.Make(handlers.HandleSubnetCompare))
+	router.Post("/subnet/subtract", handlers.Make(handlers.HandleSubnetSubtract))
+	router.Post("/subnet/range-to-cidr", handlers.Make(handlers.HandleSubnetRangeToCIDR))
+	router.Post("/subnet/contains", handlers.Make(handlers.HandleSubnetContains))
+	router.Post("/subnet/hosts", handlers.Make(handlers.HandleSubnetHosts))
+	router.Post("/subnet/hosts/export", handlers.Make(handlers.HandleSubnetHostsExport))
+	router.Post("/subnet/address-at", handlers.Make(handlers.HandleSubnetAddressAt))
+	router.Post("/subnet/next", handlers.Make(handlers.HandleSubnetNextIP))
 	router.Get("/encoder", handlers.Make(handlers.HandleEncoderIndex))
 	router.Post("/encoder/encode", handlers.Make(handlers.HandleEncoderEncode))
 	router.Post("/encoder/decode", handlers.Make(handlers.HandleEncoderDecode))
+	router.Post("/encoder/download", handlers.Make(handlers.HandleEncoderDownload))
+	router.Post("/encoder/smart", handlers.Make(handlers.HandleEncoderSmartDecode))
+	router.Post("/encoder/pipeline", handlers.Make(handlers.HandleEncoderPipeline))
+	router.Get("/jwt", handlers.Make(handlers.HandleJWTIndex))
+	router.Post("/jwt/decode", handlers.Make(handlers.HandleJWTDecode))
+	router.Post("/jwt/sign", handlers.Make(handlers.HandleJWTSign))
+	router.Get("/crypto", handlers.Make(handlers.HandleCryptoIndex))
+	router.Post("/crypto/encrypt", handlers.Make(handlers.HandleCryptoEncrypt))
+	router.Post("/crypto/decrypt", handlers.Make(handlers.HandleCryptoDecrypt))
+	router.Get("/password", handlers.Make(handlers.HandlePasswordIndex))
+	router.Post("/password/generate", handlers.Make(handlers.HandlePasswordGenerate))
+	router.Post("/password/passphrase", handlers.Make(handlers.HandlePassphraseGenerate))
+	router.Post("/password/strength", handlers.Make(handlers.HandlePasswordStrength))
 	router.Get("/formatter", handlers.Make(handlers.HandleFormatterIndex))
 	router.Post("/formatter/json", handlers.Make(handlers.HandleJSONFormat))
 	router.Post("/formatter/yaml", handlers.Make(handlers.HandleYAMLFormat))
+	router.Post("/formatter/validate", handlers.Make(handlers.HandleSchemaValidate))
+	router.Post("/formatter/infer", handlers.Make(handlers.HandleSchemaInfer))
+	router.Post("/formatter/query", handlers.Make(handlers.HandleJSONQuery))
+	router.Post("/formatter/diff", handlers.Make(handlers.HandleJSONDiff))
+	router.Post("/formatter/patch", handlers.Make(handlers.HandleJSONPatch))
 	router.Get("/converter", handlers.Make(handlers.HandleConverterIndex))
 	router.Post("/converter/csv-to-json", handlers.Make(handlers.HandleCSVToJSON))
 	router.Post("/converter/json-to-csv", handlers.Make(handlers.HandleJSONToCSV))
+	router.Post("/converter/convert", handlers.Make(handlers.HandleFormatConvert))
+	router.Post("/converter/xlsx-import", handlers.Make(handlers.HandleXLSXImport))
+	router.Post("/converter/xlsx-export", handlers.Make(handlers.HandleXLSXExport))
+	router.Post("/converter/stream/csv-to-json", handlers.Make(handlers.HandleStreamCSVToJSON))
+	router.Post("/converter/stream/json-to-csv", handlers.Make(handlers.HandleStreamJSONToCSV))
 	//User agent
 	router.Get("/useragent", handlers.Make(handlers.HandleUserAgentIndex))
 	router.Post("/useragent/parse", handlers.Make(handlers.HandleUserAgentParse))
@@ -51,8 +87,14 @@ func main() {
 	router.Get("/imagebase64", handlers.Make(handlers.HandleImageBase64Index))
 	router.Post("/imagebase64/convert", handlers.Make(handlers.HandleImageBase64Convert))
 	router.Get("/hash", handlers.Make(handlers.HandleHashIndex))
+	router.Get("/hash/algorithms", handlers.Make(handlers.HandleHashAlgorithms))
 	router.Post("/hash/generate", handlers.Make(handlers.HandleGenerateHash))
 	router.Post("/hash/file", handlers.Make(handlers.HandleFileHash))
+	router.Post("/hash/verify", handlers.Make(handlers.HandleVerifyHash))
+	router.Post("/hash/hmac", handlers.Make(handlers.HandleGenerateHMAC))
+	router.Post("/hash/hmac/verify", handlers.Make(handlers.HandleVerifyHMAC))
+	router.Post("/hash/password", handlers.Make(handlers.HandlePasswordHash))
+	router.Post("/hash/password/verify", handlers.Make(handlers.HandlePasswordVerify))
 	router.Get("/color", handlers.Make(handlers.HandleColorIndex))
 	router.Post("/color/convert", handlers.Make(handlers.HandleColorConvert))
 	router.Get("/color/random", handlers.Make(handlers.HandleRandomColor))
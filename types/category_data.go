@@ -56,12 +56,19 @@ func GetDeveloperToolsCategory() CategoryData {
 				Icon:        "M7 8h10M7 12h4m1 8l-4-4H5a2 2 0 01-2-2V6a2 2 0 012-2h14a2 2 0 012 2v8a2 2 0 01-2 2h-3l-4 4z",
 				Tags:        []string{"Encode", "Base64", "URL"},
 			},
+			{
+				Name:        "JWT Decoder",
+				Description: "Decode, verify and sign JSON Web Tokens with HMAC, RSA, ECDSA and EdDSA keys",
+				URL:         "/jwt",
+				Icon:        "M15 7a2 2 0 012 2m4 0a6 6 0 01-7.743 5.743L11 17H9v2H7v2H4a1 1 0 01-1-1v-2.586a1 1 0 01.293-.707l5.964-5.964A6 6 0 1121 9z",
+				Tags:        []string{"JWT", "JWKS", "Token"},
+			},
 			{
 				Name:        "File Converter",
-				Description: "Convert between different file formats including CSV to JSON and vice versa",
+				Description: "Convert between JSON, YAML, TOML, XML, CSV, Excel, NDJSON, INI, .env and HCL",
 				URL:         "/converter",
 				Icon:        "M8 7H5a2 2 0 00-2 2v6a2 2 0 002 2h2m2 4h6a2 2 0 002-2V9a2 2 0 00-2-2h-6a2 2 0 00-2 2v10a2 2 0 002 2zm8-12V7a2 2 0 00-2-2h-2a2 2 0 00-2 2v8a2 2 0 002 2h2a2 2 0 002-2z",
-				Tags:        []string{"Convert", "CSV", "JSON"},
+				Tags:        []string{"Convert", "CSV", "JSON", "YAML", "TOML", "XML", "Excel"},
 			},
 			// Add more tools as you build them
 		},
@@ -122,7 +129,7 @@ func GetSecurityToolsCategory() CategoryData {
 		Name:        "Cybersecurity Tools",
 		Description: "Vulnerability scanning, password testing, encryption and security analysis tools",
 		Icon:        "M12 15v2m-6 4h12a2 2 0 002-2v-6a2 2 0 00-2-2H6a2 2 0 00-2 2v6a2 2 0 002 2zm10-10V7a4 4 0 00-8 0v4h8z",
-		ToolCount:   "2 tools available",
+		ToolCount:   "4 tools available",
 		SearchHint:  "security tools",
 		Color:       "red",
 		Tools: []CategoryTool{
@@ -141,6 +148,20 @@ func GetSecurityToolsCategory() CategoryData {
 				Icon:        "M12 15v2m-6 4h12a2 2 0 002-2v-6a2 2 0 00-2-2H6a2 2 0 00-2 2v6a2 2 0 002 2zm10-10V7a4 4 0 00-8 0v4h8z",
 				Tags:        []string{"Hash", "MD5", "SHA256"},
 			},
+			{
+				Name:        "Encrypt / Decrypt",
+				Description: "Encrypt text and files with AES-256-GCM or XChaCha20-Poly1305 using an Argon2id passphrase or raw key",
+				URL:         "/crypto",
+				Icon:        "M12 15v2m-6 4h12a2 2 0 002-2v-6a2 2 0 00-2-2H6a2 2 0 00-2 2v6a2 2 0 002 2zm10-10V7a4 4 0 00-8 0v4h8z",
+				Tags:        []string{"AES", "ChaCha20", "Encryption"},
+			},
+			{
+				Name:        "Password Generator",
+				Description: "Generate random passwords and diceware passphrases and check password strength",
+				URL:         "/password",
+				Icon:        "M15 7a2 2 0 012 2m4 0a6 6 0 01-7.743 5.743L11 17H9v2H7v2H4a1 1 0 01-1-1v-2.586a1 1 0 01.293-.707l5.964-5.964A6 6 0 1121 9z",
+				Tags:        []string{"Password", "Passphrase", "Strength"},
+			},
 		},
 	}
 }